@@ -0,0 +1,11 @@
+// Package beacon embeds the panel's HTML templates and static assets so the
+// backend binary can be started from any working directory.
+package beacon
+
+import "embed"
+
+//go:embed templates/*.html
+var Templates embed.FS
+
+//go:embed static
+var Static embed.FS
@@ -1,65 +1,324 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
+	beacon "github.com/adammcgrogan/beacon"
+	"github.com/adammcgrogan/beacon/internal/audit"
+	"github.com/adammcgrogan/beacon/internal/certs"
+	"github.com/adammcgrogan/beacon/internal/config"
+	"github.com/adammcgrogan/beacon/internal/diagnostics"
+	"github.com/adammcgrogan/beacon/internal/gamerules"
 	"github.com/adammcgrogan/beacon/internal/handlers"
+	"github.com/adammcgrogan/beacon/internal/handoff"
+	"github.com/adammcgrogan/beacon/internal/history"
+	"github.com/adammcgrogan/beacon/internal/hotspots"
+	"github.com/adammcgrogan/beacon/internal/moderation"
+	"github.com/adammcgrogan/beacon/internal/players"
 	"github.com/adammcgrogan/beacon/internal/store"
+	"github.com/adammcgrogan/beacon/internal/supervisor"
+	"github.com/gorilla/websocket"
 )
 
 func main() {
+	cfg, opts, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	if opts.PrintConfig {
+		out, err := cfg.YAML()
+		if err != nil {
+			log.Fatalf("could not encode configuration: %v", err)
+		}
+		fmt.Print(string(out))
+		return
+	}
+
+	templatesFS, staticFS, err := assetFilesystems(cfg.Assets)
+	if err != nil {
+		log.Fatalf("could not load assets: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// 1. Initialize centralized state
-	serverStore := store.New()
-	authManager := handlers.NewAuthManager()
+	serverStore := store.New(cfg.Console)
+	authManager := handlers.NewAuthManager(cfg)
 	authManager.LoadPersistedState()
-	authManager.StartJanitor()
+	janitorDone := authManager.StartJanitor(ctx)
+	playerHistory := history.NewTracker(cfg.Data.Dir)
+	playerHistory.Load()
+	historyDone := playerHistory.Start(ctx)
+	playerDirectory := players.NewDirectory(cfg.Data.Dir)
+	playerDirectory.Load()
+	directoryDone := playerDirectory.Start(ctx)
+	moderationRegistry := moderation.NewRegistry(cfg.Data.Dir)
+	moderationRegistry.Load()
+	auditLog := audit.NewLog(cfg.Data.Dir)
+	auditLog.Load()
+	gamerulePresets := gamerules.NewPresets(cfg.Data.Dir)
+	gamerulePresets.Load()
+	hotspotHistory := hotspots.NewHistory(cfg.Data.Dir, cfg.Hotspots.Keep)
+	hotspotHistory.Load()
+	crashReports := diagnostics.NewReports(cfg.Data.Dir, cfg.Diagnostics.KeepReports)
+	crashReports.Load()
+	dumps := diagnostics.NewDumps(cfg.Data.Dir, cfg.Diagnostics.KeepDumps)
+	dumps.Load()
+	profiles := diagnostics.NewProfiles(cfg.Data.Dir, cfg.Diagnostics.KeepProfiles)
+	profiles.Load()
 
 	// 2. Initialize our WebSocket manager with access to the store
 	ws := &handlers.WebSocketManager{
-		Store: serverStore,
-		Auth:  authManager,
+		Store:             serverStore,
+		Auth:              authManager,
+		History:           playerHistory,
+		Players:           playerDirectory,
+		Moderation:        moderationRegistry,
+		Audit:             auditLog,
+		GamerulePresets:   gamerulePresets,
+		Hotspots:          hotspotHistory,
+		HotspotsConfig:    cfg.Hotspots,
+		CrashReports:      crashReports,
+		Dumps:             dumps,
+		Profiles:          profiles,
+		DiagnosticsConfig: cfg.Diagnostics,
+		Commands:          handlers.NewCommandPolicy(cfg.Commands),
+		RateLimits:        handlers.NewRateLimiter(cfg.RateLimit),
+		RequestTimeout:    cfg.Plugin.RequestTimeout,
+		MaxInFlight:       cfg.Plugin.MaxInFlight,
+		Compression:       cfg.Server.Compression,
+		BinaryFrames:      cfg.Plugin.BinaryFrames,
+		Web:               cfg.Web,
+		PluginSecret:      cfg.Plugin.Secret,
 	}
 
 	// 3. Initialize our UI handlers with access to the store and WebSocket manager
-	ui := handlers.NewUIHandler(serverStore, ws, authManager)
-
-	// Static Files (Adjust path based on where you run the binary from)
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("../../static"))))
-
-	// 4. Mount Page Routes
-	http.HandleFunc("/auth", ui.HandleAuthPage)
-	http.HandleFunc("/", ui.RequirePageAuth(ui.HandleDashboard))
-	http.HandleFunc("/console", ui.RequirePageAuth(ui.HandleConsole))
-	http.HandleFunc("/players", ui.RequirePageAuth(ui.HandlePlayers))
-	http.HandleFunc("/worlds", ui.RequirePageAuth(ui.HandleWorlds))
-	http.HandleFunc("/files", ui.RequirePageAuth(ui.HandleFiles))
-	http.HandleFunc("/files/", ui.RequirePageAuth(ui.HandleFiles))
-	http.HandleFunc("/access", ui.RequirePageAuth(ui.HandleAccess))
-
-	// File manager API routes
-	http.HandleFunc("/api/auth/magic-link", ui.HandleMagicLinkAuth)
-	http.HandleFunc("/api/auth/logout", ui.RequireAPIAuth(ui.HandleLogout))
-	http.HandleFunc("/api/session", ui.RequireAPIAuth(ui.HandleSession))
-	http.HandleFunc("/api/files/meta", ui.RequireAPIAuth(ui.HandleFilesMeta))
-	http.HandleFunc("/api/files/list", ui.RequireAPIAuth(ui.HandleFilesList))
-	http.HandleFunc("/api/files/content", ui.RequireAPIAuth(ui.HandleFilesContent))
-	http.HandleFunc("/api/files", ui.RequireAPIAuth(ui.HandleFilesDelete))
-	http.HandleFunc("/api/files/download", ui.RequireAPIAuth(ui.HandleFilesDownload))
-
-	http.HandleFunc("/api/files/dir", ui.RequireAPIAuth(ui.HandleFilesCreateDir))
-	http.HandleFunc("/api/files/upload", ui.RequireAPIAuth(ui.HandleFilesUpload))
-
-	http.HandleFunc("/api/access/data", ui.RequireAPIAuth(ui.HandleAccessData))
-	http.HandleFunc("/api/access/sessions", ui.RequireAPIAuth(ui.HandleAccessSessionDelete))
-	http.HandleFunc("/api/access/permissions", ui.RequireAPIAuth(ui.HandleAccessPermissionUpdate))
-	http.HandleFunc("/api/gamerules/defaults", ui.HandleGameruleDefaults)
-
-	// 5. Mount WebSocket Routes
-	http.HandleFunc("/ws", ws.HandleMinecraft)
-	http.HandleFunc("/ws/web", ws.HandleWeb)
-
-	fmt.Println("🚀 Beacon Backend running on http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	ui := handlers.NewUIHandler(serverStore, ws, authManager, templatesFS)
+	moderationDone := ws.RunModerationSchedule(ctx)
+	rateLimitDone := ws.RateLimits.Start(ctx)
+	hotspotsDone := ws.RunHotspotSchedule(ctx)
+	crashScanDone := ws.RunCrashReportScan(ctx)
+
+	// The backend can run the Minecraft server itself, so the panel can start
+	// it while the plugin is offline.
+	var process *supervisor.Supervisor
+	if cfg.Supervisor.Enabled() {
+		process = supervisor.New(supervisor.Config{
+			Command:       cfg.Supervisor.Command,
+			Dir:           cfg.Supervisor.Dir,
+			Env:           cfg.Supervisor.Env,
+			StopCommand:   cfg.Supervisor.StopCommand,
+			StopTimeout:   cfg.Supervisor.StopTimeout,
+			Restart:       cfg.Supervisor.Restart,
+			RestartDelay:  cfg.Supervisor.RestartDelay,
+			MaxRestarts:   cfg.Supervisor.MaxRestarts,
+			RestartWindow: cfg.Supervisor.RestartWindow,
+		})
+		ws.AttachProcess(process)
+		if cfg.Supervisor.AutoStart {
+			if err := process.Start(); err != nil {
+				log.Printf("beacon supervisor: %v", err)
+			}
+		}
+	}
+
+	// 4. Mount every route behind the auth its declaration asks for, and
+	// refuse to start if any declaration is missing or inconsistent.
+	// Static files are embedded unless assets.static_dir points elsewhere.
+	routes := ui.Routes(http.StripPrefix("/static/", http.FileServer(http.FS(staticFS))))
+	if err := ui.Mount(http.DefaultServeMux, routes); err != nil {
+		log.Fatalf("invalid route table:\n%v", err)
+	}
+
+	server := &http.Server{
+		Addr:    cfg.Server.Listen,
+		Handler: http.DefaultServeMux,
+	}
+
+	// 5. Configure HTTPS (HTTP/2 is negotiated automatically via ALPN)
+	scheme := "http"
+	var reloader *certs.Reloader
+	if cfg.TLSEnabled() {
+		tlsCfg := cfg.Server.TLS
+		if tlsCfg.SelfSigned {
+			generated, err := certs.EnsureSelfSigned(tlsCfg.Cert, tlsCfg.Key, tlsCfg.Hosts)
+			if err != nil {
+				log.Fatalf("could not generate self-signed certificate: %v", err)
+			}
+			if generated {
+				fmt.Printf("🔐 Generated self-signed certificate at %s\n", tlsCfg.Cert)
+			}
+		}
+
+		reloader, err = certs.NewReloader(tlsCfg.Cert, tlsCfg.Key)
+		if err != nil {
+			log.Fatalf("could not load TLS certificate: %v", err)
+		}
+		go reloader.Watch(ctx, tlsCfg.ReloadInterval)
+
+		scheme = "https"
+		server.Handler = handlers.StrictTransportSecurity(http.DefaultServeMux)
+		server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+	}
+
+	// 6. Listen, reusing a socket handed over by a previous process if there is one
+	listener, inherited, err := handoff.Listen(cfg.Server.Listen)
+	if err != nil {
+		log.Fatalf("could not listen on %s: %v", cfg.Server.Listen, err)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if reloader != nil {
+			serveErr <- server.ServeTLS(listener, "", "")
+		} else {
+			serveErr <- server.Serve(listener)
+		}
+	}()
+
+	if inherited {
+		fmt.Printf("🔁 Took over listening socket from previous backend process\n")
+	}
+	fmt.Printf("🚀 Beacon Backend running on %s://%s\n", scheme, displayAddr(cfg.Server.Listen))
+	if reloader != nil {
+		fmt.Printf("🔐 Certificate SHA-256 fingerprint: %s\n", reloader.Fingerprint())
+	}
+	handoff.NotifyReady()
+
+	// Reverse-connect mode: dial the plugin's listener instead of waiting on /ws
+	if cfg.Plugin.ConnectURL != "" {
+		fmt.Printf("🔌 Reverse-connect mode: dialing plugin at %s\n", cfg.Plugin.ConnectURL)
+		go ws.DialPlugin(ctx, cfg.Plugin.ConnectURL, cfg.Plugin.ReconnectMin, cfg.Plugin.ReconnectMax)
+	}
+	if cfg.Plugin.Secret == "" {
+		fmt.Println("⚠️  plugin.secret is not set; any client that can reach /ws may act as the Minecraft server")
+	}
+
+	// 7. Wait for a stop signal, or SIGHUP to restart in place
+	restartSignal := make(chan os.Signal, 1)
+	if cfg.Server.Handoff {
+		signal.Notify(restartSignal, syscall.SIGHUP)
+	}
+
+	closeCode := websocket.CloseGoingAway
+	closeReason := "backend shutting down"
+wait:
+	for {
+		select {
+		case err := <-serveErr:
+			if !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("server stopped: %v", err)
+			}
+			break wait
+		case <-ctx.Done():
+			fmt.Println("🛑 Shutting down...")
+			break wait
+		case <-restartSignal:
+			if _, err := handoff.Restart(listener, cfg.Server.ShutdownTimeout); err != nil {
+				log.Printf("restart failed, continuing to serve: %v", err)
+				continue
+			}
+			fmt.Println("🔁 New backend process is serving; draining this one...")
+			// The plugin socket cannot be passed on; this close code
+			// tells the plugin to reconnect to the new process.
+			closeCode = websocket.CloseServiceRestart
+			closeReason = "backend restarting"
+			break wait
+		}
+	}
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("http drain incomplete: %v", err)
+	}
+
+	// The supervised server is a child of this process, so it stops with it.
+	// Config validation refuses handoff restarts while one is configured.
+	if process != nil {
+		stopCtx, cancelStop := context.WithTimeout(context.Background(), cfg.Supervisor.StopTimeout)
+		process.Shutdown(stopCtx)
+		cancelStop()
+	}
+
+	// Flush sessions before the plugin is told to reconnect, so a new process
+	// picks up the latest state when the plugin reports its data directory.
+	<-janitorDone
+	authManager.FlushState()
+	ws.Shutdown(closeCode, closeReason)
+	<-historyDone
+	playerHistory.Flush()
+	<-directoryDone
+	playerDirectory.Flush()
+	<-moderationDone
+	<-rateLimitDone
+	<-hotspotsDone
+	<-crashScanDone
+	fmt.Println("👋 Beacon Backend stopped.")
+}
+
+func assetFilesystems(cfg config.AssetsConfig) (fs.FS, fs.FS, error) {
+	templatesFS, err := fs.Sub(beacon.Templates, "templates")
+	if err != nil {
+		return nil, nil, err
+	}
+	staticFS, err := fs.Sub(beacon.Static, "static")
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.TemplatesDir != "" {
+		if templatesFS, err = assetDir(cfg.TemplatesDir); err != nil {
+			return nil, nil, err
+		}
+	}
+	if cfg.StaticDir != "" {
+		if staticFS, err = assetDir(cfg.StaticDir); err != nil {
+			return nil, nil, err
+		}
+	}
+	return templatesFS, staticFS, nil
+}
+
+// assetDir opens an asset override by its absolute path, so what is served
+// does not change with the working directory the backend was started from.
+func assetDir(dir string) (fs.FS, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", abs)
+	}
+	return os.DirFS(abs), nil
+}
+
+func displayAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
 }
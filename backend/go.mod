@@ -2,4 +2,10 @@ module github.com/adammcgrogan/beacon
 
 go 1.25.1
 
-require github.com/gorilla/websocket v1.5.3
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
@@ -0,0 +1,124 @@
+// Package audit keeps a record of administrative changes made through the
+// panel: who did what, to whom, and when.
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/datafile"
+)
+
+// maxEntries bounds the log; the oldest entries are dropped first.
+const maxEntries = 5000
+
+// Actor is the panel user who made a change.
+type Actor struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+// Entry is one recorded change. Action is a dotted name such as
+// "whitelist.add"; Target names what was changed.
+type Entry struct {
+	At     time.Time `json:"at"`
+	Actor  Actor     `json:"actor"`
+	Action string    `json:"action"`
+	Target string    `json:"target,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+	OK     bool      `json:"ok"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Log is an append-only audit trail, saved on every record or batch. It is safe for
+// concurrent use.
+type Log struct {
+	path string
+
+	mu      sync.Mutex
+	entries []Entry
+
+	persistMu sync.Mutex
+}
+
+// NewLog keeps its entries in audit.json inside dataDir.
+func NewLog(dataDir string) *Log {
+	return &Log{path: filepath.Join(dataDir, "audit.json")}
+}
+
+// Load reads previously saved entries.
+func (l *Log) Load() {
+	var entries []Entry
+	found, err := datafile.Read(l.path, &entries)
+	if err != nil {
+		log.Printf("beacon audit: failed loading %s: %v", l.path, err)
+		return
+	}
+	if !found {
+		return
+	}
+	l.mu.Lock()
+	l.entries = entries
+	l.mu.Unlock()
+}
+
+// Record appends an entry, stamping it with the current time if At is zero.
+func (l *Log) Record(entry Entry) {
+	l.RecordBatch([]Entry{entry})
+}
+
+// RecordBatch appends entries in order with a single save, e.g. one per
+// player of a whitelist import. Entries with a zero At get the current time.
+func (l *Log) RecordBatch(entries []Entry) {
+	if len(entries) == 0 {
+		return
+	}
+	now := time.Now()
+	l.mu.Lock()
+	for _, entry := range entries {
+		if entry.At.IsZero() {
+			entry.At = now
+		}
+		l.entries = append(l.entries, entry)
+	}
+	if over := len(l.entries) - maxEntries; over > 0 {
+		l.entries = append([]Entry(nil), l.entries[over:]...)
+	}
+	l.mu.Unlock()
+	l.save()
+}
+
+// Recent lists up to limit entries, newest first. A non-empty prefix keeps
+// only actions starting with it, e.g. "whitelist.".
+func (l *Log) Recent(prefix string, limit int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]Entry, 0, min(limit, len(l.entries)))
+	for i := len(l.entries) - 1; i >= 0 && len(out) < limit; i-- {
+		if prefix != "" && !strings.HasPrefix(l.entries[i].Action, prefix) {
+			continue
+		}
+		out = append(out, l.entries[i])
+	}
+	return out
+}
+
+func (l *Log) save() {
+	l.persistMu.Lock()
+	defer l.persistMu.Unlock()
+
+	l.mu.Lock()
+	data, err := json.MarshalIndent(l.entries, "", "  ")
+	l.mu.Unlock()
+	if err != nil {
+		log.Printf("beacon audit: failed encoding entries: %v", err)
+		return
+	}
+	if err := datafile.Write(l.path, data); err != nil {
+		log.Printf("beacon audit: failed writing %s: %v", l.path, err)
+	}
+}
@@ -0,0 +1,212 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reloader serves a TLS certificate from disk and swaps it in place when the
+// certificate or key file changes, so renewals don't require a restart.
+type Reloader struct {
+	certPath string
+	keyPath  string
+
+	mu          sync.RWMutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+func NewReloader(certPath, keyPath string) (*Reloader, error) {
+	r := &Reloader{certPath: certPath, keyPath: keyPath}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is intended for tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the leaf certificate currently being served.
+func (r *Reloader) Fingerprint() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cert == nil || len(r.cert.Certificate) == 0 {
+		return ""
+	}
+	return Fingerprint(r.cert.Certificate[0])
+}
+
+// Watch polls the certificate and key files until ctx is cancelled and reloads
+// them whenever either modification time changes.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		certInfo, certErr := os.Stat(r.certPath)
+		keyInfo, keyErr := os.Stat(r.keyPath)
+		if certErr != nil || keyErr != nil {
+			continue
+		}
+
+		r.mu.RLock()
+		changed := !certInfo.ModTime().Equal(r.certModTime) || !keyInfo.ModTime().Equal(r.keyModTime)
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		if err := r.reload(); err != nil {
+			log.Printf("beacon tls: keeping previous certificate, reload failed: %v", err)
+			continue
+		}
+		log.Printf("beacon tls: reloaded certificate %s (sha256 %s)", r.certPath, r.Fingerprint())
+	}
+}
+
+func (r *Reloader) reload() error {
+	certInfo, err := os.Stat(r.certPath)
+	if err != nil {
+		return err
+	}
+	keyInfo, err := os.Stat(r.keyPath)
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+	r.mu.Unlock()
+	return nil
+}
+
+// EnsureSelfSigned writes a self-signed certificate and key to the given paths
+// unless both already exist. It reports whether new files were generated.
+func EnsureSelfSigned(certPath, keyPath string, hosts []string) (bool, error) {
+	_, certErr := os.Stat(certPath)
+	_, keyErr := os.Stat(keyPath)
+	if certErr == nil && keyErr == nil {
+		return false, nil
+	}
+	if (certErr != nil && !errors.Is(certErr, os.ErrNotExist)) || (keyErr != nil && !errors.Is(keyErr, os.ErrNotExist)) {
+		return false, fmt.Errorf("inspect certificate files: %w", errors.Join(certErr, keyErr))
+	}
+
+	certPEM, keyPEM, err := generateSelfSigned(hosts)
+	if err != nil {
+		return false, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certPath), 0o755); err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0o700); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(certPath, certPEM, 0o644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func generateSelfSigned(hosts []string) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Beacon"}, CommonName: "Beacon self-signed"},
+		NotBefore:             now.Add(-1 * time.Hour),
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1", "::1"}
+	}
+	for _, host := range hosts {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// Fingerprint formats the SHA-256 digest of a DER-encoded certificate as
+// colon-separated hex, the form the plugin accepts for pinning.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	encoded := strings.ToUpper(hex.EncodeToString(sum[:]))
+	parts := make([]string, 0, len(sum))
+	for i := 0; i < len(encoded); i += 2 {
+		parts = append(parts, encoded[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,311 @@
+package commandpolicy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxDepth bounds how many execute/return wrappers are unwrapped.
+const maxDepth = 16
+
+// aliases maps vanilla command aliases to the name rules are written against.
+var aliases = map[string]string{
+	"tp":   "teleport",
+	"tell": "msg",
+	"w":    "msg",
+	"xp":   "experience",
+}
+
+// textArgs maps commands whose last argument is free text to how many
+// arguments come before it. The text runs to the end of the line and is
+// taken as it is, so an apostrophe or a stray bracket in a message is not
+// read as an open quote.
+var textArgs = map[string]int{
+	"say":     0,
+	"me":      0,
+	"teammsg": 0,
+	"msg":     1,
+	"kick":    1,
+	"ban":     1,
+	"ban-ip":  1,
+}
+
+// Invocation is one command found in a console line: the line itself, or a
+// command an execute chain runs.
+type Invocation struct {
+	// Command is the lower-case name with any namespace and alias removed.
+	Command string `json:"command"`
+	// Namespace is the prefix the command was typed with, e.g. "minecraft".
+	Namespace string `json:"namespace,omitempty"`
+	// Args is the rest of the line with whitespace collapsed, except for the
+	// free text of commands such as say, which is kept as typed.
+	Args string `json:"args"`
+}
+
+// ErrUnresolved is returned for a line Normalize cannot take apart with
+// certainty, e.g. an unknown execute subcommand or an unclosed quote. Such a
+// line must be refused, as it is unclear which commands it would run.
+var ErrUnresolved = errors.New("cannot tell which commands this line runs")
+
+// Normalize splits a console line into the commands it would run, outermost
+// first. "/minecraft:execute as @a run tp ~ ~1 ~" yields execute, then
+// teleport. An empty line yields nothing. execute chains are parsed
+// subcommand by subcommand, so a player or tag named "run" is not mistaken
+// for the start of the command the chain runs.
+func Normalize(line string) ([]Invocation, error) {
+	line = strings.TrimPrefix(strings.TrimSpace(line), "/")
+	tokens, open := tokenize(line)
+	var out []Invocation
+	for depth := 0; len(tokens) > 0; depth++ {
+		if depth == maxDepth {
+			return out, fmt.Errorf("%w: more than %d nested commands", ErrUnresolved, maxDepth)
+		}
+		inv, text := invocation(line, tokens)
+		out = append(out, inv)
+		if text >= 0 {
+			// Everything from text on is a message, so a quote or bracket
+			// left open there does not matter.
+			if open >= text {
+				open = -1
+			}
+			break
+		}
+
+		rest := tokens[1:]
+		next := -1
+		switch inv.Command {
+		case "execute":
+			var err error
+			if next, err = executeRun(words(rest)); err != nil {
+				return out, err
+			}
+		case "return":
+			if len(rest) > 0 && strings.EqualFold(rest[0].text, "run") {
+				next = 1
+			}
+		}
+		if next < 0 {
+			break
+		}
+		if next >= len(rest) {
+			return out, fmt.Errorf("%w: %s run without a command", ErrUnresolved, inv.Command)
+		}
+		tokens = rest[next:]
+	}
+	if open >= 0 {
+		return out, fmt.Errorf("%w: unclosed quote or bracket", ErrUnresolved)
+	}
+	return out, nil
+}
+
+// executeRun walks the subcommands of an execute chain and returns where in
+// args the command after "run" starts, or -1 if the chain runs nothing.
+func executeRun(args []string) (int, error) {
+	for i := 0; i < len(args); {
+		sub := strings.ToLower(args[i])
+		if sub == "run" {
+			return i + 1, nil
+		}
+		n, err := executeArgs(sub, args[i+1:])
+		if err != nil {
+			return 0, err
+		}
+		i += 1 + n
+	}
+	return -1, nil
+}
+
+// executeArgs returns how many arguments the execute subcommand sub takes,
+// given the tokens that follow it. Positions are three tokens.
+func executeArgs(sub string, args []string) (int, error) {
+	arg := func(i int) string {
+		if i < len(args) {
+			return strings.ToLower(args[i])
+		}
+		return ""
+	}
+	var n int
+	switch sub {
+	case "align", "anchored", "as", "at", "in", "on", "summon":
+		n = 1
+	case "facing":
+		// facing <x y z> or facing entity <targets> <anchor>.
+		n = 3
+	case "positioned":
+		if arg(0) == "as" || arg(0) == "over" {
+			n = 2
+		} else {
+			n = 3
+		}
+	case "rotated":
+		// rotated <yaw pitch> or rotated as <targets>.
+		n = 2
+	case "if", "unless":
+		n = conditionArgs(arg)
+	case "store":
+		if arg(0) == "result" || arg(0) == "success" {
+			n = storeArgs(arg(1))
+		}
+	default:
+		return 0, fmt.Errorf("%w: unknown execute subcommand %q", ErrUnresolved, sub)
+	}
+	if n == 0 || n > len(args) {
+		return 0, fmt.Errorf("%w: incomplete execute %s", ErrUnresolved, sub)
+	}
+	return n, nil
+}
+
+// conditionArgs counts the arguments of execute if/unless, starting with
+// the condition kind; 0 means the condition is not known.
+func conditionArgs(arg func(int) string) int {
+	switch arg(0) {
+	case "entity", "predicate", "dimension", "function":
+		return 2
+	case "loaded":
+		return 4
+	case "block", "biome":
+		return 5
+	case "blocks":
+		return 11
+	case "data":
+		switch arg(1) {
+		case "block":
+			return 6
+		case "entity", "storage":
+			return 4
+		}
+	case "score":
+		// score <target> <objective> matches <range>, or
+		// score <target> <objective> <operator> <source> <objective>.
+		if arg(3) == "matches" {
+			return 5
+		}
+		return 6
+	case "items":
+		switch arg(1) {
+		case "block":
+			return 7
+		case "entity":
+			return 5
+		}
+	}
+	return 0
+}
+
+// storeArgs counts the arguments of execute store result|success, starting
+// with result or success; 0 means the target is not known.
+func storeArgs(target string) int {
+	switch target {
+	case "bossbar", "score":
+		return 4
+	case "entity", "storage":
+		return 6
+	case "block":
+		return 8
+	}
+	return 0
+}
+
+// invocation names the command tokens start with. For commands that end in
+// free text it also returns where in line the text starts, or -1.
+func invocation(line string, tokens []token) (Invocation, int) {
+	name := strings.ToLower(strings.TrimPrefix(tokens[0].text, "/"))
+	var namespace string
+	if i := strings.LastIndexByte(name, ':'); i >= 0 {
+		namespace, name = name[:i], name[i+1:]
+	}
+	if canonical, ok := aliases[name]; ok {
+		name = canonical
+	}
+	inv := Invocation{Command: name, Namespace: namespace}
+	args := tokens[1:]
+	if n, ok := textArgs[name]; ok && len(args) > n {
+		text := args[n].start
+		inv.Args = strings.TrimSpace(strings.Join(append(words(args[:n]), line[text:]), " "))
+		return inv, text
+	}
+	inv.Args = strings.Join(words(args), " ")
+	return inv, -1
+}
+
+// token is one word of a console line and where it starts.
+type token struct {
+	text  string
+	start int
+}
+
+func words(tokens []token) []string {
+	out := make([]string, len(tokens))
+	for i, t := range tokens {
+		out[i] = t.text
+	}
+	return out
+}
+
+// tokenize splits on whitespace outside quotes and brackets, so selectors
+// like @a[name=x, limit=1] and NBT stay in one token. A quote only opens at
+// the start of a token or inside brackets, and a bracket only at the start
+// of a token, inside brackets or right after a name such as @a or stone, so
+// "don't" and ":)[" are plain words. It returns where the outermost quote or
+// bracket left open starts, or -1.
+func tokenize(line string) ([]token, int) {
+	var (
+		tokens  []token
+		current strings.Builder
+		start   int
+		depth   int
+		quote   rune
+		escaped bool
+		open    = -1
+		prev    rune
+	)
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, token{text: current.String(), start: start})
+			current.Reset()
+		}
+	}
+	for i, c := range line {
+		if current.Len() == 0 {
+			start = i
+		}
+		atStart := current.Len() == 0
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if c == '\\' {
+				escaped = true
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (atStart || depth > 0):
+			quote = c
+		case (c == '[' || c == '{') && (atStart || depth > 0 || isNameRune(prev)):
+			depth++
+		case (c == ']' || c == '}') && depth > 0:
+			depth--
+		case depth == 0 && (c == ' ' || c == '\t' || c == '\n' || c == '\r'):
+			flush()
+			prev = c
+			continue
+		}
+		if open < 0 && (quote != 0 || depth > 0) {
+			open = i
+		} else if quote == 0 && depth == 0 {
+			open = -1
+		}
+		current.WriteRune(c)
+		prev = c
+	}
+	flush()
+	return tokens, open
+}
+
+// isNameRune reports whether c can end a selector or resource name, which a
+// bracket may follow directly, as in @a[...] or stone{...}.
+func isNameRune(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '-' || c == '.' || c == ':' || c == '/' || c == '@'
+}
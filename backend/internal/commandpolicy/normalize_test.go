@@ -0,0 +1,167 @@
+package commandpolicy
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		commands []string
+		args     []string
+	}{
+		{name: "empty", line: "   "},
+		{name: "plain", line: "give Steve  stone", commands: []string{"give"}, args: []string{"Steve stone"}},
+		{name: "leading slash", line: "/stop", commands: []string{"stop"}, args: []string{""}},
+		{name: "namespaced", line: "minecraft:STOP", commands: []string{"stop"}, args: []string{""}},
+		{name: "alias", line: "tp Steve 0 64 0", commands: []string{"teleport"}, args: []string{"Steve 0 64 0"}},
+		{name: "namespaced alias", line: "/minecraft:tp ~ ~1 ~", commands: []string{"teleport"}, args: []string{"~ ~1 ~"}},
+		{
+			name:     "execute run",
+			line:     "execute as @a at @s run tp ~ ~1 ~",
+			commands: []string{"execute", "teleport"},
+			args:     []string{"as @a at @s run tp ~ ~1 ~", "~ ~1 ~"},
+		},
+		{
+			name:     "nested execute",
+			line:     "execute as @a run minecraft:execute positioned 0 64 0 run op Steve",
+			commands: []string{"execute", "execute", "op"},
+		},
+		{
+			name:     "return run",
+			line:     "return run ban Steve",
+			commands: []string{"return", "ban"},
+		},
+		{name: "return value", line: "return 1", commands: []string{"return"}},
+		{
+			name:     "player named run",
+			line:     "execute as run run op attacker",
+			commands: []string{"execute", "op"},
+			args:     []string{"as run run op attacker", "attacker"},
+		},
+		{
+			name:     "tag named run",
+			line:     "execute if entity @a[tag=run] run deop Steve",
+			commands: []string{"execute", "deop"},
+		},
+		{
+			name:     "bracketed selector",
+			line:     "execute as @a[name=\"x run op y\", limit=1] run say hi",
+			commands: []string{"execute", "say"},
+		},
+		{
+			name:     "quoted nbt",
+			line:     "execute store result storage beacon:x \"path run op y\" int 1 run kill @e",
+			commands: []string{"execute", "kill"},
+		},
+		{
+			name:     "score matches",
+			line:     "execute if score run obj matches 1.. run op run",
+			commands: []string{"execute", "op"},
+			args:     []string{"if score run obj matches 1.. run op run", "run"},
+		},
+		{
+			name:     "score comparison",
+			line:     "execute unless score a obj < run obj run whitelist off",
+			commands: []string{"execute", "whitelist"},
+		},
+		{
+			name:     "facing and rotated",
+			line:     "execute facing entity run eyes rotated as run positioned as run run op x",
+			commands: []string{"execute", "op"},
+		},
+		{name: "execute without run", line: "execute if entity @a", commands: []string{"execute"}},
+		{name: "apostrophe in say", line: "say don't grief", commands: []string{"say"}, args: []string{"don't grief"}},
+		{name: "apostrophe in msg", line: "msg Steve it's me", commands: []string{"msg"}, args: []string{"Steve it's me"}},
+		{name: "apostrophe in kick reason", line: "kick Steve you're banned", commands: []string{"kick"}, args: []string{"Steve you're banned"}},
+		{name: "stray bracket in say", line: "say :) [brb", commands: []string{"say"}, args: []string{":) [brb"}},
+		{name: "message kept as typed", line: "tell Steve  meet at  spawn", commands: []string{"msg"}, args: []string{"Steve meet at  spawn"}},
+		{name: "quote inside a word", line: "give Steve's_pick stone", commands: []string{"give"}, args: []string{"Steve's_pick stone"}},
+		{
+			name:     "execute runs say with an apostrophe",
+			line:     "execute as @a run say it's done",
+			commands: []string{"execute", "say"},
+			args:     []string{"as @a run say it's done", "it's done"},
+		},
+		{
+			name:     "run inside block nbt",
+			line:     "execute if block ~ ~ ~ chest{a: run say x} run op Steve",
+			commands: []string{"execute", "op"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invocations, err := Normalize(tt.line)
+			if err != nil {
+				t.Fatalf("Normalize(%q): %v", tt.line, err)
+			}
+			var commands, args []string
+			for _, inv := range invocations {
+				commands = append(commands, inv.Command)
+				args = append(args, inv.Args)
+			}
+			if !slices.Equal(commands, tt.commands) {
+				t.Errorf("commands = %q, want %q", commands, tt.commands)
+			}
+			if tt.args != nil && !slices.Equal(args, tt.args) {
+				t.Errorf("args = %q, want %q", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestNormalizeNamespace(t *testing.T) {
+	invocations, err := Normalize("/Minecraft:Ban Steve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := invocations[0]; got.Command != "ban" || got.Namespace != "minecraft" {
+		t.Errorf("got %+v, want ban in namespace minecraft", got)
+	}
+}
+
+func TestNormalizeUnresolved(t *testing.T) {
+	tests := map[string]string{
+		"unknown subcommand":            "execute sideways run op Steve",
+		"incomplete":                    "execute as",
+		"run without command":           "execute as @a run",
+		"return run nothing":            "return run",
+		"unclosed quote":                "execute as \"Steve run op x",
+		"unclosed bracket":              "execute as @a[name=x run op y",
+		"unclosed quote before message": "msg \"Steve it's me",
+		"unclosed nbt":                  "execute if block ~ ~ ~ chest{a:1 run op y",
+		"unknown condition":             "execute if sometimes run op x",
+		"unknown store":                 "execute store result somewhere x run op y",
+		"too deep":                      "execute run execute run execute run execute run execute run execute run execute run execute run execute run execute run execute run execute run execute run execute run execute run execute run op x",
+	}
+	for name, line := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Normalize(line); !errors.Is(err, ErrUnresolved) {
+				t.Errorf("Normalize(%q) error = %v, want ErrUnresolved", line, err)
+			}
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+		open int
+	}{
+		{`give @p[name='a b'] stone{display:{Name:"x y"}} 1`, []string{"give", "@p[name='a b']", `stone{display:{Name:"x y"}}`, "1"}, -1},
+		{"say don't grief", []string{"say", "don't", "grief"}, -1},
+		{"say :) [brb", []string{"say", ":)", "[brb"}, 7},
+		{`say "hi`, []string{"say", `"hi`}, 4},
+		{"execute as @a[tag=x", []string{"execute", "as", "@a[tag=x"}, 13},
+	}
+	for _, tt := range tests {
+		tokens, open := tokenize(tt.line)
+		if got := words(tokens); !slices.Equal(got, tt.want) || open != tt.open {
+			t.Errorf("tokenize(%q) = %q, %d; want %q, %d", tt.line, got, open, tt.want, tt.open)
+		}
+	}
+}
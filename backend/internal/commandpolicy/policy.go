@@ -0,0 +1,232 @@
+// Package commandpolicy decides which console commands a panel user may run.
+// Commands are normalized first, so namespaced forms like minecraft:stop and
+// commands wrapped in execute ... run are judged by what they actually do.
+package commandpolicy
+
+import (
+	"strings"
+
+	"github.com/adammcgrogan/beacon/internal/config"
+)
+
+// Where a decision came from.
+const (
+	SourceConfig    = "config"
+	SourceBuiltin   = "builtin"
+	SourceRole      = "role"
+	SourceUnmatched = "unmatched"
+)
+
+// Policy evaluates console commands against configured rules, then built-in
+// rules, then per-role command lists. It is immutable and safe for
+// concurrent use.
+type Policy struct {
+	rules      []rule
+	roles      []role
+	consoleUse string
+	denyOther  bool
+}
+
+type rule struct {
+	config.CommandRule
+	source string
+}
+
+type role struct {
+	permission string
+	commands   []config.CommandRule
+}
+
+// Step explains the decision for one command in a line.
+type Step struct {
+	Invocation
+	Allowed bool                `json:"allowed"`
+	Source  string              `json:"source"`
+	Rule    *config.CommandRule `json:"rule,omitempty"`
+	Role    string              `json:"role,omitempty"`
+	Reason  string              `json:"reason"`
+}
+
+// Decision is the verdict for a whole console line: it is allowed only if
+// every command it runs is, and a line Normalize cannot resolve is refused.
+type Decision struct {
+	Input   string `json:"input"`
+	Allowed bool   `json:"allowed"`
+	Steps   []Step `json:"steps"`
+	// Error says why a line that cannot be taken apart was refused.
+	Error string `json:"error,omitempty"`
+}
+
+// New builds a policy from configuration. builtin rules are tried after the
+// configured ones; consoleUse is the node needed for commands without a more
+// specific permission.
+func New(cfg config.CommandsConfig, builtin []config.CommandRule, consoleUse string) *Policy {
+	p := &Policy{
+		consoleUse: consoleUse,
+		denyOther:  cfg.Unmatched == "deny",
+	}
+	for _, r := range cfg.Rules {
+		p.rules = append(p.rules, rule{CommandRule: normalizeRule(r), source: SourceConfig})
+	}
+	for _, r := range builtin {
+		p.rules = append(p.rules, rule{CommandRule: normalizeRule(r), source: SourceBuiltin})
+	}
+	for _, r := range cfg.Roles {
+		parsed := role{permission: strings.TrimSpace(r.Permission)}
+		for _, entry := range r.Commands {
+			name, args, _ := strings.Cut(strings.TrimSpace(entry), " ")
+			parsed.commands = append(parsed.commands, normalizeRule(config.CommandRule{Command: name, Args: args}))
+		}
+		p.roles = append(p.roles, parsed)
+	}
+	return p
+}
+
+// Evaluate decides whether a user holding the permissions has reports true
+// for may run line.
+func (p *Policy) Evaluate(line string, has func(permission string) bool) Decision {
+	decision := Decision{Input: line, Steps: make([]Step, 0, 1)}
+	invocations, err := Normalize(line)
+	if len(invocations) == 0 && err == nil {
+		return decision
+	}
+	decision.Allowed = err == nil
+	if err != nil {
+		decision.Error = err.Error()
+	}
+	for _, inv := range invocations {
+		step := p.evaluate(inv, has)
+		decision.Steps = append(decision.Steps, step)
+		if !step.Allowed {
+			decision.Allowed = false
+		}
+	}
+	return decision
+}
+
+func (p *Policy) evaluate(inv Invocation, has func(string) bool) Step {
+	step := Step{Invocation: inv}
+	for i := range p.rules {
+		r := &p.rules[i]
+		if !r.matches(inv) {
+			continue
+		}
+		matched := r.CommandRule
+		step.Rule = &matched
+		step.Source = r.source
+		if r.Deny {
+			step.Reason = "denied by rule"
+			return step
+		}
+		permission := r.Permission
+		if permission == "" {
+			permission = p.consoleUse
+		}
+		if has(permission) {
+			step.Allowed = true
+			step.Reason = "has " + permission
+			return step
+		}
+		if p.roleAllows(&step, inv, has) {
+			return step
+		}
+		step.Reason = "requires " + permission
+		return step
+	}
+
+	if p.roleAllows(&step, inv, has) {
+		return step
+	}
+	step.Source = SourceUnmatched
+	if p.denyOther {
+		step.Reason = "no rule allows this command"
+		return step
+	}
+	step.Allowed = has(p.consoleUse)
+	if step.Allowed {
+		step.Reason = "has " + p.consoleUse
+	} else {
+		step.Reason = "requires " + p.consoleUse
+	}
+	return step
+}
+
+func (p *Policy) roleAllows(step *Step, inv Invocation, has func(string) bool) bool {
+	for _, r := range p.roles {
+		if !has(r.permission) {
+			continue
+		}
+		for _, command := range r.commands {
+			if matches(command, inv) {
+				step.Allowed = true
+				step.Source = SourceRole
+				step.Role = r.permission
+				step.Reason = "listed for " + r.permission
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *rule) matches(inv Invocation) bool {
+	return matches(r.CommandRule, inv)
+}
+
+func matches(r config.CommandRule, inv Invocation) bool {
+	if r.Command != "*" && r.Command != inv.Command {
+		return false
+	}
+	if r.Args == "" {
+		return true
+	}
+	args := strings.ToLower(inv.Args)
+	if glob(r.Args, args) {
+		return true
+	}
+	// "add *" also matches a bare "add".
+	if trimmed, ok := strings.CutSuffix(r.Args, " *"); ok {
+		return glob(trimmed, args)
+	}
+	return false
+}
+
+// normalizeRule puts a rule's command in the same form Normalize produces.
+func normalizeRule(r config.CommandRule) config.CommandRule {
+	r.Command = strings.TrimSpace(r.Command)
+	if r.Command != "*" {
+		if invs, _ := Normalize(r.Command); len(invs) > 0 {
+			r.Command = invs[0].Command
+		}
+	}
+	r.Args = strings.ToLower(strings.Join(strings.Fields(r.Args), " "))
+	r.Permission = strings.TrimSpace(r.Permission)
+	return r
+}
+
+// glob matches s against a pattern where * is any run of characters and ?
+// is any single character.
+func glob(pattern, s string) bool {
+	px, sx := 0, 0
+	star, mark := -1, 0
+	for sx < len(s) {
+		switch {
+		case px < len(pattern) && (pattern[px] == '?' || pattern[px] == s[sx]):
+			px++
+			sx++
+		case px < len(pattern) && pattern[px] == '*':
+			star, mark = px, sx
+			px++
+		case star >= 0:
+			px = star + 1
+			mark++
+			sx = mark
+		default:
+			return false
+		}
+	}
+	for px < len(pattern) && pattern[px] == '*' {
+		px++
+	}
+	return px == len(pattern)
+}
@@ -0,0 +1,121 @@
+package commandpolicy
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/adammcgrogan/beacon/internal/config"
+)
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"", "", true},
+		{"", "x", false},
+		{"*", "", true},
+		{"*", "anything at all", true},
+		{"add *", "add steve", true},
+		{"add *", "add", false},
+		{"add *", "remove steve", false},
+		{"?", "a", true},
+		{"?", "ab", false},
+		{"a*c", "abbbc", true},
+		{"a*c", "abbbd", false},
+		{"*survival*", "gamemode survival steve", true},
+		{"s?ve", "save", true},
+		{"**", "x", true},
+	}
+	for _, tt := range tests {
+		if got := glob(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("glob(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+const (
+	permConsole = "console"
+	permOp      = "op"
+	permBuilder = "builder"
+)
+
+func testPolicy(cfg config.CommandsConfig) *Policy {
+	return New(cfg, []config.CommandRule{
+		{Command: "op", Permission: permOp},
+		{Command: "whitelist", Args: "add *", Permission: "whitelist.add"},
+	}, permConsole)
+}
+
+func holds(permissions ...string) func(string) bool {
+	return func(permission string) bool { return slices.Contains(permissions, permission) }
+}
+
+func TestEvaluate(t *testing.T) {
+	roles := config.CommandsConfig{
+		Rules: []config.CommandRule{
+			{Command: "stop", Deny: true},
+			{Command: "gamemode", Args: "creative *", Permission: "creative"},
+		},
+		Roles: []config.CommandRole{
+			{Permission: permBuilder, Commands: []string{"gamemode *", "stop", "minecraft:tp"}},
+		},
+	}
+	tests := []struct {
+		name        string
+		cfg         config.CommandsConfig
+		line        string
+		permissions []string
+		want        bool
+		sources     []string
+	}{
+		{name: "console use", line: "say hi", permissions: []string{permConsole}, want: true, sources: []string{SourceUnmatched}},
+		{name: "no console use", line: "say hi", want: false, sources: []string{SourceUnmatched}},
+		{name: "builtin needs its node", line: "op Steve", permissions: []string{permConsole}, want: false, sources: []string{SourceBuiltin}},
+		{name: "builtin node", line: "op Steve", permissions: []string{permOp}, want: true, sources: []string{SourceBuiltin}},
+		{name: "namespaced builtin", line: "/minecraft:op Steve", permissions: []string{permConsole}, want: false},
+		{name: "builtin args glob", line: "whitelist add Steve", permissions: []string{permConsole}, want: false},
+		{name: "unmatched args", line: "whitelist list", permissions: []string{permConsole}, want: true},
+		{name: "execute wraps builtin", line: "execute as @a run op Steve", permissions: []string{permConsole}, want: false, sources: []string{SourceUnmatched, SourceBuiltin}},
+		{name: "return run wraps builtin", line: "return run op Steve", permissions: []string{permConsole}, want: false},
+		{name: "player named run", line: "execute as run run op attacker", permissions: []string{permConsole}, want: false, sources: []string{SourceUnmatched, SourceBuiltin}},
+		{name: "unresolved line", line: "execute sideways run op x", permissions: []string{permConsole, permOp}, want: false},
+		{name: "empty line", line: "", permissions: []string{permConsole}, want: false},
+		{name: "config deny beats role", cfg: roles, line: "stop", permissions: []string{permConsole, permBuilder}, want: false, sources: []string{SourceConfig}},
+		{name: "role grants listed command", cfg: roles, line: "gamemode survival Steve", permissions: []string{permBuilder}, want: true, sources: []string{SourceRole}},
+		{name: "role covers rule it lacks the node for", cfg: roles, line: "gamemode creative Steve", permissions: []string{permBuilder}, want: true, sources: []string{SourceRole}},
+		{name: "rule node without role", cfg: roles, line: "gamemode creative Steve", permissions: []string{"creative"}, want: true, sources: []string{SourceConfig}},
+		{name: "role alias", cfg: roles, line: "teleport Steve 0 64 0", permissions: []string{permBuilder}, want: true, sources: []string{SourceRole}},
+		{name: "role not held", cfg: roles, line: "gamemode survival Steve", permissions: []string{"other"}, want: false},
+		{name: "apostrophe in say", line: "say don't grief", permissions: []string{permConsole}, want: true},
+		{name: "apostrophe in msg", line: "msg Steve it's me", permissions: []string{permConsole}, want: true},
+		{name: "apostrophe in kick reason", line: "kick Steve you're banned", permissions: []string{permConsole}, want: true},
+		{name: "stray bracket in say", line: "say :) [brb", permissions: []string{permConsole}, want: true},
+		{name: "run inside block nbt", line: "execute if block ~ ~ ~ chest{a: run say x} run op Steve", permissions: []string{permConsole}, want: false},
+		{name: "unmatched deny", cfg: config.CommandsConfig{Unmatched: "deny"}, line: "say hi", permissions: []string{permConsole}, want: false, sources: []string{SourceUnmatched}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := testPolicy(tt.cfg).Evaluate(tt.line, holds(tt.permissions...))
+			if decision.Allowed != tt.want {
+				t.Errorf("Evaluate(%q).Allowed = %v, want %v (%+v)", tt.line, decision.Allowed, tt.want, decision)
+			}
+			if tt.sources != nil {
+				var sources []string
+				for _, step := range decision.Steps {
+					sources = append(sources, step.Source)
+				}
+				if !slices.Equal(sources, tt.sources) {
+					t.Errorf("sources = %q, want %q", sources, tt.sources)
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluateUnresolvedReportsError(t *testing.T) {
+	decision := testPolicy(config.CommandsConfig{}).Evaluate(`execute as "Steve run op x`, holds(permConsole, permOp))
+	if decision.Allowed || decision.Error == "" {
+		t.Errorf("got %+v, want a refusal with an error", decision)
+	}
+}
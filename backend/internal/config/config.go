@@ -0,0 +1,697 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is read when neither -config nor BEACON_CONFIG is given. A
+// missing default file is not an error; an explicitly requested one is.
+const DefaultPath = "beacon.yml"
+
+// Config is the complete backend configuration. Values are resolved in order:
+// built-in defaults, the YAML file, BEACON_* environment variables, then
+// command-line flags. The env and flag tags drive the last two layers.
+type Config struct {
+	Server      ServerConfig      `yaml:"server"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Console     ConsoleConfig     `yaml:"console"`
+	Web         WebConfig         `yaml:"web"`
+	Plugin      PluginConfig      `yaml:"plugin"`
+	Assets      AssetsConfig      `yaml:"assets"`
+	Data        DataConfig        `yaml:"data"`
+	Commands    CommandsConfig    `yaml:"commands"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Hotspots    HotspotsConfig    `yaml:"hotspots"`
+	Diagnostics DiagnosticsConfig `yaml:"diagnostics"`
+	Supervisor  SupervisorConfig  `yaml:"supervisor"`
+}
+
+type ServerConfig struct {
+	Listen          string        `yaml:"listen" env:"BEACON_LISTEN" flag:"listen" usage:"address to listen on"`
+	AllowedOrigins  []string      `yaml:"allowed_origins" env:"BEACON_ALLOWED_ORIGINS" flag:"allowed-origins" usage:"comma-separated cross-origin sites allowed to use the panel APIs and WebSockets"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"BEACON_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"how long to wait for in-flight requests when stopping"`
+	Handoff         bool          `yaml:"handoff" env:"BEACON_HANDOFF" flag:"handoff" usage:"on SIGHUP, start a new backend process on the same socket before stopping this one"`
+	Compression     bool          `yaml:"compression" env:"BEACON_COMPRESSION" flag:"compression" usage:"negotiate permessage-deflate on the plugin and browser WebSockets"`
+	TLS             TLSConfig     `yaml:"tls"`
+}
+
+type TLSConfig struct {
+	Cert           string        `yaml:"cert" env:"BEACON_TLS_CERT" flag:"tls-cert" usage:"path to a PEM certificate; enables HTTPS when set with -tls-key"`
+	Key            string        `yaml:"key" env:"BEACON_TLS_KEY" flag:"tls-key" usage:"path to the PEM private key for -tls-cert"`
+	SelfSigned     bool          `yaml:"self_signed" env:"BEACON_TLS_SELF_SIGNED" flag:"tls-self-signed" usage:"generate a self-signed certificate at -tls-cert/-tls-key if they don't exist"`
+	Hosts          []string      `yaml:"hosts" env:"BEACON_TLS_HOSTS" flag:"tls-hosts" usage:"comma-separated hostnames and IPs for a generated self-signed certificate"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"BEACON_TLS_RELOAD_INTERVAL" flag:"tls-reload-interval" usage:"how often to check the certificate files for changes"`
+}
+
+type AuthConfig struct {
+	StatePath     string        `yaml:"state_path" env:"BEACON_AUTH_STATE_PATH" flag:"auth-state-path" usage:"file used to persist sessions until the plugin reports its data directory"`
+	SessionTTL    time.Duration `yaml:"session_ttl" env:"BEACON_SESSION_TTL" flag:"session-ttl" usage:"lifetime of a panel login session"`
+	PermissionTTL time.Duration `yaml:"permission_ttl" env:"BEACON_PERMISSION_TTL" flag:"permission-ttl" usage:"how long plugin-reported permissions are cached"`
+}
+
+type ConsoleConfig struct {
+	MaxLogLines int           `yaml:"max_log_lines" env:"BEACON_CONSOLE_MAX_LOG_LINES" flag:"console-max-log-lines" usage:"console lines kept in memory"`
+	MaxLogBytes int           `yaml:"max_log_bytes" env:"BEACON_CONSOLE_MAX_LOG_BYTES" flag:"console-max-log-bytes" usage:"total bytes of console history kept in memory"`
+	MaxLogAge   time.Duration `yaml:"max_log_age" env:"BEACON_CONSOLE_MAX_LOG_AGE" flag:"console-max-log-age" usage:"clear console history after the server has been silent this long"`
+}
+
+// WebConfig controls per-browser outbound queues. A browser that cannot keep
+// up first has console lines sampled, then is disconnected.
+type WebConfig struct {
+	SendQueueSize        int           `yaml:"send_queue_size" env:"BEACON_WEB_SEND_QUEUE_SIZE" flag:"web-send-queue-size" usage:"messages buffered per browser before new ones are dropped"`
+	DegradeAfterDrops    int           `yaml:"degrade_after_drops" env:"BEACON_WEB_DEGRADE_AFTER_DROPS" flag:"web-degrade-after-drops" usage:"dropped messages before a browser only receives sampled console lines"`
+	DisconnectAfterDrops int           `yaml:"disconnect_after_drops" env:"BEACON_WEB_DISCONNECT_AFTER_DROPS" flag:"web-disconnect-after-drops" usage:"dropped messages before a slow browser is disconnected"`
+	LogSampleRate        int           `yaml:"log_sample_rate" env:"BEACON_WEB_LOG_SAMPLE_RATE" flag:"web-log-sample-rate" usage:"while degraded, forward one in this many console lines"`
+	PingInterval         time.Duration `yaml:"ping_interval" env:"BEACON_WEB_PING_INTERVAL" flag:"web-ping-interval" usage:"keepalive ping interval; browsers silent for twice this are dropped"`
+	WriteTimeout         time.Duration `yaml:"write_timeout" env:"BEACON_WEB_WRITE_TIMEOUT" flag:"web-write-timeout" usage:"maximum time for a single write to a browser"`
+}
+
+type PluginConfig struct {
+	RequestTimeout time.Duration `yaml:"request_timeout" env:"BEACON_PLUGIN_REQUEST_TIMEOUT" flag:"plugin-request-timeout" usage:"how long to wait for the plugin to answer a request"`
+	MaxInFlight    int           `yaml:"max_in_flight" env:"BEACON_PLUGIN_MAX_IN_FLIGHT" flag:"plugin-max-in-flight" usage:"requests that may wait on the plugin at once before new ones are refused"`
+	BinaryFrames   bool          `yaml:"binary_frames" env:"BEACON_PLUGIN_BINARY_FRAMES" flag:"plugin-binary-frames" usage:"let plugins that offer it send high-frequency events as MessagePack"`
+	Secret         string        `yaml:"secret" env:"BEACON_PLUGIN_SECRET" flag:"plugin-secret" usage:"shared secret the plugin and backend present to each other; empty accepts any plugin"`
+	ConnectURL     string        `yaml:"connect_url" env:"BEACON_PLUGIN_CONNECT_URL" flag:"plugin-connect-url" usage:"reverse-connect mode: dial the plugin's listener at this ws:// or wss:// URL"`
+	ReconnectMin   time.Duration `yaml:"reconnect_min" env:"BEACON_PLUGIN_RECONNECT_MIN" flag:"plugin-reconnect-min" usage:"reverse-connect mode: first retry delay after the plugin is unreachable"`
+	ReconnectMax   time.Duration `yaml:"reconnect_max" env:"BEACON_PLUGIN_RECONNECT_MAX" flag:"plugin-reconnect-max" usage:"reverse-connect mode: longest delay between retries"`
+}
+
+type AssetsConfig struct {
+	TemplatesDir string `yaml:"templates_dir" env:"BEACON_TEMPLATES_DIR" flag:"templates-dir" usage:"serve templates from this directory instead of the embedded copy"`
+	StaticDir    string `yaml:"static_dir" env:"BEACON_STATIC_DIR" flag:"static-dir" usage:"serve static assets from this directory instead of the embedded copy"`
+}
+
+// DataConfig is where the backend keeps what it records itself, such as
+// player session history, as opposed to state mirrored from the plugin.
+type DataConfig struct {
+	Dir string `yaml:"dir" env:"BEACON_DATA_DIR" flag:"data-dir" usage:"directory for player history and other backend-owned data"`
+}
+
+// HotspotsConfig controls the scheduled scans for the chunks holding the
+// most entities and tile entities in each loaded world.
+type HotspotsConfig struct {
+	Interval time.Duration `yaml:"interval" env:"BEACON_HOTSPOTS_INTERVAL" flag:"hotspots-interval" usage:"how often to scan loaded worlds for entity hotspots; 0 only scans on request"`
+	Top      int           `yaml:"top" env:"BEACON_HOTSPOTS_TOP" flag:"hotspots-top" usage:"busiest chunks kept from each scan"`
+	Keep     int           `yaml:"keep" env:"BEACON_HOTSPOTS_KEEP" flag:"hotspots-keep" usage:"scans kept per world"`
+}
+
+// DiagnosticsConfig controls how often the server is checked for new crash
+// reports and JVM fatal error logs, and how much diagnostic history is kept.
+type DiagnosticsConfig struct {
+	ScanInterval time.Duration `yaml:"scan_interval" env:"BEACON_DIAGNOSTICS_SCAN_INTERVAL" flag:"diagnostics-scan-interval" usage:"how often to check for new crash reports; the plugin is also checked each time it connects"`
+	KeepReports  int           `yaml:"keep_reports" env:"BEACON_DIAGNOSTICS_KEEP_REPORTS" flag:"diagnostics-keep-reports" usage:"crash reports remembered, newest first"`
+	KeepDumps    int           `yaml:"keep_dumps" env:"BEACON_DIAGNOSTICS_KEEP_DUMPS" flag:"diagnostics-keep-dumps" usage:"thread dumps and heap histograms kept of each kind"`
+	KeepProfiles int           `yaml:"keep_profiles" env:"BEACON_DIAGNOSTICS_KEEP_PROFILES" flag:"diagnostics-keep-profiles" usage:"sampling profiles kept, newest first"`
+	MaxProfile   time.Duration `yaml:"max_profile" env:"BEACON_DIAGNOSTICS_MAX_PROFILE" flag:"diagnostics-max-profile" usage:"longest a sampling profile may run"`
+}
+
+// SupervisorConfig lets the backend run the Minecraft server itself, so it
+// can be started from the panel after it stops. It is off while Command is
+// empty. Command and Env are only read from the YAML file, as arguments may
+// contain commas.
+type SupervisorConfig struct {
+	Command       []string      `yaml:"command"`
+	Dir           string        `yaml:"dir" env:"BEACON_SUPERVISOR_DIR" flag:"supervisor-dir" usage:"working directory of the supervised server"`
+	Env           []string      `yaml:"env"`
+	AutoStart     bool          `yaml:"auto_start" env:"BEACON_SUPERVISOR_AUTO_START" flag:"supervisor-auto-start" usage:"start the supervised server when the backend starts"`
+	StopCommand   string        `yaml:"stop_command" env:"BEACON_SUPERVISOR_STOP_COMMAND" flag:"supervisor-stop-command" usage:"console command written to the server to stop it"`
+	StopTimeout   time.Duration `yaml:"stop_timeout" env:"BEACON_SUPERVISOR_STOP_TIMEOUT" flag:"supervisor-stop-timeout" usage:"how long a graceful stop may take before the server is killed"`
+	Restart       string        `yaml:"restart" env:"BEACON_SUPERVISOR_RESTART" flag:"supervisor-restart" usage:"restart the server after it exits on its own: never, on-crash or always"`
+	RestartDelay  time.Duration `yaml:"restart_delay" env:"BEACON_SUPERVISOR_RESTART_DELAY" flag:"supervisor-restart-delay" usage:"wait this long before an automatic restart"`
+	MaxRestarts   int           `yaml:"max_restarts" env:"BEACON_SUPERVISOR_MAX_RESTARTS" flag:"supervisor-max-restarts" usage:"automatic restarts allowed within restart_window before giving up"`
+	RestartWindow time.Duration `yaml:"restart_window" env:"BEACON_SUPERVISOR_RESTART_WINDOW" flag:"supervisor-restart-window" usage:"window in which max_restarts is counted"`
+}
+
+// Enabled reports whether the backend supervises the server process.
+func (c SupervisorConfig) Enabled() bool {
+	return len(c.Command) > 0
+}
+
+// CommandsConfig is the policy for console commands sent from the panel.
+// Rules are tried in order before the built-in ones and the first match
+// decides; a command no rule matches needs console access, or is refused when
+// Unmatched is "deny".
+type CommandsConfig struct {
+	Unmatched string        `yaml:"unmatched" env:"BEACON_COMMANDS_UNMATCHED" flag:"commands-unmatched" usage:"what to do with console commands no rule matches: console (needs console access) or deny"`
+	Rules     []CommandRule `yaml:"rules"`
+	Roles     []CommandRole `yaml:"roles"`
+}
+
+// CommandRule matches a command by name and, optionally, an argument glob
+// such as "add *". A matching rule either denies the command outright or
+// requires Permission, which defaults to console access.
+type CommandRule struct {
+	Command    string `yaml:"command" json:"command"`
+	Args       string `yaml:"args,omitempty" json:"args,omitempty"`
+	Permission string `yaml:"permission,omitempty" json:"permission,omitempty"`
+	Deny       bool   `yaml:"deny,omitempty" json:"deny,omitempty"`
+}
+
+// CommandRole lets holders of Permission run the listed commands without
+// console access. Entries are a command name optionally followed by an
+// argument glob, e.g. "gamemode survival *".
+type CommandRole struct {
+	Permission string   `yaml:"permission"`
+	Commands   []string `yaml:"commands"`
+}
+
+// RateLimitConfig throttles logins, API calls and /ws/web events. Rates are
+// written as count/period, e.g. "5/m" or "20/s". Tiers raise or lower the
+// API and event limits for users holding a permission; the first tier the
+// user matches applies.
+type RateLimitConfig struct {
+	Enabled        bool            `yaml:"enabled" env:"BEACON_RATE_LIMIT" flag:"rate-limit" usage:"throttle logins, API calls and WebSocket events"`
+	TrustedProxies []string        `yaml:"trusted_proxies" env:"BEACON_TRUSTED_PROXIES" flag:"trusted-proxies" usage:"comma-separated proxy addresses or CIDRs whose X-Forwarded-For is believed"`
+	MagicLink      string          `yaml:"magic_link" env:"BEACON_RATE_LIMIT_MAGIC_LINK" flag:"rate-limit-magic-link" usage:"login attempts allowed per client address"`
+	LockoutAfter   int             `yaml:"lockout_after" env:"BEACON_RATE_LIMIT_LOCKOUT_AFTER" flag:"rate-limit-lockout-after" usage:"failed logins in a row before a client address is locked out"`
+	Lockout        time.Duration   `yaml:"lockout" env:"BEACON_RATE_LIMIT_LOCKOUT" flag:"rate-limit-lockout" usage:"how long a client address stays locked out"`
+	API            string          `yaml:"api" env:"BEACON_RATE_LIMIT_API" flag:"rate-limit-api" usage:"API calls allowed per session"`
+	APIPerIP       string          `yaml:"api_per_ip" env:"BEACON_RATE_LIMIT_API_PER_IP" flag:"rate-limit-api-per-ip" usage:"API calls allowed per client address, across all its sessions"`
+	Events         string          `yaml:"events" env:"BEACON_RATE_LIMIT_EVENTS" flag:"rate-limit-events" usage:"WebSocket events allowed per session"`
+	Tiers          []RateLimitTier `yaml:"tiers"`
+}
+
+// RateLimitTier overrides the API and event limits for holders of
+// Permission. An empty rate keeps the default.
+type RateLimitTier struct {
+	Permission string `yaml:"permission"`
+	API        string `yaml:"api,omitempty"`
+	Events     string `yaml:"events,omitempty"`
+}
+
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Listen:          ":8080",
+			ShutdownTimeout: 15 * time.Second,
+			Compression:     true,
+			TLS: TLSConfig{
+				Hosts:          []string{"localhost", "127.0.0.1", "::1"},
+				ReloadInterval: 30 * time.Second,
+			},
+		},
+		Auth: AuthConfig{
+			SessionTTL:    24 * time.Hour,
+			PermissionTTL: 10 * time.Second,
+		},
+		Console: ConsoleConfig{
+			MaxLogLines: 1000,
+			MaxLogBytes: 5 * 1024 * 1024,
+			MaxLogAge:   1 * time.Hour,
+		},
+		Web: WebConfig{
+			SendQueueSize:        256,
+			DegradeAfterDrops:    32,
+			DisconnectAfterDrops: 1024,
+			LogSampleRate:        10,
+			PingInterval:         30 * time.Second,
+			WriteTimeout:         10 * time.Second,
+		},
+		Plugin: PluginConfig{
+			RequestTimeout: 12 * time.Second,
+			MaxInFlight:    64,
+			BinaryFrames:   true,
+			ReconnectMin:   1 * time.Second,
+			ReconnectMax:   1 * time.Minute,
+		},
+		Data: DataConfig{
+			Dir: "data",
+		},
+		Commands: CommandsConfig{
+			Unmatched: "console",
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:      true,
+			MagicLink:    "10/m",
+			LockoutAfter: 5,
+			Lockout:      15 * time.Minute,
+			API:          "600/m",
+			APIPerIP:     "1200/m",
+			Events:       "20/s",
+		},
+		Hotspots: HotspotsConfig{
+			Interval: 15 * time.Minute,
+			Top:      20,
+			Keep:     672,
+		},
+		Diagnostics: DiagnosticsConfig{
+			ScanInterval: 5 * time.Minute,
+			KeepReports:  100,
+			KeepDumps:    20,
+			KeepProfiles: 20,
+			MaxProfile:   10 * time.Minute,
+		},
+		Supervisor: SupervisorConfig{
+			StopCommand:   "stop",
+			StopTimeout:   1 * time.Minute,
+			Restart:       "on-crash",
+			RestartDelay:  10 * time.Second,
+			MaxRestarts:   3,
+			RestartWindow: 10 * time.Minute,
+		},
+	}
+}
+
+// TLSEnabled reports whether the server should terminate TLS itself.
+func (c Config) TLSEnabled() bool {
+	return c.Server.TLS.Cert != "" && c.Server.TLS.Key != ""
+}
+
+// Options are the command-line switches that control loading rather than
+// describing configuration values.
+type Options struct {
+	Path        string
+	PrintConfig bool
+}
+
+// Load parses args (typically os.Args[1:]) and returns the validated configuration.
+func Load(args []string) (Config, Options, error) {
+	fs := flag.NewFlagSet("beacon", flag.ContinueOnError)
+	var opts Options
+	fs.StringVar(&opts.Path, "config", "", "path to a YAML configuration file (default "+DefaultPath+" if present)")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective configuration and exit")
+
+	cfg := Default()
+	fields := collectFields(reflect.ValueOf(&cfg).Elem())
+	flagValues := make(map[string]*flagValue, len(fields))
+	for _, f := range fields {
+		if f.flag == "" {
+			continue
+		}
+		value := &flagValue{raw: formatField(f.value), isBool: f.value.Kind() == reflect.Bool}
+		flagValues[f.flag] = value
+		fs.Var(value, f.flag, f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, opts, err
+	}
+
+	path := opts.Path
+	required := path != ""
+	if path == "" {
+		path = strings.TrimSpace(os.Getenv("BEACON_CONFIG"))
+		required = path != ""
+	}
+	if path == "" {
+		path = DefaultPath
+	}
+	if err := loadFile(&cfg, path, required); err != nil {
+		return Config{}, opts, err
+	}
+	opts.Path = path
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(f.env)
+		if !ok {
+			continue
+		}
+		if err := setField(f.value, raw); err != nil {
+			return Config{}, opts, fmt.Errorf("%s: %w", f.env, err)
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(fl *flag.Flag) {
+		for _, f := range fields {
+			if f.flag != fl.Name {
+				continue
+			}
+			if err := setField(f.value, flagValues[f.flag].raw); err != nil && flagErr == nil {
+				flagErr = fmt.Errorf("-%s: %w", f.flag, err)
+			}
+		}
+	})
+	if flagErr != nil {
+		return Config{}, opts, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, opts, err
+	}
+	return cfg, opts, nil
+}
+
+func loadFile(cfg *Config, path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return nil
+		}
+		return fmt.Errorf("read config: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate rejects configurations that would fail or misbehave at runtime.
+func (c Config) Validate() error {
+	var errs []error
+
+	if strings.TrimSpace(c.Server.Listen) == "" {
+		errs = append(errs, errors.New("server.listen must not be empty"))
+	} else if _, _, err := net.SplitHostPort(c.Server.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("server.listen: %w", err))
+	}
+	for _, origin := range c.Server.AllowedOrigins {
+		parsed, err := url.Parse(strings.TrimSpace(origin))
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("server.allowed_origins: %q is not an origin like https://panel.example.com", origin))
+		}
+	}
+
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+
+	tls := c.Server.TLS
+	if (tls.Cert == "") != (tls.Key == "") {
+		errs = append(errs, errors.New("server.tls.cert and server.tls.key must be set together"))
+	}
+	if tls.SelfSigned && (tls.Cert == "" || tls.Key == "") {
+		errs = append(errs, errors.New("server.tls.self_signed requires server.tls.cert and server.tls.key paths"))
+	}
+	if tls.ReloadInterval <= 0 {
+		errs = append(errs, errors.New("server.tls.reload_interval must be positive"))
+	}
+
+	if c.Auth.SessionTTL <= 0 {
+		errs = append(errs, errors.New("auth.session_ttl must be positive"))
+	}
+	if c.Auth.PermissionTTL <= 0 {
+		errs = append(errs, errors.New("auth.permission_ttl must be positive"))
+	}
+
+	if c.Console.MaxLogLines <= 0 {
+		errs = append(errs, errors.New("console.max_log_lines must be positive"))
+	}
+	if c.Console.MaxLogBytes <= 0 {
+		errs = append(errs, errors.New("console.max_log_bytes must be positive"))
+	}
+	if c.Console.MaxLogAge <= 0 {
+		errs = append(errs, errors.New("console.max_log_age must be positive"))
+	}
+
+	if c.Web.SendQueueSize <= 0 {
+		errs = append(errs, errors.New("web.send_queue_size must be positive"))
+	}
+	if c.Web.DegradeAfterDrops <= 0 || c.Web.DisconnectAfterDrops < c.Web.DegradeAfterDrops {
+		errs = append(errs, errors.New("web.degrade_after_drops must be positive and no larger than web.disconnect_after_drops"))
+	}
+	if c.Web.LogSampleRate <= 0 {
+		errs = append(errs, errors.New("web.log_sample_rate must be positive"))
+	}
+	if c.Web.PingInterval <= 0 || c.Web.WriteTimeout <= 0 {
+		errs = append(errs, errors.New("web.ping_interval and web.write_timeout must be positive"))
+	}
+
+	if c.Plugin.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("plugin.request_timeout must be positive"))
+	}
+	if c.Plugin.MaxInFlight <= 0 {
+		errs = append(errs, errors.New("plugin.max_in_flight must be positive"))
+	}
+	if c.Plugin.ConnectURL != "" && c.Plugin.Secret == "" {
+		errs = append(errs, errors.New("plugin.connect_url requires plugin.secret; the plugin will not listen without one"))
+	}
+	if c.Plugin.ConnectURL != "" {
+		if u, err := url.Parse(c.Plugin.ConnectURL); err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("plugin.connect_url %q must be a ws:// or wss:// URL", c.Plugin.ConnectURL))
+		}
+		if c.Plugin.ReconnectMin <= 0 || c.Plugin.ReconnectMax < c.Plugin.ReconnectMin {
+			errs = append(errs, errors.New("plugin.reconnect_min must be positive and no larger than plugin.reconnect_max"))
+		}
+	}
+
+	if strings.TrimSpace(c.Data.Dir) == "" {
+		errs = append(errs, errors.New("data.dir must not be empty"))
+	}
+
+	switch c.Commands.Unmatched {
+	case "console", "deny":
+	default:
+		errs = append(errs, fmt.Errorf("commands.unmatched %q must be console or deny", c.Commands.Unmatched))
+	}
+	for i, rule := range c.Commands.Rules {
+		if strings.TrimSpace(rule.Command) == "" || strings.ContainsAny(strings.TrimSpace(rule.Command), " \t") {
+			errs = append(errs, fmt.Errorf("commands.rules[%d].command must be a single command name or *", i))
+		}
+		if rule.Deny && rule.Permission != "" {
+			errs = append(errs, fmt.Errorf("commands.rules[%d] cannot both deny and require a permission", i))
+		}
+	}
+	for i, role := range c.Commands.Roles {
+		if strings.TrimSpace(role.Permission) == "" {
+			errs = append(errs, fmt.Errorf("commands.roles[%d].permission must not be empty", i))
+		}
+		if len(role.Commands) == 0 {
+			errs = append(errs, fmt.Errorf("commands.roles[%d].commands must not be empty", i))
+		}
+	}
+
+	if c.RateLimit.Enabled {
+		for _, rate := range []struct{ name, value string }{
+			{"rate_limit.magic_link", c.RateLimit.MagicLink},
+			{"rate_limit.api", c.RateLimit.API},
+			{"rate_limit.api_per_ip", c.RateLimit.APIPerIP},
+			{"rate_limit.events", c.RateLimit.Events},
+		} {
+			if _, err := ratelimit.ParseRate(rate.value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", rate.name, err))
+			}
+		}
+		if c.RateLimit.LockoutAfter <= 0 || c.RateLimit.Lockout <= 0 {
+			errs = append(errs, errors.New("rate_limit.lockout_after and rate_limit.lockout must be positive"))
+		}
+		for i, tier := range c.RateLimit.Tiers {
+			if strings.TrimSpace(tier.Permission) == "" {
+				errs = append(errs, fmt.Errorf("rate_limit.tiers[%d].permission must not be empty", i))
+			}
+			for _, rate := range []string{tier.API, tier.Events} {
+				if rate == "" {
+					continue
+				}
+				if _, err := ratelimit.ParseRate(rate); err != nil {
+					errs = append(errs, fmt.Errorf("rate_limit.tiers[%d]: %w", i, err))
+				}
+			}
+		}
+		for _, proxy := range c.RateLimit.TrustedProxies {
+			if _, err := netip.ParsePrefix(proxy); err != nil {
+				if _, err := netip.ParseAddr(proxy); err != nil {
+					errs = append(errs, fmt.Errorf("rate_limit.trusted_proxies: %q is not an address or CIDR", proxy))
+				}
+			}
+		}
+	}
+
+	if c.Hotspots.Interval < 0 {
+		errs = append(errs, errors.New("hotspots.interval must not be negative"))
+	}
+	if c.Hotspots.Top <= 0 || c.Hotspots.Top > 100 {
+		errs = append(errs, errors.New("hotspots.top must be between 1 and 100"))
+	}
+	if c.Hotspots.Keep <= 0 {
+		errs = append(errs, errors.New("hotspots.keep must be positive"))
+	}
+
+	if c.Diagnostics.ScanInterval <= 0 {
+		errs = append(errs, errors.New("diagnostics.scan_interval must be positive"))
+	}
+	if c.Diagnostics.KeepReports <= 0 {
+		errs = append(errs, errors.New("diagnostics.keep_reports must be positive"))
+	}
+	if c.Diagnostics.KeepDumps <= 0 {
+		errs = append(errs, errors.New("diagnostics.keep_dumps must be positive"))
+	}
+	if c.Diagnostics.KeepProfiles <= 0 {
+		errs = append(errs, errors.New("diagnostics.keep_profiles must be positive"))
+	}
+	if c.Diagnostics.MaxProfile < 10*time.Second {
+		errs = append(errs, errors.New("diagnostics.max_profile must be at least 10s"))
+	}
+
+	if sup := c.Supervisor; sup.Enabled() {
+		if strings.TrimSpace(sup.Command[0]) == "" {
+			errs = append(errs, errors.New("supervisor.command must start with a program"))
+		}
+		switch sup.Restart {
+		case "never", "on-crash", "always":
+		default:
+			errs = append(errs, fmt.Errorf("supervisor.restart %q must be never, on-crash or always", sup.Restart))
+		}
+		if strings.TrimSpace(sup.StopCommand) == "" || sup.StopTimeout <= 0 {
+			errs = append(errs, errors.New("supervisor.stop_command must be set and supervisor.stop_timeout positive"))
+		}
+		if sup.RestartDelay < 0 || sup.MaxRestarts < 0 || sup.RestartWindow <= 0 {
+			errs = append(errs, errors.New("supervisor.restart_delay and supervisor.max_restarts must not be negative and supervisor.restart_window must be positive"))
+		}
+		for _, env := range sup.Env {
+			if key, _, ok := strings.Cut(env, "="); !ok || key == "" {
+				errs = append(errs, fmt.Errorf("supervisor.env: %q must be KEY=value", env))
+			}
+		}
+		if sup.Dir != "" {
+			if info, err := os.Stat(sup.Dir); err != nil || !info.IsDir() {
+				errs = append(errs, fmt.Errorf("supervisor.dir: %q is not a directory", sup.Dir))
+			}
+		}
+		// The server's console pipes belong to this process and cannot be
+		// handed on, so a handoff restart would take the server down with it.
+		if c.Server.Handoff {
+			errs = append(errs, errors.New("server.handoff cannot be used with supervisor.command; the supervised server would stop on every handoff"))
+		}
+	}
+
+	for _, dir := range []struct{ name, path string }{
+		{"assets.templates_dir", c.Assets.TemplatesDir},
+		{"assets.static_dir", c.Assets.StaticDir},
+	} {
+		if dir.path == "" {
+			continue
+		}
+		if info, err := os.Stat(dir.path); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("%s: %q is not a directory", dir.name, dir.path))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// redacted stands in for secrets in YAML output.
+const redacted = "<redacted>"
+
+// YAML renders the configuration in the same format Load reads, with
+// plugin.secret and the values of supervisor.env replaced by a placeholder
+// so the output can be shared.
+func (c Config) YAML() ([]byte, error) {
+	if c.Plugin.Secret != "" {
+		c.Plugin.Secret = redacted
+	}
+	if len(c.Supervisor.Env) > 0 {
+		env := make([]string, len(c.Supervisor.Env))
+		for i, entry := range c.Supervisor.Env {
+			key, _, _ := strings.Cut(entry, "=")
+			env[i] = key + "=" + redacted
+		}
+		c.Supervisor.Env = env
+	}
+	return yaml.Marshal(c)
+}
+
+// flagValue records the raw command-line value so it can be applied after the
+// file and environment layers.
+type flagValue struct {
+	raw    string
+	isBool bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.raw
+}
+
+func (f *flagValue) Set(raw string) error {
+	f.raw = raw
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool { return f.isBool }
+
+type field struct {
+	value reflect.Value
+	env   string
+	flag  string
+	usage string
+}
+
+func collectFields(v reflect.Value) []field {
+	var out []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := v.Field(i)
+		if sf.Type.Kind() == reflect.Struct && sf.Type != reflect.TypeOf(time.Duration(0)) {
+			out = append(out, collectFields(fv)...)
+			continue
+		}
+		out = append(out, field{
+			value: fv,
+			env:   sf.Tag.Get("env"),
+			flag:  sf.Tag.Get("flag"),
+			usage: sf.Tag.Get("usage"),
+		})
+	}
+	return out
+}
+
+func formatField(v reflect.Value) string {
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		return strings.Join(v.Interface().([]string), ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+func setField(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		parts := make([]string, 0)
+		for _, part := range strings.Split(raw, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		v.Set(reflect.ValueOf(parts))
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a YAML file for Load to read and returns its path.
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "beacon.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+server:
+  listen: 127.0.0.1:9001
+  shutdown_timeout: 11s
+plugin:
+  request_timeout: 12s
+`)
+	t.Setenv("BEACON_SHUTDOWN_TIMEOUT", "21s")
+	t.Setenv("BEACON_PLUGIN_REQUEST_TIMEOUT", "22s")
+	t.Setenv("BEACON_ALLOWED_ORIGINS", "https://a.example.com, https://b.example.com")
+
+	cfg, opts, err := Load([]string{"-config", path, "-plugin-request-timeout", "32s"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if opts.Path != path {
+		t.Errorf("Path = %q, want %q", opts.Path, path)
+	}
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"default", cfg.Plugin.MaxInFlight, Default().Plugin.MaxInFlight},
+		{"file over default", cfg.Server.Listen, "127.0.0.1:9001"},
+		{"env over file", cfg.Server.ShutdownTimeout, 21 * time.Second},
+		{"flag over env", cfg.Plugin.RequestTimeout, 32 * time.Second},
+		{"env list", strings.Join(cfg.Server.AllowedOrigins, " "), "https://a.example.com https://b.example.com"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	path := writeConfig(t, "server:\n  listen: 127.0.0.1:9002\n")
+	t.Setenv("BEACON_CONFIG", path)
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Listen != "127.0.0.1:9002" {
+		t.Errorf("listen = %q, BEACON_CONFIG was not read", cfg.Server.Listen)
+	}
+
+	t.Setenv("BEACON_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, _, err := Load(nil); err == nil {
+		t.Error("a missing BEACON_CONFIG file was not reported")
+	}
+}
+
+func TestLoadReportsBadValues(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want string // substring of the error
+	}{
+		{name: "file", file: "server:\n  shutdown_timeout: soon\n", want: "line 2"},
+		{name: "unknown file key", file: "server:\n  listne: :8080\n", want: "listne"},
+		{name: "env", env: map[string]string{"BEACON_SHUTDOWN_TIMEOUT": "soon"}, want: "BEACON_SHUTDOWN_TIMEOUT"},
+		{name: "flag", args: []string{"-plugin-max-in-flight", "lots"}, want: "-plugin-max-in-flight"},
+		{name: "bool env", env: map[string]string{"BEACON_HANDOFF": "maybe"}, want: "BEACON_HANDOFF"},
+		{name: "validated", env: map[string]string{"BEACON_RATE_LIMIT_API": "often"}, want: "rate_limit.api"},
+		{name: "validated flag", args: []string{"-listen", "nowhere"}, want: "server.listen"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.file)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, _, err := Load(append([]string{"-config", path}, tt.args...))
+			if err == nil {
+				t.Fatal("Load accepted a bad value")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string // substring of the error; empty means valid
+	}{
+		{"default", func(*Config) {}, ""},
+		{"supervisor alone", func(c *Config) {
+			c.Supervisor.Command = []string{"java", "-jar", "paper.jar"}
+		}, ""},
+		{"handoff alone", func(c *Config) { c.Server.Handoff = true }, ""},
+		{"handoff with supervisor", func(c *Config) {
+			c.Server.Handoff = true
+			c.Supervisor.Command = []string{"java", "-jar", "paper.jar"}
+		}, "server.handoff cannot be used with supervisor.command"},
+		{"reverse connect without secret", func(c *Config) {
+			c.Plugin.ConnectURL = "ws://127.0.0.1:8766"
+		}, "plugin.secret"},
+		{"supervisor env without value", func(c *Config) {
+			c.Supervisor.Command = []string{"java"}
+			c.Supervisor.Env = []string{"JAVA_HOME"}
+		}, "supervisor.env"},
+	}
+	for _, tt := range tests {
+		cfg := Default()
+		tt.modify(&cfg)
+		err := cfg.Validate()
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		case tt.want != "" && err == nil:
+			t.Errorf("%s: accepted, want an error mentioning %q", tt.name, tt.want)
+		case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+			t.Errorf("%s: error %q does not mention %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestYAMLRedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Plugin.Secret = "hunter2"
+	cfg.Supervisor.Env = []string{"RCON_PASSWORD=swordfish", "EMPTY="}
+	out, err := cfg.YAML()
+	if err != nil {
+		t.Fatalf("YAML: %v", err)
+	}
+	text := string(out)
+	for _, secret := range []string{"hunter2", "swordfish"} {
+		if strings.Contains(text, secret) {
+			t.Errorf("YAML output contains %q:\n%s", secret, text)
+		}
+	}
+	for _, want := range []string{"secret: <redacted>", "RCON_PASSWORD=<redacted>", "EMPTY=<redacted>"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("YAML output lacks %q:\n%s", want, text)
+		}
+	}
+	if cfg.Plugin.Secret != "hunter2" || cfg.Supervisor.Env[0] != "RCON_PASSWORD=swordfish" {
+		t.Error("YAML changed the configuration it rendered")
+	}
+
+	// The output must load back as the same shape of configuration.
+	if _, _, err := Load([]string{"-config", writeConfig(t, text)}); err != nil {
+		t.Errorf("redacted YAML does not load: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+// Package datafile reads and writes the JSON files the backend keeps in its
+// data directory. Writes go to a temporary file that is renamed into place,
+// so a crash never leaves a half-written file behind.
+package datafile
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Read decodes the JSON file at path into v. It reports false, with no error,
+// when the file does not exist yet.
+func Read(path string, v any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Write atomically replaces the file at path with data, creating its
+// directory if needed. Callers encode under their own lock and write outside it.
+func Write(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
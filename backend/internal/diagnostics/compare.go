@@ -0,0 +1,244 @@
+package diagnostics
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxDiffRows bounds how many classes or threads a diff lists.
+const maxDiffRows = 100
+
+// ClassCount is one row of a heap histogram.
+type ClassCount struct {
+	Class     string `json:"class"`
+	Instances int64  `json:"instances"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// Histogram is a parsed heap histogram, largest classes first.
+type Histogram struct {
+	Classes   []ClassCount `json:"classes"`
+	Instances int64        `json:"instances"`
+	Bytes     int64        `json:"bytes"`
+}
+
+var (
+	// histogramRow matches "   1:        123456       12345678  [B (java.base@17)".
+	histogramRow = regexp.MustCompile(`^\s*\d+:\s+(\d+)\s+(\d+)\s+(\S+)`)
+	// histogramTotal matches "Total       1234567      123456789".
+	histogramTotal = regexp.MustCompile(`^\s*Total\s+(\d+)\s+(\d+)`)
+)
+
+// ParseHistogram reads the output of the JVM's GC.class_histogram command.
+func ParseHistogram(content string) Histogram {
+	var histogram Histogram
+	scanner := lines(content)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := histogramRow.FindStringSubmatch(line); match != nil {
+			instances, _ := strconv.ParseInt(match[1], 10, 64)
+			bytes, _ := strconv.ParseInt(match[2], 10, 64)
+			histogram.Classes = append(histogram.Classes, ClassCount{Class: match[3], Instances: instances, Bytes: bytes})
+			continue
+		}
+		if match := histogramTotal.FindStringSubmatch(line); match != nil {
+			histogram.Instances, _ = strconv.ParseInt(match[1], 10, 64)
+			histogram.Bytes, _ = strconv.ParseInt(match[2], 10, 64)
+		}
+	}
+	if histogram.Instances == 0 && histogram.Bytes == 0 {
+		for _, class := range histogram.Classes {
+			histogram.Instances += class.Instances
+			histogram.Bytes += class.Bytes
+		}
+	}
+	return histogram
+}
+
+// ClassDelta is how one class changed between two histograms. Instances and
+// Bytes are the newer counts.
+type ClassDelta struct {
+	ClassCount
+	InstancesDelta int64 `json:"instances_delta"`
+	BytesDelta     int64 `json:"bytes_delta"`
+}
+
+// HistogramDiff compares two heap histograms. Classes are those whose
+// footprint changed the most, in either direction.
+type HistogramDiff struct {
+	From           Dump         `json:"from"`
+	To             Dump         `json:"to"`
+	InstancesDelta int64        `json:"instances_delta"`
+	BytesDelta     int64        `json:"bytes_delta"`
+	Classes        []ClassDelta `json:"classes"`
+}
+
+// DiffHistograms compares the histogram of dump from with that of dump to.
+func DiffHistograms(fromDump, toDump Dump, from, to Histogram) *HistogramDiff {
+	before := make(map[string]ClassCount, len(from.Classes))
+	for _, class := range from.Classes {
+		before[class.Class] = class
+	}
+	deltas := []ClassDelta{}
+	for _, class := range to.Classes {
+		old := before[class.Class]
+		delete(before, class.Class)
+		if old.Instances == class.Instances && old.Bytes == class.Bytes {
+			continue
+		}
+		deltas = append(deltas, ClassDelta{ClassCount: class, InstancesDelta: class.Instances - old.Instances, BytesDelta: class.Bytes - old.Bytes})
+	}
+	for _, old := range before {
+		deltas = append(deltas, ClassDelta{ClassCount: ClassCount{Class: old.Class}, InstancesDelta: -old.Instances, BytesDelta: -old.Bytes})
+	}
+	slices.SortFunc(deltas, func(a, b ClassDelta) int {
+		return cmp.Or(cmp.Compare(abs(b.BytesDelta), abs(a.BytesDelta)), strings.Compare(a.Class, b.Class))
+	})
+	if len(deltas) > maxDiffRows {
+		deltas = deltas[:maxDiffRows]
+	}
+	return &HistogramDiff{
+		From:           fromDump,
+		To:             toDump,
+		InstancesDelta: to.Instances - from.Instances,
+		BytesDelta:     to.Bytes - from.Bytes,
+		Classes:        deltas,
+	}
+}
+
+// Thread is one thread of a thread dump. State is the java.lang.Thread.State,
+// or "VM" for JVM-internal threads that have none; Top is the innermost
+// stack frame.
+type Thread struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Top   string `json:"top,omitempty"`
+}
+
+// threadStateVM is the state given to threads without a Java state.
+const threadStateVM = "VM"
+
+// ParseThreads reads the output of the JVM's Thread.print command.
+func ParseThreads(content string) []Thread {
+	var threads []Thread
+	scanner := lines(content)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, `"`) {
+			name := line[1:]
+			if end := strings.LastIndex(name, `" `); end >= 0 {
+				name = name[:end]
+			} else {
+				name = strings.TrimSuffix(name, `"`)
+			}
+			threads = append(threads, Thread{Name: name, State: threadStateVM})
+			continue
+		}
+		if len(threads) == 0 {
+			continue
+		}
+		current := &threads[len(threads)-1]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "java.lang.Thread.State:"):
+			state := strings.Fields(strings.TrimPrefix(trimmed, "java.lang.Thread.State:"))
+			if len(state) > 0 {
+				current.State = state[0]
+			}
+		case current.Top == "" && strings.HasPrefix(trimmed, "at "):
+			current.Top = strings.TrimPrefix(trimmed, "at ")
+		}
+	}
+	return threads
+}
+
+func summarizeThreads(threads []Thread) DumpSummary {
+	states := make(map[string]int)
+	for _, thread := range threads {
+		states[thread.State]++
+	}
+	return DumpSummary{Threads: len(threads), ThreadStates: states}
+}
+
+// ThreadChange is a thread whose state changed.
+type ThreadChange struct {
+	Name      string `json:"name"`
+	FromState string `json:"from_state"`
+	ToState   string `json:"to_state"`
+	FromTop   string `json:"from_top,omitempty"`
+	ToTop     string `json:"to_top,omitempty"`
+}
+
+// ThreadDiff compares two thread dumps: threads started and ended between
+// them, threads whose state changed, and how many threads are in each state.
+type ThreadDiff struct {
+	From        Dump           `json:"from"`
+	To          Dump           `json:"to"`
+	StateDeltas map[string]int `json:"state_deltas"`
+	Started     []Thread       `json:"started"`
+	Ended       []Thread       `json:"ended"`
+	Changed     []ThreadChange `json:"changed"`
+}
+
+// DiffThreads compares the threads of dump from with those of dump to.
+// Threads are matched by name; threads sharing a name are matched in order.
+func DiffThreads(fromDump, toDump Dump, from, to []Thread) *ThreadDiff {
+	diff := &ThreadDiff{
+		From:        fromDump,
+		To:          toDump,
+		StateDeltas: make(map[string]int),
+		Started:     []Thread{},
+		Ended:       []Thread{},
+		Changed:     []ThreadChange{},
+	}
+	before := make(map[string][]Thread)
+	for _, thread := range from {
+		before[thread.Name] = append(before[thread.Name], thread)
+		diff.StateDeltas[thread.State]--
+	}
+	for _, thread := range to {
+		diff.StateDeltas[thread.State]++
+		olds := before[thread.Name]
+		if len(olds) == 0 {
+			diff.Started = append(diff.Started, thread)
+			continue
+		}
+		old := olds[0]
+		before[thread.Name] = olds[1:]
+		if old.State != thread.State {
+			diff.Changed = append(diff.Changed, ThreadChange{
+				Name:      thread.Name,
+				FromState: old.State,
+				ToState:   thread.State,
+				FromTop:   old.Top,
+				ToTop:     thread.Top,
+			})
+		}
+	}
+	for _, olds := range before {
+		diff.Ended = append(diff.Ended, olds...)
+	}
+	for state, delta := range diff.StateDeltas {
+		if delta == 0 {
+			delete(diff.StateDeltas, state)
+		}
+	}
+	byName := func(a, b Thread) int { return strings.Compare(a.Name, b.Name) }
+	slices.SortFunc(diff.Started, byName)
+	slices.SortFunc(diff.Ended, byName)
+	slices.SortFunc(diff.Changed, func(a, b ThreadChange) int { return strings.Compare(a.Name, b.Name) })
+	diff.Started = diff.Started[:min(len(diff.Started), maxDiffRows)]
+	diff.Ended = diff.Ended[:min(len(diff.Ended), maxDiffRows)]
+	diff.Changed = diff.Changed[:min(len(diff.Changed), maxDiffRows)]
+	return diff
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,181 @@
+// Package diagnostics parses the server's crash reports and JVM fatal error
+// logs, remembers which ones have been seen, and stores thread dumps and heap
+// histograms taken through the plugin so two of them can be compared.
+package diagnostics
+
+import (
+	"bufio"
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kinds of crash file.
+const (
+	// KindCrashReport is a Minecraft crash report in crash-reports/.
+	KindCrashReport = "crash_report"
+	// KindFatalError is a JVM hs_err_pid*.log fatal error log.
+	KindFatalError = "hs_err"
+)
+
+// Report is what was found in one crash file. Time is when the crash
+// happened according to the file, or its modification time when the file
+// does not say.
+type Report struct {
+	Path        string    `json:"path"`
+	Kind        string    `json:"kind"`
+	Size        int64     `json:"size"`
+	Modified    time.Time `json:"modified"`
+	DetectedAt  time.Time `json:"detected_at"`
+	Time        time.Time `json:"time"`
+	Description string    `json:"description,omitempty"`
+	// Exception is the exception line of a crash report, or the error line
+	// of a fatal error log, e.g. SIGSEGV (0xb) at pc=...
+	Exception string `json:"exception,omitempty"`
+	// Frame is the problematic native or Java frame of a fatal error log.
+	Frame string `json:"frame,omitempty"`
+	// Culprit is the plugin owning the first stack frame from a plugin's
+	// package, when there is one.
+	Culprit string `json:"culprit,omitempty"`
+	// Truncated is set when only the start of a large file was read.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// PluginPackage ties a plugin to the package its main class lives in, which
+// is how stack frames are attributed to it.
+type PluginPackage struct {
+	Name    string `json:"name"`
+	Package string `json:"package"`
+}
+
+// Layouts seen in the Time: line of crash reports across versions.
+var crashTimeLayouts = []string{
+	"2006-01-02 15:04:05.000-0700",
+	"2006-01-02 15:04:05-0700",
+	"2006-01-02 15:04:05.000",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04",
+	"1/2/06, 3:04 PM",
+	"1/2/06 3:04 PM",
+}
+
+// hs_err logs print the time as e.g. "Mon Jan 15 12:34:56 2024 UTC elapsed
+// time: ...".
+var fatalTimeLayouts = []string{
+	"Mon Jan _2 15:04:05 2006 MST",
+	"Mon Jan _2 15:04:05 2006",
+}
+
+var (
+	// javaFrame matches "at com.example.Foo.bar(Foo.java:10)" in crash
+	// reports and "j  com.example.Foo.bar()V+12" or
+	// "J 123 c2 com.example.Foo.bar()V (10 bytes)" in fatal error logs.
+	javaFrame = regexp.MustCompile(`^(?:at|j|J\s+\d+(?:\s+%)?\s+c[12]|J\s+\d+)\s+([\w$.]+)[.(]`)
+	// fatalHeader matches "#  SIGSEGV (0xb) at pc=..., pid=1, tid=2".
+	fatalHeader = regexp.MustCompile(`^#\s+((?:SIG\w+|EXCEPTION_\w+|Internal Error|Out of Memory Error)\b.*)$`)
+)
+
+// ParseCrashReport reads the fields of a Minecraft crash report.
+func ParseCrashReport(content string, plugins []PluginPackage) Report {
+	report := Report{Kind: KindCrashReport}
+	scanner := lines(content)
+	inStack := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case report.Time.IsZero() && strings.HasPrefix(line, "Time:"):
+			report.Time = parseTime(strings.TrimSpace(strings.TrimPrefix(line, "Time:")), crashTimeLayouts)
+		case report.Description == "" && strings.HasPrefix(line, "Description:"):
+			report.Description = strings.TrimSpace(strings.TrimPrefix(line, "Description:"))
+		case report.Description != "" && report.Exception == "" && line != "":
+			report.Exception = line
+			inStack = true
+		case inStack && line == "":
+			inStack = false
+		case inStack && report.Culprit == "":
+			report.Culprit = culprit(line, plugins)
+		}
+		if report.Exception != "" && (!inStack || report.Culprit != "") {
+			break
+		}
+	}
+	return report
+}
+
+// ParseFatalError reads the fields of a JVM hs_err fatal error log.
+func ParseFatalError(content string, plugins []PluginPackage) Report {
+	report := Report{Kind: KindFatalError}
+	scanner := lines(content)
+	frameNext := false
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		switch {
+		case frameNext:
+			report.Frame = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+			frameNext = false
+		case report.Exception == "" && fatalHeader.MatchString(raw):
+			report.Exception = fatalHeader.FindStringSubmatch(raw)[1]
+		case report.Exception == "" && strings.HasPrefix(line, "# There is insufficient memory"):
+			report.Exception = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		case report.Frame == "" && strings.HasPrefix(line, "# Problematic frame:"):
+			frameNext = true
+		case report.Time.IsZero() && strings.HasPrefix(line, "Time:"):
+			value := strings.TrimSpace(strings.TrimPrefix(line, "Time:"))
+			value, _, _ = strings.Cut(value, " elapsed time")
+			report.Time = parseTime(value, fatalTimeLayouts)
+		case report.Culprit == "":
+			report.Culprit = culprit(line, plugins)
+		}
+	}
+	if report.Description == "" && report.Exception != "" {
+		report.Description = "JVM fatal error"
+	}
+	return report
+}
+
+// culprit returns the plugin owning the stack frame on line, preferring the
+// most specific package.
+func culprit(line string, plugins []PluginPackage) string {
+	match := javaFrame.FindStringSubmatch(line)
+	if match == nil {
+		return ""
+	}
+	class := match[1]
+	best := ""
+	bestLen := 0
+	for _, plugin := range plugins {
+		pkg := plugin.Package
+		if pkg == "" || len(pkg) <= bestLen {
+			continue
+		}
+		if strings.HasPrefix(class, pkg+".") {
+			best, bestLen = plugin.Name, len(pkg)
+		}
+	}
+	return best
+}
+
+func parseTime(value string, layouts []string) time.Time {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+func lines(content string) *bufio.Scanner {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	return scanner
+}
+
+// sortReports orders reports newest first.
+func sortReports(reports []Report) {
+	slices.SortFunc(reports, func(a, b Report) int {
+		return cmp.Or(b.Time.Compare(a.Time), b.Modified.Compare(a.Modified), strings.Compare(a.Path, b.Path))
+	})
+}
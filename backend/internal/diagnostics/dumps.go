@@ -0,0 +1,252 @@
+package diagnostics
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/datafile"
+)
+
+// Kinds of dump taken from the running JVM.
+const (
+	DumpThreads       = "thread_dump"
+	DumpHeapHistogram = "heap_histogram"
+)
+
+// DefaultKeepDumps is how many dumps of each kind are kept when none is
+// configured.
+const DefaultKeepDumps = 20
+
+var (
+	ErrDumpNotFound = errors.New("dump not found")
+	ErrKindMismatch = errors.New("only dumps of the same kind can be compared")
+)
+
+// dumpID matches the IDs Save hands out, so an ID never names a path.
+var dumpID = regexp.MustCompile(`^[a-z_]+-\d{8}-\d{6}-[0-9a-f]{8}$`)
+
+// Dump describes a stored thread dump or heap histogram. Its text is kept in
+// a file of its own.
+type Dump struct {
+	ID      string      `json:"id"`
+	Kind    string      `json:"kind"`
+	TakenAt time.Time   `json:"taken_at"`
+	By      string      `json:"by"`
+	Size    int         `json:"size"`
+	Summary DumpSummary `json:"summary"`
+}
+
+// DumpSummary holds the headline numbers of a dump: thread counts by state
+// for a thread dump, totals for a heap histogram.
+type DumpSummary struct {
+	Threads      int            `json:"threads,omitempty"`
+	ThreadStates map[string]int `json:"thread_states,omitempty"`
+	Classes      int            `json:"classes,omitempty"`
+	Instances    int64          `json:"instances,omitempty"`
+	Bytes        int64          `json:"bytes,omitempty"`
+}
+
+// Dumps stores thread dumps and heap histograms under dumps/ with an index
+// in dumps.json. It is safe for concurrent use.
+type Dumps struct {
+	dir   string
+	index string
+	keep  int
+
+	mu    sync.RWMutex
+	dumps []Dump
+
+	persistMu sync.Mutex
+}
+
+// NewDumps keeps up to keep dumps of each kind inside dataDir.
+func NewDumps(dataDir string, keep int) *Dumps {
+	if keep <= 0 {
+		keep = DefaultKeepDumps
+	}
+	return &Dumps{
+		dir:   filepath.Join(dataDir, "dumps"),
+		index: filepath.Join(dataDir, "dumps.json"),
+		keep:  keep,
+	}
+}
+
+// Load reads the index of previously stored dumps.
+func (d *Dumps) Load() {
+	var dumps []Dump
+	found, err := datafile.Read(d.index, &dumps)
+	if err != nil {
+		log.Printf("beacon diagnostics: failed loading %s: %v", d.index, err)
+		return
+	}
+	if !found {
+		return
+	}
+	slices.SortFunc(dumps, func(a, b Dump) int { return a.TakenAt.Compare(b.TakenAt) })
+	d.mu.Lock()
+	d.dumps = dumps
+	d.mu.Unlock()
+}
+
+// Save stores a dump taken on behalf of by, dropping the oldest of its kind
+// beyond the limit.
+func (d *Dumps) Save(kind, by, content string) (Dump, error) {
+	var summary DumpSummary
+	switch kind {
+	case DumpThreads:
+		summary = summarizeThreads(ParseThreads(content))
+	case DumpHeapHistogram:
+		histogram := ParseHistogram(content)
+		summary = DumpSummary{Classes: len(histogram.Classes), Instances: histogram.Instances, Bytes: histogram.Bytes}
+	default:
+		return Dump{}, fmt.Errorf("unknown dump kind %q", kind)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return Dump{}, err
+	}
+	now := time.Now().UTC()
+	dump := Dump{
+		ID:      fmt.Sprintf("%s-%s-%s", kind, now.Format("20060102-150405"), hex.EncodeToString(suffix)),
+		Kind:    kind,
+		TakenAt: now,
+		By:      by,
+		Size:    len(content),
+		Summary: summary,
+	}
+	if err := datafile.Write(d.file(dump.ID), []byte(content)); err != nil {
+		return Dump{}, err
+	}
+
+	d.mu.Lock()
+	d.dumps = append(d.dumps, dump)
+	var dropped []string
+	if count := countKind(d.dumps, kind); count > d.keep {
+		excess := count - d.keep
+		d.dumps = slices.DeleteFunc(d.dumps, func(existing Dump) bool {
+			if excess > 0 && existing.Kind == kind {
+				excess--
+				dropped = append(dropped, existing.ID)
+				return true
+			}
+			return false
+		})
+	}
+	d.mu.Unlock()
+
+	for _, id := range dropped {
+		if err := os.Remove(d.file(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("beacon diagnostics: failed removing dump %s: %v", id, err)
+		}
+	}
+	d.save()
+	return dump, nil
+}
+
+// List returns the stored dumps of a kind, or of every kind when kind is
+// empty, newest first.
+func (d *Dumps) List(kind string) []Dump {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	out := []Dump{}
+	for i := len(d.dumps) - 1; i >= 0; i-- {
+		if kind == "" || d.dumps[i].Kind == kind {
+			out = append(out, d.dumps[i])
+		}
+	}
+	return out
+}
+
+// Get returns a stored dump and its text.
+func (d *Dumps) Get(id string) (Dump, string, error) {
+	dump, ok := d.find(id)
+	if !ok {
+		return Dump{}, "", ErrDumpNotFound
+	}
+	content, err := os.ReadFile(d.file(dump.ID))
+	if errors.Is(err, os.ErrNotExist) {
+		return Dump{}, "", ErrDumpNotFound
+	}
+	if err != nil {
+		return Dump{}, "", err
+	}
+	return dump, string(content), nil
+}
+
+// Diff compares two stored dumps of the same kind, from the older to the
+// newer. The result is a *HistogramDiff or a *ThreadDiff.
+func (d *Dumps) Diff(fromID, toID string) (any, error) {
+	from, fromContent, err := d.Get(fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, toContent, err := d.Get(toID)
+	if err != nil {
+		return nil, err
+	}
+	if from.Kind != to.Kind {
+		return nil, ErrKindMismatch
+	}
+	if to.TakenAt.Before(from.TakenAt) {
+		from, to = to, from
+		fromContent, toContent = toContent, fromContent
+	}
+	if from.Kind == DumpHeapHistogram {
+		return DiffHistograms(from, to, ParseHistogram(fromContent), ParseHistogram(toContent)), nil
+	}
+	return DiffThreads(from, to, ParseThreads(fromContent), ParseThreads(toContent)), nil
+}
+
+func (d *Dumps) find(id string) (Dump, bool) {
+	if !dumpID.MatchString(id) {
+		return Dump{}, false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	i := slices.IndexFunc(d.dumps, func(dump Dump) bool { return dump.ID == id })
+	if i < 0 {
+		return Dump{}, false
+	}
+	return d.dumps[i], true
+}
+
+func (d *Dumps) file(id string) string {
+	return filepath.Join(d.dir, id+".txt")
+}
+
+func countKind(dumps []Dump, kind string) int {
+	count := 0
+	for _, dump := range dumps {
+		if dump.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+func (d *Dumps) save() {
+	d.persistMu.Lock()
+	defer d.persistMu.Unlock()
+
+	d.mu.RLock()
+	data, err := json.Marshal(d.dumps)
+	d.mu.RUnlock()
+	if err != nil {
+		log.Printf("beacon diagnostics: failed encoding dump index: %v", err)
+		return
+	}
+	if err := datafile.Write(d.index, data); err != nil {
+		log.Printf("beacon diagnostics: failed writing %s: %v", d.index, err)
+	}
+}
@@ -0,0 +1,344 @@
+package diagnostics
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/datafile"
+)
+
+// DefaultKeepProfiles is how many profiles are kept when none is configured.
+const DefaultKeepProfiles = 20
+
+const (
+	// minFrameShare is the smallest share of busy samples a frame needs to
+	// stay in a stored flame graph.
+	minFrameShare = 0.001
+	// maxShares bounds how many plugins, events or worlds a profile lists.
+	maxShares = 50
+)
+
+var ErrProfileNotFound = errors.New("profile not found")
+
+// profileID matches the IDs Save hands out, so an ID never names a
+// path.
+var profileID = regexp.MustCompile(`^profile-\d{8}-\d{6}-[0-9a-f]{8}$`)
+
+// FrameNode is one stack frame of a flame graph: Value is how many samples
+// were in it or its callees. The shape is the one d3-flame-graph expects.
+type FrameNode struct {
+	Name     string       `json:"name"`
+	Value    int          `json:"value"`
+	Children []*FrameNode `json:"children,omitempty"`
+}
+
+// ProfileReport is what the plugin returns when a profile stops. Samples
+// counts the main thread's busy samples; samples taken while it waited for
+// the next tick are IdleSamples and appear nowhere else. Plugins, Events and
+// WorldSamples count busy samples by the plugin whose code was running, the
+// event being handled and whether a world was ticking. WorldWeights are each
+// world's entities and tile entities when the profile stopped.
+type ProfileReport struct {
+	StartedAt    time.Time      `json:"started_at"`
+	IntervalMS   int            `json:"interval_ms"`
+	Samples      int            `json:"samples"`
+	IdleSamples  int            `json:"idle_samples"`
+	TickTimes    []float64      `json:"tick_times"`
+	Plugins      map[string]int `json:"plugins"`
+	Events       map[string]int `json:"events"`
+	WorldSamples int            `json:"world_samples"`
+	WorldWeights map[string]int `json:"world_weights"`
+	Tree         FrameNode      `json:"tree"`
+}
+
+// Share is one plugin's, event's or world's part of the busy samples.
+type Share struct {
+	Name    string  `json:"name"`
+	Samples int     `json:"samples"`
+	Percent float64 `json:"percent"`
+}
+
+// MSPT summarises the tick durations, in milliseconds, seen during a
+// profile.
+type MSPT struct {
+	Ticks int     `json:"ticks"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// Profile is a finished sampling profile of the server's main thread.
+// Worlds splits the samples spent ticking worlds by each world's share of
+// entities and tile entities, as the samples do not say which world was
+// ticking, so it is an estimate. Tree is left out of listings.
+type Profile struct {
+	ID          string     `json:"id"`
+	StartedAt   time.Time  `json:"started_at"`
+	EndedAt     time.Time  `json:"ended_at"`
+	By          string     `json:"by"`
+	IntervalMS  int        `json:"interval_ms"`
+	Samples     int        `json:"samples"`
+	IdleSamples int        `json:"idle_samples"`
+	MSPT        MSPT       `json:"mspt"`
+	Plugins     []Share    `json:"plugins"`
+	Events      []Share    `json:"events"`
+	Worlds      []Share    `json:"worlds"`
+	Tree        *FrameNode `json:"tree,omitempty"`
+}
+
+// BuildProfile turns a plugin's report into a profile: shares sorted
+// largest first, tick percentiles and a flame graph pruned of frames too
+// small to see.
+func BuildProfile(report ProfileReport, by string, endedAt time.Time) Profile {
+	profile := Profile{
+		StartedAt:   report.StartedAt,
+		EndedAt:     endedAt,
+		By:          by,
+		IntervalMS:  report.IntervalMS,
+		Samples:     report.Samples,
+		IdleSamples: report.IdleSamples,
+		MSPT:        summarizeTicks(report.TickTimes),
+		Plugins:     shares(report.Plugins, report.Samples),
+		Events:      shares(report.Events, report.Samples),
+		Worlds:      shares(splitByWeight(report.WorldSamples, report.WorldWeights), report.Samples),
+	}
+	if profile.StartedAt.IsZero() {
+		profile.StartedAt = endedAt
+	}
+	tree := report.Tree
+	tree.Name = cmp.Or(tree.Name, "root")
+	tree.Value = report.Samples
+	pruneFrames(&tree, max(1, int(math.Ceil(float64(report.Samples)*minFrameShare))))
+	profile.Tree = &tree
+	return profile
+}
+
+func summarizeTicks(times []float64) MSPT {
+	if len(times) == 0 {
+		return MSPT{}
+	}
+	sorted := slices.Clone(times)
+	slices.Sort(sorted)
+	var total float64
+	for _, t := range sorted {
+		total += t
+	}
+	return MSPT{
+		Ticks: len(sorted),
+		Mean:  round2(total / float64(len(sorted))),
+		P50:   round2(percentile(sorted, 50)),
+		P95:   round2(percentile(sorted, 95)),
+		P99:   round2(percentile(sorted, 99)),
+		Max:   round2(sorted[len(sorted)-1]),
+	}
+}
+
+// percentile is the nearest-rank percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
+
+func shares(counts map[string]int, total int) []Share {
+	out := []Share{}
+	for name, samples := range counts {
+		if samples <= 0 {
+			continue
+		}
+		share := Share{Name: name, Samples: samples}
+		if total > 0 {
+			share.Percent = round2(100 * float64(samples) / float64(total))
+		}
+		out = append(out, share)
+	}
+	slices.SortFunc(out, func(a, b Share) int {
+		return cmp.Or(cmp.Compare(b.Samples, a.Samples), strings.Compare(a.Name, b.Name))
+	})
+	return out[:min(len(out), maxShares)]
+}
+
+// splitByWeight shares samples out in proportion to weights.
+func splitByWeight(samples int, weights map[string]int) map[string]int {
+	var total int
+	for _, weight := range weights {
+		total += max(weight, 0)
+	}
+	out := make(map[string]int, len(weights))
+	if samples <= 0 || total == 0 {
+		return out
+	}
+	for name, weight := range weights {
+		out[name] = int(math.Round(float64(samples) * float64(max(weight, 0)) / float64(total)))
+	}
+	return out
+}
+
+// pruneFrames drops frames with fewer than minSamples samples, whose time is
+// left to their caller, and orders the rest largest first.
+func pruneFrames(node *FrameNode, minSamples int) {
+	node.Children = slices.DeleteFunc(node.Children, func(child *FrameNode) bool {
+		return child == nil || child.Value < minSamples
+	})
+	slices.SortFunc(node.Children, func(a, b *FrameNode) int {
+		return cmp.Or(cmp.Compare(b.Value, a.Value), strings.Compare(a.Name, b.Name))
+	})
+	for _, child := range node.Children {
+		pruneFrames(child, minSamples)
+	}
+	if len(node.Children) == 0 {
+		node.Children = nil
+	}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// Profiles stores finished profiles under profiles/ with an index in
+// profiles.json. It is safe for concurrent use.
+type Profiles struct {
+	dir   string
+	index string
+	keep  int
+
+	mu       sync.RWMutex
+	profiles []Profile
+
+	persistMu sync.Mutex
+}
+
+// NewProfiles keeps up to keep profiles inside dataDir.
+func NewProfiles(dataDir string, keep int) *Profiles {
+	if keep <= 0 {
+		keep = DefaultKeepProfiles
+	}
+	return &Profiles{
+		dir:   filepath.Join(dataDir, "profiles"),
+		index: filepath.Join(dataDir, "profiles.json"),
+		keep:  keep,
+	}
+}
+
+// Load reads the index of previously stored profiles.
+func (p *Profiles) Load() {
+	var profiles []Profile
+	found, err := datafile.Read(p.index, &profiles)
+	if err != nil {
+		log.Printf("beacon diagnostics: failed loading %s: %v", p.index, err)
+		return
+	}
+	if !found {
+		return
+	}
+	slices.SortFunc(profiles, func(a, b Profile) int { return a.EndedAt.Compare(b.EndedAt) })
+	p.mu.Lock()
+	p.profiles = profiles
+	p.mu.Unlock()
+}
+
+// Save stores a profile, dropping the oldest beyond the limit, and returns
+// it with its ID.
+func (p *Profiles) Save(profile Profile) (Profile, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return Profile{}, err
+	}
+	profile.ID = fmt.Sprintf("profile-%s-%s", profile.EndedAt.UTC().Format("20060102-150405"), hex.EncodeToString(suffix))
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return Profile{}, err
+	}
+	if err := datafile.Write(p.file(profile.ID), data); err != nil {
+		return Profile{}, err
+	}
+
+	summary := profile
+	summary.Tree = nil
+	p.mu.Lock()
+	p.profiles = append(p.profiles, summary)
+	var dropped []string
+	if over := len(p.profiles) - p.keep; over > 0 {
+		for _, old := range p.profiles[:over] {
+			dropped = append(dropped, old.ID)
+		}
+		p.profiles = slices.Clone(p.profiles[over:])
+	}
+	p.mu.Unlock()
+
+	for _, id := range dropped {
+		if err := os.Remove(p.file(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("beacon diagnostics: failed removing profile %s: %v", id, err)
+		}
+	}
+	p.save()
+	return profile, nil
+}
+
+// List returns the stored profiles without their flame graphs, newest
+// first.
+func (p *Profiles) List() []Profile {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make([]Profile, 0, len(p.profiles))
+	for i := len(p.profiles) - 1; i >= 0; i-- {
+		out = append(out, p.profiles[i])
+	}
+	return out
+}
+
+// Get returns a stored profile with its flame graph.
+func (p *Profiles) Get(id string) (Profile, error) {
+	if !profileID.MatchString(id) {
+		return Profile{}, ErrProfileNotFound
+	}
+	p.mu.RLock()
+	known := slices.ContainsFunc(p.profiles, func(profile Profile) bool { return profile.ID == id })
+	p.mu.RUnlock()
+	if !known {
+		return Profile{}, ErrProfileNotFound
+	}
+	var profile Profile
+	found, err := datafile.Read(p.file(id), &profile)
+	if err != nil {
+		return Profile{}, err
+	}
+	if !found {
+		return Profile{}, ErrProfileNotFound
+	}
+	return profile, nil
+}
+
+func (p *Profiles) file(id string) string {
+	return filepath.Join(p.dir, id+".json")
+}
+
+func (p *Profiles) save() {
+	p.persistMu.Lock()
+	defer p.persistMu.Unlock()
+
+	p.mu.RLock()
+	data, err := json.Marshal(p.profiles)
+	p.mu.RUnlock()
+	if err != nil {
+		log.Printf("beacon diagnostics: failed encoding profile index: %v", err)
+		return
+	}
+	if err := datafile.Write(p.index, data); err != nil {
+		log.Printf("beacon diagnostics: failed writing %s: %v", p.index, err)
+	}
+}
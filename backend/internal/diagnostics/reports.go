@@ -0,0 +1,175 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/datafile"
+)
+
+// DefaultKeepReports is how many reports are remembered when none is
+// configured.
+const DefaultKeepReports = 100
+
+// File is a crash file the plugin found on the server.
+type File struct {
+	Path     string    `json:"path"`
+	Kind     string    `json:"kind"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"mod_time"`
+}
+
+// Reports remembers parsed crash files in crash_reports.json, saving after
+// every change. It is safe for concurrent use.
+type Reports struct {
+	path string
+	keep int
+
+	mu      sync.RWMutex
+	reports []Report
+	// seen holds the modification time of every crash file parsed that is
+	// still on the server, including ones trimmed from reports, so they are
+	// not parsed again.
+	seen map[string]time.Time
+	// seeded is set once a scan has been recorded, so files already on the
+	// server the first time are not announced as new crashes.
+	seeded bool
+
+	persistMu sync.Mutex
+}
+
+type reportsFile struct {
+	Seeded  bool                 `json:"seeded"`
+	Seen    map[string]time.Time `json:"seen"`
+	Reports []Report             `json:"reports"`
+}
+
+// NewReports keeps up to keep reports in crash_reports.json inside dataDir.
+func NewReports(dataDir string, keep int) *Reports {
+	if keep <= 0 {
+		keep = DefaultKeepReports
+	}
+	return &Reports{
+		path: filepath.Join(dataDir, "crash_reports.json"),
+		keep: keep,
+		seen: make(map[string]time.Time),
+	}
+}
+
+// Load reads previously saved reports.
+func (r *Reports) Load() {
+	var file reportsFile
+	found, err := datafile.Read(r.path, &file)
+	if err != nil {
+		log.Printf("beacon diagnostics: failed loading %s: %v", r.path, err)
+		return
+	}
+	if !found {
+		return
+	}
+	sortReports(file.Reports)
+	r.mu.Lock()
+	r.reports = trimReports(file.Reports, r.keep)
+	r.seeded = file.Seeded
+	if file.Seen != nil {
+		r.seen = file.Seen
+	}
+	r.mu.Unlock()
+}
+
+// Unseen returns the files that have not been parsed yet, or have changed
+// since they were.
+func (r *Reports) Unseen(files []File) []File {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []File
+	for _, file := range files {
+		if modified, ok := r.seen[file.Path]; ok && modified.Equal(file.Modified) {
+			continue
+		}
+		out = append(out, file)
+	}
+	return out
+}
+
+// Record stores the reports parsed from a scan that listed files. Files no
+// longer on the server are forgotten, but their reports are kept. It returns
+// the reports of files not seen before that should be announced: none on
+// the first scan, which only takes stock of what is already on the server.
+func (r *Reports) Record(files []File, found []Report) []Report {
+	r.mu.Lock()
+	announce := r.seeded
+	r.seeded = true
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[file.Path] = true
+	}
+	for path := range r.seen {
+		if !present[path] {
+			delete(r.seen, path)
+		}
+	}
+	var fresh []Report
+	for _, report := range found {
+		if _, ok := r.seen[report.Path]; !ok {
+			fresh = append(fresh, report)
+		}
+		r.seen[report.Path] = report.Modified
+	}
+	byPath := make(map[string]int, len(r.reports))
+	for i, report := range r.reports {
+		byPath[report.Path] = i
+	}
+	for _, report := range found {
+		if i, ok := byPath[report.Path]; ok {
+			r.reports[i] = report
+			continue
+		}
+		byPath[report.Path] = len(r.reports)
+		r.reports = append(r.reports, report)
+	}
+	sortReports(r.reports)
+	r.reports = trimReports(r.reports, r.keep)
+	r.mu.Unlock()
+	r.save()
+
+	if !announce {
+		return nil
+	}
+	return fresh
+}
+
+// List returns the remembered reports, newest first.
+func (r *Reports) List() []Report {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Report, len(r.reports))
+	copy(out, r.reports)
+	return out
+}
+
+func trimReports(reports []Report, keep int) []Report {
+	if len(reports) > keep {
+		return reports[:keep]
+	}
+	return reports
+}
+
+func (r *Reports) save() {
+	r.persistMu.Lock()
+	defer r.persistMu.Unlock()
+
+	r.mu.RLock()
+	data, err := json.Marshal(reportsFile{Seeded: r.seeded, Seen: r.seen, Reports: r.reports})
+	r.mu.RUnlock()
+	if err != nil {
+		log.Printf("beacon diagnostics: failed encoding crash reports: %v", err)
+		return
+	}
+	if err := datafile.Write(r.path, data); err != nil {
+		log.Printf("beacon diagnostics: failed writing %s: %v", r.path, err)
+	}
+}
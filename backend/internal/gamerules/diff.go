@@ -0,0 +1,43 @@
+package gamerules
+
+import (
+	"slices"
+	"strings"
+)
+
+// Difference is one rule whose current value is not the expected one.
+// Current is empty when the world does not have the rule at all.
+type Difference struct {
+	Rule     string `json:"rule"`
+	Current  string `json:"current"`
+	Expected string `json:"expected"`
+	Default  string `json:"default,omitempty"`
+}
+
+// Diff lists the rules in expected whose value in current differs, sorted by
+// rule. Values are compared ignoring case, so "TRUE" matches "true".
+func Diff(current, expected, defaults map[string]string) []Difference {
+	out := make([]Difference, 0)
+	for rule, want := range expected {
+		have := current[rule]
+		if strings.EqualFold(have, want) {
+			continue
+		}
+		out = append(out, Difference{Rule: rule, Current: have, Expected: want, Default: defaults[rule]})
+	}
+	slices.SortFunc(out, func(a, b Difference) int { return strings.Compare(a.Rule, b.Rule) })
+	return out
+}
+
+// Changed returns the rules in current that differ from defaults, e.g. to save
+// a world's customizations as a preset. Rules without a known default are
+// left out.
+func Changed(current, defaults map[string]string) map[string]string {
+	out := make(map[string]string)
+	for rule, value := range current {
+		if def, ok := defaults[rule]; ok && !strings.EqualFold(def, value) {
+			out[rule] = value
+		}
+	}
+	return out
+}
@@ -0,0 +1,151 @@
+// Package gamerules keeps named gamerule presets and compares worlds'
+// gamerules against them or against the server defaults.
+package gamerules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/datafile"
+)
+
+// MaxRules bounds how many rules one preset or bulk apply may set.
+const MaxRules = 200
+
+// ErrInvalidPreset is returned when a preset fails validation.
+var ErrInvalidPreset = errors.New("invalid gamerule preset")
+
+var presetNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Preset is a named set of gamerule values.
+type Preset struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Rules       map[string]string `json:"rules"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	UpdatedBy   string            `json:"updated_by,omitempty"`
+}
+
+// Presets stores presets in gamerule_presets.json, saving on every change.
+// It is safe for concurrent use.
+type Presets struct {
+	path string
+
+	mu      sync.RWMutex
+	presets map[string]Preset
+
+	persistMu sync.Mutex
+}
+
+// NewPresets keeps its presets in gamerule_presets.json inside dataDir.
+func NewPresets(dataDir string) *Presets {
+	return &Presets{
+		path:    filepath.Join(dataDir, "gamerule_presets.json"),
+		presets: make(map[string]Preset),
+	}
+}
+
+// Load reads previously saved presets.
+func (p *Presets) Load() {
+	var presets []Preset
+	found, err := datafile.Read(p.path, &presets)
+	if err != nil {
+		log.Printf("beacon gamerules: failed loading %s: %v", p.path, err)
+		return
+	}
+	if !found {
+		return
+	}
+	p.mu.Lock()
+	for _, preset := range presets {
+		p.presets[strings.ToLower(preset.Name)] = preset
+	}
+	p.mu.Unlock()
+}
+
+// List returns every preset sorted by name.
+func (p *Presets) List() []Preset {
+	p.mu.RLock()
+	out := make([]Preset, 0, len(p.presets))
+	for _, preset := range p.presets {
+		out = append(out, preset)
+	}
+	p.mu.RUnlock()
+	slices.SortFunc(out, func(a, b Preset) int { return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) })
+	return out
+}
+
+// Get looks a preset up by name, ignoring case.
+func (p *Presets) Get(name string) (Preset, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	preset, ok := p.presets[strings.ToLower(strings.TrimSpace(name))]
+	return preset, ok
+}
+
+// Put validates and stores a preset, replacing any with the same name.
+// validRule reports a problem with one rule, or nil if it is acceptable.
+func (p *Presets) Put(preset Preset, validRule func(rule, value string) error) (Preset, error) {
+	preset.Name = strings.TrimSpace(preset.Name)
+	preset.Description = strings.TrimSpace(preset.Description)
+	if !presetNamePattern.MatchString(preset.Name) {
+		return Preset{}, fmt.Errorf("%w: names are 1-32 letters, digits, _ or -", ErrInvalidPreset)
+	}
+	if len(preset.Description) > 200 {
+		return Preset{}, fmt.Errorf("%w: description is longer than 200 characters", ErrInvalidPreset)
+	}
+	if len(preset.Rules) == 0 || len(preset.Rules) > MaxRules {
+		return Preset{}, fmt.Errorf("%w: a preset sets between 1 and %d rules", ErrInvalidPreset, MaxRules)
+	}
+	rules := make(map[string]string, len(preset.Rules))
+	for rule, value := range preset.Rules {
+		rule, value = strings.TrimSpace(rule), strings.TrimSpace(value)
+		if err := validRule(rule, value); err != nil {
+			return Preset{}, fmt.Errorf("%w: %v", ErrInvalidPreset, err)
+		}
+		rules[rule] = value
+	}
+	preset.Rules = rules
+	preset.UpdatedAt = time.Now()
+
+	p.mu.Lock()
+	p.presets[strings.ToLower(preset.Name)] = preset
+	p.mu.Unlock()
+	p.save()
+	return preset, nil
+}
+
+// Delete removes a preset and reports whether it existed.
+func (p *Presets) Delete(name string) bool {
+	key := strings.ToLower(strings.TrimSpace(name))
+	p.mu.Lock()
+	_, ok := p.presets[key]
+	delete(p.presets, key)
+	p.mu.Unlock()
+	if ok {
+		p.save()
+	}
+	return ok
+}
+
+func (p *Presets) save() {
+	p.persistMu.Lock()
+	defer p.persistMu.Unlock()
+
+	data, err := json.MarshalIndent(p.List(), "", "  ")
+	if err != nil {
+		log.Printf("beacon gamerules: failed encoding presets: %v", err)
+		return
+	}
+	if err := datafile.Write(p.path, data); err != nil {
+		log.Printf("beacon gamerules: failed writing %s: %v", p.path, err)
+	}
+}
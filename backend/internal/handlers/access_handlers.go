@@ -39,6 +39,10 @@ func panelPermissionCategories() []accessPermissionCategory {
 				{Node: "beacon.access.stop", Label: "Stop Server"},
 				{Node: "beacon.access.restart", Label: "Restart Server"},
 				{Node: "beacon.access.saveall", Label: "Save All"},
+				{Node: "beacon.access.process", Label: "Start, Stop & Kill Server Process"},
+				{Node: "beacon.access.diagnostics.view", Label: "View Crash Reports & Dumps"},
+				{Node: "beacon.access.diagnostics.capture", Label: "Take Thread Dumps & Heap Histograms"},
+				{Node: "beacon.access.diagnostics.profile", Label: "Run the Sampling Profiler"},
 			},
 		},
 		{
@@ -58,6 +62,21 @@ func panelPermissionCategories() []accessPermissionCategory {
 				{Node: "beacon.access.players.view", Label: "View Players"},
 				{Node: "beacon.access.players.kick", Label: "Kick Players"},
 				{Node: "beacon.access.players.ban", Label: "Ban Players"},
+				{Node: "beacon.access.players.tempban", Label: "Temp-Ban Players"},
+				{Node: "beacon.access.players.ipban", Label: "IP-Ban Players"},
+				{Node: "beacon.access.players.unban", Label: "Unban Players"},
+				{Node: "beacon.access.players.mute", Label: "Mute Players"},
+				{Node: "beacon.access.players.warn", Label: "Warn Players"},
+				{Node: "beacon.access.players.notes", Label: "Write Staff Notes"},
+				{Node: "beacon.access.players.whitelist", Label: "View Whitelist"},
+				{Node: "beacon.access.players.whitelist.add", Label: "Add to Whitelist"},
+				{Node: "beacon.access.players.whitelist.remove", Label: "Remove from Whitelist"},
+				{Node: "beacon.access.players.whitelist.toggle", Label: "Toggle Whitelist"},
+				{Node: "beacon.access.players.whitelist.import", Label: "Bulk Import Whitelist"},
+				{Node: "beacon.access.players.ops", Label: "View Operators"},
+				{Node: "beacon.access.players.ips", Label: "View Player IPs (Not in Pack)"},
+				{Node: "beacon.access.players.ops.add", Label: "Grant Operator (Not in Pack)"},
+				{Node: "beacon.access.players.ops.remove", Label: "Revoke Operator (Not in Pack)"},
 			},
 		},
 		{
@@ -69,6 +88,10 @@ func panelPermissionCategories() []accessPermissionCategory {
 				{Node: "beacon.access.worlds.manage", Label: "Manage Worlds"},
 				{Node: "beacon.access.worlds.reset", Label: "Reset Worlds"},
 				{Node: "beacon.access.worlds.gamerules", Label: "Edit Gamerules"},
+				{Node: "beacon.access.worlds.create", Label: "Create Worlds"},
+				{Node: "beacon.access.worlds.import", Label: "Import Worlds"},
+				{Node: "beacon.access.worlds.clone", Label: "Clone Worlds"},
+				{Node: "beacon.access.worlds.delete", Label: "Delete Worlds (Not in Pack)"},
 			},
 		},
 		{
@@ -83,6 +106,17 @@ func panelPermissionCategories() []accessPermissionCategory {
 				{Node: "beacon.access.files.download", Label: "Download Files"},
 			},
 		},
+		{
+			ID:    "plugins",
+			Label: "Plugins",
+			Permissions: []accessPermissionCheckbox{
+				{Node: "beacon.access.plugins", Label: "Plugins Pack"},
+				{Node: "beacon.access.plugins.view", Label: "View Plugins"},
+				{Node: "beacon.access.plugins.toggle", Label: "Enable & Disable Plugins"},
+				{Node: "beacon.access.plugins.reload", Label: "Reload Plugins"},
+				{Node: "beacon.access.plugins.upload", Label: "Upload Plugin Jars (Not in Pack)"},
+			},
+		},
 	}
 }
 
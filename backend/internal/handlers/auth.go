@@ -14,9 +14,12 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/adammcgrogan/beacon/internal/config"
 )
 
 const (
@@ -26,6 +29,7 @@ const (
 	PermPackPlayers          = "beacon.access.players"
 	PermPackWorlds           = "beacon.access.worlds"
 	PermPackFiles            = "beacon.access.files"
+	PermPackPlugins          = "beacon.access.plugins"
 	PermAccessView           = "beacon.access.access"
 	PermAccessManage         = "beacon.access.access.manage"
 	PermDashboardView        = "beacon.access.dashboard.view"
@@ -34,13 +38,40 @@ const (
 	PermPlayersView          = "beacon.access.players.view"
 	PermPlayersKick          = "beacon.access.players.kick"
 	PermPlayersBan           = "beacon.access.players.ban"
+	PermPlayersTempBan       = "beacon.access.players.tempban"
+	PermPlayersIPBan         = "beacon.access.players.ipban"
+	PermPlayersUnban         = "beacon.access.players.unban"
+	PermPlayersMute          = "beacon.access.players.mute"
+	PermPlayersWarn          = "beacon.access.players.warn"
+	PermPlayersIPs           = "beacon.access.players.ips"
+	PermPlayersNotes         = "beacon.access.players.notes"
+	PermWhitelistView        = "beacon.access.players.whitelist"
+	PermWhitelistAdd         = "beacon.access.players.whitelist.add"
+	PermWhitelistRemove      = "beacon.access.players.whitelist.remove"
+	PermWhitelistToggle      = "beacon.access.players.whitelist.toggle"
+	PermWhitelistImport      = "beacon.access.players.whitelist.import"
+	PermOpsView              = "beacon.access.players.ops"
+	PermOpsAdd               = "beacon.access.players.ops.add"
+	PermOpsRemove            = "beacon.access.players.ops.remove"
 	PermWorldsView           = "beacon.access.worlds.view"
 	PermWorldsManage         = "beacon.access.worlds.manage"
 	PermWorldsReset          = "beacon.access.worlds.reset"
 	PermWorldsGamerules      = "beacon.access.worlds.gamerules"
+	PermWorldsCreate         = "beacon.access.worlds.create"
+	PermWorldsImport         = "beacon.access.worlds.import"
+	PermWorldsClone          = "beacon.access.worlds.clone"
+	PermWorldsDelete         = "beacon.access.worlds.delete"
 	PermServerStop           = "beacon.access.stop"
 	PermServerRestart        = "beacon.access.restart"
 	PermServerSaveAll        = "beacon.access.saveall"
+	PermServerProcess        = "beacon.access.process"
+	PermDiagnosticsView      = "beacon.access.diagnostics.view"
+	PermDiagnosticsCapture   = "beacon.access.diagnostics.capture"
+	PermDiagnosticsProfile   = "beacon.access.diagnostics.profile"
+	PermPluginsView          = "beacon.access.plugins.view"
+	PermPluginsToggle        = "beacon.access.plugins.toggle"
+	PermPluginsReload        = "beacon.access.plugins.reload"
+	PermPluginsUpload        = "beacon.access.plugins.upload"
 	PermFilesAll             = "beacon.access.files.all"
 	PermFilesView            = "beacon.access.files.view"
 	PermFilesEdit            = "beacon.access.files.edit"
@@ -58,25 +89,52 @@ type SessionClaims struct {
 }
 
 type SessionGrants struct {
-	CanViewDashboard bool `json:"can_view_dashboard"`
-	CanViewConsole   bool `json:"can_view_console"`
-	CanUseConsole    bool `json:"can_use_console"`
-	CanViewPlayers   bool `json:"can_view_players"`
-	CanKickPlayers   bool `json:"can_kick_players"`
-	CanBanPlayers    bool `json:"can_ban_players"`
-	CanViewWorlds    bool `json:"can_view_worlds"`
-	CanManageWorlds  bool `json:"can_manage_worlds"`
-	CanResetWorlds   bool `json:"can_reset_worlds"`
-	CanEditGamerules bool `json:"can_edit_gamerules"`
-	CanStopServer    bool `json:"can_stop_server"`
-	CanRestartServer bool `json:"can_restart_server"`
-	CanSaveAll       bool `json:"can_save_all"`
-	CanViewFiles     bool `json:"can_view_files"`
-	CanEditFiles     bool `json:"can_edit_files"`
-	CanDeleteFiles   bool `json:"can_delete_files"`
-	CanDownloadFiles bool `json:"can_download_files"`
-	CanViewAccess    bool `json:"can_view_access"`
-	CanManageAccess  bool `json:"can_manage_access"`
+	CanViewDashboard   bool `json:"can_view_dashboard"`
+	CanViewConsole     bool `json:"can_view_console"`
+	CanUseConsole      bool `json:"can_use_console"`
+	CanViewPlayers     bool `json:"can_view_players"`
+	CanKickPlayers     bool `json:"can_kick_players"`
+	CanBanPlayers      bool `json:"can_ban_players"`
+	CanTempBan         bool `json:"can_tempban_players"`
+	CanIPBan           bool `json:"can_ipban_players"`
+	CanUnban           bool `json:"can_unban_players"`
+	CanMutePlayers     bool `json:"can_mute_players"`
+	CanWarnPlayers     bool `json:"can_warn_players"`
+	CanViewIPs         bool `json:"can_view_player_ips"`
+	CanEditNotes       bool `json:"can_edit_player_notes"`
+	CanViewWhitelist   bool `json:"can_view_whitelist"`
+	CanAddWhitelist    bool `json:"can_add_whitelist"`
+	CanRemoveWhitelist bool `json:"can_remove_whitelist"`
+	CanToggleWhitelist bool `json:"can_toggle_whitelist"`
+	CanImportWhitelist bool `json:"can_import_whitelist"`
+	CanViewOps         bool `json:"can_view_ops"`
+	CanAddOps          bool `json:"can_add_ops"`
+	CanRemoveOps       bool `json:"can_remove_ops"`
+	CanViewWorlds      bool `json:"can_view_worlds"`
+	CanManageWorlds    bool `json:"can_manage_worlds"`
+	CanResetWorlds     bool `json:"can_reset_worlds"`
+	CanEditGamerules   bool `json:"can_edit_gamerules"`
+	CanCreateWorlds    bool `json:"can_create_worlds"`
+	CanImportWorlds    bool `json:"can_import_worlds"`
+	CanCloneWorlds     bool `json:"can_clone_worlds"`
+	CanDeleteWorlds    bool `json:"can_delete_worlds"`
+	CanStopServer      bool `json:"can_stop_server"`
+	CanRestartServer   bool `json:"can_restart_server"`
+	CanSaveAll         bool `json:"can_save_all"`
+	CanControlProcess  bool `json:"can_control_process"`
+	CanViewDiagnostics bool `json:"can_view_diagnostics"`
+	CanCaptureDumps    bool `json:"can_capture_dumps"`
+	CanRunProfiler     bool `json:"can_run_profiler"`
+	CanViewPlugins     bool `json:"can_view_plugins"`
+	CanTogglePlugins   bool `json:"can_toggle_plugins"`
+	CanReloadPlugins   bool `json:"can_reload_plugins"`
+	CanUploadPlugins   bool `json:"can_upload_plugins"`
+	CanViewFiles       bool `json:"can_view_files"`
+	CanEditFiles       bool `json:"can_edit_files"`
+	CanDeleteFiles     bool `json:"can_delete_files"`
+	CanDownloadFiles   bool `json:"can_download_files"`
+	CanViewAccess      bool `json:"can_view_access"`
+	CanManageAccess    bool `json:"can_manage_access"`
 }
 
 type AuthManager struct {
@@ -87,12 +145,17 @@ type AuthManager struct {
 	sessions    map[string]webSession
 	users       map[string]knownUser
 
-	sessionTTL  time.Duration
-	permTTL     time.Duration
-	cookieName  string
-	signingKey  []byte
-	statePath   string
-	stateLoaded bool
+	sessionTTL     time.Duration
+	permTTL        time.Duration
+	cookieName     string
+	signingKey     []byte
+	statePath      string
+	stateLoaded    bool
+	allowedOrigins map[string]struct{}
+	secureCookies  bool
+
+	persistMu sync.Mutex     // serializes writes of the state file
+	persistWG sync.WaitGroup // tracks background persists so shutdown can wait for them
 }
 
 type magicToken struct {
@@ -123,6 +186,10 @@ type knownUser struct {
 	PlayerName string
 	FirstSeen  time.Time
 	LastSeen   time.Time
+	// Permissions are the last the plugin reported. Only
+	// OfflineProcessPermissions reads them, so a stopped server can still be
+	// started from the panel.
+	Permissions []string
 }
 
 type persistedAuthState struct {
@@ -138,22 +205,22 @@ var (
 	ErrNoSession    = errors.New("session missing")
 )
 
-func NewAuthManager() *AuthManager {
+func NewAuthManager(cfg config.Config) *AuthManager {
 	key := make([]byte, 32)
 	_, _ = rand.Read(key)
 
-	statePath := strings.TrimSpace(os.Getenv("BEACON_AUTH_STATE_PATH"))
-
 	return &AuthManager{
-		magicTokens: make(map[string]magicToken),
-		permCache:   make(map[string]permissionCache),
-		sessions:    make(map[string]webSession),
-		users:       make(map[string]knownUser),
-		sessionTTL:  24 * time.Hour,
-		permTTL:     10 * time.Second,
-		cookieName:  "beacon_session",
-		signingKey:  key,
-		statePath:   statePath,
+		magicTokens:    make(map[string]magicToken),
+		permCache:      make(map[string]permissionCache),
+		sessions:       make(map[string]webSession),
+		users:          make(map[string]knownUser),
+		sessionTTL:     cfg.Auth.SessionTTL,
+		permTTL:        cfg.Auth.PermissionTTL,
+		cookieName:     "beacon_session",
+		signingKey:     key,
+		statePath:      strings.TrimSpace(cfg.Auth.StatePath),
+		allowedOrigins: parseAllowedOrigins(strings.Join(cfg.Server.AllowedOrigins, ",")),
+		secureCookies:  cfg.TLSEnabled(),
 	}
 }
 
@@ -245,7 +312,27 @@ func (a *AuthManager) SetPluginDataDir(pluginDataDir string) {
 	}
 }
 
+// persistStateAsync writes the state file in the background without blocking
+// the caller; FlushState waits for any that are still running.
+func (a *AuthManager) persistStateAsync() {
+	a.persistWG.Add(1)
+	go func() {
+		defer a.persistWG.Done()
+		a.persistState()
+	}()
+}
+
+// FlushState waits for pending background writes and then persists the current
+// state synchronously. Call it during shutdown.
+func (a *AuthManager) FlushState() {
+	a.persistWG.Wait()
+	a.persistState()
+}
+
 func (a *AuthManager) persistState() {
+	a.persistMu.Lock()
+	defer a.persistMu.Unlock()
+
 	a.mu.RLock()
 	if strings.TrimSpace(a.statePath) == "" {
 		a.mu.RUnlock()
@@ -370,18 +457,18 @@ func (a *AuthManager) ConsumeMagicToken(rawToken string) (SessionClaims, error)
 	}
 	existingUser, exists := a.users[entry.PlayerUUID]
 	if !exists {
-		a.users[entry.PlayerUUID] = knownUser{
+		existingUser = knownUser{
 			PlayerUUID: entry.PlayerUUID,
-			PlayerName: entry.PlayerName,
 			FirstSeen:  nowTime,
-			LastSeen:   nowTime,
 		}
-	} else {
-		existingUser.PlayerName = entry.PlayerName
-		existingUser.LastSeen = nowTime
-		a.users[entry.PlayerUUID] = existingUser
 	}
-	go a.persistState()
+	existingUser.PlayerName = entry.PlayerName
+	existingUser.LastSeen = nowTime
+	if len(entry.Permissions) > 0 {
+		existingUser.Permissions = entry.Permissions
+	}
+	a.users[entry.PlayerUUID] = existingUser
+	a.persistStateAsync()
 
 	return SessionClaims{
 		PlayerUUID: entry.PlayerUUID,
@@ -396,6 +483,19 @@ func (a *AuthManager) SessionCookieName() string {
 	return a.cookieName
 }
 
+// SetSecureCookies marks session and CSRF cookies as Secure; enable it when serving over TLS.
+func (a *AuthManager) SetSecureCookies(secure bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.secureCookies = secure
+}
+
+func (a *AuthManager) useSecureCookies() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.secureCookies
+}
+
 func (a *AuthManager) EncodeSession(claims SessionClaims) (string, error) {
 	headerBytes, err := json.Marshal(map[string]string{
 		"alg": "HS256",
@@ -467,6 +567,7 @@ func (a *AuthManager) SetSessionCookie(w http.ResponseWriter, token string, expi
 		Value:    token,
 		Path:     "/",
 		HttpOnly: true,
+		Secure:   a.useSecureCookies(),
 		SameSite: http.SameSiteLaxMode,
 		Expires:  time.Unix(expiresAt, 0),
 	})
@@ -478,6 +579,7 @@ func (a *AuthManager) ClearSessionCookie(w http.ResponseWriter) {
 		Value:    "",
 		Path:     "/",
 		HttpOnly: true,
+		Secure:   a.useSecureCookies(),
 		SameSite: http.SameSiteLaxMode,
 		Expires:  time.Unix(0, 0),
 		MaxAge:   -1,
@@ -507,11 +609,20 @@ func (a *AuthManager) ReadSessionClaims(r *http.Request) (SessionClaims, error)
 	return claims, nil
 }
 
-func (a *AuthManager) StartJanitor() {
+// StartJanitor expires tokens and sessions every minute until ctx is cancelled.
+// The returned channel is closed once the janitor has stopped.
+func (a *AuthManager) StartJanitor(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		ticker := time.NewTicker(1 * time.Minute)
 		defer ticker.Stop()
-		for range ticker.C {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
 			now := time.Now()
 			changed := false
 			a.mu.Lock()
@@ -538,6 +649,7 @@ func (a *AuthManager) StartJanitor() {
 			}
 		}
 	}()
+	return done
 }
 
 func (a *AuthManager) GetPermissions(ctx context.Context, ws *WebSocketManager, playerUUID string) ([]string, bool, error) {
@@ -568,10 +680,36 @@ func (a *AuthManager) GetPermissions(ctx context.Context, ws *WebSocketManager,
 		Online:      online,
 		FetchedAt:   now,
 	}
+	// Players who are offline in game report no permissions; keep the last
+	// real ones.
+	user, known := a.users[playerUUID]
+	changed := known && online && !slices.Equal(user.Permissions, normalized)
+	if changed {
+		user.Permissions = normalized
+		a.users[playerUUID] = user
+	}
 	a.mu.Unlock()
+	if changed {
+		a.persistStateAsync()
+	}
 	return normalized, online, nil
 }
 
+// OfflineProcessPermissions stands in for GetPermissions on the server
+// process endpoints while the plugin is offline: it returns
+// PermServerProcess alone if the player held it when the plugin last
+// reported their permissions, so a stopped server can be started again.
+// Everything else waits for the plugin.
+func (a *AuthManager) OfflineProcessPermissions(playerUUID string) []string {
+	a.mu.RLock()
+	user, known := a.users[playerUUID]
+	a.mu.RUnlock()
+	if known && HasPermission(user.Permissions, PermServerProcess) {
+		return []string{PermServerProcess}
+	}
+	return nil
+}
+
 func (a *AuthManager) InvalidatePermissionCache(playerUUID string) {
 	a.mu.Lock()
 	delete(a.permCache, playerUUID)
@@ -580,25 +718,52 @@ func (a *AuthManager) InvalidatePermissionCache(playerUUID string) {
 
 func DeriveSessionGrants(permissions []string) SessionGrants {
 	return SessionGrants{
-		CanViewDashboard: HasPermission(permissions, PermDashboardView),
-		CanViewConsole:   HasPermission(permissions, PermConsoleView),
-		CanUseConsole:    HasPermission(permissions, PermConsoleUse),
-		CanViewPlayers:   HasPermission(permissions, PermPlayersView),
-		CanKickPlayers:   HasPermission(permissions, PermPlayersKick),
-		CanBanPlayers:    HasPermission(permissions, PermPlayersBan),
-		CanViewWorlds:    HasPermission(permissions, PermWorldsView),
-		CanManageWorlds:  HasPermission(permissions, PermWorldsManage),
-		CanResetWorlds:   HasPermission(permissions, PermWorldsReset),
-		CanEditGamerules: HasPermission(permissions, PermWorldsGamerules),
-		CanStopServer:    HasPermission(permissions, PermServerStop),
-		CanRestartServer: HasPermission(permissions, PermServerRestart),
-		CanSaveAll:       HasPermission(permissions, PermServerSaveAll),
-		CanViewFiles:     CanAccessAnyFileView(permissions),
-		CanEditFiles:     HasPermission(permissions, PermFilesEdit),
-		CanDeleteFiles:   HasPermission(permissions, PermFilesDelete),
-		CanDownloadFiles: HasPermission(permissions, PermFilesDownload),
-		CanViewAccess:    HasAnyPermission(permissions, PermAccessAll, PermAccessView, PermAccessManage),
-		CanManageAccess:  HasAnyPermission(permissions, PermAccessAll, PermAccessManage),
+		CanViewDashboard:   HasPermission(permissions, PermDashboardView),
+		CanViewConsole:     HasPermission(permissions, PermConsoleView),
+		CanUseConsole:      HasPermission(permissions, PermConsoleUse),
+		CanViewPlayers:     HasPermission(permissions, PermPlayersView),
+		CanKickPlayers:     HasPermission(permissions, PermPlayersKick),
+		CanBanPlayers:      HasPermission(permissions, PermPlayersBan),
+		CanTempBan:         HasPermission(permissions, PermPlayersTempBan),
+		CanIPBan:           HasPermission(permissions, PermPlayersIPBan),
+		CanUnban:           HasPermission(permissions, PermPlayersUnban),
+		CanMutePlayers:     HasPermission(permissions, PermPlayersMute),
+		CanWarnPlayers:     HasPermission(permissions, PermPlayersWarn),
+		CanViewIPs:         HasPermission(permissions, PermPlayersIPs),
+		CanEditNotes:       HasPermission(permissions, PermPlayersNotes),
+		CanViewWhitelist:   HasPermission(permissions, PermWhitelistView),
+		CanAddWhitelist:    HasPermission(permissions, PermWhitelistAdd),
+		CanRemoveWhitelist: HasPermission(permissions, PermWhitelistRemove),
+		CanToggleWhitelist: HasPermission(permissions, PermWhitelistToggle),
+		CanImportWhitelist: HasPermission(permissions, PermWhitelistImport),
+		CanViewOps:         HasPermission(permissions, PermOpsView),
+		CanAddOps:          HasPermission(permissions, PermOpsAdd),
+		CanRemoveOps:       HasPermission(permissions, PermOpsRemove),
+		CanViewWorlds:      HasPermission(permissions, PermWorldsView),
+		CanManageWorlds:    HasPermission(permissions, PermWorldsManage),
+		CanResetWorlds:     HasPermission(permissions, PermWorldsReset),
+		CanEditGamerules:   HasPermission(permissions, PermWorldsGamerules),
+		CanCreateWorlds:    HasPermission(permissions, PermWorldsCreate),
+		CanImportWorlds:    HasPermission(permissions, PermWorldsImport),
+		CanCloneWorlds:     HasPermission(permissions, PermWorldsClone),
+		CanDeleteWorlds:    HasPermission(permissions, PermWorldsDelete),
+		CanStopServer:      HasPermission(permissions, PermServerStop),
+		CanRestartServer:   HasPermission(permissions, PermServerRestart),
+		CanSaveAll:         HasPermission(permissions, PermServerSaveAll),
+		CanControlProcess:  HasPermission(permissions, PermServerProcess),
+		CanViewDiagnostics: HasPermission(permissions, PermDiagnosticsView),
+		CanCaptureDumps:    HasPermission(permissions, PermDiagnosticsCapture),
+		CanRunProfiler:     HasPermission(permissions, PermDiagnosticsProfile),
+		CanViewPlugins:     HasPermission(permissions, PermPluginsView),
+		CanTogglePlugins:   HasPermission(permissions, PermPluginsToggle),
+		CanReloadPlugins:   HasPermission(permissions, PermPluginsReload),
+		CanUploadPlugins:   HasPermission(permissions, PermPluginsUpload),
+		CanViewFiles:       CanAccessAnyFileView(permissions),
+		CanEditFiles:       HasPermission(permissions, PermFilesEdit),
+		CanDeleteFiles:     HasPermission(permissions, PermFilesDelete),
+		CanDownloadFiles:   HasPermission(permissions, PermFilesDownload),
+		CanViewAccess:      HasAnyPermission(permissions, PermAccessAll, PermAccessView, PermAccessManage),
+		CanManageAccess:    HasAnyPermission(permissions, PermAccessAll, PermAccessManage),
 	}
 }
 
@@ -627,7 +792,7 @@ func (a *AuthManager) RevokeSession(sessionID string) bool {
 	}
 	session.Revoked = true
 	a.sessions[sessionID] = session
-	go a.persistState()
+	a.persistStateAsync()
 	return true
 }
 
@@ -664,25 +829,45 @@ func permissionPackImplies(granted, required string) bool {
 		return required == PermDashboardView ||
 			required == PermServerStop ||
 			required == PermServerRestart ||
-			required == PermServerSaveAll
+			required == PermServerSaveAll ||
+			required == PermDiagnosticsView
 	case PermPackConsole:
 		return required == PermConsoleView ||
 			required == PermConsoleUse
 	case PermPackPlayers:
 		return required == PermPlayersView ||
 			required == PermPlayersKick ||
-			required == PermPlayersBan
+			required == PermPlayersBan ||
+			required == PermPlayersTempBan ||
+			required == PermPlayersIPBan ||
+			required == PermPlayersUnban ||
+			required == PermPlayersMute ||
+			required == PermPlayersWarn ||
+			required == PermPlayersNotes ||
+			required == PermWhitelistView ||
+			required == PermWhitelistAdd ||
+			required == PermWhitelistRemove ||
+			required == PermWhitelistToggle ||
+			required == PermWhitelistImport ||
+			required == PermOpsView
 	case PermPackWorlds:
 		return required == PermWorldsView ||
 			required == PermWorldsManage ||
 			required == PermWorldsReset ||
-			required == PermWorldsGamerules
+			required == PermWorldsGamerules ||
+			required == PermWorldsCreate ||
+			required == PermWorldsImport ||
+			required == PermWorldsClone
 	case PermPackFiles:
 		return required == PermFilesAll ||
 			required == PermFilesView ||
 			required == PermFilesEdit ||
 			required == PermFilesDelete ||
 			required == PermFilesDownload
+	case PermPackPlugins:
+		return required == PermPluginsView ||
+			required == PermPluginsToggle ||
+			required == PermPluginsReload
 	case PermAccessManage:
 		return required == PermAccessView
 	default:
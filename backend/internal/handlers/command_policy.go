@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/commandpolicy"
+	"github.com/adammcgrogan/beacon/internal/config"
+)
+
+// builtinCommandRules give commands with their own panel permission that
+// permission instead of plain console access. Configured rules come first
+// and can override any of them.
+func builtinCommandRules() []config.CommandRule {
+	return []config.CommandRule{
+		{Command: "stop", Permission: PermServerStop},
+		{Command: "restart", Permission: PermServerRestart},
+		{Command: "save-all", Permission: PermServerSaveAll},
+		{Command: "kick", Permission: PermPlayersKick},
+		{Command: "ban", Permission: PermPlayersBan},
+		{Command: "ban-ip", Permission: PermPlayersIPBan},
+		{Command: "pardon", Permission: PermPlayersUnban},
+		{Command: "pardon-ip", Permission: PermPlayersUnban},
+		{Command: "banlist", Permission: PermPlayersView},
+		{Command: "op", Permission: PermOpsAdd},
+		{Command: "deop", Permission: PermOpsRemove},
+		{Command: "whitelist", Args: "add *", Permission: PermWhitelistAdd},
+		{Command: "whitelist", Args: "remove *", Permission: PermWhitelistRemove},
+		{Command: "whitelist", Args: "on", Permission: PermWhitelistToggle},
+		{Command: "whitelist", Args: "off", Permission: PermWhitelistToggle},
+		{Command: "whitelist", Args: "list", Permission: PermWhitelistView},
+	}
+}
+
+// NewCommandPolicy builds the console command policy from configuration and
+// the built-in rules.
+func NewCommandPolicy(cfg config.CommandsConfig) *commandpolicy.Policy {
+	return commandpolicy.New(cfg, builtinCommandRules(), PermConsoleUse)
+}
+
+var defaultCommandPolicy = NewCommandPolicy(config.Default().Commands)
+
+func (m *WebSocketManager) commandPolicy() *commandpolicy.Policy {
+	if m.Commands != nil {
+		return m.Commands
+	}
+	return defaultCommandPolicy
+}
+
+// AuthorizeCommand evaluates a console line for a user with permissions.
+func (m *WebSocketManager) AuthorizeCommand(command string, permissions []string) commandpolicy.Decision {
+	return m.commandPolicy().Evaluate(command, func(permission string) bool {
+		return HasPermission(permissions, permission)
+	})
+}
+
+// HandleCommandExplain is a dry run of the command policy: it reports whether
+// a console line would be allowed and which rule decided each command in it,
+// without running anything. Users who may view access can ask on behalf of
+// another player with ?player_uuid=.
+func (h *UIHandler) HandleCommandExplain(w http.ResponseWriter, r *http.Request) {
+	var command string
+	switch r.Method {
+	case http.MethodGet:
+		command = r.URL.Query().Get("command")
+	case http.MethodPost:
+		var req struct {
+			Command string `json:"command"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		command = req.Command
+	default:
+		methodNotAllowed(w)
+		return
+	}
+	claims, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if strings.TrimSpace(command) == "" {
+		writeJSONError(w, http.StatusBadRequest, "command is required")
+		return
+	}
+
+	subject := claims.PlayerUUID
+	if target := strings.TrimSpace(r.URL.Query().Get("player_uuid")); target != "" && target != claims.PlayerUUID {
+		if !HasAnyPermission(permissions, PermAccessAll, PermAccessView) {
+			writeJSONError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+		defer cancel()
+		targetPermissions, _, err := h.Auth.GetPermissions(ctx, h.WS, target)
+		if err != nil {
+			writeJSONError(w, http.StatusServiceUnavailable, "could not load permissions for that player")
+			return
+		}
+		subject, permissions = target, targetPermissions
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"player_uuid": subject,
+		"decision":    h.WS.AuthorizeCommand(command, permissions),
+	})
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/diagnostics"
+)
+
+// crashReport is a report with a link to its file in the file manager.
+type crashReport struct {
+	diagnostics.Report
+	Link string `json:"link"`
+}
+
+func crashReportView(report diagnostics.Report) crashReport {
+	segments := strings.Split(report.Path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return crashReport{Report: report, Link: "/files/" + strings.Join(segments, "/")}
+}
+
+// HandleCrashReports lists the crash reports and JVM fatal error logs found
+// on the server, newest first (GET), or checks for new ones now (POST).
+func (h *UIHandler) HandleCrashReports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	_, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermDiagnosticsView) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if h.WS.CrashReports == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "crash report history is not enabled")
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		ctx, cancel := context.WithTimeout(r.Context(), dumpTimeout)
+		defer cancel()
+		if _, err := h.WS.ScanCrashReports(ctx); err != nil {
+			writePluginError(w, err)
+			return
+		}
+	}
+
+	reports := h.WS.CrashReports.List()
+	views := make([]crashReport, len(reports))
+	for i, report := range reports {
+		views[i] = crashReportView(report)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"reports": views})
+}
+
+// HandleDumps lists stored thread dumps and heap histograms, optionally of
+// one ?kind= (GET), or takes a new one (POST {"kind"}).
+func (h *UIHandler) HandleDumps(w http.ResponseWriter, r *http.Request) {
+	var permission string
+	switch r.Method {
+	case http.MethodGet:
+		permission = PermDiagnosticsView
+	case http.MethodPost:
+		permission = PermDiagnosticsCapture
+	default:
+		methodNotAllowed(w)
+		return
+	}
+	claims, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, permission) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if h.WS.Dumps == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "dump storage is not enabled")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, map[string]any{"dumps": h.WS.Dumps.List(r.URL.Query().Get("kind"))})
+		return
+	}
+
+	var req struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), dumpTimeout)
+	defer cancel()
+	dump, err := h.WS.CaptureDump(ctx, claims, req.Kind)
+	if err != nil {
+		if errors.Is(err, ErrInvalidDumpKind) {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writePluginError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"dump": dump})
+}
+
+// HandleDump returns one stored dump with its text.
+func (h *UIHandler) HandleDump(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	_, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermDiagnosticsView) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if h.WS.Dumps == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "dump storage is not enabled")
+		return
+	}
+	dump, content, err := h.WS.Dumps.Get(r.PathValue("id"))
+	if err != nil {
+		writeDumpError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"dump": dump, "content": content})
+}
+
+// HandleDumpDiff compares two stored dumps of the same kind, ?from= and
+// ?to=, in whichever order they were taken.
+func (h *UIHandler) HandleDumpDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	_, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermDiagnosticsView) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if h.WS.Dumps == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "dump storage is not enabled")
+		return
+	}
+	query := r.URL.Query()
+	if query.Get("from") == "" || query.Get("to") == "" {
+		writeJSONError(w, http.StatusBadRequest, "from and to are required")
+		return
+	}
+	diff, err := h.WS.Dumps.Diff(query.Get("from"), query.Get("to"))
+	if err != nil {
+		writeDumpError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"diff": diff})
+}
+
+func writeDumpError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, diagnostics.ErrDumpNotFound):
+		writeJSONError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, diagnostics.ErrKindMismatch):
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// HandleProfiles lists stored profiles and the one running, if any (GET),
+// or starts or stops a profile (POST {"action": "start", "duration",
+// "interval_ms"} or {"action": "stop"}).
+func (h *UIHandler) HandleProfiles(w http.ResponseWriter, r *http.Request) {
+	var permission string
+	switch r.Method {
+	case http.MethodGet:
+		permission = PermDiagnosticsView
+	case http.MethodPost:
+		permission = PermDiagnosticsProfile
+	default:
+		methodNotAllowed(w)
+		return
+	}
+	claims, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, permission) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if h.WS.Profiles == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "profile storage is not enabled")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"active":   h.WS.ActiveProfile(),
+			"profiles": h.WS.Profiles.List(),
+		})
+		return
+	}
+
+	var req struct {
+		Action     string `json:"action"`
+		Duration   string `json:"duration"`
+		IntervalMS int    `json:"interval_ms"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	switch req.Action {
+	case "start":
+		var duration time.Duration
+		if req.Duration != "" {
+			var err error
+			if duration, err = time.ParseDuration(req.Duration); err != nil {
+				writeJSONError(w, http.StatusBadRequest, "duration must look like 30s or 5m")
+				return
+			}
+		}
+		active, err := h.WS.StartProfile(r.Context(), claims, duration, req.IntervalMS)
+		if err != nil {
+			writeProfileError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"active": active})
+	case "stop":
+		profile, err := h.WS.StopProfile(r.Context(), claims)
+		if err != nil {
+			writeProfileError(w, err)
+			return
+		}
+		profile.Tree = nil
+		writeJSON(w, http.StatusOK, map[string]any{"profile": profile})
+	default:
+		writeJSONError(w, http.StatusBadRequest, "action must be start or stop")
+	}
+}
+
+// HandleProfile returns one stored profile with its flame graph.
+func (h *UIHandler) HandleProfile(w http.ResponseWriter, r *http.Request) {
+	profile, ok := h.storedProfile(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"profile": profile})
+}
+
+// HandleProfileFlameGraph returns just the flame graph of a stored profile,
+// as a tree of {name, value, children} ready for a flame graph viewer.
+func (h *UIHandler) HandleProfileFlameGraph(w http.ResponseWriter, r *http.Request) {
+	profile, ok := h.storedProfile(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, profile.Tree)
+}
+
+func (h *UIHandler) storedProfile(w http.ResponseWriter, r *http.Request) (diagnostics.Profile, bool) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return diagnostics.Profile{}, false
+	}
+	_, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return diagnostics.Profile{}, false
+	}
+	if !HasPermission(permissions, PermDiagnosticsView) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return diagnostics.Profile{}, false
+	}
+	if h.WS.Profiles == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "profile storage is not enabled")
+		return diagnostics.Profile{}, false
+	}
+	profile, err := h.WS.Profiles.Get(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, diagnostics.ErrProfileNotFound) {
+			writeJSONError(w, http.StatusNotFound, err.Error())
+		} else {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+		}
+		return diagnostics.Profile{}, false
+	}
+	return profile, true
+}
+
+func writeProfileError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidProfile):
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrProfileRunning), errors.Is(err, ErrProfileStarting):
+		writeJSONError(w, http.StatusConflict, err.Error())
+	default:
+		writePluginError(w, err)
+	}
+}
@@ -4,10 +4,10 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"path"
 	"strings"
-	"time"
 )
 
 func (h *UIHandler) HandleFilesMeta(w http.ResponseWriter, r *http.Request) {
@@ -184,10 +184,7 @@ func (h *UIHandler) fileRequest(requestCtx context.Context, action string, path
 		return nil, ErrPluginOffline
 	}
 
-	ctx, cancel := context.WithTimeout(requestCtx, 12*time.Second)
-	defer cancel()
-
-	response, err := h.WS.RequestFileManagerOperation(ctx, action, path, content)
+	response, err := h.WS.RequestFileManagerOperation(requestCtx, action, path, content)
 	if err != nil {
 		return nil, err
 	}
@@ -214,6 +211,14 @@ func writeFileError(w http.ResponseWriter, err error) {
 		writeJSONError(w, http.StatusServiceUnavailable, "server is offline")
 		return
 	}
+	if err == ErrPluginBusy {
+		writeJSONError(w, http.StatusServiceUnavailable, "server is busy, try again")
+		return
+	}
+	if err == ErrPluginUnsupported {
+		writeJSONError(w, http.StatusNotImplemented, "the server's Beacon plugin is too old for this; please update it")
+		return
+	}
 	if err == context.DeadlineExceeded || err == context.Canceled {
 		writeJSONError(w, http.StatusGatewayTimeout, "file operation timed out")
 		return
@@ -230,6 +235,14 @@ func writeFileDownloadError(w http.ResponseWriter, err error) {
 		http.Error(w, "server is offline", http.StatusServiceUnavailable)
 		return
 	}
+	if err == ErrPluginBusy {
+		http.Error(w, "server is busy, try again", http.StatusServiceUnavailable)
+		return
+	}
+	if err == ErrPluginUnsupported {
+		http.Error(w, "the server's Beacon plugin is too old for this; please update it", http.StatusNotImplemented)
+		return
+	}
 	if err == context.DeadlineExceeded || err == context.Canceled {
 		http.Error(w, "file operation timed out", http.StatusGatewayTimeout)
 		return
@@ -255,6 +268,22 @@ func writeJSONError(w http.ResponseWriter, status int, message string) {
 	writeJSON(w, status, map[string]string{"error": message})
 }
 
+// writePluginError answers a failed plugin request: 503 while the plugin is
+// offline or busy, 501 when it is too old, 504 on timeout and 502 when it
+// reported an error.
+func writePluginError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrPluginOffline), errors.Is(err, ErrPluginBusy):
+		writeJSONError(w, http.StatusServiceUnavailable, pluginErrorMessage(err))
+	case errors.Is(err, ErrPluginUnsupported):
+		writeJSONError(w, http.StatusNotImplemented, pluginErrorMessage(err))
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		writeJSONError(w, http.StatusGatewayTimeout, pluginErrorMessage(err))
+	default:
+		writeJSONError(w, http.StatusBadGateway, pluginErrorMessage(err))
+	}
+}
+
 func filterVisibleEntries(raw json.RawMessage, permissions []string) (map[string]any, error) {
 	var response struct {
 		Path    string           `json:"path"`
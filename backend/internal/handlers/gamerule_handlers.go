@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/adammcgrogan/beacon/internal/audit"
+	"github.com/adammcgrogan/beacon/internal/gamerules"
+)
+
+// HandleGamerulePresets lists presets (GET), saves one (POST {"name",
+// "description", "rules"} or {"name", "from_world"} to capture a world's
+// non-default rules) or deletes one (DELETE ?name=).
+func (h *UIHandler) HandleGamerulePresets(w http.ResponseWriter, r *http.Request) {
+	var permission string
+	switch r.Method {
+	case http.MethodGet:
+		permission = PermWorldsView
+	case http.MethodPost, http.MethodDelete:
+		permission = PermWorldsGamerules
+	default:
+		methodNotAllowed(w)
+		return
+	}
+	claims, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, permission) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	presets := h.WS.GamerulePresets
+	if presets == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "gamerule presets are not enabled")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{"presets": presets.List()})
+	case http.MethodPost:
+		var req struct {
+			gamerules.Preset
+			FromWorld string `json:"from_world"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		preset := req.Preset
+		if from := strings.TrimSpace(req.FromWorld); from != "" {
+			rules, ok := h.worldGamerules(from)
+			if !ok {
+				writeJSONError(w, http.StatusBadRequest, from+" is not a loaded world")
+				return
+			}
+			preset.Rules = gamerules.Changed(rules, h.WS.GameruleDefaults())
+		}
+		preset.UpdatedBy = claims.PlayerName
+		saved, err := presets.Put(preset, h.WS.ValidateGamerule)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.WS.audit(claims, audit.Entry{Action: "gamerules.preset.save", Target: saved.Name, OK: true})
+		writeJSON(w, http.StatusOK, map[string]any{"preset": saved})
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		if !presets.Delete(name) {
+			writeJSONError(w, http.StatusNotFound, "preset not found")
+			return
+		}
+		h.WS.audit(claims, audit.Entry{Action: "gamerules.preset.delete", Target: name, OK: true})
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	}
+}
+
+// HandleGameruleDiff compares loaded worlds' gamerules with the server
+// defaults or, given ?preset=, with a preset. ?world= (repeatable) limits it
+// to some worlds.
+func (h *UIHandler) HandleGameruleDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	_, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermWorldsView) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	against := "defaults"
+	var preset *gamerules.Preset
+	if name := strings.TrimSpace(r.URL.Query().Get("preset")); name != "" {
+		found, ok := h.lookupPreset(name)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "preset not found")
+			return
+		}
+		preset, against = &found, found.Name
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"against": against,
+		"worlds":  h.WS.GameruleDiff(r.URL.Query()["world"], preset),
+	})
+}
+
+// HandleGameruleApply sets many rules across many worlds: POST {"worlds":
+// [...], "rules": {...}} or {"worlds": [...], "preset": "name"}. Rollback is
+// on unless "rollback": false is sent.
+func (h *UIHandler) HandleGameruleApply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	claims, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermWorldsGamerules) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	var req struct {
+		Worlds   []string          `json:"worlds"`
+		Rules    map[string]string `json:"rules"`
+		Preset   string            `json:"preset"`
+		Rollback *bool             `json:"rollback"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	rules := req.Rules
+	if name := strings.TrimSpace(req.Preset); name != "" {
+		if len(rules) > 0 {
+			writeJSONError(w, http.StatusBadRequest, "send either rules or a preset, not both")
+			return
+		}
+		preset, ok := h.lookupPreset(name)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "preset not found")
+			return
+		}
+		rules = preset.Rules
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.WS.pluginRequestTimeout())
+	defer cancel()
+	result, err := h.WS.ApplyGamerules(ctx, claims, req.Worlds, rules, req.Rollback == nil || *req.Rollback)
+	if err != nil {
+		if errors.Is(err, ErrInvalidGamerules) {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writePluginError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *UIHandler) lookupPreset(name string) (gamerules.Preset, bool) {
+	if h.WS.GamerulePresets == nil {
+		return gamerules.Preset{}, false
+	}
+	return h.WS.GamerulePresets.Get(name)
+}
+
+func (h *UIHandler) worldGamerules(name string) (map[string]string, bool) {
+	for _, world := range h.Store.GetWorlds() {
+		if world.Loaded && strings.EqualFold(world.Name, name) {
+			return world.Gamerules, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultHotspotWindow is how much history is returned without ?since=.
+const defaultHotspotWindow = 24 * time.Hour
+
+// HandleWorldHotspots returns a world's latest hotspot scan and its entity
+// totals over time (GET), or scans it now (POST). ?since= (e.g. 6h, 7d)
+// widens the history; ?x=&z= adds one chunk's counts over time, to follow
+// e.g. a mob farm.
+func (h *UIHandler) HandleWorldHotspots(w http.ResponseWriter, r *http.Request) {
+	var permission string
+	switch r.Method {
+	case http.MethodGet:
+		permission = PermWorldsView
+	case http.MethodPost:
+		permission = PermWorldsManage
+	default:
+		methodNotAllowed(w)
+		return
+	}
+	_, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, permission) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	history := h.WS.Hotspots
+	if history == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "hotspot history is not enabled")
+		return
+	}
+	world := r.PathValue("name")
+
+	if r.Method == http.MethodPost {
+		ctx, cancel := context.WithTimeout(r.Context(), h.WS.pluginRequestTimeout())
+		defer cancel()
+		scan, err := h.WS.ScanHotspots(ctx, world)
+		if err != nil {
+			if errors.Is(err, ErrUnknownWorld) {
+				writeJSONError(w, http.StatusNotFound, err.Error())
+				return
+			}
+			writePluginError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"scan": scan})
+		return
+	}
+
+	query := r.URL.Query()
+	window := defaultHotspotWindow
+	if raw := query.Get("since"); raw != "" {
+		parsed, err := parseModerationDuration(raw)
+		if err != nil || parsed <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "since must be a duration such as 6h or 7d")
+			return
+		}
+		window = parsed
+	}
+	since := time.Now().Add(-window)
+
+	latest, found := history.Latest(world)
+	if !found {
+		if _, loaded := h.WS.loadedWorld(world); !loaded {
+			writeJSONError(w, http.StatusNotFound, "no hotspot scans for this world")
+			return
+		}
+	}
+	resp := map[string]any{
+		"world":  world,
+		"totals": history.Totals(world, since),
+	}
+	if found {
+		resp["world"] = latest.World
+		resp["latest"] = latest
+	}
+	if query.Has("x") || query.Has("z") {
+		x, errX := strconv.Atoi(query.Get("x"))
+		z, errZ := strconv.Atoi(query.Get("z"))
+		if errX != nil || errZ != nil {
+			writeJSONError(w, http.StatusBadRequest, "x and z must be chunk coordinates")
+			return
+		}
+		resp["chunk"] = map[string]any{
+			"x":       x,
+			"z":       z,
+			"history": history.Chunk(world, x, z, since),
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
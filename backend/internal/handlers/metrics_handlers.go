@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// HandleMetrics reports backend health counters for operators.
+func (h *UIHandler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	_, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasAnyPermission(permissions, PermAccessAll, PermAccessView, PermAccessManage) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"web":        h.WS.WebMetrics(),
+		"plugin_rpc": h.WS.RPCMetrics(),
+		"protocol":   h.WS.ProtocolInfo(),
+		"rate_limit": h.WS.RateLimits.Stats(),
+	})
+}
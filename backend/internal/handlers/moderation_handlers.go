@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/moderation"
+)
+
+// HandleModerationAction applies the action named by the last path segment,
+// e.g. POST /api/moderation/tempban {"player": "Steve", "duration": "7d"}.
+func (h *UIHandler) HandleModerationAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	claims, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+
+	action := path.Base(r.URL.Path)
+	permission, known := moderationPermission(action)
+	if !known {
+		writeJSONError(w, http.StatusNotFound, "unknown moderation action")
+		return
+	}
+	if !HasPermission(permissions, permission) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	var req ModerationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	req.Action = action
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.WS.pluginRequestTimeout())
+	defer cancel()
+	result, err := h.WS.Moderate(ctx, claims, req)
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// HandleModerationBans lists the server's ban lists, active panel mutes and
+// scheduled unbans. IP bans are only included for users who may see IPs.
+func (h *UIHandler) HandleModerationBans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	_, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermPlayersView) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	showIPs := HasPermission(permissions, PermPlayersIPs)
+	now := time.Now()
+
+	playerBans, err := h.readBanList(r.Context(), "banned-players.json", now)
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	response := map[string]any{
+		"players":     playerBans,
+		"ips_visible": showIPs,
+	}
+	var ipBans []moderation.Ban
+	if showIPs {
+		ipBans, err = h.readBanList(r.Context(), "banned-ips.json", now)
+		if err != nil {
+			writeModerationError(w, err)
+			return
+		}
+		response["ips"] = ipBans
+	}
+
+	if h.WS.Moderation != nil {
+		h.WS.Moderation.AttachAppeals(playerBans)
+		h.WS.Moderation.AttachAppeals(ipBans)
+		response["mutes"] = h.WS.Moderation.Mutes(now)
+		scheduled := make([]moderation.Expiry, 0)
+		for _, expiry := range h.WS.Moderation.Pending() {
+			if expiry.Action == moderation.LiftIPBan && !showIPs {
+				continue
+			}
+			scheduled = append(scheduled, expiry)
+		}
+		response["scheduled"] = scheduled
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// HandleModerationAppeals adds a note to the appeal of a current ban (POST
+// {"player": "Steve", "text": "..."} or {"ip": "...", "text": "..."}). Notes
+// are listed with the ban by HandleModerationBans and dropped on unban.
+func (h *UIHandler) HandleModerationAppeals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	claims, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermPlayersUnban) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if h.WS.Moderation == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "moderation is not enabled")
+		return
+	}
+
+	var req struct {
+		Player string `json:"player"`
+		IP     string `json:"ip"`
+		Text   string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	req.Player, req.IP = strings.TrimSpace(req.Player), strings.TrimSpace(req.IP)
+	if (req.Player == "") == (req.IP == "") {
+		writeJSONError(w, http.StatusBadRequest, "give either player or ip")
+		return
+	}
+	list := "banned-players.json"
+	if req.IP != "" {
+		if !HasPermission(permissions, PermPlayersIPs) {
+			writeJSONError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		list = "banned-ips.json"
+	}
+
+	bans, err := h.readBanList(r.Context(), list, time.Now())
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	index := slices.IndexFunc(bans, func(ban moderation.Ban) bool {
+		if req.IP != "" {
+			return ban.IP == req.IP
+		}
+		return strings.EqualFold(ban.Name, req.Player)
+	})
+	if index < 0 {
+		writeJSONError(w, http.StatusNotFound, "no current ban for that player or ip")
+		return
+	}
+
+	appeal, err := h.WS.Moderation.AddAppeal(bans[index], claims.PlayerName, req.Text, time.Now())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, appeal)
+}
+
+func (h *UIHandler) readBanList(parent context.Context, name string, now time.Time) ([]moderation.Ban, error) {
+	ctx, cancel := context.WithTimeout(parent, h.WS.pluginRequestTimeout())
+	defer cancel()
+	resp, err := h.WS.RequestFileManagerOperation(ctx, "read_text", name, "")
+	if err != nil {
+		return nil, err
+	}
+	if !resp.OK {
+		// The server writes the file on its first ban.
+		return []moderation.Ban{}, nil
+	}
+	var payload struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(resp.Data, &payload); err != nil || payload.Content == "" {
+		return []moderation.Ban{}, nil
+	}
+	return moderation.ParseBanList([]byte(payload.Content), now)
+}
+
+func writeModerationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidModeration):
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrModerationRejected):
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		writePluginError(w, err)
+	}
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/history"
+	"github.com/adammcgrogan/beacon/internal/players"
+)
+
+const (
+	defaultHistoryDays = 30
+	maxHistoryDays     = 365
+
+	defaultDirectoryLimit = 100
+	maxDirectoryLimit     = 1000
+)
+
+// playerProfileResponse is a directory profile with playtime from the session
+// history when it is available. Addresses are only filled in for users who may
+// see them.
+type playerProfileResponse struct {
+	players.Profile
+	AddressesVisible bool                   `json:"addresses_visible"`
+	History          *history.PlayerSummary `json:"history,omitempty"`
+}
+
+// HandlePlayersHistory reports recorded player sessions and playtime analytics.
+// With ?uuid= it returns that player's sessions; otherwise a summary of the
+// last ?days= days (default 30).
+func (h *UIHandler) HandlePlayersHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	_, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermPlayersView) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if h.WS.History == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "player history is not enabled")
+		return
+	}
+
+	if uuid := strings.TrimSpace(r.URL.Query().Get("uuid")); uuid != "" {
+		player, found := h.WS.History.Player(uuid)
+		if !found {
+			writeJSONError(w, http.StatusNotFound, "no history for this player")
+			return
+		}
+		writeJSON(w, http.StatusOK, player)
+		return
+	}
+
+	days := defaultHistoryDays
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxHistoryDays {
+			writeJSONError(w, http.StatusBadRequest, "days must be between 1 and 365")
+			return
+		}
+		days = parsed
+	}
+	writeJSON(w, http.StatusOK, h.WS.History.Report(days, time.Now()))
+}
+
+// HandlePlayersDirectory searches every player the server has seen, online or
+// not, by name or UUID.
+func (h *UIHandler) HandlePlayersDirectory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	_, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermPlayersView) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if h.WS.Players == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "player directory is not enabled")
+		return
+	}
+
+	limit := defaultDirectoryLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxDirectoryLimit {
+			writeJSONError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = parsed
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"players": h.WS.Players.Search(r.URL.Query().Get("q"), limit),
+	})
+}
+
+// HandlePlayerProfile returns one player's names, moderation history and staff
+// notes, looked up by ?uuid= or by current ?name=.
+func (h *UIHandler) HandlePlayerProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	_, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermPlayersView) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if h.WS.Players == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "player directory is not enabled")
+		return
+	}
+
+	uuid := strings.TrimSpace(r.URL.Query().Get("uuid"))
+	if uuid == "" {
+		name := strings.TrimSpace(r.URL.Query().Get("name"))
+		if name == "" {
+			writeJSONError(w, http.StatusBadRequest, "uuid or name is required")
+			return
+		}
+		uuid, _ = h.WS.Players.Resolve(name)
+	}
+	profile, found := h.WS.Players.Profile(uuid)
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "player not found")
+		return
+	}
+
+	resp := playerProfileResponse{
+		Profile:          profile,
+		AddressesVisible: HasPermission(permissions, PermPlayersIPs),
+	}
+	if !resp.AddressesVisible {
+		resp.Addresses = nil
+	}
+	if h.WS.History != nil {
+		if record, ok := h.WS.History.Player(profile.UUID); ok {
+			resp.History = &record.PlayerSummary
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandlePlayerNotes adds (POST) or deletes (DELETE ?uuid=&id=) staff notes.
+func (h *UIHandler) HandlePlayerNotes(w http.ResponseWriter, r *http.Request) {
+	claims, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermPlayersNotes) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if h.WS.Players == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "player directory is not enabled")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			UUID string `json:"uuid"`
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		author := players.Actor{UUID: claims.PlayerUUID, Name: claims.PlayerName}
+		note, err := h.WS.Players.AddNote(req.UUID, author, req.Text, time.Now())
+		switch {
+		case errors.Is(err, players.ErrUnknownPlayer):
+			writeJSONError(w, http.StatusNotFound, "player not found")
+		case err != nil:
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeJSON(w, http.StatusCreated, note)
+		}
+	case http.MethodDelete:
+		uuid := r.URL.Query().Get("uuid")
+		id := r.URL.Query().Get("id")
+		if uuid == "" || id == "" {
+			writeJSONError(w, http.StatusBadRequest, "uuid and id are required")
+			return
+		}
+		if !h.WS.Players.DeleteNote(uuid, id) {
+			writeJSONError(w, http.StatusNotFound, "note not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	default:
+		methodNotAllowed(w)
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/adammcgrogan/beacon/internal/plugins"
+)
+
+// HandlePlugins renders the plugin inventory page.
+func (h *UIHandler) HandlePlugins(w http.ResponseWriter, r *http.Request) {
+	claims, permissions, ok := h.requirePagePermission(w, r, PermPluginsView)
+	if !ok {
+		return
+	}
+	h.render(w, "plugins", "Plugins", map[string]interface{}{}, claims, DeriveSessionGrants(permissions))
+}
+
+// HandlePluginInventory lists the loaded plugins, the jars in plugins/ and
+// the update folder, and any duplicate or conflicting jars among them.
+func (h *UIHandler) HandlePluginInventory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	_, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermPluginsView) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	inventory, err := h.WS.PluginInventory(r.Context())
+	if err != nil {
+		writePluginError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"plugins":        inventory.Plugins,
+		"jars":           inventory.Jars,
+		"plugins_folder": inventory.PluginsFolder,
+		"update_folder":  inventory.UpdateFolder,
+		"conflicts":      plugins.Conflicts(inventory),
+	})
+}
+
+// HandlePluginAction enables, disables or reloads the plugin named in the
+// path (POST {"action"}).
+func (h *UIHandler) HandlePluginAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	claims, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Action string `json:"action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	permission := PermPluginsToggle
+	if req.Action == PluginReload {
+		permission = PermPluginsReload
+	}
+	if !HasPermission(permissions, permission) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	plugin, err := h.WS.PluginAction(r.Context(), claims, r.PathValue("name"), req.Action)
+	if err != nil {
+		writePluginInventoryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"plugin": plugin})
+}
+
+// HandlePluginUpload takes a plugin jar as the multipart field "jar" and
+// installs it, or stages it as an update of the plugin already installed.
+func (h *UIHandler) HandlePluginUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	claims, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermPluginsUpload) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPluginJar+(1<<20))
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "jar is larger than 64 MiB")
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, "expected a multipart form with a jar")
+		return
+	}
+	upload, header, err := r.FormFile("jar")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "jar is required")
+		return
+	}
+	defer upload.Close()
+	jar, err := io.ReadAll(io.LimitReader(upload, maxPluginJar+1))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "could not read jar")
+		return
+	}
+
+	installed, err := h.WS.InstallPluginJar(r.Context(), claims, header.Filename, jar)
+	if err != nil {
+		writePluginInventoryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"upload": installed})
+}
+
+func writePluginInventoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidPluginRequest), errors.Is(err, plugins.ErrNotPluginJar):
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, plugins.ErrAlreadyInstalled), errors.Is(err, plugins.ErrFileTaken):
+		writeJSONError(w, http.StatusConflict, err.Error())
+	default:
+		writePluginError(w, err)
+	}
+}
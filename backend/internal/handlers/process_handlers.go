@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/adammcgrogan/beacon/internal/supervisor"
+)
+
+// HandleProcess reports the server process's state alongside whether the
+// plugin is connected (GET), or starts, stops, kills or restarts it (POST
+// {"action"}). Both work while the server is down, since that is when they
+// are needed.
+func (h *UIHandler) HandleProcess(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	claims := h.sessionFromContext(r)
+	permissions, err := h.processPermissions(r, claims)
+	if err != nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "could not load permissions")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		if !HasPermission(permissions, PermDashboardView) && !HasPermission(permissions, PermServerProcess) {
+			writeJSONError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"process":          h.WS.ProcessStatus(),
+			"plugin_connected": h.WS.isMinecraftConnected(),
+		})
+		return
+	}
+
+	if !HasPermission(permissions, PermServerProcess) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	var req struct {
+		Action string `json:"action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	status, err := h.WS.ControlProcess(claims, req.Action)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, map[string]any{"process": status})
+	case errors.Is(err, ErrInvalidProcessAction):
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrProcessDisabled):
+		writeJSONError(w, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, supervisor.ErrRunning), errors.Is(err, supervisor.ErrNotRunning):
+		writeJSONError(w, http.StatusConflict, err.Error())
+	default:
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/config"
+	"github.com/adammcgrogan/beacon/internal/ratelimit"
+)
+
+const rateLimitSweepInterval = time.Minute
+
+type rateTier struct {
+	permission string
+	api        ratelimit.Rate
+	events     ratelimit.Rate
+}
+
+// RateLimiter throttles magic-link redemption per client address, API calls
+// per session and per client address, and /ws/web events per session. It is
+// safe for concurrent use.
+type RateLimiter struct {
+	magicLink ratelimit.Rate
+	api       ratelimit.Rate
+	apiPerIP  ratelimit.Rate
+	events    ratelimit.Rate
+	tiers     []rateTier
+	trusted   []netip.Prefix
+
+	buckets  *ratelimit.Limiter
+	lockouts *ratelimit.Lockout
+
+	limited   atomic.Uint64
+	lockedOut atomic.Uint64
+}
+
+// RateLimitStats counts requests turned away since startup.
+type RateLimitStats struct {
+	Limited   uint64 `json:"limited"`
+	LockedOut uint64 `json:"locked_out"`
+}
+
+// NewRateLimiter builds a limiter from validated configuration. It returns
+// nil when rate limiting is disabled; a nil limiter allows everything.
+func NewRateLimiter(cfg config.RateLimitConfig) *RateLimiter {
+	if !cfg.Enabled {
+		return nil
+	}
+	l := &RateLimiter{
+		magicLink: mustRate(cfg.MagicLink),
+		api:       mustRate(cfg.API),
+		apiPerIP:  mustRate(cfg.APIPerIP),
+		events:    mustRate(cfg.Events),
+		buckets:   ratelimit.NewLimiter(),
+		lockouts:  ratelimit.NewLockout(cfg.LockoutAfter, cfg.Lockout),
+	}
+	for _, tier := range cfg.Tiers {
+		t := rateTier{permission: tier.Permission, api: l.api, events: l.events}
+		if tier.API != "" {
+			t.api = mustRate(tier.API)
+		}
+		if tier.Events != "" {
+			t.events = mustRate(tier.Events)
+		}
+		l.tiers = append(l.tiers, t)
+	}
+	for _, proxy := range cfg.TrustedProxies {
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			l.trusted = append(l.trusted, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(proxy); err == nil {
+			l.trusted = append(l.trusted, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return l
+}
+
+func mustRate(raw string) ratelimit.Rate {
+	rate, err := ratelimit.ParseRate(raw)
+	if err != nil {
+		panic(err)
+	}
+	return rate
+}
+
+// Start sweeps idle buckets and expired lockouts until ctx is cancelled. The
+// returned channel is closed once the loop has stopped.
+func (l *RateLimiter) Start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	if l == nil {
+		close(done)
+		return done
+	}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(rateLimitSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				l.buckets.Sweep(now)
+				l.lockouts.Sweep(now)
+			}
+		}
+	}()
+	return done
+}
+
+// Stats reports how many requests have been turned away.
+func (l *RateLimiter) Stats() RateLimitStats {
+	if l == nil {
+		return RateLimitStats{}
+	}
+	return RateLimitStats{Limited: l.limited.Load(), LockedOut: l.lockedOut.Load()}
+}
+
+// allowLogin checks a client address before a magic-link redemption.
+func (l *RateLimiter) allowLogin(ip string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	now := time.Now()
+	if wait, locked := l.lockouts.Locked(ip, now); locked {
+		l.lockedOut.Add(1)
+		return false, wait
+	}
+	return l.take("login:"+ip, l.magicLink, now)
+}
+
+// loginFailed records a rejected token and reports whether the address is
+// now locked out.
+func (l *RateLimiter) loginFailed(ip string) (time.Duration, bool) {
+	if l == nil {
+		return 0, false
+	}
+	wait, locked := l.lockouts.Fail(ip, time.Now())
+	if locked {
+		l.lockedOut.Add(1)
+	}
+	return wait, locked
+}
+
+func (l *RateLimiter) loginSucceeded(ip string) {
+	if l == nil {
+		return
+	}
+	l.lockouts.Reset(ip)
+}
+
+// allowAPI takes a token for one API call from both the session and the
+// client address, so signing in again from one address does not reset the
+// limit. The session is checked first, so a session over its own limit does
+// not use up the address's share for others behind the same address.
+func (l *RateLimiter) allowAPI(ip, sessionID string, permissions []string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	now := time.Now()
+	if ok, wait := l.take("api:"+sessionID, l.tierFor(permissions).api, now); !ok {
+		return false, wait
+	}
+	return l.take("api-ip:"+ip, l.apiPerIP, now)
+}
+
+// eventRate is the /ws/web event rate for a user.
+func (l *RateLimiter) eventRate(permissions []string) ratelimit.Rate {
+	if l == nil {
+		return ratelimit.Rate{}
+	}
+	return l.tierFor(permissions).events
+}
+
+// allowEvent takes a token for one /ws/web event by a session.
+func (l *RateLimiter) allowEvent(sessionID string, rate ratelimit.Rate) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	return l.take("ws:"+sessionID, rate, time.Now())
+}
+
+// needsPermissions reports whether tiers make limits depend on permissions.
+func (l *RateLimiter) needsPermissions() bool {
+	return l != nil && len(l.tiers) > 0
+}
+
+func (l *RateLimiter) tierFor(permissions []string) rateTier {
+	for _, tier := range l.tiers {
+		if HasPermission(permissions, tier.permission) {
+			return tier
+		}
+	}
+	return rateTier{api: l.api, events: l.events}
+}
+
+func (l *RateLimiter) take(key string, rate ratelimit.Rate, now time.Time) (bool, time.Duration) {
+	ok, wait := l.buckets.Allow(key, rate, now)
+	if !ok {
+		l.limited.Add(1)
+	}
+	return ok, wait
+}
+
+// clientIP is the address a request came from. X-Forwarded-For is only
+// believed when the direct peer is a trusted proxy, and then the nearest
+// address that is not itself a trusted proxy is used.
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if l == nil || len(l.trusted) == 0 || !l.isTrusted(host) {
+		return host
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !l.isTrusted(hop) {
+			return hop
+		}
+	}
+	return host
+}
+
+func (l *RateLimiter) isTrusted(raw string) bool {
+	addr, err := netip.ParseAddr(raw)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range l.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// webEventRate is the event rate for a browser session, fixed when it
+// connects.
+func (m *WebSocketManager) webEventRate(ctx context.Context, session SessionClaims) ratelimit.Rate {
+	if !m.RateLimits.needsPermissions() {
+		return m.RateLimits.eventRate(nil)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 4*time.Second)
+	defer cancel()
+	permissions, _, _ := m.Auth.GetPermissions(ctx, m, session.PlayerUUID)
+	return m.RateLimits.eventRate(permissions)
+}
+
+// writeRateLimited answers 429 with a Retry-After in whole seconds.
+func writeRateLimited(w http.ResponseWriter, wait time.Duration, message string) {
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+	writeJSONError(w, http.StatusTooManyRequests, message)
+}
+
+func retryAfterSeconds(wait time.Duration) int {
+	return max(1, int(math.Ceil(wait.Seconds())))
+}
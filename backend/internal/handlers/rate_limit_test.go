@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adammcgrogan/beacon/internal/config"
+)
+
+func TestAllowAPIPerAddress(t *testing.T) {
+	cfg := config.Default().RateLimit
+	cfg.API = "2/m"
+	cfg.APIPerIP = "3/m"
+	l := NewRateLimiter(cfg)
+
+	tests := []struct {
+		ip, session string
+		ok          bool
+	}{
+		{"203.0.113.1", "a", true},
+		{"203.0.113.1", "a", true},
+		{"203.0.113.1", "a", false}, // session limit
+		{"203.0.113.1", "b", true},
+		{"203.0.113.1", "c", false}, // address limit, despite a fresh session
+		{"203.0.113.2", "c", true},
+	}
+	for i, tt := range tests {
+		if ok, _ := l.allowAPI(tt.ip, tt.session, nil); ok != tt.ok {
+			t.Errorf("call %d (%s, %s): allowed %v, want %v", i, tt.ip, tt.session, ok, tt.ok)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	cfg := config.Default().RateLimit
+	cfg.TrustedProxies = []string{"10.0.0.0/8"}
+	l := NewRateLimiter(cfg)
+
+	tests := []struct {
+		remote, forwarded, want string
+	}{
+		{"203.0.113.1:5000", "", "203.0.113.1"},
+		{"203.0.113.1:5000", "198.51.100.7", "203.0.113.1"},
+		{"10.0.0.2:5000", "198.51.100.7", "198.51.100.7"},
+		{"10.0.0.2:5000", "198.51.100.7, 10.0.0.3", "198.51.100.7"},
+		{"10.0.0.2:5000", "", "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/status", nil)
+		r.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := l.clientIP(r); got != tt.want {
+			t.Errorf("%s via %q: got %s, want %s", tt.remote, tt.forwarded, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+	maxImportBody     = 64 << 10
+)
+
+type rosterChangeRequest struct {
+	Player  string   `json:"player"`
+	Players []string `json:"players"`
+}
+
+func (req rosterChangeRequest) names() []string {
+	if req.Player != "" {
+		return append([]string{req.Player}, req.Players...)
+	}
+	return req.Players
+}
+
+// HandleWhitelist lists the whitelist (GET), adds players (POST
+// {"players": [...]}) or removes one (DELETE ?player=).
+func (h *UIHandler) HandleWhitelist(w http.ResponseWriter, r *http.Request) {
+	var permission string
+	switch r.Method {
+	case http.MethodGet:
+		permission = PermWhitelistView
+	case http.MethodPost:
+		permission = PermWhitelistAdd
+	case http.MethodDelete:
+		permission = PermWhitelistRemove
+	default:
+		methodNotAllowed(w)
+		return
+	}
+	claims, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, permission) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.WS.pluginRequestTimeout())
+	defer cancel()
+	switch r.Method {
+	case http.MethodGet:
+		roster, err := h.WS.Roster(ctx)
+		if err != nil {
+			writeRosterError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"enabled": roster.WhitelistEnabled,
+			"players": roster.Whitelist,
+		})
+	case http.MethodPost:
+		var req rosterChangeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		h.changeRoster(ctx, w, claims, RosterWhitelistAdd, "whitelist.add", req.names(), maxRosterBatch)
+	case http.MethodDelete:
+		h.changeRoster(ctx, w, claims, RosterWhitelistRemove, "whitelist.remove", []string{r.URL.Query().Get("player")}, 1)
+	}
+}
+
+// HandleWhitelistEnabled turns whitelist enforcement on or off, e.g.
+// POST {"enabled": true}.
+func (h *UIHandler) HandleWhitelistEnabled(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	claims, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermWhitelistToggle) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		writeJSONError(w, http.StatusBadRequest, "expected {\"enabled\": true|false}")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), h.WS.pluginRequestTimeout())
+	defer cancel()
+	if err := h.WS.SetWhitelistEnabled(ctx, claims, *req.Enabled); err != nil {
+		writeRosterError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"enabled": *req.Enabled})
+}
+
+// HandleWhitelistImport whitelists many players at once. The body is either
+// JSON {"players": [...]} or plain text with names separated by whitespace
+// or commas. Names that cannot be resolved are reported, not fatal.
+func (h *UIHandler) HandleWhitelistImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	claims, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermWhitelistImport) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBody))
+	if err != nil {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "import is too large")
+		return
+	}
+	var names []string
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/plain" {
+		names = strings.FieldsFunc(string(body), func(c rune) bool {
+			return c == ',' || c == ';' || unicode.IsSpace(c)
+		})
+	} else {
+		var req rosterChangeRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		names = req.names()
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.WS.pluginRequestTimeout())
+	defer cancel()
+	h.changeRoster(ctx, w, claims, RosterWhitelistAdd, "whitelist.import", names, maxRosterImport)
+}
+
+// HandleOps lists operators (GET), grants operator (POST {"players": [...]})
+// or revokes it (DELETE ?player=).
+func (h *UIHandler) HandleOps(w http.ResponseWriter, r *http.Request) {
+	var permission string
+	switch r.Method {
+	case http.MethodGet:
+		permission = PermOpsView
+	case http.MethodPost:
+		permission = PermOpsAdd
+	case http.MethodDelete:
+		permission = PermOpsRemove
+	default:
+		methodNotAllowed(w)
+		return
+	}
+	claims, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, permission) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.WS.pluginRequestTimeout())
+	defer cancel()
+	switch r.Method {
+	case http.MethodGet:
+		roster, err := h.WS.Roster(ctx)
+		if err != nil {
+			writeRosterError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"players": roster.Ops})
+	case http.MethodPost:
+		var req rosterChangeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		h.changeRoster(ctx, w, claims, RosterOpAdd, "ops.add", req.names(), maxRosterBatch)
+	case http.MethodDelete:
+		h.changeRoster(ctx, w, claims, RosterOpRemove, "ops.remove", []string{r.URL.Query().Get("player")}, 1)
+	}
+}
+
+// HandleAudit lists recorded admin changes, newest first. ?action= filters by
+// action prefix, e.g. "whitelist.".
+func (h *UIHandler) HandleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	_, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasAnyPermission(permissions, PermAccessAll, PermAccessView) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if h.WS.Audit == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "audit log is not enabled")
+		return
+	}
+
+	limit := defaultAuditLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxAuditLimit {
+			writeJSONError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = parsed
+	}
+	prefix := strings.TrimSpace(r.URL.Query().Get("action"))
+	writeJSON(w, http.StatusOK, map[string]any{"entries": h.WS.Audit.Recent(prefix, limit)})
+}
+
+func (h *UIHandler) changeRoster(ctx context.Context, w http.ResponseWriter, claims SessionClaims, action, auditAction string, names []string, limit int) {
+	results, err := h.WS.ChangeRoster(ctx, claims, action, auditAction, names, limit)
+	if err != nil {
+		writeRosterError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+func writeRosterError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRoster):
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	default:
+		writePluginError(w, err)
+	}
+}
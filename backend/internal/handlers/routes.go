@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// RouteAuth is what a route requires before its handler runs. The zero
+// value is deliberately invalid so a route cannot forget to say.
+type RouteAuth int
+
+const (
+	authUndeclared RouteAuth = iota
+	// AuthPublic routes need nothing: the login page, magic-link
+	// redemption and static assets.
+	AuthPublic
+	// AuthPage routes need a session and redirect to /auth without one.
+	AuthPage
+	// AuthAPI routes need a session, and a CSRF token for unsafe methods,
+	// and answer 401 without them. They are rate limited per session.
+	AuthAPI
+	// AuthPlugin is the plugin socket, which checks the shared secret itself.
+	AuthPlugin
+	// AuthWebSocket is the browser socket, which checks the session when
+	// upgrading and each event's permission as it arrives.
+	AuthWebSocket
+)
+
+func (a RouteAuth) String() string {
+	switch a {
+	case AuthPublic:
+		return "public"
+	case AuthPage:
+		return "page"
+	case AuthAPI:
+		return "api"
+	case AuthPlugin:
+		return "plugin"
+	case AuthWebSocket:
+		return "websocket"
+	default:
+		return "undeclared"
+	}
+}
+
+// Route declares one mounted path and who may reach it. Page and API routes
+// must say exactly one of AnyOf, AnySession or Scoped.
+type Route struct {
+	Pattern string
+	Auth    RouteAuth
+	// AnyOf refuses sessions holding none of these permissions before the
+	// handler runs. Handlers still check per-method permissions themselves.
+	AnyOf []string
+	// AnySession lets every signed-in user through, e.g. to log out.
+	AnySession bool
+	// Scoped routes depend on the request for their permission, such as a
+	// file's path, so only the handler can check it.
+	Scoped  bool
+	Handler http.HandlerFunc
+}
+
+// Routes is every HTTP route the backend serves. static serves /static/.
+func (h *UIHandler) Routes(static http.Handler) []Route {
+	routes := []Route{
+		{Pattern: "/static/", Auth: AuthPublic, Handler: static.ServeHTTP},
+
+		// Pages
+		{Pattern: "/auth", Auth: AuthPublic, Handler: h.HandleAuthPage},
+		// The dashboard and the process endpoint let process controllers in
+		// while the plugin is offline, which only the handlers can tell.
+		{Pattern: "/", Auth: AuthPage, Scoped: true, Handler: h.HandleDashboard},
+		{Pattern: "/console", Auth: AuthPage, AnyOf: []string{PermConsoleView}, Handler: h.HandleConsole},
+		{Pattern: "/players", Auth: AuthPage, AnyOf: []string{PermPlayersView}, Handler: h.HandlePlayers},
+		{Pattern: "/worlds", Auth: AuthPage, AnyOf: []string{PermWorldsView}, Handler: h.HandleWorlds},
+		{Pattern: "/files", Auth: AuthPage, Scoped: true, Handler: h.HandleFiles},
+		{Pattern: "/files/", Auth: AuthPage, Scoped: true, Handler: h.HandleFiles},
+		{Pattern: "/plugins", Auth: AuthPage, AnyOf: []string{PermPluginsView}, Handler: h.HandlePlugins},
+		{Pattern: "/access", Auth: AuthPage, AnyOf: []string{PermAccessView}, Handler: h.HandleAccess},
+
+		// Session
+		{Pattern: "/api/auth/magic-link", Auth: AuthPublic, Handler: h.HandleMagicLinkAuth},
+		{Pattern: "/api/auth/logout", Auth: AuthAPI, AnySession: true, Handler: h.HandleLogout},
+		{Pattern: "/api/session", Auth: AuthAPI, AnySession: true, Handler: h.HandleSession},
+
+		// Files
+		{Pattern: "/api/files/meta", Auth: AuthAPI, Scoped: true, Handler: h.HandleFilesMeta},
+		{Pattern: "/api/files/list", Auth: AuthAPI, Scoped: true, Handler: h.HandleFilesList},
+		{Pattern: "/api/files/content", Auth: AuthAPI, Scoped: true, Handler: h.HandleFilesContent},
+		{Pattern: "/api/files", Auth: AuthAPI, Scoped: true, Handler: h.HandleFilesDelete},
+		{Pattern: "/api/files/download", Auth: AuthAPI, Scoped: true, Handler: h.HandleFilesDownload},
+		{Pattern: "/api/files/dir", Auth: AuthAPI, Scoped: true, Handler: h.HandleFilesCreateDir},
+		{Pattern: "/api/files/upload", Auth: AuthAPI, Scoped: true, Handler: h.HandleFilesUpload},
+
+		// Access
+		{Pattern: "/api/access/data", Auth: AuthAPI, AnyOf: []string{PermAccessView}, Handler: h.HandleAccessData},
+		{Pattern: "/api/access/sessions", Auth: AuthAPI, AnyOf: []string{PermAccessManage}, Handler: h.HandleAccessSessionDelete},
+		{Pattern: "/api/access/permissions", Auth: AuthAPI, AnyOf: []string{PermAccessManage}, Handler: h.HandleAccessPermissionUpdate},
+		{Pattern: "/api/audit", Auth: AuthAPI, AnyOf: []string{PermAccessView}, Handler: h.HandleAudit},
+		{Pattern: "/api/metrics", Auth: AuthAPI, AnyOf: []string{PermAccessView}, Handler: h.HandleMetrics},
+		// Anyone may explain their own commands; explaining another
+		// player's needs access view, checked by the handler.
+		{Pattern: "/api/commands/explain", Auth: AuthAPI, AnySession: true, Handler: h.HandleCommandExplain},
+
+		// Server process
+		{Pattern: "/api/server/process", Auth: AuthAPI, Scoped: true, Handler: h.HandleProcess},
+
+		// Diagnostics
+		{Pattern: "/api/diagnostics/crashes", Auth: AuthAPI, AnyOf: []string{PermDiagnosticsView}, Handler: h.HandleCrashReports},
+		{Pattern: "/api/diagnostics/dumps", Auth: AuthAPI, AnyOf: []string{PermDiagnosticsView, PermDiagnosticsCapture}, Handler: h.HandleDumps},
+		{Pattern: "/api/diagnostics/dumps/diff", Auth: AuthAPI, AnyOf: []string{PermDiagnosticsView}, Handler: h.HandleDumpDiff},
+		{Pattern: "/api/diagnostics/dumps/{id}", Auth: AuthAPI, AnyOf: []string{PermDiagnosticsView}, Handler: h.HandleDump},
+		{Pattern: "/api/diagnostics/profiles", Auth: AuthAPI, AnyOf: []string{PermDiagnosticsView, PermDiagnosticsProfile}, Handler: h.HandleProfiles},
+		{Pattern: "/api/diagnostics/profiles/{id}", Auth: AuthAPI, AnyOf: []string{PermDiagnosticsView}, Handler: h.HandleProfile},
+		{Pattern: "/api/diagnostics/profiles/{id}/flamegraph", Auth: AuthAPI, AnyOf: []string{PermDiagnosticsView}, Handler: h.HandleProfileFlameGraph},
+
+		// Plugins
+		{Pattern: "/api/plugins", Auth: AuthAPI, AnyOf: []string{PermPluginsView}, Handler: h.HandlePluginInventory},
+		{Pattern: "/api/plugins/upload", Auth: AuthAPI, AnyOf: []string{PermPluginsUpload}, Handler: h.HandlePluginUpload},
+		{Pattern: "/api/plugins/{name}", Auth: AuthAPI, AnyOf: []string{PermPluginsToggle, PermPluginsReload}, Handler: h.HandlePluginAction},
+
+		// Players
+		{Pattern: "/api/players/history", Auth: AuthAPI, AnyOf: []string{PermPlayersView}, Handler: h.HandlePlayersHistory},
+		{Pattern: "/api/players/directory", Auth: AuthAPI, AnyOf: []string{PermPlayersView}, Handler: h.HandlePlayersDirectory},
+		{Pattern: "/api/players/profile", Auth: AuthAPI, AnyOf: []string{PermPlayersView}, Handler: h.HandlePlayerProfile},
+		{Pattern: "/api/players/notes", Auth: AuthAPI, AnyOf: []string{PermPlayersNotes}, Handler: h.HandlePlayerNotes},
+		{Pattern: "/api/moderation/bans", Auth: AuthAPI, AnyOf: []string{PermPlayersView}, Handler: h.HandleModerationBans},
+		{Pattern: "/api/moderation/appeals", Auth: AuthAPI, AnyOf: []string{PermPlayersUnban}, Handler: h.HandleModerationAppeals},
+		{Pattern: "/api/whitelist", Auth: AuthAPI, AnyOf: []string{PermWhitelistView, PermWhitelistAdd, PermWhitelistRemove}, Handler: h.HandleWhitelist},
+		{Pattern: "/api/whitelist/enabled", Auth: AuthAPI, AnyOf: []string{PermWhitelistToggle}, Handler: h.HandleWhitelistEnabled},
+		{Pattern: "/api/whitelist/import", Auth: AuthAPI, AnyOf: []string{PermWhitelistImport}, Handler: h.HandleWhitelistImport},
+		{Pattern: "/api/ops", Auth: AuthAPI, AnyOf: []string{PermOpsView, PermOpsAdd, PermOpsRemove}, Handler: h.HandleOps},
+
+		// Worlds
+		{Pattern: "/api/worlds/operations", Auth: AuthAPI, AnyOf: []string{PermWorldsView}, Handler: h.HandleWorldOperations},
+		{Pattern: "/api/worlds/create", Auth: AuthAPI, AnyOf: []string{PermWorldsCreate}, Handler: h.HandleWorldCreate},
+		{Pattern: "/api/worlds/import", Auth: AuthAPI, AnyOf: []string{PermWorldsImport}, Handler: h.HandleWorldImport},
+		{Pattern: "/api/worlds/clone", Auth: AuthAPI, AnyOf: []string{PermWorldsClone}, Handler: h.HandleWorldClone},
+		{Pattern: "/api/worlds/delete", Auth: AuthAPI, AnyOf: []string{PermWorldsDelete}, Handler: h.HandleWorldDelete},
+		{Pattern: "/api/worlds/{name}/hotspots", Auth: AuthAPI, AnyOf: []string{PermWorldsView, PermWorldsManage}, Handler: h.HandleWorldHotspots},
+		{Pattern: "/api/gamerules/defaults", Auth: AuthAPI, AnyOf: []string{PermWorldsView}, Handler: h.HandleGameruleDefaults},
+		{Pattern: "/api/gamerules/presets", Auth: AuthAPI, AnyOf: []string{PermWorldsView, PermWorldsGamerules}, Handler: h.HandleGamerulePresets},
+		{Pattern: "/api/gamerules/diff", Auth: AuthAPI, AnyOf: []string{PermWorldsView}, Handler: h.HandleGameruleDiff},
+		{Pattern: "/api/gamerules/apply", Auth: AuthAPI, AnyOf: []string{PermWorldsGamerules}, Handler: h.HandleGameruleApply},
+
+		// Sockets
+		{Pattern: "/ws", Auth: AuthPlugin, Handler: h.WS.HandleMinecraft},
+		{Pattern: "/ws/web", Auth: AuthWebSocket, Handler: h.WS.HandleWeb},
+	}
+	for _, action := range ModerationActions() {
+		permission, _ := moderationPermission(action)
+		routes = append(routes, Route{Pattern: "/api/moderation/" + action, Auth: AuthAPI, AnyOf: []string{permission}, Handler: h.HandleModerationAction})
+	}
+	return routes
+}
+
+// Mount checks every route's declaration and registers it on mux behind the
+// auth it declares. It fails if a declaration is missing or inconsistent, or
+// if a Handle method of UIHandler or WebSocketManager is not routed at all.
+func (h *UIHandler) Mount(mux *http.ServeMux, routes []Route) error {
+	if err := checkRoutes(routes); err != nil {
+		return err
+	}
+	for _, route := range routes {
+		mux.HandleFunc(route.Pattern, h.guard(route))
+	}
+	return nil
+}
+
+func (h *UIHandler) guard(route Route) http.HandlerFunc {
+	next := route.Handler
+	if len(route.AnyOf) > 0 {
+		next = h.requireAnyPermission(route.Auth, route.AnyOf, next)
+	}
+	switch route.Auth {
+	case AuthPage:
+		return h.RequirePageAuth(next)
+	case AuthAPI:
+		return h.RequireAPIAuth(next)
+	default:
+		return next
+	}
+}
+
+// requireAnyPermission refuses sessions holding none of permissions. It runs
+// inside RequirePageAuth or RequireAPIAuth, so the session is known.
+func (h *UIHandler) requireAnyPermission(auth RouteAuth, permissions []string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := h.sessionFromContext(r)
+		ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+		granted, _, err := h.Auth.GetPermissions(ctx, h.WS, claims.PlayerUUID)
+		cancel()
+
+		status, message := 0, "forbidden"
+		if err != nil && err != ErrPluginOffline {
+			status, message = http.StatusServiceUnavailable, "could not load permissions"
+		} else if !HasAnyPermission(granted, permissions...) {
+			status = http.StatusForbidden
+		}
+		switch {
+		case status == 0:
+			next(w, r)
+		case auth == AuthPage:
+			http.Error(w, message, status)
+		default:
+			writeJSONError(w, status, message)
+		}
+	}
+}
+
+func checkRoutes(routes []Route) error {
+	var problems []error
+	seen := make(map[string]bool)
+	routed := make(map[string]bool)
+	for _, route := range routes {
+		name := route.Pattern
+		if name == "" || !strings.HasPrefix(name, "/") {
+			problems = append(problems, fmt.Errorf("route %q: pattern must start with /", name))
+		}
+		if seen[name] {
+			problems = append(problems, fmt.Errorf("route %s: declared twice", name))
+		}
+		seen[name] = true
+		if route.Handler == nil {
+			problems = append(problems, fmt.Errorf("route %s: no handler", name))
+			continue
+		}
+		routed[handlerName(route.Handler)] = true
+
+		declared := 0
+		for _, set := range []bool{len(route.AnyOf) > 0, route.AnySession, route.Scoped} {
+			if set {
+				declared++
+			}
+		}
+		switch route.Auth {
+		case AuthPage, AuthAPI:
+			if declared != 1 {
+				problems = append(problems, fmt.Errorf("route %s: declare exactly one of AnyOf, AnySession or Scoped", name))
+			}
+			for _, permission := range route.AnyOf {
+				if !strings.HasPrefix(permission, "beacon.access.") {
+					problems = append(problems, fmt.Errorf("route %s: %q is not a panel permission", name, permission))
+				}
+			}
+		case AuthPublic, AuthPlugin, AuthWebSocket:
+			if declared != 0 {
+				problems = append(problems, fmt.Errorf("route %s: %s routes cannot require permissions", name, route.Auth))
+			}
+		default:
+			problems = append(problems, fmt.Errorf("route %s: auth is undeclared", name))
+		}
+	}
+
+	for _, receiver := range []any{(*UIHandler)(nil), (*WebSocketManager)(nil)} {
+		for _, method := range handleMethods(receiver) {
+			if !routed[method] {
+				problems = append(problems, fmt.Errorf("%s has no route declaration", method))
+			}
+		}
+	}
+	return errors.Join(problems...)
+}
+
+var handlerFuncType = reflect.TypeFor[func(http.ResponseWriter, *http.Request)]()
+
+// handleMethods names the exported Handle* methods of receiver's type that
+// look like HTTP handlers, as "(*UIHandler).HandleX".
+func handleMethods(receiver any) []string {
+	t := reflect.TypeOf(receiver)
+	var names []string
+	for i := range t.NumMethod() {
+		method := t.Method(i)
+		if !strings.HasPrefix(method.Name, "Handle") {
+			continue
+		}
+		// Drop the receiver so the signature can be compared.
+		in := make([]reflect.Type, 0, method.Type.NumIn()-1)
+		for j := 1; j < method.Type.NumIn(); j++ {
+			in = append(in, method.Type.In(j))
+		}
+		out := make([]reflect.Type, 0, method.Type.NumOut())
+		for j := range method.Type.NumOut() {
+			out = append(out, method.Type.Out(j))
+		}
+		if reflect.FuncOf(in, out, false) == handlerFuncType {
+			names = append(names, "(*"+t.Elem().Name()+")."+method.Name)
+		}
+	}
+	return names
+}
+
+// handlerName names the method a handler was taken from, e.g.
+// "(*UIHandler).HandleAudit" for h.HandleAudit.
+func handlerName(handler http.HandlerFunc) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(handler).Pointer())
+	if fn == nil {
+		return ""
+	}
+	name := strings.TrimSuffix(fn.Name(), "-fm")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if _, rest, ok := strings.Cut(name, "."); ok {
+		return rest
+	}
+	return name
+}
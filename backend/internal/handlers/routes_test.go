@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	beacon "github.com/adammcgrogan/beacon"
+	"github.com/adammcgrogan/beacon/internal/config"
+	"github.com/adammcgrogan/beacon/internal/store"
+)
+
+// TestRoutesRefuseAnonymousRequests sends a request without a session to
+// every protected route and checks each one is refused before its handler
+// runs: pages redirect to /auth, and API routes, the browser socket and the
+// plugin socket (with a secret configured) answer 401.
+func TestRoutesRefuseAnonymousRequests(t *testing.T) {
+	templates, err := fs.Sub(beacon.Templates, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	auth := newTestAuth(t)
+	ws := &WebSocketManager{Store: store.New(cfg.Console), Auth: auth, PluginSecret: "secret"}
+	ui := NewUIHandler(ws.Store, ws, auth, templates)
+	routes := ui.Routes(http.NotFoundHandler())
+	mux := http.NewServeMux()
+	if err := ui.Mount(mux, routes); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	for _, route := range routes {
+		if route.Auth == AuthPublic {
+			continue
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, route.Pattern, nil))
+		switch route.Auth {
+		case AuthPage:
+			if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/auth" {
+				t.Errorf("route %s: anonymous request got %d, want a redirect to /auth", route.Pattern, rec.Code)
+			}
+		default:
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("route %s: anonymous request got %d, want 401", route.Pattern, rec.Code)
+			}
+		}
+	}
+}
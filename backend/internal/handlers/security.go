@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	csrfCookieName = "beacon_csrf"
+	csrfHeaderName = "X-CSRF-Token"
+)
+
+var ErrCSRFMismatch = errors.New("csrf token mismatch")
+
+func parseAllowedOrigins(raw string) map[string]struct{} {
+	allowed := make(map[string]struct{})
+	for _, value := range strings.Split(raw, ",") {
+		origin := normalizeOrigin(value)
+		if origin == "" {
+			continue
+		}
+		allowed[origin] = struct{}{}
+	}
+	return allowed
+}
+
+func normalizeOrigin(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return ""
+	}
+	return strings.ToLower(parsed.Scheme + "://" + parsed.Host)
+}
+
+// SetAllowedOrigins replaces the list of cross-origin sites that may open
+// WebSockets or call state-changing APIs. Same-origin requests are always allowed.
+func (a *AuthManager) SetAllowedOrigins(origins []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.allowedOrigins = parseAllowedOrigins(strings.Join(origins, ","))
+}
+
+// CheckOrigin reports whether the request's Origin header is the backend itself
+// or one of the configured allowed origins. Requests without an Origin header
+// (the plugin, CLI tools) are not browser-initiated and are allowed through.
+func (a *AuthManager) CheckOrigin(r *http.Request) bool {
+	rawOrigin := r.Header.Get("Origin")
+	if rawOrigin == "" {
+		return true
+	}
+	origin := normalizeOrigin(rawOrigin)
+	if origin == "" {
+		return false
+	}
+
+	parsed, _ := url.Parse(origin)
+	if strings.EqualFold(parsed.Host, r.Host) {
+		return true
+	}
+
+	a.mu.RLock()
+	_, ok := a.allowedOrigins[origin]
+	a.mu.RUnlock()
+	return ok
+}
+
+// EnsureCSRFCookie returns the request's CSRF token, issuing a fresh cookie if
+// the browser does not have one yet.
+func (a *AuthManager) EnsureCSRFCookie(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && len(cookie.Value) == 64 {
+		return cookie.Value
+	}
+	return a.RotateCSRFCookie(w)
+}
+
+// RotateCSRFCookie always issues a new CSRF token, used when a session is created.
+func (a *AuthManager) RotateCSRFCookie(w http.ResponseWriter) string {
+	token, err := randomHex(32)
+	if err != nil {
+		return ""
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: false,
+		Secure:   a.useSecureCookies(),
+		SameSite: http.SameSiteStrictMode,
+		Expires:  time.Now().Add(a.sessionTTL),
+	})
+	return token
+}
+
+func (a *AuthManager) ClearCSRFCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    "",
+		Path:     "/",
+		Secure:   a.useSecureCookies(),
+		SameSite: http.SameSiteStrictMode,
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+	})
+}
+
+// VerifyCSRF enforces the double-submit check for state-changing requests: the
+// X-CSRF-Token header must match the beacon_csrf cookie, and the Origin (if
+// any) must pass CheckOrigin.
+func (a *AuthManager) VerifyCSRF(r *http.Request) error {
+	if isSafeMethod(r.Method) {
+		return nil
+	}
+	if !a.CheckOrigin(r) {
+		return ErrCSRFMismatch
+	}
+	cookie, err := r.Cookie(csrfCookieName)
+	if err != nil || cookie.Value == "" {
+		return ErrCSRFMismatch
+	}
+	header := r.Header.Get(csrfHeaderName)
+	if header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+		return ErrCSRFMismatch
+	}
+	return nil
+}
+
+// StrictTransportSecurity adds an HSTS header to every response. Only wrap
+// handlers that are served exclusively over TLS.
+func StrictTransportSecurity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m *WebSocketManager) upgrader() *websocket.Upgrader {
+	u := &websocket.Upgrader{EnableCompression: m.Compression}
+	if m.Auth != nil {
+		u.CheckOrigin = m.Auth.CheckOrigin
+	}
+	return u
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/config"
+	"github.com/gorilla/websocket"
+)
+
+func newTestAuth(t *testing.T, allowedOrigins ...string) *AuthManager {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Auth.StatePath = ""
+	cfg.Server.AllowedOrigins = allowedOrigins
+	return NewAuthManager(cfg)
+}
+
+// testSession signs a player in the way the magic link does and returns the
+// session cookie.
+func testSession(t *testing.T, auth *AuthManager) *http.Cookie {
+	t.Helper()
+	auth.StoreMagicToken("token", "uuid-1", "Steve", time.Now().Add(time.Minute).Unix(), []string{PermDashboardView})
+	claims, err := auth.ConsumeMagicToken("token")
+	if err != nil {
+		t.Fatalf("ConsumeMagicToken: %v", err)
+	}
+	value, err := auth.EncodeSession(claims)
+	if err != nil {
+		t.Fatalf("EncodeSession: %v", err)
+	}
+	return &http.Cookie{Name: auth.SessionCookieName(), Value: value}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	auth := newTestAuth(t, "https://panel.example.com")
+	tests := []struct {
+		name, host, origin string
+		want               bool
+	}{
+		{"no origin", "beacon.local:8080", "", true},
+		{"same origin", "beacon.local:8080", "http://beacon.local:8080", true},
+		{"same origin any case", "beacon.local:8080", "HTTP://Beacon.Local:8080", true},
+		{"allowed origin", "beacon.local:8080", "https://panel.example.com", true},
+		{"allowed origin with path", "beacon.local:8080", "https://panel.example.com/", true},
+		{"other port", "beacon.local:8080", "http://beacon.local:9090", false},
+		{"other site", "beacon.local:8080", "https://evil.example.com", false},
+		{"allowed host over other scheme", "beacon.local:8080", "http://panel.example.com", false},
+		{"null", "beacon.local:8080", "null", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = tt.host
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := auth.CheckOrigin(r); got != tt.want {
+			t.Errorf("%s: CheckOrigin(%q on %q) = %v, want %v", tt.name, tt.origin, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyCSRF(t *testing.T) {
+	auth := newTestAuth(t)
+	const token = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name           string
+		method         string
+		cookie, header string
+		origin         string
+		wantErr        bool
+	}{
+		{"safe method needs no token", http.MethodGet, "", "", "", false},
+		{"missing cookie", http.MethodPost, "", token, "", true},
+		{"missing header", http.MethodPost, token, "", "", true},
+		{"mismatched token", http.MethodPost, token, strings.Repeat("f", 64), "", true},
+		{"valid token", http.MethodPost, token, token, "", false},
+		{"valid token same origin", http.MethodDelete, token, token, "http://example.com", false},
+		{"valid token cross origin", http.MethodPost, token, token, "https://evil.example.com", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "http://example.com/api/settings", nil)
+		if tt.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: tt.cookie})
+		}
+		if tt.header != "" {
+			r.Header.Set(csrfHeaderName, tt.header)
+		}
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		err := auth.VerifyCSRF(r)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: VerifyCSRF = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWebSocketRefusesCrossOrigin(t *testing.T) {
+	auth := newTestAuth(t)
+	ws := &WebSocketManager{Auth: auth}
+	server := httptest.NewServer(http.HandlerFunc(ws.HandleWeb))
+	defer server.Close()
+
+	header := http.Header{}
+	header.Set("Origin", "https://evil.example.com")
+	header.Set("Cookie", testSession(t, auth).String())
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/web"
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if err == nil {
+		conn.Close()
+		t.Fatal("cross-origin upgrade succeeded")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("cross-origin upgrade: got %v, want 403", resp)
+	}
+}
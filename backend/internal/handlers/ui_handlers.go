@@ -3,7 +3,9 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"html/template"
+	"io/fs"
 	"net/http"
 	"strings"
 	"time"
@@ -11,23 +13,26 @@ import (
 	"github.com/adammcgrogan/beacon/internal/store"
 )
 
-var tmpl = template.Must(template.ParseGlob("../../templates/*.html"))
-
 type UIHandler struct {
 	Store *store.ServerStore
 	WS    *WebSocketManager
 	Auth  *AuthManager
+
+	templates *template.Template
 }
 
 type contextKey string
 
 const sessionContextKey contextKey = "session_claims"
 
-func NewUIHandler(s *store.ServerStore, ws *WebSocketManager, auth *AuthManager) *UIHandler {
+// NewUIHandler parses every *.html template at the root of templates, which is
+// either the embedded copy or a directory on disk during development.
+func NewUIHandler(s *store.ServerStore, ws *WebSocketManager, auth *AuthManager, templates fs.FS) *UIHandler {
 	return &UIHandler{
-		Store: s,
-		WS:    ws,
-		Auth:  auth,
+		Store:     s,
+		WS:        ws,
+		Auth:      auth,
+		templates: template.Must(template.ParseFS(templates, "*.html")),
 	}
 }
 
@@ -43,12 +48,22 @@ func (h *UIHandler) render(w http.ResponseWriter, tabName, title string, extraDa
 		data[k] = v
 	}
 
-	tmpl.ExecuteTemplate(w, "base", data)
+	h.templates.ExecuteTemplate(w, "base", data)
 }
 
+// HandleDashboard renders the dashboard. While the plugin is offline it is
+// also shown to players who may control the server process, with nothing
+// else granted, so they can start the server.
 func (h *UIHandler) HandleDashboard(w http.ResponseWriter, r *http.Request) {
-	claims, permissions, ok := h.requirePagePermission(w, r, PermDashboardView)
-	if !ok {
+	claims := h.sessionFromContext(r)
+	permissions, err := h.processPermissions(r, claims)
+	if err != nil {
+		http.Error(w, "permissions unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	offlineProcess := !h.WS.isMinecraftConnected() && HasPermission(permissions, PermServerProcess)
+	if !HasPermission(permissions, PermDashboardView) && !offlineProcess {
+		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
 	h.render(w, "dashboard", "Overview", map[string]interface{}{
@@ -100,7 +115,7 @@ func (h *UIHandler) HandleFiles(w http.ResponseWriter, r *http.Request) {
 	} {
 		data[k] = v
 	}
-	tmpl.ExecuteTemplate(w, "base", data)
+	h.templates.ExecuteTemplate(w, "base", data)
 }
 
 func (h *UIHandler) HandleAuthPage(w http.ResponseWriter, r *http.Request) {
@@ -109,8 +124,9 @@ func (h *UIHandler) HandleAuthPage(w http.ResponseWriter, r *http.Request) {
 			http.Redirect(w, r, "/", http.StatusFound)
 			return
 		}
+		h.Auth.EnsureCSRFCookie(w, r)
 	}
-	_ = tmpl.ExecuteTemplate(w, "auth", map[string]interface{}{})
+	_ = h.templates.ExecuteTemplate(w, "auth", map[string]interface{}{})
 }
 
 func (h *UIHandler) HandleMagicLinkAuth(w http.ResponseWriter, r *http.Request) {
@@ -122,6 +138,15 @@ func (h *UIHandler) HandleMagicLinkAuth(w http.ResponseWriter, r *http.Request)
 		writeJSONError(w, http.StatusInternalServerError, "auth not configured")
 		return
 	}
+	if err := h.Auth.VerifyCSRF(r); err != nil {
+		writeJSONError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	ip := h.WS.RateLimits.clientIP(r)
+	if ok, wait := h.WS.RateLimits.allowLogin(ip); !ok {
+		writeRateLimited(w, wait, "too many login attempts, try again later")
+		return
+	}
 
 	var req struct {
 		Token string `json:"token"`
@@ -133,9 +158,14 @@ func (h *UIHandler) HandleMagicLinkAuth(w http.ResponseWriter, r *http.Request)
 
 	claims, err := h.Auth.ConsumeMagicToken(strings.TrimSpace(req.Token))
 	if err != nil {
+		if wait, locked := h.WS.RateLimits.loginFailed(ip); locked {
+			writeRateLimited(w, wait, "too many failed login attempts, try again later")
+			return
+		}
 		writeJSONError(w, http.StatusUnauthorized, "invalid or expired token")
 		return
 	}
+	h.WS.RateLimits.loginSucceeded(ip)
 
 	signedToken, err := h.Auth.EncodeSession(claims)
 	if err != nil {
@@ -144,6 +174,7 @@ func (h *UIHandler) HandleMagicLinkAuth(w http.ResponseWriter, r *http.Request)
 	}
 
 	h.Auth.SetSessionCookie(w, signedToken, claims.ExpiresAt)
+	h.Auth.RotateCSRFCookie(w)
 	writeJSON(w, http.StatusOK, map[string]any{
 		"ok": true,
 	})
@@ -155,11 +186,8 @@ func (h *UIHandler) HandleSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
-	defer cancel()
-
-	permissions, _, err := h.Auth.GetPermissions(ctx, h.WS, claims.PlayerUUID)
-	if err != nil && err != ErrPluginOffline {
+	permissions, err := h.processPermissions(r, claims)
+	if err != nil {
 		writeJSONError(w, http.StatusServiceUnavailable, "could not refresh permissions")
 		return
 	}
@@ -180,6 +208,7 @@ func (h *UIHandler) HandleLogout(w http.ResponseWriter, r *http.Request) {
 	}
 	if h.Auth != nil {
 		h.Auth.ClearSessionCookie(w)
+		h.Auth.ClearCSRFCookie(w)
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 }
@@ -191,6 +220,7 @@ func (h *UIHandler) RequirePageAuth(next http.HandlerFunc) http.HandlerFunc {
 			http.Redirect(w, r, "/auth", http.StatusFound)
 			return
 		}
+		h.Auth.EnsureCSRFCookie(w, r)
 		next(w, r.WithContext(context.WithValue(r.Context(), sessionContextKey, claims)))
 	}
 }
@@ -202,10 +232,37 @@ func (h *UIHandler) RequireAPIAuth(next http.HandlerFunc) http.HandlerFunc {
 			writeJSONError(w, http.StatusUnauthorized, "authentication required")
 			return
 		}
+		if err := h.Auth.VerifyCSRF(r); err != nil {
+			writeJSONError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if !h.allowAPICall(w, r, claims) {
+			return
+		}
 		next(w, r.WithContext(context.WithValue(r.Context(), sessionContextKey, claims)))
 	}
 }
 
+// allowAPICall applies the client address's and the session's API rate
+// limits, answering 429 when either is exhausted. Permissions are only looked up when tiers depend on them.
+func (h *UIHandler) allowAPICall(w http.ResponseWriter, r *http.Request, claims SessionClaims) bool {
+	limits := h.WS.RateLimits
+	if limits == nil {
+		return true
+	}
+	var permissions []string
+	if limits.needsPermissions() {
+		ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+		permissions, _, _ = h.Auth.GetPermissions(ctx, h.WS, claims.PlayerUUID)
+		cancel()
+	}
+	if ok, wait := limits.allowAPI(limits.clientIP(r), claims.SessionID, permissions); !ok {
+		writeRateLimited(w, wait, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
 func (h *UIHandler) requireAuthForAPI(w http.ResponseWriter, r *http.Request) (SessionClaims, []string, bool) {
 	claims := h.sessionFromContext(r)
 	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
@@ -252,18 +309,38 @@ func (h *UIHandler) requirePageFileViewPermission(w http.ResponseWriter, r *http
 	return claims, permissions, true
 }
 
+// processPermissions loads the session's permissions, falling back to
+// OfflineProcessPermissions while the plugin is offline. Only the server
+// process endpoints, and the pages that lead to them, use it.
+func (h *UIHandler) processPermissions(r *http.Request, claims SessionClaims) ([]string, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+	defer cancel()
+	permissions, _, err := h.Auth.GetPermissions(ctx, h.WS, claims.PlayerUUID)
+	if errors.Is(err, ErrPluginOffline) {
+		return h.Auth.OfflineProcessPermissions(claims.PlayerUUID), nil
+	}
+	return permissions, err
+}
+
 func (h *UIHandler) sessionFromContext(r *http.Request) SessionClaims {
 	claims, _ := r.Context().Value(sessionContextKey).(SessionClaims)
 	return claims
 }
 
+// HandleGameruleDefaults returns the server's default gamerules, as last
+// reported by the plugin.
 func (h *UIHandler) HandleGameruleDefaults(w http.ResponseWriter, r *http.Request) {
-	defaults := h.Store.GetStats().DefaultGamerules
-
-	if defaults == nil {
-		defaults = make(map[string]string)
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(defaults)
+	_, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermWorldsView) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.WS.GameruleDefaults())
 }
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// HandleWorldOperations lists recent world create, import, clone and delete
+// operations, newest first.
+func (h *UIHandler) HandleWorldOperations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	_, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return
+	}
+	if !HasPermission(permissions, PermWorldsView) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"operations": h.WS.WorldOperations()})
+}
+
+// HandleWorldCreate generates a new world. It answers 202 once the server
+// has started; progress follows as world_operation events on /ws/web.
+func (h *UIHandler) HandleWorldCreate(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.requireWorldPermission(w, r, PermWorldsCreate)
+	if !ok {
+		return
+	}
+	var req CreateWorldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.WS.pluginRequestTimeout())
+	defer cancel()
+	op, err := h.WS.CreateWorld(ctx, claims, req)
+	writeWorldOperation(w, op, err)
+}
+
+// HandleWorldClone copies a world ({"source": ..., "name": ...}) and loads
+// the copy.
+func (h *UIHandler) HandleWorldClone(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.requireWorldPermission(w, r, PermWorldsClone)
+	if !ok {
+		return
+	}
+	var req struct {
+		Source string `json:"source"`
+		Name   string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.WS.pluginRequestTimeout())
+	defer cancel()
+	op, err := h.WS.CloneWorld(ctx, claims, req.Source, req.Name)
+	writeWorldOperation(w, op, err)
+}
+
+// HandleWorldDelete permanently deletes a world. The caller must repeat the
+// world's name in "confirm".
+func (h *UIHandler) HandleWorldDelete(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.requireWorldPermission(w, r, PermWorldsDelete)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name    string `json:"name"`
+		Confirm string `json:"confirm"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if strings.TrimSpace(req.Confirm) != strings.TrimSpace(req.Name) {
+		writeJSONError(w, http.StatusBadRequest, "confirm must repeat the world name")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.WS.pluginRequestTimeout())
+	defer cancel()
+	op, err := h.WS.DeleteWorld(ctx, claims, req.Name)
+	writeWorldOperation(w, op, err)
+}
+
+// HandleWorldImport loads a world from a zip upload (multipart field
+// "archive", plus "name" and optional "environment"). The archive is checked
+// here, then streamed to the server in the background.
+func (h *UIHandler) HandleWorldImport(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.requireWorldPermission(w, r, PermWorldsImport)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxWorldArchive+(1<<20))
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "archive is larger than 1 GiB")
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, "expected a multipart form with an archive")
+		return
+	}
+	upload, header, err := r.FormFile("archive")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "archive is required")
+		return
+	}
+	defer upload.Close()
+
+	// The form's own temporary files go when this handler returns, but the
+	// upload to the server carries on after that.
+	archive, err := os.CreateTemp("", "beacon-world-*.zip")
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "could not store archive")
+		return
+	}
+	size, err := io.Copy(archive, upload)
+	if err != nil || size != header.Size {
+		archive.Close()
+		os.Remove(archive.Name())
+		writeJSONError(w, http.StatusInternalServerError, "could not store archive")
+		return
+	}
+
+	op, err := h.WS.ImportWorld(claims, r.FormValue("name"), r.FormValue("environment"), archive, size)
+	writeWorldOperation(w, op, err)
+}
+
+func (h *UIHandler) requireWorldPermission(w http.ResponseWriter, r *http.Request, permission string) (SessionClaims, bool) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return SessionClaims{}, false
+	}
+	claims, permissions, ok := h.requireAuthForAPI(w, r)
+	if !ok {
+		return SessionClaims{}, false
+	}
+	if !HasPermission(permissions, permission) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return SessionClaims{}, false
+	}
+	return claims, true
+}
+
+func writeWorldOperation(w http.ResponseWriter, op WorldOperation, err error) {
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusAccepted, map[string]any{"operation": op})
+	case errors.Is(err, ErrInvalidWorld):
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	default:
+		writePluginError(w, err)
+	}
+}
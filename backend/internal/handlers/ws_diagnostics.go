@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/audit"
+	"github.com/adammcgrogan/beacon/internal/diagnostics"
+	"github.com/adammcgrogan/beacon/internal/protocol"
+)
+
+// ErrInvalidDumpKind is returned for a dump kind other than a thread dump or
+// heap histogram.
+var ErrInvalidDumpKind = errors.New("kind must be thread_dump or heap_histogram")
+
+const (
+	// crashScanPoll is how often the scan loop checks for a newly connected
+	// plugin, which is scanned straight away.
+	crashScanPoll = 15 * time.Second
+	// maxCrashReadsPerScan bounds how many crash files one scan reads; the
+	// rest are read on the next scan.
+	maxCrashReadsPerScan = 20
+	// dumpTimeout bounds taking a dump: a heap histogram runs a full garbage
+	// collection first, which takes a while on a large heap.
+	dumpTimeout = time.Minute
+)
+
+type diagnosticsRequest struct {
+	Action string `json:"action"`
+	Path   string `json:"path,omitempty"`
+}
+
+type diagnosticsData struct {
+	Files     []diagnostics.File          `json:"files"`
+	Plugins   []diagnostics.PluginPackage `json:"plugins"`
+	Path      string                      `json:"path"`
+	Content   string                      `json:"content"`
+	Truncated bool                        `json:"truncated"`
+}
+
+type diagnosticsResponse struct {
+	RequestID string          `json:"request_id"`
+	OK        bool            `json:"ok"`
+	Error     string          `json:"error"`
+	Data      diagnosticsData `json:"data"`
+}
+
+var (
+	diagnosticsRPC = newPluginRPC[diagnosticsRequest, diagnosticsResponse]("diagnostics_request", protocol.EventDiagnosticsResponse, protocol.CapDiagnostics, 0, 2)
+	dumpRPC        = newPluginRPC[diagnosticsRequest, diagnosticsResponse]("diagnostics_request", protocol.EventDiagnosticsResponse, protocol.CapDiagnostics, dumpTimeout, 1)
+)
+
+func (m *WebSocketManager) diagnosticsCall(ctx context.Context, rpc pluginRPC[diagnosticsRequest, diagnosticsResponse], req diagnosticsRequest) (diagnosticsData, error) {
+	resp, err := callPlugin(ctx, m, rpc, req)
+	if err != nil {
+		return diagnosticsData{}, err
+	}
+	if !resp.OK {
+		if resp.Error == "" {
+			resp.Error = req.Action + " failed"
+		}
+		return diagnosticsData{}, errors.New(resp.Error)
+	}
+	return resp.Data, nil
+}
+
+// ScanCrashReports asks the plugin for the crash reports and JVM fatal error
+// logs on the server, parses the ones not seen before and announces new
+// crashes. It returns the reports parsed by this scan.
+func (m *WebSocketManager) ScanCrashReports(ctx context.Context) ([]diagnostics.Report, error) {
+	if m.CrashReports == nil {
+		return nil, errors.New("crash report history is not enabled")
+	}
+	listing, err := m.diagnosticsCall(ctx, diagnosticsRPC, diagnosticsRequest{Action: "list"})
+	if err != nil {
+		return nil, err
+	}
+
+	unseen := m.CrashReports.Unseen(listing.Files)
+	if len(unseen) > maxCrashReadsPerScan {
+		unseen = unseen[:maxCrashReadsPerScan]
+	}
+	found := make([]diagnostics.Report, 0, len(unseen))
+	for _, file := range unseen {
+		read, err := m.diagnosticsCall(ctx, diagnosticsRPC, diagnosticsRequest{Action: "read", Path: file.Path})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			log.Printf("beacon diagnostics: could not read %s: %v", file.Path, err)
+			continue
+		}
+		var report diagnostics.Report
+		if file.Kind == diagnostics.KindFatalError {
+			report = diagnostics.ParseFatalError(read.Content, listing.Plugins)
+		} else {
+			report = diagnostics.ParseCrashReport(read.Content, listing.Plugins)
+		}
+		report.Path, report.Kind, report.Size, report.Modified = file.Path, file.Kind, file.Size, file.Modified
+		report.DetectedAt = time.Now().UTC()
+		report.Truncated = read.Truncated
+		if report.Time.IsZero() {
+			report.Time = file.Modified
+		}
+		found = append(found, report)
+	}
+
+	for _, report := range m.CrashReports.Record(listing.Files, found) {
+		m.announceCrash(report)
+	}
+	return found, nil
+}
+
+func (m *WebSocketManager) announceCrash(report diagnostics.Report) {
+	detail := report.Exception
+	if report.Culprit != "" {
+		detail = fmt.Sprintf("%s (culprit: %s)", detail, report.Culprit)
+	}
+	m.audit(SessionClaims{PlayerName: "Beacon"}, audit.Entry{
+		Action: "crash.detected",
+		Target: report.Path,
+		Detail: detail,
+		OK:     true,
+	})
+	message, err := json.Marshal(map[string]any{
+		"event":   "crash_report",
+		"payload": crashReportView(report),
+	})
+	if err != nil {
+		return
+	}
+	m.broadcastToWeb("crash_report", message)
+}
+
+// RunCrashReportScan checks for new crash files each time the plugin
+// connects, which is usually right after the server came back from a crash,
+// and at the configured interval while it stays connected. The returned
+// channel is closed once the loop has stopped.
+func (m *WebSocketManager) RunCrashReportScan(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	interval := m.DiagnosticsConfig.ScanInterval
+	if interval <= 0 || m.CrashReports == nil {
+		close(done)
+		return done
+	}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(crashScanPoll)
+		defer ticker.Stop()
+		var lastScan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if !m.isMinecraftConnected() {
+				lastScan = time.Time{}
+				continue
+			}
+			if !m.pluginSupports(protocol.CapDiagnostics) || time.Since(lastScan) < interval {
+				continue
+			}
+			lastScan = time.Now()
+			// Each plugin call is bounded by the request timeout.
+			_, err := m.ScanCrashReports(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("beacon diagnostics: crash report scan failed: %v", err)
+			}
+		}
+	}()
+	return done
+}
+
+// CaptureDump takes a thread dump or heap histogram of the running server
+// through the plugin and stores it.
+func (m *WebSocketManager) CaptureDump(ctx context.Context, issuer SessionClaims, kind string) (diagnostics.Dump, error) {
+	if kind != diagnostics.DumpThreads && kind != diagnostics.DumpHeapHistogram {
+		return diagnostics.Dump{}, ErrInvalidDumpKind
+	}
+	if m.Dumps == nil {
+		return diagnostics.Dump{}, errors.New("dump storage is not enabled")
+	}
+	data, err := m.diagnosticsCall(ctx, dumpRPC, diagnosticsRequest{Action: kind})
+	var dump diagnostics.Dump
+	if err == nil {
+		dump, err = m.Dumps.Save(kind, issuer.PlayerName, data.Content)
+	}
+
+	entry := audit.Entry{Action: "diagnostics." + kind, Target: dump.ID, OK: err == nil}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	m.audit(issuer, entry)
+	return dump, err
+}
@@ -9,11 +9,13 @@ import (
 	"strings"
 	"time"
 
-	"github.com/gorilla/websocket"
+	"github.com/adammcgrogan/beacon/internal/protocol"
 )
 
 var ErrPluginOffline = errors.New("server is offline")
 
+const defaultPluginRequestTimeout = 12 * time.Second
+
 type fileManagerResponse struct {
 	RequestID string          `json:"request_id"`
 	OK        bool            `json:"ok"`
@@ -21,92 +23,27 @@ type fileManagerResponse struct {
 	Data      json.RawMessage `json:"data"`
 }
 
-func (m *WebSocketManager) RequestFileManagerOperation(ctx context.Context, action string, path string, content string) (fileManagerResponse, error) {
-	if !m.isMinecraftConnected() {
-		return fileManagerResponse{}, ErrPluginOffline
-	}
-
-	requestID, err := randomHex(16)
-	if err != nil {
-		return fileManagerResponse{}, err
-	}
-
-	responseChan := make(chan fileManagerResponse, 1)
-	m.fileReqLock.Lock()
-	if m.pendingFileReqByID == nil {
-		m.pendingFileReqByID = make(map[string]chan fileManagerResponse)
-	}
-	m.pendingFileReqByID[requestID] = responseChan
-	m.fileReqLock.Unlock()
-
-	defer func() {
-		m.fileReqLock.Lock()
-		delete(m.pendingFileReqByID, requestID)
-		m.fileReqLock.Unlock()
-	}()
-
-	payload := map[string]string{
-		"request_id": requestID,
-		"action":     action,
-		"path":       path,
-	}
-	if content != "" {
-		payload["content"] = content
-	}
-
-	message, err := json.Marshal(map[string]interface{}{
-		"event":   "file_manager_request",
-		"payload": payload,
-	})
-	if err != nil {
-		return fileManagerResponse{}, err
-	}
-
-	m.mcConnLock.RLock()
-	mcConn := m.mcConn
-	m.mcConnLock.RUnlock()
-	if mcConn == nil {
-		return fileManagerResponse{}, ErrPluginOffline
-	}
-	m.mcWriteLock.Lock()
-	err = mcConn.WriteMessage(websocket.TextMessage, message)
-	m.mcWriteLock.Unlock()
-	if err != nil {
-		return fileManagerResponse{}, ErrPluginOffline
-	}
-
-	select {
-	case response := <-responseChan:
-		return response, nil
-	case <-ctx.Done():
-		return fileManagerResponse{}, ctx.Err()
-	}
+type fileManagerRequest struct {
+	Action  string `json:"action"`
+	Path    string `json:"path"`
+	Content string `json:"content,omitempty"`
 }
 
-func (m *WebSocketManager) resolvePendingFileRequest(response fileManagerResponse) {
-	m.fileReqLock.Lock()
-	defer m.fileReqLock.Unlock()
+var fileManagerRPC = newPluginRPC[fileManagerRequest, fileManagerResponse]("file_manager_request", protocol.EventFileManagerResponse, protocol.CapFileManager, 0, 0)
 
-	ch, ok := m.pendingFileReqByID[response.RequestID]
-	if !ok {
-		return
-	}
-
-	select {
-	case ch <- response:
-	default:
-	}
+func (m *WebSocketManager) RequestFileManagerOperation(ctx context.Context, action string, path string, content string) (fileManagerResponse, error) {
+	return callPlugin(ctx, m, fileManagerRPC, fileManagerRequest{
+		Action:  action,
+		Path:    path,
+		Content: content,
+	})
 }
 
-func (m *WebSocketManager) failAllPendingFileRequests(message string) {
-	m.fileReqLock.Lock()
-	defer m.fileReqLock.Unlock()
-	for id, ch := range m.pendingFileReqByID {
-		select {
-		case ch <- fileManagerResponse{RequestID: id, OK: false, Error: message}:
-		default:
-		}
+func (m *WebSocketManager) pluginRequestTimeout() time.Duration {
+	if m.RequestTimeout <= 0 {
+		return defaultPluginRequestTimeout
 	}
+	return m.RequestTimeout
 }
 
 func randomHex(numBytes int) (string, error) {
@@ -117,8 +54,8 @@ func randomHex(numBytes int) (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
-func (m *WebSocketManager) sendLatestLogSnapshot(conn *websocket.Conn) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 12*time.Second)
+func (m *WebSocketManager) sendLatestLogSnapshot(client *webClient) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.pluginRequestTimeout())
 	defer cancel()
 
 	resp, err := m.RequestFileManagerOperation(ctx, "read_text", "logs/latest.log", "")
@@ -163,8 +100,9 @@ func (m *WebSocketManager) sendLatestLogSnapshot(conn *websocket.Conn) error {
 			continue
 		}
 
-		if err := conn.WriteMessage(websocket.TextMessage, envelope); err != nil {
-			return err
+		// The browser went away mid-snapshot; nothing left to fall back to.
+		if !client.push(envelope) {
+			return nil
 		}
 	}
 
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/adammcgrogan/beacon/internal/audit"
+	"github.com/adammcgrogan/beacon/internal/gamerules"
+	"github.com/adammcgrogan/beacon/internal/protocol"
+)
+
+// maxGameruleWorlds bounds how many worlds one bulk apply may touch.
+const maxGameruleWorlds = 50
+
+// ErrInvalidGamerules is returned for a malformed bulk gamerule request.
+var ErrInvalidGamerules = errors.New("invalid gamerule request")
+
+// GameruleResult is the outcome of setting one rule in one world.
+type GameruleResult struct {
+	World      string `json:"world"`
+	Rule       string `json:"rule"`
+	Previous   string `json:"previous"`
+	Value      string `json:"value"`
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	RolledBack bool   `json:"rolled_back,omitempty"`
+}
+
+// GameruleApply is the outcome of a bulk apply. When Rollback was asked for
+// and any rule failed, every rule that had been set is restored and
+// RolledBack is true.
+type GameruleApply struct {
+	OK         bool             `json:"ok"`
+	RolledBack bool             `json:"rolled_back"`
+	Results    []GameruleResult `json:"results"`
+}
+
+type gameruleApplyRequest struct {
+	Action   string            `json:"action"`
+	Worlds   []string          `json:"worlds"`
+	Rules    map[string]string `json:"rules"`
+	Rollback bool              `json:"rollback"`
+}
+
+type gameruleApplyResponse struct {
+	RequestID string        `json:"request_id"`
+	OK        bool          `json:"ok"`
+	Error     string        `json:"error"`
+	Data      GameruleApply `json:"data"`
+}
+
+var gameruleRPC = newPluginRPC[gameruleApplyRequest, gameruleApplyResponse]("gamerules_request", protocol.EventGamerulesResponse, protocol.CapGamerules, 0, 0)
+
+// GameruleDefaults are the defaults the plugin last reported.
+func (m *WebSocketManager) GameruleDefaults() map[string]string {
+	defaults := m.Store.GetStats().DefaultGamerules
+	if defaults == nil {
+		return map[string]string{}
+	}
+	return defaults
+}
+
+// ValidateGamerule checks a rule is well formed and, once the plugin has
+// reported its defaults, that the server knows it.
+func (m *WebSocketManager) ValidateGamerule(rule, value string) error {
+	if err := protocol.ValidateGamerule(rule, value); err != nil {
+		return err
+	}
+	if defaults := m.GameruleDefaults(); len(defaults) > 0 {
+		if _, ok := defaults[rule]; !ok {
+			return fmt.Errorf("unknown gamerule %s", rule)
+		}
+	}
+	return nil
+}
+
+// GameruleDiff compares each listed loaded world (all of them when worlds is
+// empty) with a preset, or with the server defaults when preset is nil.
+func (m *WebSocketManager) GameruleDiff(worlds []string, preset *gamerules.Preset) map[string][]gamerules.Difference {
+	defaults := m.GameruleDefaults()
+	expected := defaults
+	if preset != nil {
+		expected = preset.Rules
+	}
+	out := make(map[string][]gamerules.Difference)
+	for _, world := range m.Store.GetWorlds() {
+		if !world.Loaded || (len(worlds) > 0 && !slices.ContainsFunc(worlds, func(name string) bool { return strings.EqualFold(name, world.Name) })) {
+			continue
+		}
+		out[world.Name] = gamerules.Diff(world.Gamerules, expected, defaults)
+	}
+	return out
+}
+
+// ApplyGamerules sets rules in every listed world in one go. The plugin
+// reports each rule's outcome; with rollback, one failure restores everything
+// that was already changed.
+func (m *WebSocketManager) ApplyGamerules(ctx context.Context, issuer SessionClaims, worlds []string, rules map[string]string, rollback bool) (GameruleApply, error) {
+	if len(worlds) == 0 || len(worlds) > maxGameruleWorlds {
+		return GameruleApply{}, fmt.Errorf("%w: choose between 1 and %d worlds", ErrInvalidGamerules, maxGameruleWorlds)
+	}
+	if len(rules) == 0 || len(rules) > gamerules.MaxRules {
+		return GameruleApply{}, fmt.Errorf("%w: set between 1 and %d rules", ErrInvalidGamerules, gamerules.MaxRules)
+	}
+
+	names := make([]string, 0, len(worlds))
+	for _, name := range worlds {
+		world, ok := m.loadedWorld(strings.TrimSpace(name))
+		if !ok {
+			return GameruleApply{}, fmt.Errorf("%w: %s is not a loaded world", ErrInvalidGamerules, name)
+		}
+		if !slices.Contains(names, world) {
+			names = append(names, world)
+		}
+	}
+	cleaned := make(map[string]string, len(rules))
+	for rule, value := range rules {
+		rule, value = strings.TrimSpace(rule), strings.TrimSpace(value)
+		if err := m.ValidateGamerule(rule, value); err != nil {
+			return GameruleApply{}, fmt.Errorf("%w: %v", ErrInvalidGamerules, err)
+		}
+		cleaned[rule] = value
+	}
+
+	resp, err := callPlugin(ctx, m, gameruleRPC, gameruleApplyRequest{
+		Action:   "apply",
+		Worlds:   names,
+		Rules:    cleaned,
+		Rollback: rollback,
+	})
+	if err == nil && !resp.OK {
+		if resp.Error == "" {
+			resp.Error = "apply failed"
+		}
+		err = errors.New(resp.Error)
+	}
+
+	entry := audit.Entry{
+		Action: "gamerules.apply",
+		Target: strings.Join(names, ", "),
+		Detail: strings.Join(slices.Sorted(maps.Keys(cleaned)), ", "),
+	}
+	if err != nil {
+		entry.Error = pluginErrorMessage(err)
+		m.audit(issuer, entry)
+		return GameruleApply{}, err
+	}
+	result := resp.Data
+	if result.Results == nil {
+		result.Results = []GameruleResult{}
+	}
+	entry.OK = result.OK
+	if result.RolledBack {
+		entry.Error = "rolled back after a rule failed"
+	}
+	m.audit(issuer, entry)
+	return result, nil
+}
+
+// loadedWorld finds a loaded world by name, ignoring case, and returns its
+// exact name.
+func (m *WebSocketManager) loadedWorld(name string) (string, bool) {
+	for _, world := range m.Store.GetWorlds() {
+		if world.Loaded && strings.EqualFold(world.Name, name) {
+			return world.Name, true
+		}
+	}
+	return "", false
+}
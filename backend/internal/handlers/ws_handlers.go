@@ -7,142 +7,196 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/adammcgrogan/beacon/internal/audit"
+	"github.com/adammcgrogan/beacon/internal/commandpolicy"
+	"github.com/adammcgrogan/beacon/internal/config"
+	"github.com/adammcgrogan/beacon/internal/diagnostics"
+	"github.com/adammcgrogan/beacon/internal/gamerules"
+	"github.com/adammcgrogan/beacon/internal/history"
+	"github.com/adammcgrogan/beacon/internal/hotspots"
 	"github.com/adammcgrogan/beacon/internal/models"
+	"github.com/adammcgrogan/beacon/internal/moderation"
+	"github.com/adammcgrogan/beacon/internal/players"
+	"github.com/adammcgrogan/beacon/internal/protocol"
 	"github.com/adammcgrogan/beacon/internal/store"
+	"github.com/adammcgrogan/beacon/internal/supervisor"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
-
 type WebSocketManager struct {
-	Store       *store.ServerStore
-	Auth        *AuthManager
-	webClients  map[*websocket.Conn]bool
-	clientsLock sync.Mutex
+	Store *store.ServerStore
+	Auth  *AuthManager
+	// History, when set, records player sessions from server_stats and console lines.
+	History *history.Tracker
+	// Players, when set, keeps the offline player directory.
+	Players *players.Directory
+	// Moderation, when set, tracks mutes and schedules automatic unbans.
+	Moderation *moderation.Registry
+	// Audit, when set, records whitelist, operator and other admin changes.
+	Audit *audit.Log
+	// GamerulePresets, when set, stores named gamerule presets.
+	GamerulePresets *gamerules.Presets
+	// Hotspots, when set, keeps the history of chunk hotspot scans.
+	Hotspots *hotspots.History
+	// HotspotsConfig schedules hotspot scans and sizes them.
+	HotspotsConfig config.HotspotsConfig
+	// CrashReports remembers the server's crash files; Dumps stores thread
+	// dumps and heap histograms and Profiles sampling profiles, all taken
+	// through the plugin.
+	CrashReports      *diagnostics.Reports
+	Dumps             *diagnostics.Dumps
+	Profiles          *diagnostics.Profiles
+	DiagnosticsConfig config.DiagnosticsConfig
+	// Process, when set, runs the server process; see AttachProcess.
+	Process *supervisor.Supervisor
+	// Commands decides which console commands panel users may run; nil uses the built-in rules.
+	Commands *commandpolicy.Policy
+	// RateLimits, when set, throttles /ws/web events and is shared with the API handlers.
+	RateLimits *RateLimiter
+	// RequestTimeout bounds how long requests wait for the plugin; zero uses the default.
+	RequestTimeout time.Duration
+	// MaxInFlight caps requests waiting on the plugin at once; zero uses the default.
+	MaxInFlight int
+	// Compression negotiates permessage-deflate with plugins and browsers that offer it.
+	Compression bool
+	// BinaryFrames lets the plugin send MessagePack binary frames if it offers them.
+	BinaryFrames bool
+	// PluginSecret, when set, must be presented by the plugin as a bearer token,
+	// and is presented to the plugin in reverse-connect mode.
+	PluginSecret string
+	// Web tunes per-browser send queues and keepalives; zero fields use the defaults.
+	Web config.WebConfig
+
+	webClients  map[*webClient]struct{}
+	clientsLock sync.RWMutex
+	webMetrics  webMetrics
 	mcConn      *websocket.Conn
 	mcConnLock  sync.RWMutex
 	mcWriteLock sync.Mutex
 
-	fileReqLock        sync.Mutex
-	pendingFileReqByID map[string]chan fileManagerResponse
+	shuttingDown atomic.Bool
 
-	permReqLock        sync.Mutex
-	pendingPermReqByID map[string]chan playerPermissionsResponse
+	rpc      rpcBroker
+	proto    pluginProtocol
+	worlds   worldOperations
+	profiler profilerState
 
-	permissionAdminReqLock        sync.Mutex
-	pendingPermissionAdminReqByID map[string]chan permissionAdminResponse
+	// lastProcessCrash is only touched from the supervisor's state callback.
+	lastProcessCrash time.Time
 }
 
 // HandleMinecraft handles the connection from the Java plugin
 func (m *WebSocketManager) HandleMinecraft(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	if m.shuttingDown.Load() {
+		http.Error(w, "backend shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	if !m.pluginAuthorized(r.Header.Get("Authorization")) {
+		http.Error(w, "invalid plugin secret", http.StatusUnauthorized)
+		return
+	}
+	conn, err := m.upgrader().Upgrade(w, r, nil)
 	if err != nil {
 		return
 	}
+	m.serveMinecraft(conn)
+}
+
+// serveMinecraft runs the plugin session on conn until it disconnects,
+// whichever side opened the socket.
+func (m *WebSocketManager) serveMinecraft(conn *websocket.Conn) {
 	defer conn.Close()
 
+	m.proto.reset()
 	m.setMinecraftConn(conn)
 	m.Store.ClearLogs()
 	fmt.Println("🟢 Minecraft Server Connected!")
 	m.broadcastPluginStatus(true)
+	if m.Players != nil {
+		go m.importUserCache()
+	}
 
 	defer func() {
 		m.setMinecraftConn(nil)
-		m.failAllPendingFileRequests("plugin disconnected")
-		m.failAllPendingPermissionRequests()
-		m.failAllPendingPermissionAdminRequests()
+		m.failAllPending()
+		m.abandonWorldOperations()
+		if m.History != nil {
+			m.History.MarkAllOffline(time.Now())
+		}
 		m.broadcastPluginStatus(false)
 		fmt.Println("🔴 Minecraft Server Disconnected.")
 	}()
 
 	for {
-		_, messageBytes, err := conn.ReadMessage()
+		messageType, messageBytes, err := conn.ReadMessage()
 		if err != nil {
 			break
 		}
+		if messageType == websocket.BinaryMessage {
+			if messageBytes, err = m.decodeBinaryFrame(messageBytes); err != nil {
+				m.proto.reject("", err)
+				continue
+			}
+		}
 
-		shouldBroadcast := m.processMinecraftMessage(messageBytes)
+		event, shouldBroadcast := m.processMinecraftMessage(messageBytes)
 		if shouldBroadcast {
-			m.broadcastToWeb(messageBytes)
+			m.broadcastToWeb(event, messageBytes)
 		}
 	}
 }
 
-func (m *WebSocketManager) processMinecraftMessage(messageBytes []byte) bool {
-	var envelope struct {
-		Event   string          `json:"event"`
-		Payload json.RawMessage `json:"payload"`
-	}
-
-	if err := json.Unmarshal(messageBytes, &envelope); err != nil {
-		return true
+func (m *WebSocketManager) processMinecraftMessage(messageBytes []byte) (string, bool) {
+	msg, err := protocol.DecodePluginMessage(messageBytes)
+	if err != nil {
+		m.proto.reject(msg.Event, err)
+		return msg.Event, false
 	}
 
-	switch envelope.Event {
-	case "server_stats":
-		var stats models.ServerStats
-		if err := json.Unmarshal(envelope.Payload, &stats); err == nil {
-			m.Store.UpdateStats(stats)
+	switch payload := msg.Payload.(type) {
+	case *protocol.Hello:
+		m.handlePluginHello(*payload)
+	case *protocol.RPCResponse:
+		m.rpc.resolve(msg.Event, msg.RawPayload)
+	case *protocol.ServerStats:
+		m.Store.UpdateStats(payload.ServerStats)
+		if m.History != nil {
+			m.History.Observe(payload.PlayerList, time.Now())
 		}
-	case "console_log":
-		m.Store.AddLog(messageBytes)
-	case "world_stats":
-		var worlds []models.WorldInfo
-		if err := json.Unmarshal(envelope.Payload, &worlds); err == nil {
-			m.Store.UpdateWorlds(worlds)
-		}
-	case "server_env":
-		var env models.ServerEnv
-		if err := json.Unmarshal(envelope.Payload, &env); err == nil {
-			m.Store.UpdateEnv(env)
-		}
-	case "plugin_paths":
-		if m.Auth != nil {
-			var payload struct {
-				PluginDataDir string `json:"plugin_data_dir"`
-			}
-			if err := json.Unmarshal(envelope.Payload, &payload); err == nil {
-				m.Auth.SetPluginDataDir(payload.PluginDataDir)
+		if m.Players != nil {
+			for _, player := range payload.PlayerList {
+				m.Players.Seen(player.UUID, player.Name, time.Now())
 			}
 		}
-		return false
-	case "file_manager_response":
-		var response fileManagerResponse
-		if err := json.Unmarshal(envelope.Payload, &response); err == nil {
-			m.resolvePendingFileRequest(response)
+	case *protocol.ConsoleLog:
+		m.Store.AddLog(messageBytes)
+		if m.History != nil {
+			m.History.ObserveLogLine(payload.Message, time.Now())
 		}
-		return false
-	case "auth_token_issued":
+	case *protocol.WorldProgress:
+		m.handleWorldProgress(*payload)
+	case *protocol.WorldStats:
+		m.Store.UpdateWorlds(*payload)
+	case *models.ServerEnv:
+		m.Store.UpdateEnv(*payload)
+	case *protocol.PluginPaths:
 		if m.Auth != nil {
-			var payload struct {
-				Token         string   `json:"token"`
-				PlayerUUID    string   `json:"player_uuid"`
-				PlayerName    string   `json:"player_name"`
-				ExpiresAtUnix int64    `json:"expires_at_unix"`
-				Permissions   []string `json:"permissions"`
-			}
-			if err := json.Unmarshal(envelope.Payload, &payload); err == nil {
-				m.Auth.StoreMagicToken(payload.Token, payload.PlayerUUID, payload.PlayerName, payload.ExpiresAtUnix, payload.Permissions)
-			}
+			m.Auth.SetPluginDataDir(payload.PluginDataDir)
 		}
-		return false
-	case "player_permissions_response":
-		var response playerPermissionsResponse
-		if err := json.Unmarshal(envelope.Payload, &response); err == nil {
-			m.resolvePendingPermissionsRequest(response)
+	case *protocol.PlayerJoin:
+		if m.Players != nil {
+			m.Players.Joined(payload.UUID, payload.Name, payload.Address, time.Now())
 		}
-		return false
-	case "permission_admin_response":
-		var response permissionAdminResponse
-		if err := json.Unmarshal(envelope.Payload, &response); err == nil {
-			m.resolvePendingPermissionAdminRequest(response)
+	case *protocol.AuthTokenIssued:
+		if m.Auth != nil {
+			m.Auth.StoreMagicToken(payload.Token, payload.PlayerUUID, payload.PlayerName, payload.ExpiresAtUnix, payload.Permissions)
 		}
-		return false
 	}
 
-	return true
+	return msg.Event, msg.Broadcast
 }
 
 // HandleWeb handles browser UI connections
@@ -151,26 +205,38 @@ func (m *WebSocketManager) HandleWeb(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "auth unavailable", http.StatusServiceUnavailable)
 		return
 	}
+	if m.shuttingDown.Load() {
+		http.Error(w, "backend shutting down", http.StatusServiceUnavailable)
+		return
+	}
 	session, err := m.Auth.ReadSessionClaims(r)
 	if err != nil {
 		http.Error(w, "authentication required", http.StatusUnauthorized)
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := m.upgrader().Upgrade(w, r, nil)
 	if err != nil {
 		return
 	}
 
-	m.registerWebClient(conn)
-	defer m.unregisterWebClient(conn)
-	m.sendPluginStatus(conn)
+	client := newWebClient(conn, m.webSettings(), &m.webMetrics)
+	go client.writeLoop()
+	client.keepAlive()
+
+	m.registerWebClient(client)
+	defer m.unregisterWebClient(client)
+	m.sendPluginStatus(client)
+	m.sendProcessStatus(client)
+	eventRate := m.webEventRate(r.Context(), session)
 
 	// Send latest.log snapshot on connect, then continue with live socket stream.
-	if err := m.sendLatestLogSnapshot(conn); err != nil {
+	if err := m.sendLatestLogSnapshot(client); err != nil {
 		// Fallback to in-memory history if file snapshot is unavailable.
 		for _, msg := range m.Store.GetLogs() {
-			_ = conn.WriteMessage(websocket.TextMessage, msg)
+			if !client.push(msg) {
+				break
+			}
 		}
 	}
 
@@ -186,78 +252,155 @@ func (m *WebSocketManager) HandleWeb(w http.ResponseWriter, r *http.Request) {
 		if err := json.Unmarshal(messageBytes, &envelope); err != nil {
 			continue
 		}
+		if ok, wait := m.RateLimits.allowEvent(session.SessionID, eventRate); !ok {
+			message, _ := json.Marshal(map[string]any{
+				"event":   "rate_limited",
+				"payload": map[string]any{"event": envelope.Event, "retry_after_ms": wait.Milliseconds()},
+			})
+			client.enqueue("rate_limited", message)
+			continue
+		}
 
 		switch envelope.Event {
 		case "plugin_status_request":
-			m.sendPluginStatus(conn)
+			m.sendPluginStatus(client)
 			continue
 		case "clear_logs":
 			if !m.authorizeSessionEvent(r.Context(), session, envelope.Event, messageBytes) {
-				_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"permission_denied","payload":{"reason":"clear_logs"}}`))
+				client.enqueue("permission_denied", []byte(`{"event":"permission_denied","payload":{"reason":"clear_logs"}}`))
 				continue
 			}
 			m.Store.ClearLogs()
-			m.broadcastToWeb([]byte(`{"event":"clear_logs"}`))
+			m.broadcastToWeb("clear_logs", []byte(`{"event":"clear_logs"}`))
+			continue
+		case "moderation_action":
+			if !m.authorizeSessionEvent(r.Context(), session, envelope.Event, messageBytes) {
+				client.enqueue("permission_denied", []byte(`{"event":"permission_denied","payload":{"reason":"forbidden"}}`))
+				continue
+			}
+			go m.handleModerationEvent(r.Context(), client, session, messageBytes)
+			continue
+		case protocol.EventWorldAction:
+			if !m.authorizeSessionEvent(r.Context(), session, envelope.Event, messageBytes) {
+				client.enqueue("permission_denied", []byte(`{"event":"permission_denied","payload":{"reason":"forbidden"}}`))
+				continue
+			}
+			m.forwardWorldAction(client, messageBytes)
 			continue
 		}
 
 		if !m.authorizeSessionEvent(r.Context(), session, envelope.Event, messageBytes) {
-			_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"permission_denied","payload":{"reason":"forbidden"}}`))
+			client.enqueue("permission_denied", []byte(`{"event":"permission_denied","payload":{"reason":"forbidden"}}`))
 			continue
 		}
 
-		m.forwardToMinecraft(conn, messageBytes)
+		if m.forwardToMinecraft(client, envelope.Event, messageBytes) && envelope.Event == "console_command" {
+			m.recordPanelModeration(session, messageBytes)
+		}
 	}
 }
 
-func (m *WebSocketManager) forwardToMinecraft(webConn *websocket.Conn, raw []byte) {
+// forwardToMinecraft relays a browser event to the plugin and reports whether
+// it was sent; the browser is told why when it was not.
+func (m *WebSocketManager) forwardToMinecraft(client *webClient, event string, raw []byte) bool {
 	if !m.isMinecraftConnected() {
-		_ = webConn.WriteMessage(websocket.TextMessage, []byte(`{"event":"command_rejected","payload":{"reason":"plugin_offline"}}`))
-		return
+		client.enqueue("command_rejected", []byte(`{"event":"command_rejected","payload":{"reason":"plugin_offline"}}`))
+		return false
+	}
+
+	if !m.pluginSupports(webEventCapability(event)) {
+		client.enqueue("command_rejected", []byte(`{"event":"command_rejected","payload":{"reason":"plugin_unsupported"}}`))
+		return false
+	}
+
+	if err := m.writeToMinecraft(raw); err != nil {
+		client.enqueue("command_rejected", []byte(`{"event":"command_rejected","payload":{"reason":"plugin_offline"}}`))
+		if err != ErrPluginOffline {
+			m.setMinecraftConn(nil)
+			m.failAllPending()
+			m.broadcastPluginStatus(false)
+		}
+		return false
 	}
+	return true
+}
 
+// writeToMinecraft sends one message to the plugin, serialized with every
+// other writer. It returns ErrPluginOffline when no plugin is connected.
+func (m *WebSocketManager) writeToMinecraft(message []byte) error {
 	m.mcConnLock.RLock()
 	mcConn := m.mcConn
 	m.mcConnLock.RUnlock()
 	if mcConn == nil {
-		_ = webConn.WriteMessage(websocket.TextMessage, []byte(`{"event":"command_rejected","payload":{"reason":"plugin_offline"}}`))
-		return
+		return ErrPluginOffline
 	}
 
 	m.mcWriteLock.Lock()
-	err := mcConn.WriteMessage(websocket.TextMessage, raw)
-	m.mcWriteLock.Unlock()
-	if err != nil {
-		_ = webConn.WriteMessage(websocket.TextMessage, []byte(`{"event":"command_rejected","payload":{"reason":"plugin_offline"}}`))
-		m.setMinecraftConn(nil)
-		m.failAllPendingFileRequests("plugin disconnected")
-		m.failAllPendingPermissionRequests()
-		m.failAllPendingPermissionAdminRequests()
-		m.broadcastPluginStatus(false)
+	defer m.mcWriteLock.Unlock()
+	return mcConn.WriteMessage(websocket.TextMessage, message)
+}
+
+// Shutdown sends a close frame with code and reason to every browser and to the
+// plugin, fails outstanding plugin requests with reason, and refuses new
+// connections from then on. Use websocket.CloseServiceRestart when a new
+// backend process is taking over so clients reconnect straight away.
+func (m *WebSocketManager) Shutdown(code int, reason string) {
+	m.shuttingDown.Store(true)
+	closeMessage := websocket.FormatCloseMessage(code, reason)
+	deadline := time.Now().Add(2 * time.Second)
+
+	m.clientsLock.RLock()
+	for client := range m.webClients {
+		_ = client.conn.WriteControl(websocket.CloseMessage, closeMessage, deadline)
+		client.close()
+	}
+	m.clientsLock.RUnlock()
+
+	m.mcConnLock.RLock()
+	mcConn := m.mcConn
+	m.mcConnLock.RUnlock()
+	if mcConn != nil {
+		_ = mcConn.WriteControl(websocket.CloseMessage, closeMessage, deadline)
+		_ = mcConn.Close()
 	}
+	m.setMinecraftConn(nil)
+	m.failAllPending()
+}
+
+// failAllPending resolves every outstanding plugin request with
+// ErrPluginOffline so callers stop waiting, e.g. when the plugin socket goes away.
+func (m *WebSocketManager) failAllPending() {
+	m.rpc.failAll(ErrPluginOffline)
 }
 
-func (m *WebSocketManager) registerWebClient(conn *websocket.Conn) {
+func (m *WebSocketManager) registerWebClient(client *webClient) {
 	m.clientsLock.Lock()
 	defer m.clientsLock.Unlock()
 	if m.webClients == nil {
-		m.webClients = make(map[*websocket.Conn]bool)
+		m.webClients = make(map[*webClient]struct{})
 	}
-	m.webClients[conn] = true
+	m.webClients[client] = struct{}{}
 }
 
-func (m *WebSocketManager) unregisterWebClient(conn *websocket.Conn) {
+func (m *WebSocketManager) unregisterWebClient(client *webClient) {
 	m.clientsLock.Lock()
-	defer m.clientsLock.Unlock()
-	delete(m.webClients, conn)
-	_ = conn.Close()
+	delete(m.webClients, client)
+	m.clientsLock.Unlock()
+	client.close()
 }
 
-func (m *WebSocketManager) broadcastToWeb(message []byte) {
-	m.clientsLock.Lock()
-	defer m.clientsLock.Unlock()
+// broadcastToWeb queues message for every browser without waiting on any of
+// them; event lets degraded clients sample console output.
+func (m *WebSocketManager) broadcastToWeb(event string, message []byte) {
+	m.clientsLock.RLock()
+	clients := make([]*webClient, 0, len(m.webClients))
 	for client := range m.webClients {
-		_ = client.WriteMessage(websocket.TextMessage, message)
+		clients = append(clients, client)
+	}
+	m.clientsLock.RUnlock()
+
+	for _, client := range clients {
+		client.enqueue(event, message)
 	}
 }
 
@@ -273,12 +416,12 @@ func (m *WebSocketManager) isMinecraftConnected() bool {
 	return m.mcConn != nil
 }
 
-func (m *WebSocketManager) sendPluginStatus(conn *websocket.Conn) {
+func (m *WebSocketManager) sendPluginStatus(client *webClient) {
 	status := "offline"
 	if m.isMinecraftConnected() {
 		status = "online"
 	}
-	_ = conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"event":"plugin_status","payload":{"status":"%s"}}`, status)))
+	client.enqueue("plugin_status", []byte(fmt.Sprintf(`{"event":"plugin_status","payload":{"status":"%s"}}`, status)))
 }
 
 func (m *WebSocketManager) broadcastPluginStatus(online bool) {
@@ -286,7 +429,7 @@ func (m *WebSocketManager) broadcastPluginStatus(online bool) {
 	if online {
 		status = "online"
 	}
-	m.broadcastToWeb([]byte(fmt.Sprintf(`{"event":"plugin_status","payload":{"status":"%s"}}`, status)))
+	m.broadcastToWeb("plugin_status", []byte(fmt.Sprintf(`{"event":"plugin_status","payload":{"status":"%s"}}`, status)))
 }
 
 func (m *WebSocketManager) authorizeSessionEvent(parent context.Context, session SessionClaims, event string, raw []byte) bool {
@@ -318,38 +461,34 @@ func (m *WebSocketManager) authorizeSessionEvent(parent context.Context, session
 		if err := json.Unmarshal(raw, &cmdEnvelope); err != nil {
 			return false
 		}
-		command := strings.ToLower(strings.TrimSpace(cmdEnvelope.Command))
-		switch {
-		case command == "stop" || strings.HasPrefix(command, "stop "):
-			return HasPermission(permissions, PermServerStop)
-		case command == "restart" || strings.HasPrefix(command, "restart "):
-			return HasPermission(permissions, PermServerRestart)
-		case command == "save-all" || strings.HasPrefix(command, "save-all "):
-			return HasPermission(permissions, PermServerSaveAll)
-		case command == "kick" || strings.HasPrefix(command, "kick "):
-			return HasPermission(permissions, PermPlayersKick)
-		case command == "ban" || strings.HasPrefix(command, "ban "):
-			return HasPermission(permissions, PermPlayersBan)
-		default:
-			return HasPermission(permissions, PermConsoleUse)
-		}
+		return m.AuthorizeCommand(cmdEnvelope.Command, permissions).Allowed
 	case "world_action":
 		var worldEnvelope struct {
-			Payload struct {
-				Action string `json:"action"`
-			} `json:"payload"`
+			Payload protocol.WorldAction `json:"payload"`
 		}
 		if err := json.Unmarshal(raw, &worldEnvelope); err != nil {
 			return false
 		}
+		worldEnvelope.Payload.Normalize()
 		switch worldEnvelope.Payload.Action {
-		case "reset":
+		case protocol.WorldActionReset:
 			return HasPermission(permissions, PermWorldsReset)
-		case "set_gamerule":
+		case protocol.WorldActionSetGamerule:
 			return HasPermission(permissions, PermWorldsGamerules)
 		default:
 			return HasPermission(permissions, PermWorldsManage)
 		}
+	case "moderation_action":
+		var modEnvelope struct {
+			Payload struct {
+				Action string `json:"action"`
+			} `json:"payload"`
+		}
+		if err := json.Unmarshal(raw, &modEnvelope); err != nil {
+			return false
+		}
+		permission, ok := moderationPermission(strings.ToLower(strings.TrimSpace(modEnvelope.Payload.Action)))
+		return ok && HasPermission(permissions, permission)
 	case "clear_logs":
 		return HasPermission(permissions, PermConsoleUse)
 	default:
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/hotspots"
+	"github.com/adammcgrogan/beacon/internal/protocol"
+)
+
+// ErrUnknownWorld is returned when a world is not loaded on the server.
+var ErrUnknownWorld = errors.New("world is not loaded")
+
+type hotspotsRequest struct {
+	World string `json:"world"`
+	Top   int    `json:"top"`
+}
+
+type hotspotsResponse struct {
+	RequestID string        `json:"request_id"`
+	OK        bool          `json:"ok"`
+	Error     string        `json:"error"`
+	Data      hotspots.Scan `json:"data"`
+}
+
+// Scans walk every loaded chunk on the main thread, so only two may wait on
+// the plugin at once.
+var hotspotsRPC = newPluginRPC[hotspotsRequest, hotspotsResponse]("hotspots_request", protocol.EventHotspotsResponse, protocol.CapHotspots, 0, 2)
+
+// ScanHotspots asks the plugin for a loaded world's busiest chunks and
+// records the result.
+func (m *WebSocketManager) ScanHotspots(ctx context.Context, world string) (hotspots.Scan, error) {
+	name, ok := m.loadedWorld(world)
+	if !ok {
+		return hotspots.Scan{}, fmt.Errorf("%w: %s", ErrUnknownWorld, world)
+	}
+	top := m.HotspotsConfig.Top
+	if top <= 0 {
+		top = hotspots.DefaultTop
+	}
+	resp, err := callPlugin(ctx, m, hotspotsRPC, hotspotsRequest{World: name, Top: min(top, hotspots.MaxTop)})
+	if err != nil {
+		return hotspots.Scan{}, err
+	}
+	if !resp.OK {
+		if resp.Error == "" {
+			resp.Error = "scan failed"
+		}
+		return hotspots.Scan{}, errors.New(resp.Error)
+	}
+
+	scan := resp.Data
+	scan.World = name
+	scan.TakenAt = time.Now().UTC()
+	if m.Hotspots == nil {
+		return scan, nil
+	}
+	return m.Hotspots.Record(scan), nil
+}
+
+// RunHotspotSchedule scans every loaded world at the configured interval
+// until ctx is cancelled. Ticks while the plugin is offline or lacks the
+// capability are skipped. The returned channel is closed once the loop has
+// stopped.
+func (m *WebSocketManager) RunHotspotSchedule(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	interval := m.HotspotsConfig.Interval
+	if interval <= 0 || m.Hotspots == nil {
+		close(done)
+		return done
+	}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if !m.isMinecraftConnected() || !m.pluginSupports(protocol.CapHotspots) {
+				continue
+			}
+			for _, world := range m.Store.GetWorlds() {
+				if !world.Loaded {
+					continue
+				}
+				callCtx, cancel := context.WithTimeout(ctx, m.pluginRequestTimeout())
+				_, err := m.ScanHotspots(callCtx, world.Name)
+				cancel()
+				if err != nil && ctx.Err() == nil {
+					log.Printf("beacon hotspots: scan of %s failed: %v", world.Name, err)
+				}
+			}
+		}
+	}()
+	return done
+}
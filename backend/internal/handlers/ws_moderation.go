@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/moderation"
+	"github.com/adammcgrogan/beacon/internal/players"
+	"github.com/adammcgrogan/beacon/internal/protocol"
+)
+
+const (
+	maxModerationReason = 256
+	moderationTick      = 30 * time.Second
+)
+
+var (
+	// ErrInvalidModeration is returned for a malformed moderation request.
+	ErrInvalidModeration = errors.New("invalid moderation request")
+	// ErrModerationRejected is returned when the plugin refuses an action,
+	// e.g. kicking a player who is not online.
+	ErrModerationRejected = errors.New("moderation action rejected")
+)
+
+// moderationPermissions maps each moderation action to the node that allows it.
+var moderationPermissions = map[string]string{
+	players.ActionKick:    PermPlayersKick,
+	players.ActionBan:     PermPlayersBan,
+	players.ActionTempBan: PermPlayersTempBan,
+	players.ActionIPBan:   PermPlayersIPBan,
+	players.ActionUnban:   PermPlayersUnban,
+	players.ActionMute:    PermPlayersMute,
+	players.ActionUnmute:  PermPlayersMute,
+	players.ActionWarn:    PermPlayersWarn,
+}
+
+// ModerationActions lists the actions accepted by Moderate.
+func ModerationActions() []string {
+	return []string{
+		players.ActionKick,
+		players.ActionBan,
+		players.ActionTempBan,
+		players.ActionIPBan,
+		players.ActionUnban,
+		players.ActionMute,
+		players.ActionUnmute,
+		players.ActionWarn,
+	}
+}
+
+func moderationPermission(action string) (string, bool) {
+	permission, ok := moderationPermissions[action]
+	return permission, ok
+}
+
+// ModerationRequest is one moderation action from the panel. Duration accepts
+// Go durations plus d (days) and w (weeks), e.g. "90m", "7d".
+type ModerationRequest struct {
+	Action   string `json:"action"`
+	Player   string `json:"player"`
+	IP       string `json:"ip"`
+	Reason   string `json:"reason"`
+	Duration string `json:"duration"`
+}
+
+// ModerationResult describes an applied action.
+type ModerationResult struct {
+	Action    string    `json:"action"`
+	Player    string    `json:"player,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	// Delivered is set for warnings: false when the player was offline.
+	Delivered *bool `json:"delivered,omitempty"`
+}
+
+type moderationPluginRequest struct {
+	Action        string `json:"action"`
+	Player        string `json:"player,omitempty"`
+	IP            string `json:"ip,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	Source        string `json:"source"`
+	ExpiresAtUnix int64  `json:"expires_at_unix,omitempty"`
+}
+
+type moderationResponse struct {
+	RequestID string `json:"request_id"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error"`
+	Data      struct {
+		IP        string `json:"ip"`
+		Delivered *bool  `json:"delivered"`
+	} `json:"data"`
+}
+
+var moderationRPC = newPluginRPC[moderationPluginRequest, moderationResponse]("moderation_request", protocol.EventModerationResponse, protocol.CapModeration, 0, 0)
+
+// Moderate validates and applies a moderation action on behalf of issuer,
+// records it on the target's profile and schedules automatic unbans and
+// unmutes. Callers check the issuer's permission first.
+func (m *WebSocketManager) Moderate(ctx context.Context, issuer SessionClaims, req ModerationRequest) (ModerationResult, error) {
+	req.Action = strings.ToLower(strings.TrimSpace(req.Action))
+	req.Player = strings.TrimSpace(req.Player)
+	req.IP = strings.TrimSpace(req.IP)
+	req.Reason = strings.TrimSpace(req.Reason)
+
+	if _, ok := moderationPermission(req.Action); !ok {
+		return ModerationResult{}, fmt.Errorf("%w: unknown action %q", ErrInvalidModeration, req.Action)
+	}
+	if len(req.Reason) > maxModerationReason {
+		return ModerationResult{}, fmt.Errorf("%w: reason is longer than %d characters", ErrInvalidModeration, maxModerationReason)
+	}
+	ipTarget := req.Action == players.ActionIPBan || req.Action == players.ActionUnban
+	if req.Player == "" && !(ipTarget && req.IP != "") {
+		return ModerationResult{}, fmt.Errorf("%w: player is required", ErrInvalidModeration)
+	}
+
+	var duration time.Duration
+	if req.Duration != "" {
+		switch req.Action {
+		case players.ActionTempBan, players.ActionIPBan, players.ActionMute:
+		default:
+			return ModerationResult{}, fmt.Errorf("%w: %s does not take a duration", ErrInvalidModeration, req.Action)
+		}
+		parsed, err := parseModerationDuration(req.Duration)
+		if err != nil {
+			return ModerationResult{}, fmt.Errorf("%w: %v", ErrInvalidModeration, err)
+		}
+		duration = parsed
+	} else if req.Action == players.ActionTempBan {
+		return ModerationResult{}, fmt.Errorf("%w: tempban needs a duration", ErrInvalidModeration)
+	}
+
+	now := time.Now()
+	var expiresAt time.Time
+	if duration > 0 {
+		expiresAt = now.Add(duration)
+	}
+
+	pluginReq := moderationPluginRequest{
+		Action: req.Action,
+		Player: req.Player,
+		IP:     req.IP,
+		Reason: req.Reason,
+		Source: "Beacon (" + issuer.PlayerName + ")",
+	}
+	switch req.Action {
+	case players.ActionTempBan:
+		pluginReq.Action = "ban"
+	case players.ActionIPBan:
+		pluginReq.Action = "ban_ip"
+	case players.ActionUnban:
+		if req.IP != "" {
+			pluginReq.Action = "unban_ip"
+		}
+	}
+	if !expiresAt.IsZero() {
+		pluginReq.ExpiresAtUnix = expiresAt.Unix()
+	}
+
+	resp, err := callPlugin(ctx, m, moderationRPC, pluginReq)
+	if err != nil {
+		return ModerationResult{}, err
+	}
+	if !resp.OK {
+		if resp.Error == "" {
+			resp.Error = req.Action + " failed"
+		}
+		return ModerationResult{}, fmt.Errorf("%w: %s", ErrModerationRejected, resp.Error)
+	}
+
+	result := ModerationResult{
+		Action:    req.Action,
+		Player:    req.Player,
+		IP:        req.IP,
+		ExpiresAt: expiresAt,
+		Delivered: resp.Data.Delivered,
+	}
+	m.afterModeration(issuer, req, resp.Data.IP, result, now)
+	return result, nil
+}
+
+// afterModeration keeps the mute list, the unban schedule and the target's
+// profile in step with an action the plugin has applied.
+func (m *WebSocketManager) afterModeration(issuer SessionClaims, req ModerationRequest, bannedIP string, result ModerationResult, now time.Time) {
+	var uuid string
+	if m.Players != nil && req.Player != "" {
+		uuid, _ = m.Players.Resolve(req.Player)
+	}
+
+	if m.Moderation != nil {
+		switch req.Action {
+		case players.ActionBan:
+			m.Moderation.Cancel(moderation.LiftBan, req.Player, "")
+		case players.ActionTempBan:
+			m.Moderation.Schedule(moderation.Expiry{Action: moderation.LiftBan, Player: req.Player, UUID: uuid, At: result.ExpiresAt})
+		case players.ActionIPBan:
+			ip := req.IP
+			if ip == "" {
+				ip = bannedIP
+			}
+			if result.ExpiresAt.IsZero() {
+				m.Moderation.Cancel(moderation.LiftIPBan, "", ip)
+			} else if ip != "" {
+				m.Moderation.Schedule(moderation.Expiry{Action: moderation.LiftIPBan, Player: req.Player, UUID: uuid, IP: ip, At: result.ExpiresAt})
+			}
+		case players.ActionUnban:
+			if req.IP != "" {
+				m.Moderation.Cancel(moderation.LiftIPBan, "", req.IP)
+			} else {
+				m.Moderation.Cancel(moderation.LiftBan, req.Player, "")
+			}
+			m.Moderation.ClearAppeals(req.Player, req.IP)
+		case players.ActionMute:
+			m.Moderation.SetMute(moderation.Mute{
+				Player: req.Player,
+				UUID:   uuid,
+				Reason: req.Reason,
+				Issuer: issuer.PlayerName,
+				Since:  now,
+				Until:  result.ExpiresAt,
+			})
+			if result.ExpiresAt.IsZero() {
+				m.Moderation.Cancel(moderation.LiftMute, req.Player, "")
+			} else {
+				m.Moderation.Schedule(moderation.Expiry{Action: moderation.LiftMute, Player: req.Player, UUID: uuid, At: result.ExpiresAt})
+			}
+		case players.ActionUnmute:
+			m.Moderation.ClearMute(req.Player)
+			m.Moderation.Cancel(moderation.LiftMute, req.Player, "")
+		}
+	}
+
+	if uuid != "" {
+		_, _ = m.Players.RecordAction(uuid, players.Action{
+			Type:    req.Action,
+			Reason:  req.Reason,
+			Issuer:  players.Actor{UUID: issuer.PlayerUUID, Name: issuer.PlayerName},
+			At:      now,
+			Expires: result.ExpiresAt,
+		})
+	}
+}
+
+// RunModerationSchedule lifts temporary bans and mutes as they fall due,
+// checking every 30 seconds until ctx is cancelled. Expiries that fall due
+// while the plugin is offline are retried once it is back. The returned
+// channel is closed once the loop has stopped.
+func (m *WebSocketManager) RunModerationSchedule(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(moderationTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if m.Moderation == nil || !m.isMinecraftConnected() {
+				continue
+			}
+			for _, expiry := range m.Moderation.Due(time.Now()) {
+				m.liftExpiry(ctx, expiry)
+			}
+		}
+	}()
+	return done
+}
+
+func (m *WebSocketManager) liftExpiry(ctx context.Context, expiry moderation.Expiry) {
+	req := ModerationRequest{Action: players.ActionUnban, Player: expiry.Player}
+	switch expiry.Action {
+	case moderation.LiftIPBan:
+		req.Player = ""
+		req.IP = expiry.IP
+	case moderation.LiftMute:
+		req.Action = players.ActionUnmute
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, m.pluginRequestTimeout())
+	defer cancel()
+	system := SessionClaims{PlayerName: "Beacon"}
+	_, err := m.Moderate(callCtx, system, req)
+	switch {
+	case err == nil:
+		// Moderate cancelled the expiry.
+	case errors.Is(err, ErrPluginOffline), errors.Is(err, ErrPluginBusy), errors.Is(err, context.DeadlineExceeded):
+		// Try again on the next tick.
+	default:
+		log.Printf("beacon moderation: dropping scheduled %s for %s%s: %v", expiry.Action, expiry.Player, expiry.IP, err)
+		m.Moderation.Remove(expiry.ID)
+	}
+}
+
+// handleModerationEvent applies a moderation_action from /ws/web and answers
+// the browser with moderation_result.
+func (m *WebSocketManager) handleModerationEvent(ctx context.Context, client *webClient, session SessionClaims, raw []byte) {
+	var envelope struct {
+		Payload ModerationRequest `json:"payload"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, m.pluginRequestTimeout())
+	defer cancel()
+	result, err := m.Moderate(callCtx, session, envelope.Payload)
+	payload := map[string]any{
+		"ok":     err == nil,
+		"action": envelope.Payload.Action,
+		"player": envelope.Payload.Player,
+	}
+	if err != nil {
+		payload["error"] = pluginErrorMessage(err)
+	} else {
+		payload["result"] = result
+	}
+	message, err := json.Marshal(map[string]any{"event": "moderation_result", "payload": payload})
+	if err != nil {
+		return
+	}
+	client.enqueue("moderation_result", message)
+}
+
+// parseModerationDuration accepts Go durations and whole days or weeks.
+func parseModerationDuration(raw string) (time.Duration, error) {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	if raw == "" {
+		return 0, errors.New("empty duration")
+	}
+	var duration time.Duration
+	if unit := raw[len(raw)-1]; unit == 'd' || unit == 'w' {
+		count, err := strconv.Atoi(raw[:len(raw)-1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", raw)
+		}
+		duration = time.Duration(count) * 24 * time.Hour
+		if unit == 'w' {
+			duration *= 7
+		}
+	} else {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", raw)
+		}
+		duration = parsed
+	}
+	if duration < time.Minute {
+		return 0, fmt.Errorf("duration %q is shorter than a minute", raw)
+	}
+	return duration, nil
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/commandpolicy"
+	"github.com/adammcgrogan/beacon/internal/players"
+)
+
+// importUserCache seeds the player directory from the server's usercache.json
+// so players who have not joined since the backend started can be looked up.
+func (m *WebSocketManager) importUserCache() {
+	ctx, cancel := context.WithTimeout(context.Background(), m.pluginRequestTimeout())
+	defer cancel()
+
+	resp, err := m.RequestFileManagerOperation(ctx, "read_text", "usercache.json", "")
+	if err != nil {
+		if !errors.Is(err, ErrPluginOffline) && !errors.Is(err, ErrPluginUnsupported) {
+			log.Printf("beacon players: could not read usercache.json: %v", err)
+		}
+		return
+	}
+	if !resp.OK {
+		return
+	}
+
+	var payload struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(resp.Data, &payload); err != nil || payload.Content == "" {
+		return
+	}
+	added, err := m.Players.ImportUserCache([]byte(payload.Content))
+	if err != nil {
+		log.Printf("beacon players: could not parse usercache.json: %v", err)
+		return
+	}
+	if added > 0 {
+		fmt.Printf("👥 Imported %d players from usercache.json\n", added)
+	}
+}
+
+// recordPanelModeration stores kicks and bans sent from the panel against the
+// target's profile, with the issuing panel user and reason.
+func (m *WebSocketManager) recordPanelModeration(session SessionClaims, raw []byte) {
+	if m.Players == nil {
+		return
+	}
+	var cmdEnvelope struct {
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal(raw, &cmdEnvelope); err != nil {
+		return
+	}
+
+	invocations, err := commandpolicy.Normalize(cmdEnvelope.Command)
+	if err != nil || len(invocations) == 0 {
+		return
+	}
+	// Record what actually runs, e.g. the ban in "execute run minecraft:ban".
+	command := invocations[len(invocations)-1]
+	fields := strings.Fields(command.Args)
+	if len(fields) < 1 {
+		return
+	}
+	var actionType string
+	switch command.Command {
+	case "kick":
+		actionType = players.ActionKick
+	case "ban":
+		actionType = players.ActionBan
+	default:
+		return
+	}
+
+	uuid, ok := m.Players.Resolve(fields[0])
+	if !ok {
+		return
+	}
+	_, _ = m.Players.RecordAction(uuid, players.Action{
+		Type:   actionType,
+		Reason: strings.Join(fields[1:], " "),
+		Issuer: players.Actor{UUID: session.PlayerUUID, Name: session.PlayerName},
+		At:     time.Now(),
+	})
+}
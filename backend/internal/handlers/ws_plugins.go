@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/audit"
+	"github.com/adammcgrogan/beacon/internal/plugins"
+	"github.com/adammcgrogan/beacon/internal/protocol"
+)
+
+// Actions on a loaded plugin.
+const (
+	PluginEnable  = "enable"
+	PluginDisable = "disable"
+	PluginReload  = "reload"
+)
+
+const (
+	// maxPluginJar bounds uploaded plugin jars.
+	maxPluginJar = 64 << 20
+	// pluginUploadChunk is how much of an uploaded jar goes in one message.
+	pluginUploadChunk = 512 << 10
+	// pluginToggleTimeout bounds enabling, disabling or reloading a plugin,
+	// which runs the plugin's own start-up or shutdown code.
+	pluginToggleTimeout = time.Minute
+)
+
+// ErrInvalidPluginRequest is returned for a malformed plugin action or
+// upload.
+var ErrInvalidPluginRequest = errors.New("invalid plugin request")
+
+var (
+	// pluginNamePattern is what Bukkit allows in a plugin's name.
+	pluginNamePattern = regexp.MustCompile(`^[A-Za-z0-9 _.-]{1,64}$`)
+	pluginJarPattern  = regexp.MustCompile(`^[A-Za-z0-9 _.+()-]{1,128}\.jar$`)
+)
+
+type pluginsRequest struct {
+	Action   string `json:"action"`
+	Name     string `json:"name,omitempty"`
+	UploadID string `json:"upload_id,omitempty"`
+	File     string `json:"file,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+	Offset   int64  `json:"offset,omitempty"`
+	Data     string `json:"data,omitempty"`
+}
+
+type pluginsData struct {
+	plugins.Inventory
+	Plugin plugins.Plugin `json:"plugin"`
+}
+
+type pluginsResponse struct {
+	RequestID string      `json:"request_id"`
+	OK        bool        `json:"ok"`
+	Error     string      `json:"error"`
+	Data      pluginsData `json:"data"`
+}
+
+var (
+	pluginsRPC      = newPluginRPC[pluginsRequest, pluginsResponse]("plugins_request", protocol.EventPluginsResponse, protocol.CapPlugins, 0, 2)
+	pluginToggleRPC = newPluginRPC[pluginsRequest, pluginsResponse]("plugins_request", protocol.EventPluginsResponse, protocol.CapPlugins, pluginToggleTimeout, 1)
+)
+
+// PluginUpload is where an uploaded jar went. Staged jars replace the
+// installed jar of the same plugin on the next restart; others load then.
+type PluginUpload struct {
+	File    string `json:"file"`
+	Plugin  string `json:"plugin"`
+	Version string `json:"version"`
+	SHA256  string `json:"sha256"`
+	Staged  bool   `json:"staged"`
+}
+
+func (m *WebSocketManager) pluginsCall(ctx context.Context, rpc pluginRPC[pluginsRequest, pluginsResponse], req pluginsRequest) (pluginsData, error) {
+	resp, err := callPlugin(ctx, m, rpc, req)
+	if err != nil {
+		return pluginsData{}, err
+	}
+	if !resp.OK {
+		if resp.Error == "" {
+			resp.Error = req.Action + " failed"
+		}
+		return pluginsData{}, errors.New(resp.Error)
+	}
+	return resp.Data, nil
+}
+
+// PluginInventory asks the plugin for the loaded plugins and the jars in
+// plugins/ and the update folder.
+func (m *WebSocketManager) PluginInventory(ctx context.Context) (plugins.Inventory, error) {
+	data, err := m.pluginsCall(ctx, pluginsRPC, pluginsRequest{Action: "list"})
+	if err != nil {
+		return plugins.Inventory{}, err
+	}
+	data.Inventory.Prepare()
+	return data.Inventory, nil
+}
+
+// PluginAction enables, disables or reloads a loaded plugin and returns its
+// new state.
+func (m *WebSocketManager) PluginAction(ctx context.Context, issuer SessionClaims, name, action string) (plugins.Plugin, error) {
+	if !slices.Contains([]string{PluginEnable, PluginDisable, PluginReload}, action) {
+		return plugins.Plugin{}, fmt.Errorf("%w: action must be enable, disable or reload", ErrInvalidPluginRequest)
+	}
+	if !pluginNamePattern.MatchString(name) {
+		return plugins.Plugin{}, fmt.Errorf("%w: invalid plugin name", ErrInvalidPluginRequest)
+	}
+	data, err := m.pluginsCall(ctx, pluginToggleRPC, pluginsRequest{Action: action, Name: name})
+
+	entry := audit.Entry{Action: "plugins." + action, Target: name, OK: err == nil}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.Detail = data.Plugin.Version
+	}
+	m.audit(issuer, entry)
+	return data.Plugin, err
+}
+
+// InstallPluginJar uploads a plugin jar to the server. A newer jar of an
+// installed plugin is staged as its update; a new plugin goes into plugins/.
+// Neither is loaded until the server restarts.
+func (m *WebSocketManager) InstallPluginJar(ctx context.Context, issuer SessionClaims, fileName string, jar []byte) (PluginUpload, error) {
+	upload, err := m.installPluginJar(ctx, fileName, jar)
+	entry := audit.Entry{Action: "plugins.upload", Target: upload.Plugin, OK: err == nil}
+	if entry.Target == "" {
+		entry.Target = fileName
+	}
+	switch {
+	case err != nil:
+		entry.Error = err.Error()
+	case upload.Staged:
+		entry.Detail = fmt.Sprintf("staged %s %s as %s", upload.Plugin, upload.Version, upload.File)
+	default:
+		entry.Detail = fmt.Sprintf("installed %s %s as %s", upload.Plugin, upload.Version, upload.File)
+	}
+	m.audit(issuer, entry)
+	return upload, err
+}
+
+func (m *WebSocketManager) installPluginJar(ctx context.Context, fileName string, jar []byte) (PluginUpload, error) {
+	if !pluginJarPattern.MatchString(fileName) || path.Base(fileName) != fileName {
+		return PluginUpload{}, fmt.Errorf("%w: file name must end in .jar and use only letters, digits, spaces and _ . + ( ) -", ErrInvalidPluginRequest)
+	}
+	if len(jar) > maxPluginJar {
+		return PluginUpload{}, fmt.Errorf("%w: jar is larger than %d MiB", ErrInvalidPluginRequest, maxPluginJar>>20)
+	}
+	descriptor, err := plugins.ReadDescriptor(bytes.NewReader(jar), int64(len(jar)))
+	if err != nil {
+		return PluginUpload{}, err
+	}
+	sum := sha256.Sum256(jar)
+	upload := PluginUpload{Plugin: descriptor.Name, Version: descriptor.Version, SHA256: hex.EncodeToString(sum[:])}
+
+	inventory, err := m.PluginInventory(ctx)
+	if err != nil {
+		return upload, err
+	}
+	upload.File, upload.Staged, err = plugins.UploadTarget(inventory, descriptor, fileName, upload.SHA256)
+	if err != nil {
+		return upload, err
+	}
+
+	uploadID, err := randomHex(16)
+	if err != nil {
+		return upload, err
+	}
+	for offset := 0; offset < len(jar); offset += pluginUploadChunk {
+		chunk := jar[offset:min(offset+pluginUploadChunk, len(jar))]
+		_, err := m.pluginsCall(ctx, pluginsRPC, pluginsRequest{
+			Action:   "upload_chunk",
+			UploadID: uploadID,
+			Offset:   int64(offset),
+			Data:     base64.StdEncoding.EncodeToString(chunk),
+		})
+		if err != nil {
+			m.discardPluginUpload(uploadID)
+			return upload, fmt.Errorf("upload failed: %w", err)
+		}
+	}
+	_, err = m.pluginsCall(ctx, pluginsRPC, pluginsRequest{
+		Action:   "install",
+		UploadID: uploadID,
+		File:     upload.File,
+		SHA256:   upload.SHA256,
+	})
+	if err != nil {
+		m.discardPluginUpload(uploadID)
+		return upload, err
+	}
+	return upload, nil
+}
+
+// discardPluginUpload drops a partial upload from the server. The plugin
+// also clears leftover uploads when it starts.
+func (m *WebSocketManager) discardPluginUpload(uploadID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.pluginRequestTimeout())
+	defer cancel()
+	_, _ = m.pluginsCall(ctx, pluginsRPC, pluginsRequest{Action: "discard", UploadID: uploadID})
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/audit"
+	"github.com/adammcgrogan/beacon/internal/supervisor"
+)
+
+// Actions accepted by ControlProcess.
+const (
+	ProcessStart   = "start"
+	ProcessStop    = "stop"
+	ProcessKill    = "kill"
+	ProcessRestart = "restart"
+)
+
+var (
+	// ErrProcessDisabled is returned when the backend does not supervise the
+	// server process.
+	ErrProcessDisabled = errors.New("the backend does not run the server process")
+	// ErrInvalidProcessAction is returned for an unknown action.
+	ErrInvalidProcessAction = errors.New("action must be start, stop, kill or restart")
+)
+
+// ProcessStatus is the supervised process's state, reported separately from
+// whether the plugin is connected.
+type ProcessStatus struct {
+	Enabled bool `json:"enabled"`
+	supervisor.Status
+}
+
+// AttachProcess lets the manager control the server process through s.
+// While the plugin is not connected, the server's own output is fed into
+// the console stream instead.
+func (m *WebSocketManager) AttachProcess(s *supervisor.Supervisor) {
+	m.Process = s
+	s.OnOutput = m.processOutput
+	s.OnState = m.processStateChanged
+}
+
+// ProcessStatus returns the supervised process's state.
+func (m *WebSocketManager) ProcessStatus() ProcessStatus {
+	if m.Process == nil {
+		return ProcessStatus{Status: supervisor.Status{State: supervisor.StateStopped}}
+	}
+	return ProcessStatus{Enabled: true, Status: m.Process.Status()}
+}
+
+// ControlProcess starts, stops, kills or restarts the server process and
+// records who asked.
+func (m *WebSocketManager) ControlProcess(issuer SessionClaims, action string) (ProcessStatus, error) {
+	if m.Process == nil {
+		return ProcessStatus{}, ErrProcessDisabled
+	}
+	var err error
+	switch action {
+	case ProcessStart:
+		err = m.Process.Start()
+	case ProcessStop:
+		err = m.Process.Stop()
+	case ProcessKill:
+		err = m.Process.Kill()
+	case ProcessRestart:
+		err = m.Process.Restart()
+	default:
+		return ProcessStatus{}, ErrInvalidProcessAction
+	}
+
+	entry := audit.Entry{Action: "process." + action, OK: err == nil}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	m.audit(issuer, entry)
+	if err != nil {
+		return ProcessStatus{}, err
+	}
+	return m.ProcessStatus(), nil
+}
+
+func (m *WebSocketManager) processOutput(line string) {
+	if line == "" || m.isMinecraftConnected() {
+		return
+	}
+	envelope, err := json.Marshal(map[string]any{
+		"event": "console_log",
+		"payload": map[string]string{
+			"message": line,
+			"level":   detectLogLevel(line),
+		},
+	})
+	if err != nil {
+		return
+	}
+	m.Store.AddLog(envelope)
+	m.broadcastToWeb("console_log", envelope)
+}
+
+// processStateChanged runs under the supervisor's lock, so it must not call
+// back into it.
+func (m *WebSocketManager) processStateChanged(status supervisor.Status) {
+	m.broadcastToWeb("process_status", processStatusMessage(ProcessStatus{Enabled: true, Status: status}))
+
+	if status.Crashed && status.ExitedAt.After(m.lastProcessCrash) {
+		m.lastProcessCrash = status.ExitedAt
+		exitCode := ""
+		if status.ExitCode != nil {
+			exitCode = strconv.Itoa(*status.ExitCode)
+		}
+		m.audit(SessionClaims{PlayerName: "Beacon"}, audit.Entry{
+			Action: "process.crash",
+			Target: exitCode,
+			Detail: fmt.Sprintf("ran for %s", status.ExitedAt.Sub(status.StartedAt).Round(time.Second)),
+			Error:  status.Reason,
+		})
+	}
+}
+
+func (m *WebSocketManager) sendProcessStatus(client *webClient) {
+	if m.Process == nil {
+		return
+	}
+	client.enqueue("process_status", processStatusMessage(m.ProcessStatus()))
+}
+
+func processStatusMessage(status ProcessStatus) []byte {
+	message, _ := json.Marshal(map[string]any{
+		"event":   "process_status",
+		"payload": status,
+	})
+	return message
+}
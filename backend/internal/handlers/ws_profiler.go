@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/audit"
+	"github.com/adammcgrogan/beacon/internal/diagnostics"
+	"github.com/adammcgrogan/beacon/internal/protocol"
+)
+
+const (
+	// DefaultProfileDuration is how long a profile runs when no duration is
+	// given.
+	DefaultProfileDuration = time.Minute
+	// MinProfileDuration is the shortest profile worth taking.
+	MinProfileDuration = 5 * time.Second
+	// DefaultProfileInterval is how often, in milliseconds, the main
+	// thread is sampled when no interval is given.
+	DefaultProfileInterval = 10
+	// maxProfileInterval bounds the sampling interval in milliseconds.
+	maxProfileInterval = 1000
+)
+
+var (
+	ErrInvalidProfile  = errors.New("invalid profile request")
+	ErrProfileRunning  = errors.New("a profile is already running")
+	ErrProfileStarting = errors.New("the profile is still starting")
+)
+
+type profilerRequest struct {
+	Action     string `json:"action"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	IntervalMS int    `json:"interval_ms,omitempty"`
+}
+
+type profilerResponse struct {
+	RequestID string                    `json:"request_id"`
+	OK        bool                      `json:"ok"`
+	Error     string                    `json:"error"`
+	Data      diagnostics.ProfileReport `json:"data"`
+}
+
+var profilerRPC = newPluginRPC[profilerRequest, profilerResponse]("profiler_request", protocol.EventProfilerResponse, protocol.CapProfiler, 0, 1)
+
+// ActiveProfile is a profile that is still sampling.
+type ActiveProfile struct {
+	StartedAt  time.Time `json:"started_at"`
+	EndsAt     time.Time `json:"ends_at"`
+	By         string    `json:"by"`
+	IntervalMS int       `json:"interval_ms"`
+}
+
+// profilerState tracks the running profile and the timer that collects it.
+// The zero value is ready to use.
+type profilerState struct {
+	mu     sync.Mutex
+	active *ActiveProfile
+	timer  *time.Timer
+}
+
+func (m *WebSocketManager) profilerCall(ctx context.Context, req profilerRequest) (diagnostics.ProfileReport, error) {
+	resp, err := callPlugin(ctx, m, profilerRPC, req)
+	if err != nil {
+		return diagnostics.ProfileReport{}, err
+	}
+	if !resp.OK {
+		if resp.Error == "" {
+			resp.Error = "profile " + req.Action + " failed"
+		}
+		return diagnostics.ProfileReport{}, errors.New(resp.Error)
+	}
+	return resp.Data, nil
+}
+
+// ActiveProfile returns the profile that is running, if any.
+func (m *WebSocketManager) ActiveProfile() *ActiveProfile {
+	m.profiler.mu.Lock()
+	defer m.profiler.mu.Unlock()
+	if m.profiler.active == nil || m.profiler.active.StartedAt.IsZero() {
+		return nil
+	}
+	active := *m.profiler.active
+	return &active
+}
+
+// StartProfile starts sampling the server's main thread every intervalMS
+// milliseconds. The profile is collected and stored once duration has
+// passed, or earlier by StopProfile.
+func (m *WebSocketManager) StartProfile(ctx context.Context, issuer SessionClaims, duration time.Duration, intervalMS int) (ActiveProfile, error) {
+	if m.Profiles == nil {
+		return ActiveProfile{}, errors.New("profile storage is not enabled")
+	}
+	if duration == 0 {
+		duration = DefaultProfileDuration
+	}
+	if intervalMS == 0 {
+		intervalMS = DefaultProfileInterval
+	}
+	longest := m.DiagnosticsConfig.MaxProfile
+	if longest <= 0 {
+		longest = 10 * time.Minute
+	}
+	if duration < MinProfileDuration || duration > longest {
+		return ActiveProfile{}, fmt.Errorf("%w: duration must be between %s and %s", ErrInvalidProfile, MinProfileDuration, longest)
+	}
+	if intervalMS < 1 || intervalMS > maxProfileInterval {
+		return ActiveProfile{}, fmt.Errorf("%w: interval_ms must be between 1 and %d", ErrInvalidProfile, maxProfileInterval)
+	}
+
+	// Claim the slot before asking the plugin, so two starts cannot race.
+	claim := &ActiveProfile{}
+	m.profiler.mu.Lock()
+	if m.profiler.active != nil {
+		m.profiler.mu.Unlock()
+		return ActiveProfile{}, ErrProfileRunning
+	}
+	m.profiler.active = claim
+	m.profiler.mu.Unlock()
+
+	_, err := m.profilerCall(ctx, profilerRequest{Action: "start", DurationMS: duration.Milliseconds(), IntervalMS: intervalMS})
+	entry := audit.Entry{Action: "diagnostics.profile_start", Detail: fmt.Sprintf("%s every %dms", duration, intervalMS), OK: err == nil}
+	if err != nil {
+		entry.Error = err.Error()
+		m.audit(issuer, entry)
+		m.profiler.mu.Lock()
+		m.profiler.active = nil
+		m.profiler.mu.Unlock()
+		return ActiveProfile{}, err
+	}
+	m.audit(issuer, entry)
+
+	now := time.Now().UTC()
+	m.profiler.mu.Lock()
+	*claim = ActiveProfile{StartedAt: now, EndsAt: now.Add(duration), By: issuer.PlayerName, IntervalMS: intervalMS}
+	m.profiler.timer = time.AfterFunc(duration, func() { m.collectProfile(claim) })
+	active := *claim
+	m.profiler.mu.Unlock()
+
+	m.broadcastProfilerStatus(nil)
+	return active, nil
+}
+
+// collectProfile stores the profile started as active once its time is up,
+// unless it was stopped early.
+func (m *WebSocketManager) collectProfile(active *ActiveProfile) {
+	m.profiler.mu.Lock()
+	current := m.profiler.active == active
+	m.profiler.mu.Unlock()
+	if !current {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.pluginRequestTimeout())
+	defer cancel()
+	if _, err := m.StopProfile(ctx, SessionClaims{PlayerName: "Beacon"}); err != nil {
+		log.Printf("beacon diagnostics: collecting profile failed: %v", err)
+	}
+}
+
+// StopProfile stops the running profile and stores it. A profile the plugin
+// is still holding, e.g. from before the backend restarted, is collected
+// too.
+func (m *WebSocketManager) StopProfile(ctx context.Context, issuer SessionClaims) (diagnostics.Profile, error) {
+	if m.Profiles == nil {
+		return diagnostics.Profile{}, errors.New("profile storage is not enabled")
+	}
+	m.profiler.mu.Lock()
+	if m.profiler.active != nil && m.profiler.active.StartedAt.IsZero() {
+		m.profiler.mu.Unlock()
+		return diagnostics.Profile{}, ErrProfileStarting
+	}
+	by := issuer.PlayerName
+	if m.profiler.active != nil {
+		by = m.profiler.active.By
+	}
+	m.profiler.active = nil
+	if m.profiler.timer != nil {
+		m.profiler.timer.Stop()
+		m.profiler.timer = nil
+	}
+	m.profiler.mu.Unlock()
+
+	report, err := m.profilerCall(ctx, profilerRequest{Action: "stop"})
+	var profile diagnostics.Profile
+	if err == nil {
+		profile, err = m.Profiles.Save(diagnostics.BuildProfile(report, by, time.Now().UTC()))
+	}
+
+	entry := audit.Entry{Action: "diagnostics.profile", Target: profile.ID, OK: err == nil}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.Detail = fmt.Sprintf("%d samples, p95 %.1f ms", profile.Samples, profile.MSPT.P95)
+	}
+	m.audit(issuer, entry)
+	if err != nil {
+		m.broadcastProfilerStatus(nil)
+		return diagnostics.Profile{}, err
+	}
+	summary := profile
+	summary.Tree = nil
+	m.broadcastProfilerStatus(&summary)
+	return profile, nil
+}
+
+// broadcastProfilerStatus tells browsers whether a profile is running and,
+// when one has just been stored, which.
+func (m *WebSocketManager) broadcastProfilerStatus(finished *diagnostics.Profile) {
+	message, err := json.Marshal(map[string]any{
+		"event": "profiler_status",
+		"payload": map[string]any{
+			"active":   m.ActiveProfile(),
+			"finished": finished,
+		},
+	})
+	if err != nil {
+		return
+	}
+	m.broadcastToWeb("profiler_status", message)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/adammcgrogan/beacon/internal/protocol"
+)
+
+// ErrPluginUnsupported is returned when the connected plugin did not declare
+// the capability a request needs, usually because it is older than the backend.
+var ErrPluginUnsupported = errors.New("plugin does not support this request")
+
+// pluginProtocol tracks what was negotiated with the current plugin connection.
+type pluginProtocol struct {
+	mu       sync.RWMutex
+	session  protocol.Session
+	reported map[string]bool
+
+	unknown   atomic.Uint64
+	malformed atomic.Uint64
+}
+
+// ProtocolSnapshot reports the negotiated protocol and rejected-event counters.
+type ProtocolSnapshot struct {
+	BackendVersion   int              `json:"backend_version"`
+	Plugin           protocol.Session `json:"plugin"`
+	UnknownEvents    uint64           `json:"unknown_events"`
+	MalformedEvents  uint64           `json:"malformed_events"`
+	MinPluginVersion int              `json:"min_plugin_version"`
+}
+
+// reset assumes a legacy plugin until it says otherwise in its hello.
+func (p *pluginProtocol) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.session = protocol.LegacySession()
+	p.reported = make(map[string]bool)
+}
+
+func (p *pluginProtocol) current() protocol.Session {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.session.Capabilities == nil {
+		return protocol.LegacySession()
+	}
+	return p.session
+}
+
+// reject counts a dropped event and logs it the first time it is seen on this
+// connection, so a chatty plugin cannot flood the log.
+func (p *pluginProtocol) reject(event string, err error) {
+	unknown := errors.Is(err, protocol.ErrUnknownEvent)
+	if unknown {
+		p.unknown.Add(1)
+	} else {
+		p.malformed.Add(1)
+	}
+
+	key := fmt.Sprintf("%s/%t", event, unknown)
+	p.mu.Lock()
+	if p.reported == nil {
+		p.reported = make(map[string]bool)
+	}
+	seen := p.reported[key]
+	p.reported[key] = true
+	p.mu.Unlock()
+	if seen {
+		return
+	}
+
+	if unknown {
+		log.Printf("plugin protocol: dropping unknown event %q (further occurrences are counted, not logged)", event)
+		return
+	}
+	log.Printf("plugin protocol: dropping invalid message: %v (further occurrences are counted, not logged)", err)
+}
+
+func (m *WebSocketManager) handlePluginHello(hello protocol.Hello) {
+	var encodings []string
+	if m.BinaryFrames {
+		encodings = append(encodings, protocol.EncodingMsgpack)
+	}
+	session, ack := protocol.Negotiate(hello, encodings)
+	message, err := protocol.Encode(protocol.EventHelloAck, ack)
+	if err != nil {
+		return
+	}
+	_ = m.writeToMinecraft(message)
+
+	m.proto.mu.Lock()
+	m.proto.session = session
+	m.proto.mu.Unlock()
+	fmt.Printf("🤝 Plugin %s speaks protocol v%d (%d capabilities, %s frames)\n", displayPluginVersion(hello.PluginVersion), session.ProtocolVersion, len(session.Capabilities), session.Encoding)
+}
+
+// decodeBinaryFrame turns a binary frame into JSON using the encoding agreed
+// in the handshake; binary frames before that are rejected.
+func (m *WebSocketManager) decodeBinaryFrame(data []byte) ([]byte, error) {
+	if m.proto.current().Encoding != protocol.EncodingMsgpack {
+		return nil, fmt.Errorf("%w: binary frame without a negotiated encoding", protocol.ErrMalformed)
+	}
+	return protocol.BinaryToJSON(data)
+}
+
+func (m *WebSocketManager) pluginSupports(capability string) bool {
+	return m.proto.current().Supports(capability)
+}
+
+// ProtocolInfo reports what was negotiated with the connected plugin.
+func (m *WebSocketManager) ProtocolInfo() ProtocolSnapshot {
+	return ProtocolSnapshot{
+		BackendVersion:   protocol.Version,
+		Plugin:           m.proto.current(),
+		UnknownEvents:    m.proto.unknown.Load(),
+		MalformedEvents:  m.proto.malformed.Load(),
+		MinPluginVersion: protocol.MinVersion,
+	}
+}
+
+// webEventCapability maps browser events forwarded to the plugin to the
+// capability the plugin must have declared to handle them.
+func webEventCapability(event string) string {
+	switch event {
+	case "console_command":
+		return protocol.CapConsole
+	case "console_tab_complete":
+		return protocol.CapTabComplete
+	case "world_action":
+		return protocol.CapWorldActions
+	}
+	return ""
+}
+
+func displayPluginVersion(version string) string {
+	if version == "" {
+		return "(unknown version)"
+	}
+	return version
+}
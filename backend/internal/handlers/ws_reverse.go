@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DialPlugin is the reverse-connect transport: instead of waiting for the
+// plugin on /ws, the backend connects to a listener the plugin exposes and
+// serves the session exactly as if the plugin had dialed in. It reconnects
+// with jittered exponential backoff between minDelay and maxDelay until ctx
+// is cancelled.
+func (m *WebSocketManager) DialPlugin(ctx context.Context, url string, minDelay, maxDelay time.Duration) {
+	dialer := websocket.Dialer{
+		HandshakeTimeout:  10 * time.Second,
+		EnableCompression: m.Compression,
+	}
+	header := http.Header{}
+	if m.PluginSecret != "" {
+		header.Set("Authorization", "Bearer "+m.PluginSecret)
+	}
+
+	delay := minDelay
+	for {
+		conn, resp, err := dialer.DialContext(ctx, url, header)
+		if err == nil {
+			fmt.Printf("🔌 Connected to plugin listener at %s\n", url)
+			delay = minDelay
+			m.serveMinecraft(conn)
+		} else if ctx.Err() == nil {
+			if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+				log.Printf("plugin listener at %s rejected the shared secret", url)
+			} else {
+				log.Printf("could not reach plugin listener at %s: %v", url, err)
+			}
+		}
+
+		if ctx.Err() != nil || m.shuttingDown.Load() {
+			return
+		}
+
+		wait := jitter(delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		delay = min(delay*2, maxDelay)
+	}
+}
+
+// jitter spreads retries over [d/2, d) so many backends don't reconnect in lockstep.
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + rand.N(half)
+}
+
+func (m *WebSocketManager) pluginAuthorized(header string) bool {
+	if m.PluginSecret == "" {
+		return true
+	}
+	expected := "Bearer " + m.PluginSecret
+	return subtle.ConstantTimeCompare([]byte(header), []byte(expected)) == 1
+}
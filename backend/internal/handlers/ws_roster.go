@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/audit"
+	"github.com/adammcgrogan/beacon/internal/protocol"
+)
+
+// Roster changes accepted by ChangeRoster.
+const (
+	RosterWhitelistAdd    = "whitelist_add"
+	RosterWhitelistRemove = "whitelist_remove"
+	RosterOpAdd           = "op_add"
+	RosterOpRemove        = "op_remove"
+)
+
+const (
+	maxRosterBatch  = 20
+	maxRosterImport = 1000
+)
+
+// ErrInvalidRoster is returned for a malformed whitelist or operator request.
+var ErrInvalidRoster = errors.New("invalid roster request")
+
+// rosterNamePattern matches Java edition names, plus the leading dot
+// Floodgate gives Bedrock players.
+var rosterNamePattern = regexp.MustCompile(`^\.?[A-Za-z0-9_]{1,16}$`)
+
+// RosterEntry is one whitelisted player or operator.
+type RosterEntry struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+// Roster is the server's whitelist and operator list.
+type Roster struct {
+	WhitelistEnabled bool          `json:"whitelist_enabled"`
+	Whitelist        []RosterEntry `json:"whitelist"`
+	Ops              []RosterEntry `json:"ops"`
+}
+
+// RosterChange is the outcome of adding or removing one player.
+type RosterChange struct {
+	Name  string `json:"name"`
+	UUID  string `json:"uuid,omitempty"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type rosterPluginRequest struct {
+	Action  string        `json:"action"`
+	Players []RosterEntry `json:"players,omitempty"`
+	Enabled bool          `json:"enabled,omitempty"`
+}
+
+type rosterResponse struct {
+	RequestID string `json:"request_id"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error"`
+	Data      struct {
+		Roster
+		Results []RosterChange `json:"results"`
+	} `json:"data"`
+}
+
+var rosterRPC = newPluginRPC[rosterPluginRequest, rosterResponse]("roster_request", protocol.EventRosterResponse, protocol.CapRoster, 0, 0)
+
+// Roster fetches the current whitelist and operators from the plugin.
+func (m *WebSocketManager) Roster(ctx context.Context) (Roster, error) {
+	resp, err := m.callRoster(ctx, rosterPluginRequest{Action: "list"})
+	if err != nil {
+		return Roster{}, err
+	}
+	roster := resp.Data.Roster
+	if roster.Whitelist == nil {
+		roster.Whitelist = []RosterEntry{}
+	}
+	if roster.Ops == nil {
+		roster.Ops = []RosterEntry{}
+	}
+	m.fillRosterNames(roster.Whitelist)
+	m.fillRosterNames(roster.Ops)
+	return roster, nil
+}
+
+// ChangeRoster adds players to or removes them from the whitelist or operator
+// list. Names are resolved to UUIDs from the player directory first and then
+// by the plugin from the server's own caches; nothing is looked up online.
+// Each player's outcome is audited as auditAction.
+func (m *WebSocketManager) ChangeRoster(ctx context.Context, issuer SessionClaims, action, auditAction string, names []string, limit int) ([]RosterChange, error) {
+	switch action {
+	case RosterWhitelistAdd, RosterWhitelistRemove, RosterOpAdd, RosterOpRemove:
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidRoster, action)
+	}
+	entries, err := m.rosterEntries(names, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := m.callRoster(ctx, rosterPluginRequest{Action: action, Players: entries})
+	if err != nil {
+		return nil, err
+	}
+	results := resp.Data.Results
+	if results == nil {
+		results = []RosterChange{}
+	}
+	now := time.Now()
+	audited := make([]audit.Entry, 0, len(results))
+	for _, result := range results {
+		target := result.Name
+		if result.UUID != "" {
+			target += " (" + result.UUID + ")"
+		}
+		audited = append(audited, audit.Entry{At: now, Action: auditAction, Target: target, OK: result.OK, Error: result.Error})
+	}
+	m.audit(issuer, audited...)
+	return results, nil
+}
+
+// SetWhitelistEnabled turns whitelist enforcement on or off.
+func (m *WebSocketManager) SetWhitelistEnabled(ctx context.Context, issuer SessionClaims, enabled bool) error {
+	_, err := m.callRoster(ctx, rosterPluginRequest{Action: "whitelist_toggle", Enabled: enabled})
+	entry := audit.Entry{Action: "whitelist.toggle", Detail: fmt.Sprintf("enabled=%t", enabled), OK: err == nil}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	m.audit(issuer, entry)
+	return err
+}
+
+func (m *WebSocketManager) callRoster(ctx context.Context, req rosterPluginRequest) (rosterResponse, error) {
+	resp, err := callPlugin(ctx, m, rosterRPC, req)
+	if err != nil {
+		return rosterResponse{}, err
+	}
+	if !resp.OK {
+		if resp.Error == "" {
+			resp.Error = req.Action + " failed"
+		}
+		return rosterResponse{}, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// rosterEntries validates and de-duplicates names, filling in UUIDs the
+// player directory knows.
+func (m *WebSocketManager) rosterEntries(names []string, limit int) ([]RosterEntry, error) {
+	seen := make(map[string]struct{}, len(names))
+	entries := make([]RosterEntry, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !rosterNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%w: %q is not a valid player name", ErrInvalidRoster, name)
+		}
+		key := strings.ToLower(name)
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		entry := RosterEntry{Name: name}
+		if m.Players != nil {
+			entry.UUID, _ = m.Players.Resolve(name)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: no player names given", ErrInvalidRoster)
+	}
+	if len(entries) > limit {
+		return nil, fmt.Errorf("%w: at most %d players at a time", ErrInvalidRoster, limit)
+	}
+	return entries, nil
+}
+
+// fillRosterNames names entries the server only knows by UUID.
+func (m *WebSocketManager) fillRosterNames(entries []RosterEntry) {
+	if m.Players == nil {
+		return
+	}
+	for i := range entries {
+		if entries[i].Name != "" {
+			continue
+		}
+		if profile, ok := m.Players.Profile(entries[i].UUID); ok {
+			entries[i].Name = profile.Name
+		}
+	}
+}
+
+// audit records changes made by a panel user, if auditing is enabled.
+// Several entries, such as one per player of an import, are saved at once.
+func (m *WebSocketManager) audit(issuer SessionClaims, entries ...audit.Entry) {
+	if m.Audit == nil {
+		return
+	}
+	for i := range entries {
+		entries[i].Actor = audit.Actor{UUID: issuer.PlayerUUID, Name: issuer.PlayerName}
+	}
+	m.Audit.RecordBatch(entries)
+}
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/protocol"
+)
+
+// ErrPluginBusy is returned when too many requests are already waiting on the plugin.
+var ErrPluginBusy = errors.New("plugin is busy")
+
+const defaultPluginMaxInFlight = 64
+
+// pluginRPC describes one request/response exchange with the plugin. Req is
+// sent as the payload of the request event with a request_id added; the
+// plugin answers with the response event, a protocol.RPCResponse carrying the
+// same request_id.
+type pluginRPC[Req, Resp any] struct {
+	request  string
+	response string
+	// capability must have been declared by the plugin for the request to be sent.
+	capability string
+	// timeout bounds each call; zero uses WebSocketManager.RequestTimeout.
+	timeout time.Duration
+	// maxInFlight caps concurrent calls of this kind; zero means only the global cap applies.
+	maxInFlight int
+}
+
+func newPluginRPC[Req, Resp any](request, response, capability string, timeout time.Duration, maxInFlight int) pluginRPC[Req, Resp] {
+	return pluginRPC[Req, Resp]{
+		request:     request,
+		response:    response,
+		capability:  capability,
+		timeout:     timeout,
+		maxInFlight: maxInFlight,
+	}
+}
+
+// callPlugin sends req to the plugin and waits for the matching response.
+// When ctx ends first the plugin is told to cancel the request.
+func callPlugin[Req, Resp any](ctx context.Context, m *WebSocketManager, rpc pluginRPC[Req, Resp], req Req) (Resp, error) {
+	var zero Resp
+	if !m.isMinecraftConnected() {
+		return zero, ErrPluginOffline
+	}
+	if !m.pluginSupports(rpc.capability) {
+		return zero, ErrPluginUnsupported
+	}
+
+	timeout := rpc.timeout
+	if timeout <= 0 {
+		timeout = m.pluginRequestTimeout()
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	requestID, err := randomHex(16)
+	if err != nil {
+		return zero, err
+	}
+	message, err := rpcRequestMessage(rpc.request, requestID, req)
+	if err != nil {
+		return zero, err
+	}
+
+	call, err := m.rpc.begin(requestID, rpc.request, rpc.response, rpc.maxInFlight, m.pluginMaxInFlight())
+	if err != nil {
+		return zero, err
+	}
+
+	if err := m.writeToMinecraft(message); err != nil {
+		m.rpc.end(call, ErrPluginOffline)
+		return zero, ErrPluginOffline
+	}
+
+	select {
+	case result := <-call.result:
+		if result.err != nil {
+			m.rpc.end(call, result.err)
+			return zero, result.err
+		}
+		var response Resp
+		if err := json.Unmarshal(result.payload, &response); err != nil {
+			m.rpc.end(call, err)
+			return zero, err
+		}
+		m.rpc.end(call, nil)
+		return response, nil
+	case <-ctx.Done():
+		m.rpc.end(call, ctx.Err())
+		m.cancelPluginRequest(requestID, rpc.request)
+		return zero, ctx.Err()
+	}
+}
+
+// pluginErrorMessage describes a failed plugin request for panel users.
+func pluginErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrPluginOffline):
+		return "server is offline"
+	case errors.Is(err, ErrPluginBusy):
+		return "server is busy, try again"
+	case errors.Is(err, ErrPluginUnsupported):
+		return "the server's Beacon plugin is too old for this; please update it"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return "request to the server timed out"
+	}
+	return err.Error()
+}
+
+func rpcRequestMessage(event, requestID string, req any) ([]byte, error) {
+	raw, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	payload := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+	payload["request_id"], _ = json.Marshal(requestID)
+
+	return protocol.Encode(event, payload)
+}
+
+// cancelPluginRequest tells the plugin to drop a request nobody is waiting for any more.
+func (m *WebSocketManager) cancelPluginRequest(requestID, event string) {
+	if !m.pluginSupports(protocol.CapRPCCancel) {
+		return
+	}
+	message, err := protocol.Encode(protocol.EventRPCCancel, map[string]string{
+		"request_id": requestID,
+		"request":    event,
+	})
+	if err != nil {
+		return
+	}
+	_ = m.writeToMinecraft(message)
+}
+
+func (m *WebSocketManager) pluginMaxInFlight() int {
+	if m.MaxInFlight <= 0 {
+		return defaultPluginMaxInFlight
+	}
+	return m.MaxInFlight
+}
+
+type rpcResult struct {
+	payload json.RawMessage
+	err     error
+}
+
+type rpcCall struct {
+	id       string
+	event    string
+	response string
+	started  time.Time
+	result   chan rpcResult
+}
+
+type rpcMethodMetrics struct {
+	Calls      uint64
+	Succeeded  uint64
+	Failed     uint64
+	TimedOut   uint64
+	Canceled   uint64
+	Rejected   uint64
+	InFlight   int
+	TotalTime  time.Duration
+	MaxLatency time.Duration
+}
+
+// RPCMetricsSnapshot summarizes plugin requests of one kind.
+type RPCMetricsSnapshot struct {
+	Request      string  `json:"request"`
+	Calls        uint64  `json:"calls"`
+	Succeeded    uint64  `json:"succeeded"`
+	Failed       uint64  `json:"failed"`
+	TimedOut     uint64  `json:"timed_out"`
+	Canceled     uint64  `json:"canceled"`
+	Rejected     uint64  `json:"rejected"`
+	InFlight     int     `json:"in_flight"`
+	AvgLatencyMS float64 `json:"avg_latency_ms"`
+	MaxLatencyMS float64 `json:"max_latency_ms"`
+}
+
+// rpcBroker correlates plugin responses with the calls waiting on them.
+type rpcBroker struct {
+	mu       sync.Mutex
+	pending  map[string]*rpcCall
+	inFlight int
+	metrics  map[string]*rpcMethodMetrics
+}
+
+func (b *rpcBroker) begin(id, event, response string, methodLimit, globalLimit int) (*rpcCall, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending == nil {
+		b.pending = make(map[string]*rpcCall)
+		b.metrics = make(map[string]*rpcMethodMetrics)
+	}
+
+	metrics := b.metricsFor(event)
+	if b.inFlight >= globalLimit || (methodLimit > 0 && metrics.InFlight >= methodLimit) {
+		metrics.Rejected++
+		return nil, ErrPluginBusy
+	}
+
+	call := &rpcCall{
+		id:       id,
+		event:    event,
+		response: response,
+		started:  time.Now(),
+		result:   make(chan rpcResult, 1),
+	}
+	b.pending[id] = call
+	b.inFlight++
+	metrics.Calls++
+	metrics.InFlight++
+	return call, nil
+}
+
+// end removes call and records how it finished.
+func (b *rpcBroker) end(call *rpcCall, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.pending[call.id]; !ok {
+		return
+	}
+	delete(b.pending, call.id)
+	b.inFlight--
+
+	metrics := b.metricsFor(call.event)
+	metrics.InFlight--
+	switch {
+	case err == nil:
+		metrics.Succeeded++
+	case errors.Is(err, context.DeadlineExceeded):
+		metrics.TimedOut++
+	case errors.Is(err, context.Canceled):
+		metrics.Canceled++
+	default:
+		metrics.Failed++
+	}
+	elapsed := time.Since(call.started)
+	metrics.TotalTime += elapsed
+	if elapsed > metrics.MaxLatency {
+		metrics.MaxLatency = elapsed
+	}
+}
+
+// resolve hands a response to the call waiting on its request_id. A response
+// of another kind fails the call rather than being decoded as the wrong type.
+func (b *rpcBroker) resolve(event string, payload json.RawMessage) {
+	var envelope struct {
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(payload, &envelope); err != nil || envelope.RequestID == "" {
+		return
+	}
+
+	b.mu.Lock()
+	call, ok := b.pending[envelope.RequestID]
+	b.mu.Unlock()
+	if !ok {
+		return
+	}
+	result := rpcResult{payload: payload}
+	if event != call.response {
+		result = rpcResult{err: fmt.Errorf("plugin answered %s with %s instead of %s", call.event, event, call.response)}
+	}
+	select {
+	case call.result <- result:
+	default:
+	}
+}
+
+// failAll wakes every waiting call with err, e.g. when the plugin socket goes away.
+func (b *rpcBroker) failAll(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, call := range b.pending {
+		select {
+		case call.result <- rpcResult{err: err}:
+		default:
+		}
+	}
+}
+
+func (b *rpcBroker) metricsFor(event string) *rpcMethodMetrics {
+	metrics, ok := b.metrics[event]
+	if !ok {
+		metrics = &rpcMethodMetrics{}
+		b.metrics[event] = metrics
+	}
+	return metrics
+}
+
+func (b *rpcBroker) snapshot() []RPCMetricsSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make([]RPCMetricsSnapshot, 0, len(b.metrics))
+	for event, metrics := range b.metrics {
+		snapshot := RPCMetricsSnapshot{
+			Request:      event,
+			Calls:        metrics.Calls,
+			Succeeded:    metrics.Succeeded,
+			Failed:       metrics.Failed,
+			TimedOut:     metrics.TimedOut,
+			Canceled:     metrics.Canceled,
+			Rejected:     metrics.Rejected,
+			InFlight:     metrics.InFlight,
+			MaxLatencyMS: float64(metrics.MaxLatency) / float64(time.Millisecond),
+		}
+		if finished := metrics.Calls - uint64(metrics.InFlight); finished > 0 {
+			snapshot.AvgLatencyMS = float64(metrics.TotalTime) / float64(finished) / float64(time.Millisecond)
+		}
+		out = append(out, snapshot)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Request < out[j].Request })
+	return out
+}
+
+// RPCMetrics reports per-request-type counters for calls to the plugin.
+func (m *WebSocketManager) RPCMetrics() []RPCMetricsSnapshot {
+	return m.rpc.snapshot()
+}
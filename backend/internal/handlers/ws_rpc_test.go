@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/adammcgrogan/beacon/internal/protocol"
+)
+
+func TestRPCBrokerResolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   string
+		payload string
+		want    string // payload delivered; empty when the call should fail
+		err     string
+	}{
+		{"matching response", protocol.EventRosterResponse, `{"request_id":"r1","ok":true}`, `{"request_id":"r1","ok":true}`, ""},
+		{"other kind of response", protocol.EventModerationResponse, `{"request_id":"r1","success":true}`, "", "instead of roster_response"},
+	}
+	for _, tt := range tests {
+		var b rpcBroker
+		call, err := b.begin("r1", "roster_request", protocol.EventRosterResponse, 0, 4)
+		if err != nil {
+			t.Fatalf("%s: begin: %v", tt.name, err)
+		}
+		b.resolve(protocol.EventRosterResponse, json.RawMessage(`{"request_id":"unknown"}`))
+		b.resolve(tt.event, json.RawMessage(tt.payload))
+
+		select {
+		case result := <-call.result:
+			switch {
+			case tt.err != "" && (result.err == nil || !strings.Contains(result.err.Error(), tt.err)):
+				t.Errorf("%s: err = %v, want one mentioning %q", tt.name, result.err, tt.err)
+			case tt.err == "" && (result.err != nil || string(result.payload) != tt.want):
+				t.Errorf("%s: got (%s, %v), want %s", tt.name, result.payload, result.err, tt.want)
+			}
+			b.end(call, result.err)
+		default:
+			t.Fatalf("%s: the call was not woken", tt.name)
+		}
+
+		metrics := b.snapshot()[0]
+		if failed := tt.err != ""; (metrics.Failed == 1) != failed || (metrics.Succeeded == 1) == failed {
+			t.Errorf("%s: metrics = %+v", tt.name, metrics)
+		}
+	}
+}
+
+func TestRPCBrokerLimits(t *testing.T) {
+	var b rpcBroker
+	first, err := b.begin("a", "profiler_request", protocol.EventProfilerResponse, 1, 2)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, err := b.begin("b", "profiler_request", protocol.EventProfilerResponse, 1, 2); err != ErrPluginBusy {
+		t.Errorf("second call of a kind limited to one: err = %v, want ErrPluginBusy", err)
+	}
+	if _, err := b.begin("c", "roster_request", protocol.EventRosterResponse, 0, 2); err != nil {
+		t.Errorf("other kind: %v", err)
+	}
+	if _, err := b.begin("d", "roster_request", protocol.EventRosterResponse, 0, 2); err != ErrPluginBusy {
+		t.Errorf("past the global limit: err = %v, want ErrPluginBusy", err)
+	}
+	b.end(first, nil)
+	if _, err := b.begin("e", "profiler_request", protocol.EventProfilerResponse, 1, 2); err != nil {
+		t.Errorf("after the first call ended: %v", err)
+	}
+}
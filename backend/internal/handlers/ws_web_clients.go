@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/config"
+	"github.com/gorilla/websocket"
+)
+
+// webClient owns one browser socket. Everything sent to the browser goes
+// through the bounded send queue and a single writer goroutine, so a slow
+// connection only ever delays itself.
+type webClient struct {
+	conn     *websocket.Conn
+	settings config.WebConfig
+	metrics  *webMetrics
+
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// drops counts messages dropped since the queue was last fully drained.
+	drops     atomic.Int64
+	degraded  atomic.Bool
+	logSeqNum atomic.Uint64
+}
+
+// webMetrics are cumulative counters across all browser connections.
+type webMetrics struct {
+	sent           atomic.Uint64
+	dropped        atomic.Uint64
+	sampledOut     atomic.Uint64
+	degradations   atomic.Uint64
+	slowDisconnect atomic.Uint64
+}
+
+type WebMetricsSnapshot struct {
+	Clients          int    `json:"clients"`
+	DegradedClients  int    `json:"degraded_clients"`
+	MessagesSent     uint64 `json:"messages_sent"`
+	MessagesDropped  uint64 `json:"messages_dropped"`
+	LogLinesSampled  uint64 `json:"log_lines_sampled_out"`
+	Degradations     uint64 `json:"degradations"`
+	SlowDisconnects  uint64 `json:"slow_disconnects"`
+	SendQueueSize    int    `json:"send_queue_size"`
+	QueuedMessages   int    `json:"queued_messages"`
+	MaxClientBacklog int    `json:"max_client_backlog"`
+}
+
+func newWebClient(conn *websocket.Conn, settings config.WebConfig, metrics *webMetrics) *webClient {
+	return &webClient{
+		conn:     conn,
+		settings: settings,
+		metrics:  metrics,
+		send:     make(chan []byte, settings.SendQueueSize),
+		done:     make(chan struct{}),
+	}
+}
+
+// enqueue queues a message without blocking. Console lines are sampled while
+// the client is degraded; when the queue is full the message is dropped, and
+// a client that keeps falling behind is disconnected.
+func (c *webClient) enqueue(event string, message []byte) {
+	if event == "console_log" && c.degraded.Load() && c.settings.LogSampleRate > 1 {
+		if c.logSeqNum.Add(1)%uint64(c.settings.LogSampleRate) != 0 {
+			c.metrics.sampledOut.Add(1)
+			return
+		}
+	}
+
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+
+	select {
+	case c.send <- message:
+		return
+	default:
+	}
+
+	c.metrics.dropped.Add(1)
+	drops := c.drops.Add(1)
+	if drops >= int64(c.settings.DisconnectAfterDrops) {
+		c.metrics.slowDisconnect.Add(1)
+		c.close()
+		return
+	}
+	if drops >= int64(c.settings.DegradeAfterDrops) && c.degraded.CompareAndSwap(false, true) {
+		c.metrics.degradations.Add(1)
+	}
+}
+
+// push queues a message, waiting for room instead of dropping. Use it only
+// from the client's own handler goroutine, e.g. for the initial log snapshot.
+func (c *webClient) push(message []byte) bool {
+	select {
+	case c.send <- message:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+func (c *webClient) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		_ = c.conn.Close()
+	})
+}
+
+// writeLoop drains the send queue and keeps the connection alive with pings
+// until the client is closed or a write fails.
+func (c *webClient) writeLoop() {
+	ticker := time.NewTicker(c.settings.PingInterval)
+	defer ticker.Stop()
+	defer c.close()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case message := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(c.settings.WriteTimeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+			c.metrics.sent.Add(1)
+			if len(c.send) == 0 {
+				c.drops.Store(0)
+				c.degraded.Store(false)
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.settings.WriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// keepAlive arms the read deadline so a browser that stops answering pings is
+// dropped; call it before the read loop starts.
+func (c *webClient) keepAlive() {
+	pongWait := 2 * c.settings.PingInterval
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+}
+
+func (m *WebSocketManager) webSettings() config.WebConfig {
+	settings := m.Web
+	defaults := config.Default().Web
+	if settings.SendQueueSize <= 0 {
+		settings.SendQueueSize = defaults.SendQueueSize
+	}
+	if settings.DegradeAfterDrops <= 0 {
+		settings.DegradeAfterDrops = defaults.DegradeAfterDrops
+	}
+	if settings.DisconnectAfterDrops <= 0 {
+		settings.DisconnectAfterDrops = defaults.DisconnectAfterDrops
+	}
+	if settings.LogSampleRate <= 0 {
+		settings.LogSampleRate = defaults.LogSampleRate
+	}
+	if settings.PingInterval <= 0 {
+		settings.PingInterval = defaults.PingInterval
+	}
+	if settings.WriteTimeout <= 0 {
+		settings.WriteTimeout = defaults.WriteTimeout
+	}
+	return settings
+}
+
+// WebMetrics reports queue health across all connected browsers.
+func (m *WebSocketManager) WebMetrics() WebMetricsSnapshot {
+	m.clientsLock.RLock()
+	defer m.clientsLock.RUnlock()
+
+	snapshot := WebMetricsSnapshot{
+		Clients:         len(m.webClients),
+		MessagesSent:    m.webMetrics.sent.Load(),
+		MessagesDropped: m.webMetrics.dropped.Load(),
+		LogLinesSampled: m.webMetrics.sampledOut.Load(),
+		Degradations:    m.webMetrics.degradations.Load(),
+		SlowDisconnects: m.webMetrics.slowDisconnect.Load(),
+		SendQueueSize:   m.webSettings().SendQueueSize,
+	}
+	for client := range m.webClients {
+		backlog := len(client.send)
+		snapshot.QueuedMessages += backlog
+		if backlog > snapshot.MaxClientBacklog {
+			snapshot.MaxClientBacklog = backlog
+		}
+		if client.degraded.Load() {
+			snapshot.DegradedClients++
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/adammcgrogan/beacon/internal/protocol"
+)
+
+// forwardWorldAction validates a browser's world_action and relays only the
+// fields its action uses. Invalid actions never reach the plugin; the browser
+// gets a world_action_rejected event saying why.
+func (m *WebSocketManager) forwardWorldAction(client *webClient, raw []byte) {
+	var envelope struct {
+		Payload protocol.WorldAction `json:"payload"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		rejectWorldAction(client, envelope.Payload, "invalid world action")
+		return
+	}
+	action := envelope.Payload
+	action.Normalize()
+	if err := action.Validate(); err != nil {
+		rejectWorldAction(client, action, err.Error())
+		return
+	}
+	if !m.knownWorld(action.World) {
+		rejectWorldAction(client, action, "unknown world "+action.World)
+		return
+	}
+	if m.worlds.busy(action.World) {
+		rejectWorldAction(client, action, action.World+" is busy with another operation")
+		return
+	}
+	if m.isMinecraftConnected() && !m.pluginSupports(action.Capability()) {
+		client.enqueue("command_rejected", []byte(`{"event":"command_rejected","payload":{"reason":"plugin_unsupported"}}`))
+		return
+	}
+
+	message, err := json.Marshal(map[string]any{"event": protocol.EventWorldAction, "payload": action.Trimmed()})
+	if err != nil {
+		return
+	}
+	m.forwardToMinecraft(client, protocol.EventWorldAction, message)
+}
+
+// knownWorld reports whether the plugin has listed a world, loaded or not.
+// Before the first world_stats arrives every name is accepted.
+func (m *WebSocketManager) knownWorld(name string) bool {
+	worlds := m.Store.GetWorlds()
+	if len(worlds) == 0 {
+		return true
+	}
+	for _, world := range worlds {
+		if strings.EqualFold(world.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func rejectWorldAction(client *webClient, action protocol.WorldAction, reason string) {
+	message, err := json.Marshal(map[string]any{
+		"event":   "world_action_rejected",
+		"payload": map[string]string{"action": action.Action, "world": action.World, "error": reason},
+	})
+	if err != nil {
+		return
+	}
+	client.enqueue("world_action_rejected", message)
+}
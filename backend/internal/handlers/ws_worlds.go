@@ -0,0 +1,511 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/audit"
+	"github.com/adammcgrogan/beacon/internal/protocol"
+)
+
+// World operations run by the plugin in the background.
+const (
+	WorldCreate = "create"
+	WorldImport = "import"
+	WorldClone  = "clone"
+	WorldDelete = "delete"
+)
+
+const (
+	maxWorldOperations = 50
+	// worldImportChunk is how much of an uploaded archive goes in one message.
+	worldImportChunk = 512 << 10
+	// maxWorldArchive bounds uploaded archives; maxWorldUnpacked what they may
+	// expand to.
+	maxWorldArchive  = 1 << 30
+	maxWorldUnpacked = 8 << 30
+)
+
+// ErrInvalidWorld is returned for a malformed world create, import, clone or
+// delete request.
+var ErrInvalidWorld = errors.New("invalid world request")
+
+var (
+	worldNamePattern      = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	worldGeneratorPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}(:[^\s]{0,64})?$`)
+)
+
+var worldEnvironments = []string{"normal", "nether", "the_end"}
+var worldTypes = []string{"normal", "flat", "amplified", "large_biomes"}
+
+// WorldOperation is a create, import, clone or delete and how far it has got.
+type WorldOperation struct {
+	ID         string    `json:"id"`
+	Action     string    `json:"action"`
+	World      string    `json:"world"`
+	Source     string    `json:"source,omitempty"`
+	Stage      string    `json:"stage"`
+	Progress   float64   `json:"progress"`
+	Done       bool      `json:"done"`
+	Error      string    `json:"error,omitempty"`
+	StartedBy  string    `json:"started_by"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+
+	issuer SessionClaims
+}
+
+// CreateWorldRequest describes a new world. Empty fields use the server's
+// defaults; Structures defaults to true.
+type CreateWorldRequest struct {
+	Name        string `json:"name"`
+	Environment string `json:"environment"`
+	Type        string `json:"type"`
+	Seed        string `json:"seed"`
+	Generator   string `json:"generator"`
+	Structures  *bool  `json:"structures"`
+}
+
+type worldManageRequest struct {
+	Action      string `json:"action"`
+	OperationID string `json:"operation_id"`
+	World       string `json:"world,omitempty"`
+	Source      string `json:"source,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Seed        string `json:"seed,omitempty"`
+	Generator   string `json:"generator,omitempty"`
+	Structures  *bool  `json:"structures,omitempty"`
+	// Import only: the archive's top-level folder holding level.dat, and
+	// the chunk being uploaded.
+	Root   string `json:"root,omitempty"`
+	Offset int64  `json:"offset,omitempty"`
+	Data   string `json:"data,omitempty"`
+}
+
+type worldManageResponse struct {
+	RequestID string `json:"request_id"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error"`
+}
+
+var worldManageRPC = newPluginRPC[worldManageRequest, worldManageResponse]("world_manage_request", protocol.EventWorldManageResponse, protocol.CapWorldManagement, 0, 0)
+
+// worldOperations remembers recent operations so browsers that connect late
+// can catch up. The zero value is ready to use.
+type worldOperations struct {
+	mu  sync.Mutex
+	ops []*WorldOperation
+}
+
+func (w *worldOperations) add(op *WorldOperation) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ops = append(w.ops, op)
+	if over := len(w.ops) - maxWorldOperations; over > 0 {
+		// Drop the oldest finished operations first.
+		w.ops = slices.DeleteFunc(w.ops, func(o *WorldOperation) bool {
+			if over > 0 && o.Done {
+				over--
+				return true
+			}
+			return false
+		})
+	}
+}
+
+// update applies fn to an operation and returns a copy of the result.
+func (w *worldOperations) update(id string, fn func(op *WorldOperation)) (WorldOperation, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, op := range w.ops {
+		if op.ID == id {
+			fn(op)
+			return *op, true
+		}
+	}
+	return WorldOperation{}, false
+}
+
+func (w *worldOperations) list() []WorldOperation {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make([]WorldOperation, 0, len(w.ops))
+	for i := len(w.ops) - 1; i >= 0; i-- {
+		out = append(out, *w.ops[i])
+	}
+	return out
+}
+
+func (w *worldOperations) busy(world string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, op := range w.ops {
+		if !op.Done && (strings.EqualFold(op.World, world) || strings.EqualFold(op.Source, world)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *worldOperations) running() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var ids []string
+	for _, op := range w.ops {
+		if !op.Done {
+			ids = append(ids, op.ID)
+		}
+	}
+	return ids
+}
+
+// WorldOperations lists recent world operations, newest first.
+func (m *WebSocketManager) WorldOperations() []WorldOperation {
+	return m.worlds.list()
+}
+
+// CreateWorld asks the plugin to generate a new world.
+func (m *WebSocketManager) CreateWorld(ctx context.Context, issuer SessionClaims, req CreateWorldRequest) (WorldOperation, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Environment = strings.ToLower(strings.TrimSpace(req.Environment))
+	req.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	req.Seed = strings.TrimSpace(req.Seed)
+	req.Generator = strings.TrimSpace(req.Generator)
+
+	if err := m.checkNewWorldName(req.Name); err != nil {
+		return WorldOperation{}, err
+	}
+	if req.Environment == "" {
+		req.Environment = "normal"
+	}
+	if !slices.Contains(worldEnvironments, req.Environment) {
+		return WorldOperation{}, fmt.Errorf("%w: environment must be one of %s", ErrInvalidWorld, strings.Join(worldEnvironments, ", "))
+	}
+	if req.Type != "" && !slices.Contains(worldTypes, req.Type) {
+		return WorldOperation{}, fmt.Errorf("%w: type must be one of %s", ErrInvalidWorld, strings.Join(worldTypes, ", "))
+	}
+	if len(req.Seed) > 64 {
+		return WorldOperation{}, fmt.Errorf("%w: seed is longer than 64 characters", ErrInvalidWorld)
+	}
+	if req.Generator != "" && !worldGeneratorPattern.MatchString(req.Generator) {
+		return WorldOperation{}, fmt.Errorf("%w: generator must look like PluginName or PluginName:id", ErrInvalidWorld)
+	}
+
+	op := m.startWorldOperation(issuer, WorldCreate, req.Name, "")
+	return m.submitWorldOperation(ctx, op, worldManageRequest{
+		Action:      WorldCreate,
+		OperationID: op.ID,
+		World:       req.Name,
+		Environment: req.Environment,
+		Type:        req.Type,
+		Seed:        req.Seed,
+		Generator:   req.Generator,
+		Structures:  req.Structures,
+	})
+}
+
+// CloneWorld copies an existing world's folder to a new name and loads it.
+func (m *WebSocketManager) CloneWorld(ctx context.Context, issuer SessionClaims, source, name string) (WorldOperation, error) {
+	source = strings.TrimSpace(source)
+	name = strings.TrimSpace(name)
+	if !worldNamePattern.MatchString(source) {
+		return WorldOperation{}, fmt.Errorf("%w: source world is required", ErrInvalidWorld)
+	}
+	if err := m.checkNewWorldName(name); err != nil {
+		return WorldOperation{}, err
+	}
+	if m.worlds.busy(source) {
+		return WorldOperation{}, fmt.Errorf("%w: %s is busy with another operation", ErrInvalidWorld, source)
+	}
+
+	op := m.startWorldOperation(issuer, WorldClone, name, source)
+	return m.submitWorldOperation(ctx, op, worldManageRequest{
+		Action:      WorldClone,
+		OperationID: op.ID,
+		World:       name,
+		Source:      source,
+	})
+}
+
+// DeleteWorld unloads a world and permanently removes its folder. The
+// server's primary world cannot be deleted.
+func (m *WebSocketManager) DeleteWorld(ctx context.Context, issuer SessionClaims, name string) (WorldOperation, error) {
+	name = strings.TrimSpace(name)
+	if !worldNamePattern.MatchString(name) {
+		return WorldOperation{}, fmt.Errorf("%w: world name is required", ErrInvalidWorld)
+	}
+	if m.worlds.busy(name) {
+		return WorldOperation{}, fmt.Errorf("%w: %s is busy with another operation", ErrInvalidWorld, name)
+	}
+
+	op := m.startWorldOperation(issuer, WorldDelete, name, "")
+	return m.submitWorldOperation(ctx, op, worldManageRequest{
+		Action:      WorldDelete,
+		OperationID: op.ID,
+		World:       name,
+	})
+}
+
+// ImportWorld uploads a zipped world to the plugin in the background and
+// loads it as name. It takes ownership of archive and removes it when done.
+func (m *WebSocketManager) ImportWorld(issuer SessionClaims, name, environment string, archive *os.File, size int64) (WorldOperation, error) {
+	fail := func(err error) (WorldOperation, error) {
+		archive.Close()
+		os.Remove(archive.Name())
+		return WorldOperation{}, err
+	}
+
+	name = strings.TrimSpace(name)
+	environment = strings.ToLower(strings.TrimSpace(environment))
+	if err := m.checkNewWorldName(name); err != nil {
+		return fail(err)
+	}
+	if environment == "" {
+		environment = "normal"
+	}
+	if !slices.Contains(worldEnvironments, environment) {
+		return fail(fmt.Errorf("%w: environment must be one of %s", ErrInvalidWorld, strings.Join(worldEnvironments, ", ")))
+	}
+	root, err := inspectWorldArchive(archive, size)
+	if err != nil {
+		return fail(err)
+	}
+	if !m.isMinecraftConnected() {
+		return fail(ErrPluginOffline)
+	}
+	if !m.pluginSupports(protocol.CapWorldManagement) {
+		return fail(ErrPluginUnsupported)
+	}
+
+	op := m.startWorldOperation(issuer, WorldImport, name, "")
+	go func() {
+		defer os.Remove(archive.Name())
+		defer archive.Close()
+		if _, err := archive.Seek(0, io.SeekStart); err != nil {
+			m.finishWorldOperation(op.ID, "upload failed: "+err.Error())
+			return
+		}
+		if err := m.uploadWorldArchive(op, archive, size); err != nil {
+			m.finishWorldOperation(op.ID, err.Error())
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), m.pluginRequestTimeout())
+		defer cancel()
+		_, err := m.submitWorldOperation(ctx, op, worldManageRequest{
+			Action:      WorldImport,
+			OperationID: op.ID,
+			World:       name,
+			Environment: environment,
+			Root:        root,
+		})
+		if err != nil {
+			log.Printf("beacon worlds: import of %s failed to start: %v", name, err)
+		}
+	}()
+	return *op, nil
+}
+
+func (m *WebSocketManager) uploadWorldArchive(op *WorldOperation, archive *os.File, size int64) error {
+	buf := make([]byte, worldImportChunk)
+	var offset int64
+	for {
+		n, err := io.ReadFull(archive, buf)
+		if n > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), m.pluginRequestTimeout())
+			resp, callErr := callPlugin(ctx, m, worldManageRPC, worldManageRequest{
+				Action:      "import_chunk",
+				OperationID: op.ID,
+				World:       op.World,
+				Offset:      offset,
+				Data:        base64.StdEncoding.EncodeToString(buf[:n]),
+			})
+			cancel()
+			if callErr != nil {
+				return fmt.Errorf("upload failed: %s", pluginErrorMessage(callErr))
+			}
+			if !resp.OK {
+				return fmt.Errorf("upload failed: %s", resp.Error)
+			}
+			offset += int64(n)
+			m.progressWorldOperation(op.ID, "uploading", 0.5*float64(offset)/float64(size))
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("upload failed: %v", err)
+		}
+	}
+}
+
+// inspectWorldArchive checks a zip holds a world and returns the folder its
+// level.dat is in: "" for the archive root or a single top-level folder.
+func inspectWorldArchive(archive io.ReaderAt, size int64) (string, error) {
+	reader, err := zip.NewReader(archive, size)
+	if err != nil {
+		return "", fmt.Errorf("%w: archive is not a zip file", ErrInvalidWorld)
+	}
+	var unpacked uint64
+	root, found := "", false
+	for _, file := range reader.File {
+		name := strings.ReplaceAll(file.Name, "\\", "/")
+		if strings.HasPrefix(name, "/") || slices.Contains(strings.Split(name, "/"), "..") {
+			return "", fmt.Errorf("%w: archive entry %q escapes the world folder", ErrInvalidWorld, file.Name)
+		}
+		unpacked += file.UncompressedSize64
+		if unpacked > maxWorldUnpacked {
+			return "", fmt.Errorf("%w: archive unpacks to more than %d GiB", ErrInvalidWorld, maxWorldUnpacked>>30)
+		}
+		if path.Base(name) != "level.dat" {
+			continue
+		}
+		dir := path.Dir(name)
+		if dir == "." {
+			dir = ""
+		}
+		if strings.Contains(dir, "/") {
+			continue
+		}
+		if !found || dir == "" {
+			root, found = dir, true
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("%w: archive has no level.dat at its root or in a top-level folder", ErrInvalidWorld)
+	}
+	return root, nil
+}
+
+func (m *WebSocketManager) checkNewWorldName(name string) error {
+	if !worldNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: world names are 1-64 letters, digits, _ or -", ErrInvalidWorld)
+	}
+	for _, world := range m.Store.GetWorlds() {
+		if strings.EqualFold(world.Name, name) {
+			return fmt.Errorf("%w: a world named %s already exists", ErrInvalidWorld, name)
+		}
+	}
+	if m.worlds.busy(name) {
+		return fmt.Errorf("%w: %s is busy with another operation", ErrInvalidWorld, name)
+	}
+	return nil
+}
+
+func (m *WebSocketManager) startWorldOperation(issuer SessionClaims, action, world, source string) *WorldOperation {
+	id, _ := randomHex(8)
+	op := &WorldOperation{
+		ID:        id,
+		Action:    action,
+		World:     world,
+		Source:    source,
+		Stage:     "queued",
+		StartedBy: issuer.PlayerName,
+		StartedAt: time.Now(),
+		issuer:    issuer,
+	}
+	m.worlds.add(op)
+	m.broadcastWorldOperation(*op)
+	return op
+}
+
+// submitWorldOperation hands an operation to the plugin, which answers once it
+// has accepted it and then reports progress with world_progress events.
+func (m *WebSocketManager) submitWorldOperation(ctx context.Context, op *WorldOperation, req worldManageRequest) (WorldOperation, error) {
+	resp, err := callPlugin(ctx, m, worldManageRPC, req)
+	if err == nil && !resp.OK {
+		if resp.Error == "" {
+			resp.Error = req.Action + " failed"
+		}
+		err = fmt.Errorf("%w: %s", ErrInvalidWorld, resp.Error)
+	}
+	if err != nil {
+		m.finishWorldOperation(op.ID, pluginErrorMessage(err))
+		return WorldOperation{}, err
+	}
+	snapshot, _ := m.worlds.update(op.ID, func(*WorldOperation) {})
+	return snapshot, nil
+}
+
+func (m *WebSocketManager) progressWorldOperation(id, stage string, progress float64) {
+	snapshot, ok := m.worlds.update(id, func(op *WorldOperation) {
+		op.Stage = stage
+		op.Progress = progress
+	})
+	if ok {
+		m.broadcastWorldOperation(snapshot)
+	}
+}
+
+func (m *WebSocketManager) finishWorldOperation(id, failure string) {
+	snapshot, ok := m.worlds.update(id, func(op *WorldOperation) {
+		if op.Done {
+			return
+		}
+		op.Done = true
+		op.FinishedAt = time.Now()
+		op.Error = failure
+		if failure == "" {
+			op.Stage = "done"
+			op.Progress = 1
+		} else {
+			op.Stage = "failed"
+		}
+	})
+	if !ok || snapshot.FinishedAt.IsZero() {
+		return
+	}
+	m.broadcastWorldOperation(snapshot)
+	if failure == "" && snapshot.Action == WorldDelete && m.Hotspots != nil {
+		m.Hotspots.Forget(snapshot.World)
+	}
+
+	target := snapshot.World
+	if snapshot.Source != "" {
+		target = snapshot.Source + " -> " + snapshot.World
+	}
+	m.audit(snapshot.issuer, audit.Entry{
+		Action: "worlds." + snapshot.Action,
+		Target: target,
+		OK:     failure == "",
+		Error:  failure,
+	})
+}
+
+// handleWorldProgress applies a world_progress event from the plugin.
+func (m *WebSocketManager) handleWorldProgress(progress protocol.WorldProgress) {
+	if progress.Done {
+		m.finishWorldOperation(progress.OperationID, progress.Error)
+		return
+	}
+	m.progressWorldOperation(progress.OperationID, progress.Stage, progress.Progress)
+}
+
+// abandonWorldOperations fails operations the plugin can no longer finish
+// because it disconnected.
+func (m *WebSocketManager) abandonWorldOperations() {
+	for _, id := range m.worlds.running() {
+		m.finishWorldOperation(id, "server disconnected before the operation finished")
+	}
+}
+
+func (m *WebSocketManager) broadcastWorldOperation(op WorldOperation) {
+	message, err := json.Marshal(map[string]any{"event": "world_operation", "payload": op})
+	if err != nil {
+		return
+	}
+	m.broadcastToWeb("world_operation", message)
+}
@@ -0,0 +1,124 @@
+// Package handoff lets a running backend pass its listening socket to a freshly
+// started copy of itself, so an upgrade never refuses connections: the new
+// process starts accepting before the old one stops.
+package handoff
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+const (
+	listenFDEnv = "BEACON_LISTEN_FD"
+	readyFDEnv  = "BEACON_READY_FD"
+)
+
+// Listen returns the listener inherited from a parent process if there is one,
+// otherwise it opens a new TCP listener on addr.
+func Listen(addr string) (net.Listener, bool, error) {
+	raw := os.Getenv(listenFDEnv)
+	if raw == "" {
+		ln, err := net.Listen("tcp", addr)
+		return ln, false, err
+	}
+	_ = os.Unsetenv(listenFDEnv)
+
+	fd, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", listenFDEnv, err)
+	}
+	file := os.NewFile(uintptr(fd), "beacon-listener")
+	if file == nil {
+		return nil, false, fmt.Errorf("%s: invalid descriptor %d", listenFDEnv, fd)
+	}
+	defer file.Close()
+
+	ln, err := net.FileListener(file)
+	if err != nil {
+		return nil, false, err
+	}
+	return ln, true, nil
+}
+
+// NotifyReady tells the parent process, if any, that this process is serving
+// and the parent may begin shutting down.
+func NotifyReady() {
+	raw := os.Getenv(readyFDEnv)
+	if raw == "" {
+		return
+	}
+	_ = os.Unsetenv(readyFDEnv)
+
+	fd, err := strconv.Atoi(raw)
+	if err != nil {
+		return
+	}
+	if file := os.NewFile(uintptr(fd), "beacon-ready"); file != nil {
+		_, _ = file.Write([]byte{1})
+		_ = file.Close()
+	}
+}
+
+// Restart starts the current executable with the same arguments, handing it
+// ln, and waits until the child reports it is ready or timeout elapses.
+func Restart(ln net.Listener, timeout time.Duration) (*os.Process, error) {
+	tcpLn, ok := ln.(*net.TCPListener)
+	if !ok {
+		return nil, errors.New("listener does not support handoff")
+	}
+	lnFile, err := tcpLn.File()
+	if err != nil {
+		return nil, err
+	}
+	defer lnFile.Close()
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer readyR.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		_ = readyW.Close()
+		return nil, err
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{lnFile, readyW}
+	// ExtraFiles[i] becomes descriptor 3+i in the child.
+	cmd.Env = append(os.Environ(), listenFDEnv+"=3", readyFDEnv+"=4")
+
+	if err := cmd.Start(); err != nil {
+		_ = readyW.Close()
+		return nil, err
+	}
+	_ = readyW.Close()
+
+	ready := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		_, err := readyR.Read(buf)
+		ready <- err
+	}()
+
+	select {
+	case err := <-ready:
+		if err != nil {
+			_ = cmd.Process.Kill()
+			return nil, fmt.Errorf("new process exited before becoming ready: %w", err)
+		}
+		return cmd.Process, nil
+	case <-time.After(timeout):
+		_ = cmd.Process.Kill()
+		return nil, errors.New("new process did not become ready in time")
+	}
+}
@@ -0,0 +1,332 @@
+// Package history records when players are online. Sessions are derived from
+// the successive player lists in server_stats, with console join/leave lines
+// closing gaps between two stats updates, and persisted per UUID so players
+// stay visible after they log off.
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/datafile"
+	"github.com/adammcgrogan/beacon/internal/models"
+)
+
+const (
+	// staleAfter is how long the backend may go without a player list before
+	// open sessions are assumed to have ended at the last one seen.
+	staleAfter = 2 * time.Minute
+	// maxSessions is how many sessions are kept per player; older ones still
+	// count towards total playtime.
+	maxSessions = 500
+	// keepDays is how many days of daily peaks are kept.
+	keepDays = 400
+
+	flushInterval = 1 * time.Minute
+	dayLayout     = "2006-01-02"
+)
+
+// Console lines such as "Steve joined the game", optionally prefixed with the
+// log header when the plugin forwards the formatted line.
+var joinLeavePattern = regexp.MustCompile(`(?:^|\]: )([A-Za-z0-9_]{1,16}) (joined|left) the game$`)
+
+// Session is one continuous stretch online. While Open, End is the last time
+// the player was seen.
+type Session struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Open  bool      `json:"open,omitempty"`
+}
+
+func (s Session) duration() time.Duration {
+	if s.End.Before(s.Start) {
+		return 0
+	}
+	return s.End.Sub(s.Start)
+}
+
+type playerRecord struct {
+	UUID      string    `json:"uuid"`
+	Name      string    `json:"name"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// Playtime is the total of all closed sessions, including trimmed ones.
+	Playtime time.Duration `json:"playtime_ns"`
+	Sessions []Session     `json:"sessions"`
+}
+
+func (p *playerRecord) open() bool {
+	return len(p.Sessions) > 0 && p.Sessions[len(p.Sessions)-1].Open
+}
+
+func (p *playerRecord) totalPlaytime() time.Duration {
+	total := p.Playtime
+	if p.open() {
+		total += p.Sessions[len(p.Sessions)-1].duration()
+	}
+	return total
+}
+
+type dayRecord struct {
+	Peak    int      `json:"peak"`
+	Players []string `json:"players"`
+}
+
+type persistedHistory struct {
+	Players []*playerRecord       `json:"players"`
+	Days    map[string]*dayRecord `json:"days"`
+}
+
+// Tracker derives and stores player sessions. It is safe for concurrent use.
+type Tracker struct {
+	path string
+
+	mu           sync.Mutex
+	players      map[string]*playerRecord
+	days         map[string]*dayRecord
+	lastObserved time.Time
+	dirty        bool
+
+	persistMu sync.Mutex
+}
+
+// NewTracker keeps its history in player_history.json inside dataDir.
+func NewTracker(dataDir string) *Tracker {
+	return &Tracker{
+		path:    filepath.Join(dataDir, "player_history.json"),
+		players: make(map[string]*playerRecord),
+		days:    make(map[string]*dayRecord),
+	}
+}
+
+// Load reads previously saved history. Sessions left open by a backend that
+// stopped are closed at the last time their player was seen.
+func (t *Tracker) Load() {
+	var state persistedHistory
+	found, err := datafile.Read(t.path, &state)
+	if err != nil {
+		log.Printf("beacon history: failed loading %s: %v", t.path, err)
+		return
+	}
+	if !found {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, player := range state.Players {
+		if player == nil || player.UUID == "" {
+			continue
+		}
+		if player.open() {
+			t.closeSession(player, player.Sessions[len(player.Sessions)-1].End)
+		}
+		t.players[player.UUID] = player
+	}
+	for day, record := range state.Days {
+		if record != nil {
+			t.days[day] = record
+		}
+	}
+}
+
+// Observe records the players online at the given time.
+func (t *Tracker) Observe(online []models.PlayerInfo, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.lastObserved.IsZero() && at.Sub(t.lastObserved) > staleAfter {
+		t.closeAll(t.lastObserved)
+	}
+	t.lastObserved = at
+
+	current := make(map[string]bool, len(online))
+	for _, info := range online {
+		if info.UUID == "" {
+			continue
+		}
+		current[info.UUID] = true
+		player := t.seen(info.UUID, info.Name, at)
+		if info.FirstJoin > 0 {
+			if firstJoin := time.UnixMilli(info.FirstJoin); firstJoin.Before(player.FirstSeen) {
+				player.FirstSeen = firstJoin
+			}
+		}
+	}
+	for uuid, player := range t.players {
+		if player.open() && !current[uuid] {
+			t.closeSession(player, at)
+		}
+	}
+
+	day := t.day(at)
+	day.Peak = max(day.Peak, len(current))
+	t.dirty = true
+}
+
+// ObserveLogLine opens or closes a session from a console join/leave line.
+// Names the tracker has never seen with a UUID are left to the next Observe.
+func (t *Tracker) ObserveLogLine(line string, at time.Time) {
+	match := joinLeavePattern.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	player := t.byName(match[1])
+	if player == nil {
+		return
+	}
+	if match[2] == "joined" {
+		t.seen(player.UUID, player.Name, at)
+	} else if player.open() {
+		t.closeSession(player, at)
+	}
+	t.dirty = true
+}
+
+// MarkAllOffline ends every open session, e.g. when the plugin disconnects.
+func (t *Tracker) MarkAllOffline(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeAll(at)
+	t.lastObserved = time.Time{}
+}
+
+// seen marks a player online at the given time, opening a session if needed.
+func (t *Tracker) seen(uuid, name string, at time.Time) *playerRecord {
+	player, ok := t.players[uuid]
+	if !ok {
+		player = &playerRecord{UUID: uuid, FirstSeen: at}
+		t.players[uuid] = player
+	}
+	if name != "" {
+		player.Name = name
+	}
+	player.LastSeen = at
+
+	if player.open() {
+		player.Sessions[len(player.Sessions)-1].End = at
+	} else {
+		player.Sessions = append(player.Sessions, Session{Start: at, End: at, Open: true})
+		if len(player.Sessions) > maxSessions {
+			player.Sessions = player.Sessions[len(player.Sessions)-maxSessions:]
+		}
+	}
+
+	day := t.day(at)
+	if !slices.Contains(day.Players, uuid) {
+		day.Players = append(day.Players, uuid)
+	}
+	return player
+}
+
+func (t *Tracker) closeSession(player *playerRecord, at time.Time) {
+	session := &player.Sessions[len(player.Sessions)-1]
+	if at.After(session.End) {
+		session.End = at
+	}
+	session.Open = false
+	player.Playtime += session.duration()
+}
+
+func (t *Tracker) closeAll(at time.Time) {
+	for _, player := range t.players {
+		if player.open() {
+			t.closeSession(player, at)
+			t.dirty = true
+		}
+	}
+}
+
+func (t *Tracker) byName(name string) *playerRecord {
+	var found *playerRecord
+	for _, player := range t.players {
+		if strings.EqualFold(player.Name, name) && (found == nil || player.LastSeen.After(found.LastSeen)) {
+			found = player
+		}
+	}
+	return found
+}
+
+func (t *Tracker) day(at time.Time) *dayRecord {
+	key := at.Format(dayLayout)
+	record, ok := t.days[key]
+	if !ok {
+		record = &dayRecord{}
+		t.days[key] = record
+		t.pruneDays(at)
+	}
+	return record
+}
+
+func (t *Tracker) pruneDays(now time.Time) {
+	cutoff := now.AddDate(0, 0, -keepDays).Format(dayLayout)
+	for key := range t.days {
+		if key < cutoff {
+			delete(t.days, key)
+		}
+	}
+}
+
+// Start saves changed history every minute until ctx is cancelled. The
+// returned channel is closed once the loop has stopped.
+func (t *Tracker) Start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.save(false)
+			}
+		}
+	}()
+	return done
+}
+
+// Flush saves synchronously. Call it during shutdown; sessions still open are
+// closed at their last sighting when the history is next loaded.
+func (t *Tracker) Flush() {
+	t.save(true)
+}
+
+func (t *Tracker) save(force bool) {
+	t.persistMu.Lock()
+	defer t.persistMu.Unlock()
+
+	t.mu.Lock()
+	if !t.dirty && !force {
+		t.mu.Unlock()
+		return
+	}
+	state := persistedHistory{
+		Players: make([]*playerRecord, 0, len(t.players)),
+		Days:    t.days,
+	}
+	for _, player := range t.players {
+		state.Players = append(state.Players, player)
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	t.dirty = false
+	t.mu.Unlock()
+	if err != nil {
+		log.Printf("beacon history: failed encoding history: %v", err)
+		return
+	}
+	if err := datafile.Write(t.path, data); err != nil {
+		log.Printf("beacon history: failed writing %s: %v", t.path, err)
+	}
+}
@@ -0,0 +1,153 @@
+package history
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// PlayerSummary is one player's history without the session list.
+type PlayerSummary struct {
+	UUID            string    `json:"uuid"`
+	Name            string    `json:"name"`
+	Online          bool      `json:"online"`
+	FirstSeen       time.Time `json:"first_seen"`
+	LastSeen        time.Time `json:"last_seen"`
+	SessionCount    int       `json:"session_count"`
+	PlaytimeSeconds int64     `json:"playtime_seconds"`
+}
+
+// PlayerHistory is one player's summary and their recorded sessions, newest
+// first.
+type PlayerHistory struct {
+	PlayerSummary
+	Sessions []Session `json:"sessions"`
+}
+
+// DayStats describes activity on one calendar day.
+type DayStats struct {
+	Date             string `json:"date"`
+	PeakPlayers      int    `json:"peak_players"`
+	UniquePlayers    int    `json:"unique_players"`
+	NewPlayers       int    `json:"new_players"`
+	ReturningPlayers int    `json:"returning_players"`
+}
+
+// RetentionRate is the share of players first seen at least Days days ago who
+// played again Days or more days after their first visit.
+type RetentionRate struct {
+	Days     int     `json:"days"`
+	Cohort   int     `json:"cohort"`
+	Retained int     `json:"retained"`
+	Rate     float64 `json:"rate"`
+}
+
+// Report summarises activity over the last WindowDays days.
+type Report struct {
+	WindowDays       int             `json:"window_days"`
+	Players          []PlayerSummary `json:"players"`
+	Daily            []DayStats      `json:"daily"`
+	NewPlayers       int             `json:"new_players"`
+	ReturningPlayers int             `json:"returning_players"`
+	Retention        []RetentionRate `json:"retention"`
+}
+
+var retentionDays = []int{1, 7, 30}
+
+// Report builds the analytics for the last days days, newest players first.
+func (t *Tracker) Report(days int, now time.Time) Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	start := startOfDay(now).AddDate(0, 0, -(days - 1))
+	report := Report{
+		WindowDays: days,
+		Players:    make([]PlayerSummary, 0),
+		Daily:      make([]DayStats, 0, days),
+		Retention:  make([]RetentionRate, 0, len(retentionDays)),
+	}
+
+	for _, player := range t.players {
+		if player.LastSeen.Before(start) {
+			continue
+		}
+		report.Players = append(report.Players, player.summary())
+		if player.FirstSeen.Before(start) {
+			report.ReturningPlayers++
+		} else {
+			report.NewPlayers++
+		}
+	}
+	sort.Slice(report.Players, func(i, j int) bool {
+		return report.Players[i].LastSeen.After(report.Players[j].LastSeen)
+	})
+
+	for day := start; !day.After(now); day = day.AddDate(0, 0, 1) {
+		key := day.Format(dayLayout)
+		stats := DayStats{Date: key}
+		if record, ok := t.days[key]; ok {
+			stats.PeakPlayers = record.Peak
+			stats.UniquePlayers = len(record.Players)
+			for _, uuid := range record.Players {
+				if player, ok := t.players[uuid]; ok && player.FirstSeen.Format(dayLayout) == key {
+					stats.NewPlayers++
+				}
+			}
+			stats.ReturningPlayers = stats.UniquePlayers - stats.NewPlayers
+		}
+		report.Daily = append(report.Daily, stats)
+	}
+
+	for _, n := range retentionDays {
+		rate := RetentionRate{Days: n}
+		for _, player := range t.players {
+			returnBy := player.FirstSeen.AddDate(0, 0, n)
+			if returnBy.After(now) {
+				continue
+			}
+			rate.Cohort++
+			if !player.LastSeen.Before(returnBy) {
+				rate.Retained++
+			}
+		}
+		if rate.Cohort > 0 {
+			rate.Rate = float64(rate.Retained) / float64(rate.Cohort)
+		}
+		report.Retention = append(report.Retention, rate)
+	}
+	return report
+}
+
+// Player returns one player's full history.
+func (t *Tracker) Player(uuid string) (PlayerHistory, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	player, ok := t.players[uuid]
+	if !ok {
+		return PlayerHistory{}, false
+	}
+	sessions := slices.Clone(player.Sessions)
+	slices.Reverse(sessions)
+	return PlayerHistory{
+		PlayerSummary: player.summary(),
+		Sessions:      sessions,
+	}, true
+}
+
+func (p *playerRecord) summary() PlayerSummary {
+	return PlayerSummary{
+		UUID:            p.UUID,
+		Name:            p.Name,
+		Online:          p.open(),
+		FirstSeen:       p.FirstSeen,
+		LastSeen:        p.LastSeen,
+		SessionCount:    len(p.Sessions),
+		PlaytimeSeconds: int64(p.totalPlaytime().Seconds()),
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
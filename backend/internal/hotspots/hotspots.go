@@ -0,0 +1,213 @@
+// Package hotspots keeps per-world scans of the chunks holding the most
+// entities and tile entities, so growth such as a mob farm filling up can be
+// followed over time.
+package hotspots
+
+import (
+	"cmp"
+	"encoding/json"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/datafile"
+)
+
+const (
+	// DefaultTop is how many chunks a scan keeps when none is configured.
+	DefaultTop = 20
+	// MaxTop bounds how many chunks one scan may keep.
+	MaxTop = 100
+	// DefaultKeep is how many scans are kept per world when none is
+	// configured: a week at the default 15 minute interval.
+	DefaultKeep = 672
+)
+
+// Chunk is one chunk's load at the time of a scan. X and Z are chunk
+// coordinates; multiply by 16 for block coordinates.
+type Chunk struct {
+	X               int            `json:"x"`
+	Z               int            `json:"z"`
+	Entities        int            `json:"entities"`
+	TileEntities    int            `json:"tile_entities"`
+	EntityTypes     map[string]int `json:"entity_types,omitempty"`
+	TileEntityTypes map[string]int `json:"tile_entity_types,omitempty"`
+}
+
+// Load is what chunks are ranked by.
+func (c Chunk) Load() int {
+	return c.Entities + c.TileEntities
+}
+
+// Scan is one pass over a world's loaded chunks. Totals cover every loaded
+// chunk; Hotspots only the busiest, busiest first.
+type Scan struct {
+	World        string    `json:"world"`
+	TakenAt      time.Time `json:"taken_at"`
+	LoadedChunks int       `json:"loaded_chunks"`
+	Entities     int       `json:"entities"`
+	TileEntities int       `json:"tile_entities"`
+	Hotspots     []Chunk   `json:"hotspots"`
+}
+
+// Point is one sample of a world's or a chunk's counts. For a chunk, Ranked
+// is false when it was not among the scan's hotspots and its counts are
+// unknown.
+type Point struct {
+	TakenAt      time.Time `json:"taken_at"`
+	Entities     int       `json:"entities"`
+	TileEntities int       `json:"tile_entities"`
+	Ranked       bool      `json:"ranked"`
+}
+
+// History stores scans in hotspot_history.json, saving after every scan. It
+// is safe for concurrent use.
+type History struct {
+	path string
+	keep int
+
+	mu     sync.RWMutex
+	worlds map[string][]Scan
+
+	persistMu sync.Mutex
+}
+
+// NewHistory keeps up to keep scans per world in hotspot_history.json inside
+// dataDir.
+func NewHistory(dataDir string, keep int) *History {
+	if keep <= 0 {
+		keep = DefaultKeep
+	}
+	return &History{
+		path:   filepath.Join(dataDir, "hotspot_history.json"),
+		keep:   keep,
+		worlds: make(map[string][]Scan),
+	}
+}
+
+// Load reads previously saved scans.
+func (h *History) Load() {
+	var scans []Scan
+	found, err := datafile.Read(h.path, &scans)
+	if err != nil {
+		log.Printf("beacon hotspots: failed loading %s: %v", h.path, err)
+		return
+	}
+	if !found {
+		return
+	}
+	h.mu.Lock()
+	for _, scan := range scans {
+		key := strings.ToLower(scan.World)
+		h.worlds[key] = append(h.worlds[key], scan)
+	}
+	for key, scans := range h.worlds {
+		slices.SortFunc(scans, func(a, b Scan) int { return a.TakenAt.Compare(b.TakenAt) })
+		h.worlds[key] = trim(scans, h.keep)
+	}
+	h.mu.Unlock()
+}
+
+// Record stores a scan, ranking and trimming its hotspots to MaxTop.
+func (h *History) Record(scan Scan) Scan {
+	slices.SortStableFunc(scan.Hotspots, func(a, b Chunk) int { return cmp.Compare(b.Load(), a.Load()) })
+	if len(scan.Hotspots) > MaxTop {
+		scan.Hotspots = scan.Hotspots[:MaxTop]
+	}
+	if scan.Hotspots == nil {
+		scan.Hotspots = []Chunk{}
+	}
+
+	h.mu.Lock()
+	key := strings.ToLower(scan.World)
+	h.worlds[key] = trim(append(h.worlds[key], scan), h.keep)
+	h.mu.Unlock()
+	h.save()
+	return scan
+}
+
+// Latest returns a world's most recent scan.
+func (h *History) Latest(world string) (Scan, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	scans := h.worlds[strings.ToLower(world)]
+	if len(scans) == 0 {
+		return Scan{}, false
+	}
+	return scans[len(scans)-1], true
+}
+
+// Totals returns a world's entity and tile entity totals for every scan
+// taken since the given time, oldest first.
+func (h *History) Totals(world string, since time.Time) []Point {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := []Point{}
+	for _, scan := range h.worlds[strings.ToLower(world)] {
+		if scan.TakenAt.Before(since) {
+			continue
+		}
+		out = append(out, Point{TakenAt: scan.TakenAt, Entities: scan.Entities, TileEntities: scan.TileEntities, Ranked: true})
+	}
+	return out
+}
+
+// Chunk returns one chunk's counts for every scan taken since the given
+// time, oldest first.
+func (h *History) Chunk(world string, x, z int, since time.Time) []Point {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := []Point{}
+	for _, scan := range h.worlds[strings.ToLower(world)] {
+		if scan.TakenAt.Before(since) {
+			continue
+		}
+		point := Point{TakenAt: scan.TakenAt}
+		if i := slices.IndexFunc(scan.Hotspots, func(c Chunk) bool { return c.X == x && c.Z == z }); i >= 0 {
+			point.Entities, point.TileEntities, point.Ranked = scan.Hotspots[i].Entities, scan.Hotspots[i].TileEntities, true
+		}
+		out = append(out, point)
+	}
+	return out
+}
+
+// Forget drops a world's scans, e.g. once the world is deleted.
+func (h *History) Forget(world string) {
+	h.mu.Lock()
+	_, ok := h.worlds[strings.ToLower(world)]
+	delete(h.worlds, strings.ToLower(world))
+	h.mu.Unlock()
+	if ok {
+		h.save()
+	}
+}
+
+func trim(scans []Scan, keep int) []Scan {
+	if len(scans) > keep {
+		return slices.Clone(scans[len(scans)-keep:])
+	}
+	return scans
+}
+
+func (h *History) save() {
+	h.persistMu.Lock()
+	defer h.persistMu.Unlock()
+
+	h.mu.RLock()
+	scans := make([]Scan, 0, len(h.worlds))
+	for _, world := range h.worlds {
+		scans = append(scans, world...)
+	}
+	data, err := json.Marshal(scans)
+	h.mu.RUnlock()
+	if err != nil {
+		log.Printf("beacon hotspots: failed encoding history: %v", err)
+		return
+	}
+	if err := datafile.Write(h.path, data); err != nil {
+		log.Printf("beacon hotspots: failed writing %s: %v", h.path, err)
+	}
+}
@@ -8,6 +8,55 @@ type ServerStats struct {
 	RamMax           int64             `json:"ram_max"`
 	PlayerList       []PlayerInfo      `json:"player_list"`
 	DefaultGamerules map[string]string `json:"default_gamerules"`
+
+	// The fields below are not reported by older plugins, which only send the
+	// one-minute TPS as a string, and are nil then.
+	TPS1m   *float64   `json:"tps_1m,omitempty"`
+	TPS5m   *float64   `json:"tps_5m,omitempty"`
+	TPS15m  *float64   `json:"tps_15m,omitempty"`
+	MSPT    *TickTimes `json:"mspt,omitempty"`
+	CPU     *CPUUsage  `json:"cpu,omitempty"`
+	GC      []GCStats  `json:"gc,omitempty"`
+	Threads *int       `json:"threads,omitempty"`
+	Disk    *DiskUsage `json:"disk,omitempty"`
+}
+
+// TickTimes summarises the durations, in milliseconds, of the last minute
+// of ticks.
+type TickTimes struct {
+	Avg float64 `json:"avg"`
+	P95 float64 `json:"p95"`
+	Max float64 `json:"max"`
+}
+
+// CPUUsage is CPU load in percent of all cores. A field is nil when the JVM
+// cannot measure it.
+type CPUUsage struct {
+	Process *float64 `json:"process,omitempty"`
+	System  *float64 `json:"system,omitempty"`
+}
+
+// GCStats counts one garbage collector's collections and the time spent in
+// them since the server started.
+type GCStats struct {
+	Name   string `json:"name"`
+	Count  int64  `json:"count"`
+	TimeMS int64  `json:"time_ms"`
+}
+
+// DiskUsage is the size of the world and backup folders, measured every few
+// minutes, and the space on the disk that holds the worlds.
+type DiskUsage struct {
+	Total   int64         `json:"total"`
+	Free    int64         `json:"free"`
+	Folders []FolderUsage `json:"folders"`
+}
+
+// FolderUsage is the size of a world ("world") or backup ("backup") folder.
+type FolderUsage struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Bytes int64  `json:"bytes"`
 }
 
 type PlayerInfo struct {
@@ -31,6 +80,23 @@ type WorldInfo struct {
 	Difficulty  string            `json:"difficulty"`
 	Seed        string            `json:"seed"`
 	Gamerules   map[string]string `json:"gamerules"`
+	// Border, Spawn and PVP are only reported for loaded worlds, and not by
+	// plugins older than the world_settings capability.
+	Border *WorldBorder `json:"border,omitempty"`
+	Spawn  *Location    `json:"spawn,omitempty"`
+	PVP    *bool        `json:"pvp,omitempty"`
+}
+
+type WorldBorder struct {
+	CenterX float64 `json:"center_x"`
+	CenterZ float64 `json:"center_z"`
+	Size    float64 `json:"size"`
+}
+
+type Location struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
 }
 
 type ServerEnv struct {
@@ -0,0 +1,73 @@
+// Package moderation reads the server's ban lists and keeps the moderation
+// state the server itself does not: who is muted, which bans and mutes are
+// due to be lifted automatically, and the appeal notes on each ban.
+package moderation
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// banTimeLayout is how the server writes created/expires in banned-*.json.
+const banTimeLayout = "2006-01-02 15:04:05 -0700"
+
+// Ban is one entry from banned-players.json or banned-ips.json.
+type Ban struct {
+	Name      string    `json:"name,omitempty"`
+	UUID      string    `json:"uuid,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	Reason    string    `json:"reason"`
+	Source    string    `json:"source"`
+	Created   time.Time `json:"created,omitzero"`
+	Expires   time.Time `json:"expires,omitzero"`
+	Permanent bool      `json:"permanent"`
+	// Appeals are the notes staff kept on this ban; see Registry.AttachAppeals.
+	Appeals []Appeal `json:"appeals,omitempty"`
+}
+
+type rawBan struct {
+	Name    string `json:"name"`
+	UUID    string `json:"uuid"`
+	IP      string `json:"ip"`
+	Reason  string `json:"reason"`
+	Source  string `json:"source"`
+	Created string `json:"created"`
+	Expires string `json:"expires"`
+}
+
+// ParseBanList decodes banned-players.json or banned-ips.json. Entries whose
+// expiry has passed are left out, as the server ignores them too.
+func ParseBanList(data []byte, now time.Time) ([]Ban, error) {
+	var raw []rawBan
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	bans := make([]Ban, 0, len(raw))
+	for _, entry := range raw {
+		ban := Ban{
+			Name:   entry.Name,
+			UUID:   entry.UUID,
+			IP:     entry.IP,
+			Reason: entry.Reason,
+			Source: entry.Source,
+		}
+		if created, err := time.Parse(banTimeLayout, entry.Created); err == nil {
+			ban.Created = created
+		}
+		expires := strings.TrimSpace(entry.Expires)
+		if expires == "" || strings.EqualFold(expires, "forever") {
+			ban.Permanent = true
+		} else if parsed, err := time.Parse(banTimeLayout, expires); err == nil {
+			if parsed.Before(now) {
+				continue
+			}
+			ban.Expires = parsed
+		} else {
+			ban.Permanent = true
+		}
+		bans = append(bans, ban)
+	}
+	return bans, nil
+}
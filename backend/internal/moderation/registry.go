@@ -0,0 +1,297 @@
+package moderation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/datafile"
+)
+
+// Actions lifted automatically when an Expiry falls due.
+const (
+	LiftBan   = "unban"
+	LiftIPBan = "unban_ip"
+	LiftMute  = "unmute"
+)
+
+// Expiry is a scheduled unban or unmute. Player or IP names the target.
+type Expiry struct {
+	ID     string    `json:"id"`
+	Action string    `json:"action"`
+	Player string    `json:"player,omitempty"`
+	UUID   string    `json:"uuid,omitempty"`
+	IP     string    `json:"ip,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+func (e Expiry) matches(action, player, ip string) bool {
+	if e.Action != action {
+		return false
+	}
+	if ip != "" {
+		return e.IP == ip
+	}
+	return player != "" && strings.EqualFold(e.Player, player)
+}
+
+// Mute is a player muted from the panel. Until is zero for permanent mutes.
+type Mute struct {
+	Player string    `json:"player"`
+	UUID   string    `json:"uuid,omitempty"`
+	Reason string    `json:"reason"`
+	Issuer string    `json:"issuer"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until,omitzero"`
+}
+
+// MaxAppealLength is the longest appeal note accepted, in bytes.
+const MaxAppealLength = 2000
+
+// maxAppeals bounds the notes kept on one ban; the oldest are dropped first.
+const maxAppeals = 50
+
+var (
+	ErrEmptyAppeal   = errors.New("appeal note is empty")
+	ErrAppealTooLong = errors.New("appeal note is too long")
+)
+
+// Appeal is a note on a ban's appeal, e.g. what the player asked and what
+// staff decided. The server's ban files have no room for it, so it is kept
+// here until the ban is lifted. IP names the ban for IP bans, Player and
+// UUID otherwise.
+type Appeal struct {
+	ID     string    `json:"id"`
+	Player string    `json:"player,omitempty"`
+	UUID   string    `json:"uuid,omitempty"`
+	IP     string    `json:"ip,omitempty"`
+	Text   string    `json:"text"`
+	Author string    `json:"author"`
+	At     time.Time `json:"at"`
+}
+
+// of reports whether the appeal belongs to ban.
+func (a Appeal) of(ban Ban) bool {
+	if ban.IP != "" || a.IP != "" {
+		return a.IP == ban.IP
+	}
+	if a.UUID != "" && ban.UUID != "" {
+		return strings.EqualFold(a.UUID, ban.UUID)
+	}
+	return a.Player != "" && strings.EqualFold(a.Player, ban.Name)
+}
+
+type persistedRegistry struct {
+	Mutes    []Mute   `json:"mutes"`
+	Expiries []Expiry `json:"expiries"`
+	Appeals  []Appeal `json:"appeals,omitempty"`
+}
+
+// Registry holds mutes, scheduled expiries and appeal notes, saving on every
+// change. It is safe for concurrent use.
+type Registry struct {
+	path string
+
+	mu       sync.Mutex
+	mutes    []Mute
+	expiries []Expiry
+	appeals  []Appeal
+
+	persistMu sync.Mutex
+}
+
+// NewRegistry keeps its state in moderation.json inside dataDir.
+func NewRegistry(dataDir string) *Registry {
+	return &Registry{path: filepath.Join(dataDir, "moderation.json")}
+}
+
+// Load reads the previously saved state.
+func (r *Registry) Load() {
+	var state persistedRegistry
+	found, err := datafile.Read(r.path, &state)
+	if err != nil {
+		log.Printf("beacon moderation: failed loading %s: %v", r.path, err)
+		return
+	}
+	if !found {
+		return
+	}
+	r.mu.Lock()
+	r.mutes = state.Mutes
+	r.expiries = state.Expiries
+	r.appeals = state.Appeals
+	r.mu.Unlock()
+}
+
+// Schedule adds an expiry, replacing any pending one of the same kind for the
+// same target.
+func (r *Registry) Schedule(expiry Expiry) Expiry {
+	r.mu.Lock()
+	expiry.ID = newID()
+	r.expiries = slices.DeleteFunc(r.expiries, func(e Expiry) bool {
+		return e.matches(expiry.Action, expiry.Player, expiry.IP)
+	})
+	r.expiries = append(r.expiries, expiry)
+	r.mu.Unlock()
+	r.save()
+	return expiry
+}
+
+// Cancel drops pending expiries of action for a player or IP, e.g. after a
+// manual unban.
+func (r *Registry) Cancel(action, player, ip string) {
+	r.mu.Lock()
+	before := len(r.expiries)
+	r.expiries = slices.DeleteFunc(r.expiries, func(e Expiry) bool {
+		return e.matches(action, player, ip)
+	})
+	changed := len(r.expiries) != before
+	r.mu.Unlock()
+	if changed {
+		r.save()
+	}
+}
+
+// Due lists expiries whose time has come, oldest first.
+func (r *Registry) Due(now time.Time) []Expiry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	due := make([]Expiry, 0)
+	for _, expiry := range r.expiries {
+		if !expiry.At.After(now) {
+			due = append(due, expiry)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].At.Before(due[j].At) })
+	return due
+}
+
+// Remove drops one expiry by ID.
+func (r *Registry) Remove(id string) {
+	r.mu.Lock()
+	r.expiries = slices.DeleteFunc(r.expiries, func(e Expiry) bool { return e.ID == id })
+	r.mu.Unlock()
+	r.save()
+}
+
+// Pending lists every scheduled expiry, soonest first.
+func (r *Registry) Pending() []Expiry {
+	r.mu.Lock()
+	pending := slices.Clone(r.expiries)
+	r.mu.Unlock()
+	sort.Slice(pending, func(i, j int) bool { return pending[i].At.Before(pending[j].At) })
+	return pending
+}
+
+// SetMute records a mute, replacing any earlier one for the player.
+func (r *Registry) SetMute(mute Mute) {
+	r.mu.Lock()
+	r.mutes = slices.DeleteFunc(r.mutes, func(m Mute) bool { return strings.EqualFold(m.Player, mute.Player) })
+	r.mutes = append(r.mutes, mute)
+	r.mu.Unlock()
+	r.save()
+}
+
+// ClearMute removes a player's mute.
+func (r *Registry) ClearMute(player string) {
+	r.mu.Lock()
+	r.mutes = slices.DeleteFunc(r.mutes, func(m Mute) bool { return strings.EqualFold(m.Player, player) })
+	r.mu.Unlock()
+	r.save()
+}
+
+// Mutes lists current mutes, dropping any that have run out.
+func (r *Registry) Mutes(now time.Time) []Mute {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	active := make([]Mute, 0, len(r.mutes))
+	for _, mute := range r.mutes {
+		if mute.Until.IsZero() || mute.Until.After(now) {
+			active = append(active, mute)
+		}
+	}
+	return active
+}
+
+// AddAppeal keeps a note on ban's appeal.
+func (r *Registry) AddAppeal(ban Ban, author, text string, at time.Time) (Appeal, error) {
+	text = strings.TrimSpace(text)
+	switch {
+	case text == "":
+		return Appeal{}, ErrEmptyAppeal
+	case len(text) > MaxAppealLength:
+		return Appeal{}, ErrAppealTooLong
+	}
+	appeal := Appeal{ID: newID(), Player: ban.Name, UUID: ban.UUID, IP: ban.IP, Text: text, Author: author, At: at}
+
+	r.mu.Lock()
+	r.appeals = append(r.appeals, appeal)
+	kept := 0
+	for i := len(r.appeals) - 1; i >= 0; i-- {
+		if !r.appeals[i].of(ban) {
+			continue
+		}
+		if kept++; kept > maxAppeals {
+			r.appeals = slices.Delete(r.appeals, i, i+1)
+		}
+	}
+	r.mu.Unlock()
+	r.save()
+	return appeal, nil
+}
+
+// ClearAppeals drops the notes on a lifted ban of a player or IP.
+func (r *Registry) ClearAppeals(player, ip string) {
+	ban := Ban{Name: player, IP: ip}
+	r.mu.Lock()
+	before := len(r.appeals)
+	r.appeals = slices.DeleteFunc(r.appeals, func(a Appeal) bool { return a.of(ban) })
+	changed := len(r.appeals) != before
+	r.mu.Unlock()
+	if changed {
+		r.save()
+	}
+}
+
+// AttachAppeals fills in each ban's appeal notes, oldest first.
+func (r *Registry) AttachAppeals(bans []Ban) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range bans {
+		for _, appeal := range r.appeals {
+			if appeal.of(bans[i]) {
+				bans[i].Appeals = append(bans[i].Appeals, appeal)
+			}
+		}
+	}
+}
+
+func (r *Registry) save() {
+	r.persistMu.Lock()
+	defer r.persistMu.Unlock()
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(persistedRegistry{Mutes: r.mutes, Expiries: r.expiries, Appeals: r.appeals}, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		log.Printf("beacon moderation: failed encoding state: %v", err)
+		return
+	}
+	if err := datafile.Write(r.path, data); err != nil {
+		log.Printf("beacon moderation: failed writing %s: %v", r.path, err)
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
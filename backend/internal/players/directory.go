@@ -0,0 +1,445 @@
+// Package players keeps a directory of every player the server has seen,
+// keyed by UUID, so staff can look up someone who is offline: the names they
+// have used, the addresses they joined from, moderation actions taken from the
+// panel and notes left by staff.
+package players
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adammcgrogan/beacon/internal/datafile"
+)
+
+const (
+	maxNames     = 50
+	maxAddresses = 50
+	maxActions   = 500
+	maxNotes     = 200
+	// MaxNoteLength is the longest staff note accepted, in bytes.
+	MaxNoteLength = 2000
+
+	flushInterval = 1 * time.Minute
+)
+
+// Moderation action types recorded from the panel.
+const (
+	ActionKick    = "kick"
+	ActionBan     = "ban"
+	ActionTempBan = "tempban"
+	ActionIPBan   = "ipban"
+	ActionUnban   = "unban"
+	ActionMute    = "mute"
+	ActionUnmute  = "unmute"
+	ActionWarn    = "warn"
+)
+
+var (
+	ErrUnknownPlayer = errors.New("unknown player")
+	ErrEmptyNote     = errors.New("note is empty")
+	ErrNoteTooLong   = errors.New("note is too long")
+)
+
+// Actor is the panel user behind an action or note.
+type Actor struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+// NameChange is a name the player has used and when it was first seen.
+type NameChange struct {
+	Name      string    `json:"name"`
+	FirstSeen time.Time `json:"first_seen,omitzero"`
+}
+
+// Address is an IP the player has joined from.
+type Address struct {
+	IP        string    `json:"ip"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Joins     int       `json:"joins"`
+}
+
+// Action is a moderation action issued through the panel.
+type Action struct {
+	ID     string    `json:"id"`
+	Type   string    `json:"type"`
+	Reason string    `json:"reason"`
+	Issuer Actor     `json:"issuer"`
+	At     time.Time `json:"at"`
+	// Expires is when a temporary ban or mute ends; zero means permanent.
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+// Note is a free-text staff note about a player.
+type Note struct {
+	ID     string    `json:"id"`
+	Text   string    `json:"text"`
+	Author Actor     `json:"author"`
+	At     time.Time `json:"at"`
+}
+
+// Profile is everything known about one player.
+type Profile struct {
+	UUID      string       `json:"uuid"`
+	Name      string       `json:"name"`
+	FirstSeen time.Time    `json:"first_seen,omitzero"`
+	LastSeen  time.Time    `json:"last_seen,omitzero"`
+	Names     []NameChange `json:"names"`
+	Addresses []Address    `json:"addresses,omitempty"`
+	Actions   []Action     `json:"actions"`
+	Notes     []Note       `json:"notes"`
+}
+
+// Entry is a directory listing row.
+type Entry struct {
+	UUID     string    `json:"uuid"`
+	Name     string    `json:"name"`
+	LastSeen time.Time `json:"last_seen,omitzero"`
+	Actions  int       `json:"actions"`
+	Notes    int       `json:"notes"`
+}
+
+type persistedDirectory struct {
+	Players []*Profile `json:"players"`
+}
+
+// Directory is the offline player index. It is safe for concurrent use.
+type Directory struct {
+	path string
+
+	mu       sync.Mutex
+	profiles map[string]*Profile
+	dirty    bool
+
+	persistMu sync.Mutex
+}
+
+// NewDirectory keeps its index in players.json inside dataDir.
+func NewDirectory(dataDir string) *Directory {
+	return &Directory{
+		path:     filepath.Join(dataDir, "players.json"),
+		profiles: make(map[string]*Profile),
+	}
+}
+
+// Load reads the previously saved index.
+func (d *Directory) Load() {
+	var state persistedDirectory
+	found, err := datafile.Read(d.path, &state)
+	if err != nil {
+		log.Printf("beacon players: failed loading %s: %v", d.path, err)
+		return
+	}
+	if !found {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, profile := range state.Players {
+		if profile != nil && profile.UUID != "" {
+			d.profiles[profile.UUID] = profile
+		}
+	}
+}
+
+// Seen records a player online at the given time under their current name.
+func (d *Directory) Seen(uuid, name string, at time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.seen(uuid, name, at)
+}
+
+// Joined records a join, including the address it came from when known.
+func (d *Directory) Joined(uuid, name, ip string, at time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	profile := d.seen(uuid, name, at)
+	if ip == "" {
+		return
+	}
+	for i := range profile.Addresses {
+		if profile.Addresses[i].IP == ip {
+			profile.Addresses[i].LastSeen = at
+			profile.Addresses[i].Joins++
+			return
+		}
+	}
+	profile.Addresses = append(profile.Addresses, Address{IP: ip, FirstSeen: at, LastSeen: at, Joins: 1})
+	if len(profile.Addresses) > maxAddresses {
+		profile.Addresses = profile.Addresses[len(profile.Addresses)-maxAddresses:]
+	}
+}
+
+// ImportUserCache adds players from the server's usercache.json, which lists
+// everyone who joined recently enough, and returns how many were new.
+func (d *Directory) ImportUserCache(data []byte) (int, error) {
+	var entries []struct {
+		Name string `json:"name"`
+		UUID string `json:"uuid"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	added := 0
+	for _, entry := range entries {
+		if entry.UUID == "" || entry.Name == "" {
+			continue
+		}
+		profile, ok := d.profiles[entry.UUID]
+		if !ok {
+			d.profiles[entry.UUID] = &Profile{
+				UUID:  entry.UUID,
+				Name:  entry.Name,
+				Names: []NameChange{{Name: entry.Name}},
+			}
+			added++
+			d.dirty = true
+			continue
+		}
+		if profile.Name == "" {
+			d.rename(profile, entry.Name, time.Time{})
+		}
+	}
+	return added, nil
+}
+
+// Resolve finds the UUID of the player most recently seen with name.
+func (d *Directory) Resolve(name string) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var found *Profile
+	for _, profile := range d.profiles {
+		if strings.EqualFold(profile.Name, name) && (found == nil || profile.LastSeen.After(found.LastSeen)) {
+			found = profile
+		}
+	}
+	if found == nil {
+		return "", false
+	}
+	return found.UUID, true
+}
+
+// RecordAction stores a moderation action against a known player.
+func (d *Directory) RecordAction(uuid string, action Action) (Action, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	profile, ok := d.profiles[uuid]
+	if !ok {
+		return Action{}, ErrUnknownPlayer
+	}
+	action.ID = newID()
+	profile.Actions = append(profile.Actions, action)
+	if len(profile.Actions) > maxActions {
+		profile.Actions = profile.Actions[len(profile.Actions)-maxActions:]
+	}
+	d.dirty = true
+	return action, nil
+}
+
+// AddNote attaches a staff note to a known player.
+func (d *Directory) AddNote(uuid string, author Actor, text string, at time.Time) (Note, error) {
+	text = strings.TrimSpace(text)
+	switch {
+	case text == "":
+		return Note{}, ErrEmptyNote
+	case len(text) > MaxNoteLength:
+		return Note{}, ErrNoteTooLong
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	profile, ok := d.profiles[uuid]
+	if !ok {
+		return Note{}, ErrUnknownPlayer
+	}
+	note := Note{ID: newID(), Text: text, Author: author, At: at}
+	profile.Notes = append(profile.Notes, note)
+	if len(profile.Notes) > maxNotes {
+		profile.Notes = profile.Notes[len(profile.Notes)-maxNotes:]
+	}
+	d.dirty = true
+	return note, nil
+}
+
+// DeleteNote removes a note and reports whether it existed.
+func (d *Directory) DeleteNote(uuid, id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	profile, ok := d.profiles[uuid]
+	if !ok {
+		return false
+	}
+	for i, note := range profile.Notes {
+		if note.ID == id {
+			profile.Notes = append(profile.Notes[:i], profile.Notes[i+1:]...)
+			d.dirty = true
+			return true
+		}
+	}
+	return false
+}
+
+// Profile returns a copy of one player's record.
+func (d *Directory) Profile(uuid string) (Profile, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	profile, ok := d.profiles[uuid]
+	if !ok {
+		return Profile{}, false
+	}
+	out := *profile
+	out.Names = append([]NameChange{}, profile.Names...)
+	out.Addresses = append([]Address{}, profile.Addresses...)
+	out.Actions = append([]Action{}, profile.Actions...)
+	out.Notes = append([]Note{}, profile.Notes...)
+	return out, true
+}
+
+// Search lists players whose current or past name, or UUID, contains query,
+// most recently seen first. An empty query lists everyone.
+func (d *Directory) Search(query string, limit int) []Entry {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	d.mu.Lock()
+	entries := make([]Entry, 0)
+	for _, profile := range d.profiles {
+		if query != "" && !profile.matches(query) {
+			continue
+		}
+		entries = append(entries, Entry{
+			UUID:     profile.UUID,
+			Name:     profile.Name,
+			LastSeen: profile.LastSeen,
+			Actions:  len(profile.Actions),
+			Notes:    len(profile.Notes),
+		})
+	}
+	d.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].LastSeen.Equal(entries[j].LastSeen) {
+			return entries[i].LastSeen.After(entries[j].LastSeen)
+		}
+		return strings.ToLower(entries[i].Name) < strings.ToLower(entries[j].Name)
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+func (p *Profile) matches(query string) bool {
+	if strings.Contains(strings.ToLower(p.UUID), query) {
+		return true
+	}
+	for _, name := range p.Names {
+		if strings.Contains(strings.ToLower(name.Name), query) {
+			return true
+		}
+	}
+	return strings.Contains(strings.ToLower(p.Name), query)
+}
+
+func (d *Directory) seen(uuid, name string, at time.Time) *Profile {
+	profile, ok := d.profiles[uuid]
+	if !ok {
+		profile = &Profile{UUID: uuid}
+		d.profiles[uuid] = profile
+	}
+	if profile.FirstSeen.IsZero() || at.Before(profile.FirstSeen) {
+		profile.FirstSeen = at
+	}
+	if at.After(profile.LastSeen) {
+		profile.LastSeen = at
+	}
+	if name != "" && name != profile.Name {
+		d.rename(profile, name, at)
+	}
+	d.dirty = true
+	return profile
+}
+
+func (d *Directory) rename(profile *Profile, name string, at time.Time) {
+	profile.Name = name
+	for _, known := range profile.Names {
+		if known.Name == name {
+			d.dirty = true
+			return
+		}
+	}
+	profile.Names = append(profile.Names, NameChange{Name: name, FirstSeen: at})
+	if len(profile.Names) > maxNames {
+		profile.Names = profile.Names[len(profile.Names)-maxNames:]
+	}
+	d.dirty = true
+}
+
+// Start saves changes every minute until ctx is cancelled. The returned
+// channel is closed once the loop has stopped.
+func (d *Directory) Start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				d.save(false)
+			}
+		}
+	}()
+	return done
+}
+
+// Flush saves synchronously. Call it during shutdown.
+func (d *Directory) Flush() {
+	d.save(true)
+}
+
+func (d *Directory) save(force bool) {
+	d.persistMu.Lock()
+	defer d.persistMu.Unlock()
+
+	d.mu.Lock()
+	if !d.dirty && !force {
+		d.mu.Unlock()
+		return
+	}
+	state := persistedDirectory{Players: make([]*Profile, 0, len(d.profiles))}
+	for _, profile := range d.profiles {
+		state.Players = append(state.Players, profile)
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	d.dirty = false
+	d.mu.Unlock()
+	if err != nil {
+		log.Printf("beacon players: failed encoding directory: %v", err)
+		return
+	}
+	if err := datafile.Write(d.path, data); err != nil {
+		log.Printf("beacon players: failed writing %s: %v", d.path, err)
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
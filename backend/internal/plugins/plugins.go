@@ -0,0 +1,307 @@
+// Package plugins describes the plugins installed on the server: what the
+// plugin reports about loaded plugins and the jars in plugins/, the checks
+// an uploaded jar has to pass, and the duplicate or conflicting jars worth
+// warning about.
+package plugins
+
+import (
+	"archive/zip"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrNotPluginJar     = errors.New("not a plugin jar: no plugin.yml or paper-plugin.yml with a name")
+	ErrAlreadyInstalled = errors.New("this jar is already installed")
+	ErrFileTaken        = errors.New("a jar with this file name belongs to another plugin")
+)
+
+// maxDescriptor bounds how much of a plugin.yml is read.
+const maxDescriptor = 1 << 20
+
+// Plugin is a plugin loaded by the server. File is the jar it was loaded
+// from, relative to the server root, and Update the jar staged to replace
+// it on the next restart, if any.
+type Plugin struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Authors     []string `json:"authors"`
+	Description string   `json:"description"`
+	Enabled     bool     `json:"enabled"`
+	Depend      []string `json:"depend"`
+	SoftDepend  []string `json:"softdepend"`
+	Main        string   `json:"main"`
+	File        string   `json:"file"`
+	SHA256      string   `json:"sha256"`
+	Update      string   `json:"update,omitempty"`
+}
+
+// Jar is a jar file in plugins/ or, when Staged, in the update folder. Name
+// and Version come from its descriptor and are empty for jars without one.
+type Jar struct {
+	File     string    `json:"file"`
+	Size     int64     `json:"size"`
+	SHA256   string    `json:"sha256"`
+	Modified time.Time `json:"mod_time"`
+	Staged   bool      `json:"staged"`
+	Name     string    `json:"name"`
+	Version  string    `json:"version"`
+}
+
+// Inventory is everything the plugin reports about installed plugins. The
+// folders are relative to the server root; UpdateFolder is where jars are
+// staged.
+type Inventory struct {
+	Plugins       []Plugin `json:"plugins"`
+	Jars          []Jar    `json:"jars"`
+	PluginsFolder string   `json:"plugins_folder"`
+	UpdateFolder  string   `json:"update_folder"`
+}
+
+// Kinds of conflict.
+const (
+	// KindDuplicate is two or more jars declaring the same plugin; the
+	// server loads only one of them.
+	KindDuplicate = "duplicate"
+	// KindIdentical is the same jar installed under two file names.
+	KindIdentical = "identical"
+	// KindMissingDependency is a loaded plugin whose hard dependency is
+	// not loaded.
+	KindMissingDependency = "missing_dependency"
+	// KindNotLoaded is a plugin jar the server has not loaded: it failed
+	// to load or was added since the last restart.
+	KindNotLoaded = "not_loaded"
+	// KindOrphanUpdate is a staged jar with no installed jar of the same
+	// file name, which the server ignores.
+	KindOrphanUpdate = "orphan_update"
+)
+
+// Conflict is one problem with the installed jars.
+type Conflict struct {
+	Kind    string   `json:"kind"`
+	Plugin  string   `json:"plugin,omitempty"`
+	Files   []string `json:"files"`
+	Message string   `json:"message"`
+}
+
+// Prepare fills in each plugin's staged update and sorts plugins and jars
+// by name.
+func (inv *Inventory) Prepare() {
+	if inv.Plugins == nil {
+		inv.Plugins = []Plugin{}
+	}
+	if inv.Jars == nil {
+		inv.Jars = []Jar{}
+	}
+	staged := make(map[string]string)
+	for _, jar := range inv.Jars {
+		if jar.Staged {
+			staged[path.Base(jar.File)] = jar.File
+		}
+	}
+	for i, plugin := range inv.Plugins {
+		if plugin.File != "" {
+			inv.Plugins[i].Update = staged[path.Base(plugin.File)]
+		}
+	}
+	slices.SortFunc(inv.Plugins, func(a, b Plugin) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	slices.SortFunc(inv.Jars, func(a, b Jar) int {
+		return cmp.Or(compareBool(a.Staged, b.Staged), strings.Compare(strings.ToLower(a.File), strings.ToLower(b.File)))
+	})
+}
+
+// Plugin returns the loaded plugin with a name, ignoring case.
+func (inv Inventory) Plugin(name string) (Plugin, bool) {
+	i := slices.IndexFunc(inv.Plugins, func(p Plugin) bool { return strings.EqualFold(p.Name, name) })
+	if i < 0 {
+		return Plugin{}, false
+	}
+	return inv.Plugins[i], true
+}
+
+// Conflicts finds duplicate and conflicting jars, missing dependencies and
+// jars the server has not loaded.
+func Conflicts(inv Inventory) []Conflict {
+	conflicts := []Conflict{}
+	byName := make(map[string][]Jar)
+	byHash := make(map[string][]Jar)
+	installed := make(map[string]bool)
+	for _, jar := range inv.Jars {
+		if jar.Staged {
+			continue
+		}
+		installed[path.Base(jar.File)] = true
+		if jar.Name != "" {
+			byName[strings.ToLower(jar.Name)] = append(byName[strings.ToLower(jar.Name)], jar)
+		}
+		if jar.SHA256 != "" {
+			byHash[jar.SHA256] = append(byHash[jar.SHA256], jar)
+		}
+	}
+	loaded := make(map[string]bool, len(inv.Plugins))
+	for _, plugin := range inv.Plugins {
+		loaded[strings.ToLower(plugin.Name)] = true
+	}
+
+	for _, jars := range byName {
+		if len(jars) < 2 {
+			continue
+		}
+		conflicts = append(conflicts, Conflict{
+			Kind:    KindDuplicate,
+			Plugin:  jars[0].Name,
+			Files:   jarFiles(jars),
+			Message: fmt.Sprintf("%d jars declare %s; the server loads only one of them", len(jars), jars[0].Name),
+		})
+	}
+	for _, jars := range byHash {
+		if len(jars) < 2 {
+			continue
+		}
+		conflicts = append(conflicts, Conflict{
+			Kind:    KindIdentical,
+			Plugin:  jars[0].Name,
+			Files:   jarFiles(jars),
+			Message: "the same jar is installed under more than one file name",
+		})
+	}
+	for _, plugin := range inv.Plugins {
+		for _, dependency := range plugin.Depend {
+			if loaded[strings.ToLower(dependency)] {
+				continue
+			}
+			conflicts = append(conflicts, Conflict{
+				Kind:    KindMissingDependency,
+				Plugin:  plugin.Name,
+				Files:   nonEmpty(plugin.File),
+				Message: fmt.Sprintf("%s depends on %s, which is not loaded", plugin.Name, dependency),
+			})
+		}
+	}
+	for name, jars := range byName {
+		if loaded[name] {
+			continue
+		}
+		conflicts = append(conflicts, Conflict{
+			Kind:    KindNotLoaded,
+			Plugin:  jars[0].Name,
+			Files:   jarFiles(jars),
+			Message: fmt.Sprintf("%s is not loaded; it failed to load or was added since the last restart", jars[0].Name),
+		})
+	}
+	for _, jar := range inv.Jars {
+		if !jar.Staged || installed[path.Base(jar.File)] {
+			continue
+		}
+		conflicts = append(conflicts, Conflict{
+			Kind:    KindOrphanUpdate,
+			Plugin:  jar.Name,
+			Files:   []string{jar.File},
+			Message: "no installed jar has this file name, so the server will not apply this update",
+		})
+	}
+
+	slices.SortFunc(conflicts, func(a, b Conflict) int {
+		return cmp.Or(strings.Compare(a.Kind, b.Kind), strings.Compare(a.Plugin, b.Plugin), slices.Compare(a.Files, b.Files))
+	})
+	return conflicts
+}
+
+// Descriptor is what an uploaded jar says about itself.
+type Descriptor struct {
+	Name    string `json:"name" yaml:"name"`
+	Version string `json:"version" yaml:"version"`
+	Main    string `json:"main" yaml:"main"`
+}
+
+// ReadDescriptor reads the plugin.yml, or a Paper plugin's
+// paper-plugin.yml, of a jar.
+func ReadDescriptor(jar io.ReaderAt, size int64) (Descriptor, error) {
+	reader, err := zip.NewReader(jar, size)
+	if err != nil {
+		return Descriptor{}, ErrNotPluginJar
+	}
+	for _, name := range []string{"paper-plugin.yml", "plugin.yml"} {
+		file, err := reader.Open(name)
+		if err != nil {
+			continue
+		}
+		content, err := io.ReadAll(io.LimitReader(file, maxDescriptor))
+		file.Close()
+		if err != nil {
+			return Descriptor{}, fmt.Errorf("reading %s: %w", name, err)
+		}
+		var descriptor Descriptor
+		if err := yaml.Unmarshal(content, &descriptor); err != nil {
+			return Descriptor{}, fmt.Errorf("%w: %s is not valid YAML", ErrNotPluginJar, name)
+		}
+		descriptor.Name = strings.TrimSpace(descriptor.Name)
+		if descriptor.Name == "" {
+			continue
+		}
+		return descriptor, nil
+	}
+	return Descriptor{}, ErrNotPluginJar
+}
+
+// UploadTarget decides where an uploaded jar goes. A newer jar of an
+// installed plugin is staged in the update folder under the installed jar's
+// file name, which the server swaps in on its next restart; any other
+// plugin goes straight into plugins/ as fileName and loads on the next
+// restart. A staged target replaces any update staged before it.
+func UploadTarget(inv Inventory, descriptor Descriptor, fileName, sha256 string) (target string, staged bool, err error) {
+	for _, jar := range inv.Jars {
+		if jar.SHA256 == sha256 {
+			return "", false, fmt.Errorf("%w as %s", ErrAlreadyInstalled, jar.File)
+		}
+	}
+	for _, jar := range inv.Jars {
+		if !jar.Staged && strings.EqualFold(jar.Name, descriptor.Name) {
+			return path.Join(inv.UpdateFolder, path.Base(jar.File)), true, nil
+		}
+	}
+	target = path.Join(cmp.Or(inv.PluginsFolder, "plugins"), fileName)
+	for _, jar := range inv.Jars {
+		if !jar.Staged && strings.EqualFold(jar.File, target) {
+			return "", false, fmt.Errorf("%w (%s)", ErrFileTaken, cmp.Or(jar.Name, "not a plugin"))
+		}
+	}
+	return target, false, nil
+}
+
+func jarFiles(jars []Jar) []string {
+	files := make([]string, len(jars))
+	for i, jar := range jars {
+		files[i] = jar.File
+	}
+	slices.Sort(files)
+	return files
+}
+
+func nonEmpty(file string) []string {
+	if file == "" {
+		return []string{}
+	}
+	return []string{file}
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
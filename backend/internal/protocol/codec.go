@@ -0,0 +1,29 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Frame encodings a plugin can offer in its hello. JSON text frames are always
+// understood; MessagePack is only used for binary frames once agreed.
+const (
+	EncodingJSON    = "json"
+	EncodingMsgpack = "msgpack"
+)
+
+// BinaryToJSON converts a MessagePack-encoded message into the JSON the rest of
+// the backend expects, so handlers never see the wire encoding.
+func BinaryToJSON(data []byte) ([]byte, error) {
+	var value any
+	if err := msgpack.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("%w: msgpack: %v", ErrMalformed, err)
+	}
+	out, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: msgpack: %v", ErrMalformed, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,286 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/adammcgrogan/beacon/internal/models"
+)
+
+// Events sent by the plugin.
+const (
+	EventHello                     = "hello"
+	EventServerStats               = "server_stats"
+	EventWorldStats                = "world_stats"
+	EventServerEnv                 = "server_env"
+	EventConsoleLog                = "console_log"
+	EventPluginPaths               = "plugin_paths"
+	EventAuthTokenIssued           = "auth_token_issued"
+	EventTabCompleteResult         = "console_tab_complete_result"
+	EventFileManagerResponse       = "file_manager_response"
+	EventPlayerPermissionsResponse = "player_permissions_response"
+	EventPermissionAdminResponse   = "permission_admin_response"
+	EventPlayerJoin                = "player_join"
+	EventModerationResponse        = "moderation_response"
+	EventRosterResponse            = "roster_response"
+	EventWorldManageResponse       = "world_manage_response"
+	EventWorldProgress             = "world_progress"
+	EventGamerulesResponse         = "gamerules_response"
+	EventHotspotsResponse          = "hotspots_response"
+	EventDiagnosticsResponse       = "diagnostics_response"
+	EventPluginsResponse           = "plugins_response"
+	EventProfilerResponse          = "profiler_response"
+)
+
+// Events sent by the backend.
+const (
+	EventHelloAck  = "hello_ack"
+	EventRPCCancel = "rpc_cancel"
+)
+
+var (
+	ErrUnknownEvent = errors.New("unknown event")
+	ErrMalformed    = errors.New("malformed message")
+)
+
+// Message is a decoded plugin event. Payload holds a pointer to the event's
+// typed payload, e.g. *models.ServerStats for server_stats.
+type Message struct {
+	Event      string
+	Payload    any
+	RawPayload json.RawMessage
+	// Broadcast is true for events browsers render directly.
+	Broadcast bool
+}
+
+type eventSpec struct {
+	broadcast bool
+	decode    func(json.RawMessage) (any, error)
+}
+
+// validator is implemented by payloads with required fields.
+type validator interface {
+	Validate() error
+}
+
+func event[T any](broadcast bool) eventSpec {
+	return eventSpec{
+		broadcast: broadcast,
+		decode: func(raw json.RawMessage) (any, error) {
+			payload := new(T)
+			if err := json.Unmarshal(raw, payload); err != nil {
+				return nil, err
+			}
+			if v, ok := any(payload).(validator); ok {
+				if err := v.Validate(); err != nil {
+					return nil, err
+				}
+			}
+			return payload, nil
+		},
+	}
+}
+
+var pluginEvents = map[string]eventSpec{
+	EventHello:                     event[Hello](false),
+	EventServerStats:               event[ServerStats](true),
+	EventWorldStats:                event[WorldStats](true),
+	EventServerEnv:                 event[models.ServerEnv](true),
+	EventConsoleLog:                event[ConsoleLog](true),
+	EventPluginPaths:               event[PluginPaths](false),
+	EventAuthTokenIssued:           event[AuthTokenIssued](false),
+	EventTabCompleteResult:         event[TabCompleteResult](true),
+	EventFileManagerResponse:       event[RPCResponse](false),
+	EventPlayerPermissionsResponse: event[RPCResponse](false),
+	EventPermissionAdminResponse:   event[RPCResponse](false),
+	EventPlayerJoin:                event[PlayerJoin](false), // never broadcast: carries the player's IP
+	EventModerationResponse:        event[RPCResponse](false),
+	EventRosterResponse:            event[RPCResponse](false),
+	EventWorldManageResponse:       event[RPCResponse](false),
+	EventWorldProgress:             event[WorldProgress](false), // rebroadcast by the backend with who started it
+	EventGamerulesResponse:         event[RPCResponse](false),
+	EventHotspotsResponse:          event[RPCResponse](false),
+	EventDiagnosticsResponse:       event[RPCResponse](false),
+	EventPluginsResponse:           event[RPCResponse](false),
+	EventProfilerResponse:          event[RPCResponse](false),
+}
+
+// DecodePluginMessage parses and validates one message from the plugin. It
+// returns ErrUnknownEvent for events this backend does not know and
+// ErrMalformed when the envelope or payload does not match its schema.
+func DecodePluginMessage(raw []byte) (Message, error) {
+	var envelope struct {
+		Event   string          `json:"event"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return Message{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if envelope.Event == "" {
+		return Message{}, fmt.Errorf("%w: missing event", ErrMalformed)
+	}
+
+	spec, ok := pluginEvents[envelope.Event]
+	if !ok {
+		return Message{Event: envelope.Event}, ErrUnknownEvent
+	}
+	if len(envelope.Payload) == 0 || bytes.Equal(envelope.Payload, []byte("null")) {
+		return Message{Event: envelope.Event}, fmt.Errorf("%w: %s: missing payload", ErrMalformed, envelope.Event)
+	}
+
+	payload, err := spec.decode(envelope.Payload)
+	if err != nil {
+		return Message{Event: envelope.Event}, fmt.Errorf("%w: %s: %v", ErrMalformed, envelope.Event, err)
+	}
+	return Message{
+		Event:      envelope.Event,
+		Payload:    payload,
+		RawPayload: envelope.Payload,
+		Broadcast:  spec.broadcast,
+	}, nil
+}
+
+// Encode builds the event envelope for a message to the plugin.
+func Encode(event string, payload any) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"event":   event,
+		"payload": payload,
+	})
+}
+
+type ServerStats struct {
+	models.ServerStats
+}
+
+func (s *ServerStats) Validate() error {
+	if s.Players < 0 || s.MaxPlayers < 0 {
+		return fieldError("players", "must not be negative")
+	}
+	if s.Threads != nil && *s.Threads < 0 {
+		return fieldError("threads", "must not be negative")
+	}
+	for i, player := range s.PlayerList {
+		if player.UUID == "" {
+			return fieldError(fmt.Sprintf("player_list[%d].uuid", i), "is required")
+		}
+	}
+	return nil
+}
+
+type WorldStats []models.WorldInfo
+
+func (w *WorldStats) Validate() error {
+	for i, world := range *w {
+		if world.Name == "" {
+			return fieldError(fmt.Sprintf("[%d].name", i), "is required")
+		}
+	}
+	return nil
+}
+
+type ConsoleLog struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+func (c *ConsoleLog) Validate() error {
+	if c.Level == "" {
+		return fieldError("level", "is required")
+	}
+	return nil
+}
+
+type PluginPaths struct {
+	PluginDataDir string `json:"plugin_data_dir"`
+}
+
+func (p *PluginPaths) Validate() error {
+	if p.PluginDataDir == "" {
+		return fieldError("plugin_data_dir", "is required")
+	}
+	return nil
+}
+
+type AuthTokenIssued struct {
+	Token         string   `json:"token"`
+	PlayerUUID    string   `json:"player_uuid"`
+	PlayerName    string   `json:"player_name"`
+	ExpiresAtUnix int64    `json:"expires_at_unix"`
+	Permissions   []string `json:"permissions"`
+}
+
+func (a *AuthTokenIssued) Validate() error {
+	switch {
+	case a.Token == "":
+		return fieldError("token", "is required")
+	case a.PlayerUUID == "":
+		return fieldError("player_uuid", "is required")
+	case a.ExpiresAtUnix <= 0:
+		return fieldError("expires_at_unix", "must be positive")
+	}
+	return nil
+}
+
+type PlayerJoin struct {
+	UUID    string `json:"uuid"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+func (p *PlayerJoin) Validate() error {
+	switch {
+	case p.UUID == "":
+		return fieldError("uuid", "is required")
+	case p.Name == "":
+		return fieldError("name", "is required")
+	}
+	return nil
+}
+
+// WorldProgress reports how far a world create, import, clone or delete has
+// got. Progress runs from 0 to 1; the last event for an operation has Done set
+// and Error filled in if it failed.
+type WorldProgress struct {
+	OperationID string  `json:"operation_id"`
+	World       string  `json:"world"`
+	Action      string  `json:"action"`
+	Stage       string  `json:"stage"`
+	Progress    float64 `json:"progress"`
+	Done        bool    `json:"done"`
+	Error       string  `json:"error,omitempty"`
+}
+
+func (w *WorldProgress) Validate() error {
+	switch {
+	case w.OperationID == "":
+		return fieldError("operation_id", "is required")
+	case w.Progress < 0 || w.Progress > 1:
+		return fieldError("progress", "must be between 0 and 1")
+	}
+	return nil
+}
+
+type TabCompleteResult struct {
+	RequestID   string   `json:"request_id"`
+	Command     string   `json:"command"`
+	Completions []string `json:"completions"`
+}
+
+// RPCResponse is the part of every plugin response the backend checks before
+// handing the payload to the caller waiting on RequestID.
+type RPCResponse struct {
+	RequestID string `json:"request_id"`
+}
+
+func (r *RPCResponse) Validate() error {
+	if r.RequestID == "" {
+		return fieldError("request_id", "is required")
+	}
+	return nil
+}
+
+func fieldError(field, problem string) error {
+	return fmt.Errorf("%s %s", field, problem)
+}
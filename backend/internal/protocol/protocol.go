@@ -0,0 +1,144 @@
+// Package protocol defines the messages exchanged between the Minecraft plugin
+// and the backend over /ws: the version handshake, the capabilities each side
+// may rely on, and a typed, validated payload for every plugin event.
+package protocol
+
+import (
+	"slices"
+)
+
+// Version is the newest protocol version this backend speaks.
+const Version = 1
+
+// MinVersion is the oldest plugin protocol this backend accepts. Version 0 is
+// a plugin that predates the handshake and never sends hello.
+const MinVersion = 0
+
+// Capabilities a plugin can declare in its hello.
+const (
+	CapFileManager     = "file_manager"
+	CapPermissions     = "permissions"
+	CapPermissionAdmin = "permission_admin"
+	CapConsole         = "console"
+	CapTabComplete     = "tab_complete"
+	CapWorldActions    = "world_actions"
+	CapRPCCancel       = "rpc_cancel"
+	CapModeration      = "moderation"
+	CapRoster          = "roster"
+	CapWorldManagement = "world_management"
+	CapWorldSettings   = "world_settings"
+	CapGamerules       = "gamerules"
+	CapHotspots        = "hotspots"
+	CapDiagnostics     = "diagnostics"
+	CapPlugins         = "plugins"
+	CapProfiler        = "profiler"
+)
+
+// Capabilities lists everything this backend knows how to use.
+var Capabilities = []string{
+	CapFileManager,
+	CapPermissions,
+	CapPermissionAdmin,
+	CapConsole,
+	CapTabComplete,
+	CapWorldActions,
+	CapRPCCancel,
+	CapModeration,
+	CapRoster,
+	CapWorldManagement,
+	CapWorldSettings,
+	CapGamerules,
+	CapHotspots,
+	CapDiagnostics,
+	CapPlugins,
+	CapProfiler,
+}
+
+// legacyCapabilities is what a version 0 plugin supported without saying so.
+var legacyCapabilities = []string{
+	CapFileManager,
+	CapPermissions,
+	CapPermissionAdmin,
+	CapConsole,
+	CapTabComplete,
+	CapWorldActions,
+}
+
+// Hello is the first message a plugin sends after connecting.
+type Hello struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	PluginVersion   string   `json:"plugin_version"`
+	Capabilities    []string `json:"capabilities"`
+	// Encodings the plugin can send binary frames in, most preferred first.
+	Encodings []string `json:"encodings"`
+}
+
+func (h *Hello) Validate() error {
+	if h.ProtocolVersion < 1 {
+		return fieldError("protocol_version", "must be at least 1")
+	}
+	return nil
+}
+
+// HelloAck is the backend's answer to Hello.
+type HelloAck struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	Capabilities    []string `json:"capabilities"`
+	Encoding        string   `json:"encoding"`
+}
+
+// Session is what was agreed with the connected plugin.
+type Session struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	PluginVersion   string   `json:"plugin_version"`
+	Capabilities    []string `json:"capabilities"`
+	Encoding        string   `json:"encoding"`
+}
+
+// LegacySession describes a plugin that has not (yet) sent hello.
+func LegacySession() Session {
+	return Session{
+		ProtocolVersion: 0,
+		Capabilities:    slices.Clone(legacyCapabilities),
+		Encoding:        EncodingJSON,
+	}
+}
+
+// Supports reports whether capability was agreed for this session.
+func (s Session) Supports(capability string) bool {
+	return capability == "" || slices.Contains(s.Capabilities, capability)
+}
+
+// Negotiate picks the protocol version, capabilities and frame encoding both
+// sides support; encodings are the binary encodings the backend allows. A
+// plugin newer than the backend is spoken to at the backend's version and is
+// expected to fall back. Every plugin that sends a valid hello is accepted.
+func Negotiate(hello Hello, encodings []string) (Session, HelloAck) {
+	version := min(hello.ProtocolVersion, Version)
+	capabilities := make([]string, 0, len(hello.Capabilities))
+	for _, capability := range hello.Capabilities {
+		if slices.Contains(Capabilities, capability) && !slices.Contains(capabilities, capability) {
+			capabilities = append(capabilities, capability)
+		}
+	}
+
+	encoding := EncodingJSON
+	for _, offered := range hello.Encodings {
+		if slices.Contains(encodings, offered) {
+			encoding = offered
+			break
+		}
+	}
+
+	session := Session{
+		ProtocolVersion: version,
+		PluginVersion:   hello.PluginVersion,
+		Capabilities:    capabilities,
+		Encoding:        encoding,
+	}
+	return session, HelloAck{
+		ProtocolVersion: version,
+		Capabilities:    capabilities,
+		Encoding:        encoding,
+	}
+}
@@ -0,0 +1,201 @@
+package protocol
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// EventWorldAction is sent by browsers and forwarded to the plugin once the
+// backend has validated it.
+const EventWorldAction = "world_action"
+
+// Actions a world_action can carry.
+const (
+	WorldActionSetDay        = "set_day"
+	WorldActionSetNight      = "set_night"
+	WorldActionToggleWeather = "toggle_weather"
+	WorldActionSave          = "save"
+	WorldActionLoad          = "load"
+	WorldActionUnload        = "unload"
+	WorldActionReset         = "reset"
+	WorldActionSetGamerule   = "set_gamerule"
+	WorldActionSetDifficulty = "set_difficulty"
+	WorldActionSetSpawn      = "set_spawn"
+	WorldActionSetBorder     = "set_border"
+	WorldActionSetPVP        = "set_pvp"
+)
+
+// Limits matching the vanilla world: coordinates stop at the world border's
+// maximum extent and heights at the largest build limit a datapack can set.
+const (
+	MaxWorldCoordinate       = 29_999_984
+	MaxWorldBorderSize       = 59_999_968
+	MinWorldHeight           = -2032
+	MaxWorldHeight           = 2031
+	MaxBorderTransitionSecs  = 86_400
+	maxGameruleValueLength   = 64
+	maxWorldActionNameLength = 64
+)
+
+// Difficulties accepted by set_difficulty.
+var Difficulties = []string{"peaceful", "easy", "normal", "hard"}
+
+var gamerulePattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,64}$`)
+
+// worldSettingsActions need CapWorldSettings on top of CapWorldActions, as
+// older plugins silently ignore them.
+var worldSettingsActions = []string{
+	WorldActionSetDifficulty,
+	WorldActionSetSpawn,
+	WorldActionSetBorder,
+	WorldActionSetPVP,
+}
+
+// WorldAction is one world_action payload. Only the fields its action uses
+// are kept when it is forwarded.
+type WorldAction struct {
+	Action string `json:"action"`
+	World  string `json:"world"`
+
+	// set_gamerule
+	Rule  string `json:"rule,omitempty"`
+	Value string `json:"value,omitempty"`
+
+	// set_difficulty
+	Difficulty string `json:"difficulty,omitempty"`
+
+	// set_spawn; Y defaults to the highest block at X, Z.
+	X *float64 `json:"x,omitempty"`
+	Y *float64 `json:"y,omitempty"`
+	Z *float64 `json:"z,omitempty"`
+
+	// set_border; the center is left alone when omitted.
+	Size              *float64 `json:"size,omitempty"`
+	CenterX           *float64 `json:"center_x,omitempty"`
+	CenterZ           *float64 `json:"center_z,omitempty"`
+	TransitionSeconds int64    `json:"transition_seconds,omitempty"`
+
+	// set_pvp
+	PVP *bool `json:"pvp,omitempty"`
+}
+
+// Normalize trims and lower-cases fields so equivalent requests look the same.
+func (a *WorldAction) Normalize() {
+	a.Action = strings.ToLower(strings.TrimSpace(a.Action))
+	a.World = strings.TrimSpace(a.World)
+	a.Rule = strings.TrimSpace(a.Rule)
+	a.Value = strings.TrimSpace(a.Value)
+	a.Difficulty = strings.ToLower(strings.TrimSpace(a.Difficulty))
+}
+
+func (a *WorldAction) Validate() error {
+	if a.World == "" || len(a.World) > maxWorldActionNameLength || strings.ContainsAny(a.World, "/\\") {
+		return fieldError("world", "must be a world name")
+	}
+	switch a.Action {
+	case WorldActionSetDay, WorldActionSetNight, WorldActionToggleWeather, WorldActionSave,
+		WorldActionLoad, WorldActionUnload, WorldActionReset:
+		return nil
+	case WorldActionSetGamerule:
+		return ValidateGamerule(a.Rule, a.Value)
+	case WorldActionSetDifficulty:
+		if !slices.Contains(Difficulties, a.Difficulty) {
+			return fieldError("difficulty", "must be one of "+strings.Join(Difficulties, ", "))
+		}
+		return nil
+	case WorldActionSetSpawn:
+		if err := coordinate("x", a.X, true); err != nil {
+			return err
+		}
+		if err := coordinate("z", a.Z, true); err != nil {
+			return err
+		}
+		if a.Y != nil && (!finite(*a.Y) || *a.Y < MinWorldHeight || *a.Y > MaxWorldHeight) {
+			return fieldError("y", "is outside the build height")
+		}
+		return nil
+	case WorldActionSetBorder:
+		if a.Size == nil || !finite(*a.Size) || *a.Size < 1 || *a.Size > MaxWorldBorderSize {
+			return fieldError("size", "must be between 1 and 59999968")
+		}
+		if (a.CenterX == nil) != (a.CenterZ == nil) {
+			return fieldError("center_x", "and center_z must be set together")
+		}
+		if err := coordinate("center_x", a.CenterX, false); err != nil {
+			return err
+		}
+		if err := coordinate("center_z", a.CenterZ, false); err != nil {
+			return err
+		}
+		if a.TransitionSeconds < 0 || a.TransitionSeconds > MaxBorderTransitionSecs {
+			return fieldError("transition_seconds", "must be between 0 and 86400")
+		}
+		return nil
+	case WorldActionSetPVP:
+		if a.PVP == nil {
+			return fieldError("pvp", "is required")
+		}
+		return nil
+	case "":
+		return fieldError("action", "is required")
+	default:
+		return fieldError("action", "is not a known world action")
+	}
+}
+
+// Capability is what the plugin must have declared to handle the action.
+func (a *WorldAction) Capability() string {
+	if slices.Contains(worldSettingsActions, a.Action) {
+		return CapWorldSettings
+	}
+	return CapWorldActions
+}
+
+// Trimmed returns a copy carrying only the fields the action uses.
+func (a WorldAction) Trimmed() WorldAction {
+	out := WorldAction{Action: a.Action, World: a.World}
+	switch a.Action {
+	case WorldActionSetGamerule:
+		out.Rule, out.Value = a.Rule, a.Value
+	case WorldActionSetDifficulty:
+		out.Difficulty = a.Difficulty
+	case WorldActionSetSpawn:
+		out.X, out.Y, out.Z = a.X, a.Y, a.Z
+	case WorldActionSetBorder:
+		out.Size, out.CenterX, out.CenterZ, out.TransitionSeconds = a.Size, a.CenterX, a.CenterZ, a.TransitionSeconds
+	case WorldActionSetPVP:
+		out.PVP = a.PVP
+	}
+	return out
+}
+
+// ValidateGamerule checks that a rule name and value are well formed. It does
+// not know which rules the server has.
+func ValidateGamerule(rule, value string) error {
+	if !gamerulePattern.MatchString(rule) {
+		return fieldError("rule", "must be a gamerule name")
+	}
+	if value == "" || len(value) > maxGameruleValueLength || strings.ContainsAny(value, " \t\r\n") {
+		return fieldError("value", "of "+rule+" must be 1-64 characters without spaces")
+	}
+	return nil
+}
+
+func coordinate(field string, v *float64, required bool) error {
+	if v == nil {
+		if required {
+			return fieldError(field, "is required")
+		}
+		return nil
+	}
+	if !finite(*v) || math.Abs(*v) > MaxWorldCoordinate {
+		return fieldError(field, "must be within ±29999984")
+	}
+	return nil
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
@@ -0,0 +1,180 @@
+// Package ratelimit provides keyed token buckets and a failure lockout for
+// throttling logins, API calls and WebSocket events.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate allows Limit events per Per, in bursts of up to Limit.
+type Rate struct {
+	Limit int
+	Per   time.Duration
+}
+
+// ParseRate reads rates like "5/m", "20/s", "300/1h" or "10/30s".
+func ParseRate(raw string) (Rate, error) {
+	count, unit, ok := strings.Cut(strings.TrimSpace(raw), "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("rate %q must look like 10/m", raw)
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || limit <= 0 {
+		return Rate{}, fmt.Errorf("rate %q must start with a positive count", raw)
+	}
+	unit = strings.TrimSpace(unit)
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		per, err = time.ParseDuration(unit)
+		if err != nil || per <= 0 {
+			return Rate{}, fmt.Errorf("rate %q has an invalid period", raw)
+		}
+	}
+	return Rate{Limit: limit, Per: per}, nil
+}
+
+func (r Rate) String() string {
+	return fmt.Sprintf("%d/%s", r.Limit, r.Per)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rate   Rate
+}
+
+// Limiter keeps one token bucket per key. It is safe for concurrent use.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{buckets: make(map[string]*bucket)}
+}
+
+// Allow takes a token from key's bucket. When none is left it reports how
+// long until one is.
+func (l *Limiter) Allow(key string, rate Rate, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok || b.rate != rate {
+		b = &bucket{tokens: float64(rate.Limit), last: now, rate: rate}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	perToken := rate.Per / time.Duration(rate.Limit)
+	wait := time.Duration(math.Ceil((1 - b.tokens) * float64(perToken)))
+	return false, wait
+}
+
+// Sweep drops buckets that have refilled completely, as they behave the same
+// as a fresh one.
+func (l *Limiter) Sweep(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.rate.Limit) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens = math.Min(float64(b.rate.Limit), b.tokens+elapsed.Seconds()*float64(b.rate.Limit)/b.rate.Per.Seconds())
+	b.last = now
+}
+
+type failures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// Lockout locks a key out for a while after repeated failures, such as wrong
+// login tokens from one address. Failures are forgotten once a key has been
+// quiet for the lockout duration. It is safe for concurrent use.
+type Lockout struct {
+	after    int
+	duration time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*failures
+}
+
+// NewLockout locks keys out for duration after the given number of failures
+// in a row.
+func NewLockout(after int, duration time.Duration) *Lockout {
+	return &Lockout{after: after, duration: duration, entries: make(map[string]*failures)}
+}
+
+// Locked reports whether key is locked out and for how much longer.
+func (l *Lockout) Locked(key string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, ok := l.entries[key]
+	if !ok || !now.Before(entry.lockedUntil) {
+		return 0, false
+	}
+	return entry.lockedUntil.Sub(now), true
+}
+
+// Fail records a failure and reports whether it locked key out.
+func (l *Lockout) Fail(key string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, ok := l.entries[key]
+	if !ok || now.Sub(entry.last) > l.duration {
+		entry = &failures{}
+		l.entries[key] = entry
+	}
+	entry.count++
+	entry.last = now
+	if entry.count < l.after {
+		return 0, false
+	}
+	entry.count = 0
+	entry.lockedUntil = now.Add(l.duration)
+	return l.duration, true
+}
+
+// Reset forgets key's failures, e.g. after a successful login.
+func (l *Lockout) Reset(key string) {
+	l.mu.Lock()
+	delete(l.entries, key)
+	l.mu.Unlock()
+}
+
+// Sweep drops keys that are neither locked nor have recent failures.
+func (l *Lockout) Sweep(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, entry := range l.entries {
+		if !now.Before(entry.lockedUntil) && now.Sub(entry.last) > l.duration {
+			delete(l.entries, key)
+		}
+	}
+}
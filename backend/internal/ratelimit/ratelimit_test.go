@@ -0,0 +1,199 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want Rate
+		ok   bool
+	}{
+		{"5/m", Rate{5, time.Minute}, true},
+		{" 20 / s ", Rate{20, time.Second}, true},
+		{"300/1h", Rate{300, time.Hour}, true},
+		{"10/30s", Rate{10, 30 * time.Second}, true},
+		{"10", Rate{}, false},
+		{"0/m", Rate{}, false},
+		{"-1/m", Rate{}, false},
+		{"x/m", Rate{}, false},
+		{"5/fortnight", Rate{}, false},
+		{"5/-1s", Rate{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.raw)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: err = %v, want ok %v", tt.raw, err, tt.ok)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestLimiterAllow(t *testing.T) {
+	rate := Rate{Limit: 3, Per: time.Minute} // one token every 20s
+	type step struct {
+		at   time.Duration
+		ok   bool
+		wait time.Duration
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"burst", []step{
+			{0, true, 0},
+			{0, true, 0},
+			{0, true, 0},
+			{0, false, 20 * time.Second},
+		}},
+		{"wait shrinks as the bucket refills", []step{
+			{0, true, 0}, {0, true, 0}, {0, true, 0},
+			{5 * time.Second, false, 15 * time.Second},
+			{15 * time.Second, false, 5 * time.Second},
+		}},
+		{"refill one token", []step{
+			{0, true, 0}, {0, true, 0}, {0, true, 0},
+			{20 * time.Second, true, 0},
+			{20 * time.Second, false, 20 * time.Second},
+		}},
+		{"refill is capped at the burst", []step{
+			{0, true, 0},
+			{time.Hour, true, 0},
+			{time.Hour, true, 0},
+			{time.Hour, true, 0},
+			{time.Hour, false, 20 * time.Second},
+		}},
+		{"clock going backwards does not refill", []step{
+			{time.Minute, true, 0}, {time.Minute, true, 0}, {time.Minute, true, 0},
+			{0, false, 20 * time.Second},
+		}},
+	}
+	for _, tt := range tests {
+		l := NewLimiter()
+		for i, s := range tt.steps {
+			ok, wait := l.Allow("key", rate, epoch.Add(s.at))
+			if ok != s.ok || wait != s.wait {
+				t.Errorf("%s: step %d: got (%v, %v), want (%v, %v)", tt.name, i, ok, wait, s.ok, s.wait)
+			}
+		}
+	}
+}
+
+func TestLimiterKeysAndRates(t *testing.T) {
+	l := NewLimiter()
+	rate := Rate{Limit: 1, Per: time.Minute}
+	if ok, _ := l.Allow("a", rate, epoch); !ok {
+		t.Fatal("first call for a refused")
+	}
+	if ok, _ := l.Allow("b", rate, epoch); !ok {
+		t.Error("b shares a's bucket")
+	}
+	if ok, _ := l.Allow("a", rate, epoch); ok {
+		t.Error("a allowed past its burst")
+	}
+	// A new rate for a key, e.g. after a permission change, starts afresh.
+	if ok, _ := l.Allow("a", Rate{Limit: 2, Per: time.Minute}, epoch); !ok {
+		t.Error("changed rate kept the old bucket")
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l := NewLimiter()
+	rate := Rate{Limit: 2, Per: time.Minute}
+	l.Allow("idle", rate, epoch)
+	l.Allow("busy", rate, epoch.Add(50*time.Second))
+	l.Sweep(epoch.Add(time.Minute))
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("refilled bucket was kept")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Error("partly used bucket was dropped")
+	}
+}
+
+func TestLockout(t *testing.T) {
+	const after, duration = 3, 10 * time.Minute
+	type step struct {
+		at     time.Duration
+		fail   bool // Fail rather than Locked
+		reset  bool
+		wait   time.Duration
+		locked bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"locks after repeated failures", []step{
+			{at: 0, fail: true},
+			{at: time.Second, fail: true},
+			{at: 2 * time.Second},
+			{at: 3 * time.Second, fail: true, wait: duration, locked: true},
+			{at: 4 * time.Second, wait: duration - time.Second, locked: true},
+		}},
+		{"lock expires", []step{
+			{at: 0, fail: true}, {at: 0, fail: true},
+			{at: 0, fail: true, wait: duration, locked: true},
+			{at: duration - time.Second, wait: time.Second, locked: true},
+			{at: duration},
+		}},
+		{"count starts over after a lockout", []step{
+			{at: 0, fail: true}, {at: 0, fail: true},
+			{at: 0, fail: true, wait: duration, locked: true},
+			{at: duration, fail: true},
+			{at: duration},
+		}},
+		{"quiet failures are forgotten", []step{
+			{at: 0, fail: true},
+			{at: time.Second, fail: true},
+			{at: duration + 2*time.Second, fail: true},
+			{at: duration + 2*time.Second},
+		}},
+		{"reset clears failures", []step{
+			{at: 0, fail: true}, {at: 0, fail: true},
+			{at: 0, reset: true},
+			{at: 0, fail: true},
+			{at: 0},
+		}},
+	}
+	for _, tt := range tests {
+		l := NewLockout(after, duration)
+		for i, s := range tt.steps {
+			now := epoch.Add(s.at)
+			var wait time.Duration
+			var locked bool
+			switch {
+			case s.reset:
+				l.Reset("key")
+				continue
+			case s.fail:
+				wait, locked = l.Fail("key", now)
+			default:
+				wait, locked = l.Locked("key", now)
+			}
+			if wait != s.wait || locked != s.locked {
+				t.Errorf("%s: step %d: got (%v, %v), want (%v, %v)", tt.name, i, wait, locked, s.wait, s.locked)
+			}
+		}
+	}
+}
+
+func TestLockoutSweep(t *testing.T) {
+	l := NewLockout(1, time.Minute)
+	l.Fail("locked", epoch)
+	l.Fail("expired", epoch.Add(-2*time.Minute))
+	l.Sweep(epoch.Add(30 * time.Second))
+	if _, ok := l.entries["locked"]; !ok {
+		t.Error("locked key was dropped")
+	}
+	if _, ok := l.entries["expired"]; ok {
+		t.Error("expired key was kept")
+	}
+}
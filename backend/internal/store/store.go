@@ -1,18 +1,15 @@
 package store
 
 import (
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/adammcgrogan/beacon/internal/config"
 	"github.com/adammcgrogan/beacon/internal/models"
 )
 
-const (
-	MaxLogLines = 1000
-	MaxLogBytes = 5 * 1024 * 1024 // 5MB limit for total memory buffer
-	MaxLogAge   = 1 * time.Hour   // Clear logs if the server has been silent this long
-)
-
 // ServerStore holds all thread-safe data for the application
 type ServerStore struct {
 	mu sync.RWMutex
@@ -24,21 +21,40 @@ type ServerStore struct {
 
 	totalBytes  int
 	lastLogTime time.Time
+
+	maxLogLines int
+	maxLogBytes int           // limit for total memory buffer
+	maxLogAge   time.Duration // clear logs if the server has been silent this long
 }
 
-func New() *ServerStore {
+func New(cfg config.ConsoleConfig) *ServerStore {
 	return &ServerStore{
 		latestStats: models.ServerStats{TPS: "0.00"},
 		env:         models.ServerEnv{Software: "Awaiting Data...", Java: "Awaiting Data...", OS: "Awaiting Data..."},
 		worlds:      make([]models.WorldInfo, 0),
 		logHistory:  make([][]byte, 0),
 		lastLogTime: time.Now(),
+		maxLogLines: cfg.MaxLogLines,
+		maxLogBytes: cfg.MaxLogBytes,
+		maxLogAge:   cfg.MaxLogAge,
 	}
 }
 
 // --- Server Stats ---
 
+// UpdateStats stores the latest stats. Older plugins only send TPS as a
+// string, formatted in the server's locale (e.g. "19,98"), so the one-minute
+// TPS is filled in from it, and the other way round for plugins that only
+// send numbers.
 func (s *ServerStore) UpdateStats(stats models.ServerStats) {
+	if stats.TPS1m == nil {
+		raw := strings.Replace(strings.TrimSpace(stats.TPS), ",", ".", 1)
+		if tps, err := strconv.ParseFloat(raw, 64); err == nil {
+			stats.TPS1m = &tps
+		}
+	} else if stats.TPS == "" {
+		stats.TPS = strconv.FormatFloat(*stats.TPS1m, 'f', 2, 64)
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.latestStats = stats
@@ -84,18 +100,18 @@ func (s *ServerStore) AddLog(log []byte) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if time.Since(s.lastLogTime) > MaxLogAge && len(s.logHistory) > 0 {
+	if time.Since(s.lastLogTime) > s.maxLogAge && len(s.logHistory) > 0 {
 		s.logHistory = make([][]byte, 0)
 		s.totalBytes = 0
 	}
 
-	if len(s.logHistory) >= MaxLogLines {
+	if len(s.logHistory) >= s.maxLogLines {
 		s.totalBytes -= len(s.logHistory[0])
 		s.logHistory = s.logHistory[1:]
 	}
 
 	s.totalBytes += len(log)
-	for s.totalBytes > MaxLogBytes && len(s.logHistory) > 0 {
+	for s.totalBytes > s.maxLogBytes && len(s.logHistory) > 0 {
 		s.totalBytes -= len(s.logHistory[0])
 		s.logHistory = s.logHistory[1:]
 	}
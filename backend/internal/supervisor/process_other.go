@@ -0,0 +1,14 @@
+//go:build !unix
+
+package supervisor
+
+import "os/exec"
+
+// isolate does nothing where process groups are not available.
+func isolate(cmd *exec.Cmd) {}
+
+// kill ends the server process. Children it started may outlive it; the
+// wait delay stops them holding up the exit.
+func kill(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
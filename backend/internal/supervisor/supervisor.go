@@ -0,0 +1,439 @@
+// Package supervisor runs the Minecraft server as a child process, so the
+// panel can start it again after it stops, and restarts it after a crash
+// when the restart policy asks for it.
+package supervisor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+)
+
+// State is what the supervised process is doing.
+type State string
+
+const (
+	StateStopped    State = "stopped"
+	StateRunning    State = "running"
+	StateStopping   State = "stopping"
+	StateRestarting State = "restarting"
+	StateCrashed    State = "crashed"
+)
+
+// Restart policies.
+const (
+	RestartNever   = "never"
+	RestartOnCrash = "on-crash"
+	RestartAlways  = "always"
+)
+
+var (
+	ErrRunning    = errors.New("server is already running")
+	ErrNotRunning = errors.New("server is not running")
+)
+
+// maxLine bounds one line of server output; longer lines are truncated.
+const maxLine = 1 << 20
+
+// waitDelay is how long output may stay open after the server exits, e.g.
+// held by a child it left behind, before the exit is recorded anyway.
+const waitDelay = 5 * time.Second
+
+// Config describes how to launch and stop the server.
+type Config struct {
+	// Command is the program and its arguments, e.g. java -jar paper.jar nogui.
+	Command []string
+	// Dir is the working directory; empty uses the backend's.
+	Dir string
+	// Env is added to the backend's environment, as KEY=value.
+	Env []string
+	// StopCommand is written to the server's stdin to stop it gracefully.
+	StopCommand string
+	// StopTimeout is how long a graceful stop may take before the process is
+	// killed.
+	StopTimeout time.Duration
+	// Restart is one of RestartNever, RestartOnCrash or RestartAlways. A
+	// crash is any exit the panel did not ask for with a non-zero code;
+	// RestartAlways also restarts after clean exits such as /stop in game.
+	Restart string
+	// RestartDelay is how long to wait before restarting automatically.
+	RestartDelay time.Duration
+	// MaxRestarts automatic restarts within RestartWindow stop further ones,
+	// so a server that crashes on boot is not restarted forever.
+	MaxRestarts   int
+	RestartWindow time.Duration
+}
+
+// Status is the process state reported to the panel. It is separate from
+// whether the plugin is connected: a running server may not have loaded the
+// plugin yet.
+type Status struct {
+	State     State     `json:"state"`
+	PID       int       `json:"pid,omitempty"`
+	StartedAt time.Time `json:"started_at,omitzero"`
+	ExitedAt  time.Time `json:"exited_at,omitzero"`
+	// ExitCode is the last exit code; -1 when the process was killed by a
+	// signal.
+	ExitCode *int `json:"exit_code,omitempty"`
+	// Reason says why the process last exited; Crashed is set when it
+	// exited on its own with a non-zero code.
+	Reason  string `json:"reason,omitempty"`
+	Crashed bool   `json:"crashed,omitempty"`
+	// Restarts is how many automatic restarts fell within the restart window.
+	Restarts    int       `json:"restarts"`
+	NextRestart time.Time `json:"next_restart,omitzero"`
+}
+
+// Supervisor starts, stops and watches one server process. It is safe for
+// concurrent use.
+type Supervisor struct {
+	cfg Config
+
+	// OnOutput receives every line the server writes to stdout or stderr.
+	OnOutput func(line string)
+	// OnState receives the status after every change.
+	OnState func(Status)
+
+	mu       sync.Mutex
+	status   Status
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	exited   chan struct{}
+	stopping string // "stopped" or "killed" once the exit was asked for
+	restart  bool   // start again once the current process exits
+	timer    *time.Timer
+	restarts []time.Time
+	closed   bool
+}
+
+// New returns a supervisor with nothing running.
+func New(cfg Config) *Supervisor {
+	if cfg.StopCommand == "" {
+		cfg.StopCommand = "stop"
+	}
+	if cfg.Restart == "" {
+		cfg.Restart = RestartNever
+	}
+	return &Supervisor{cfg: cfg, status: Status{State: StateStopped}}
+}
+
+// Status returns the current status.
+func (s *Supervisor) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Start launches the server. A pending automatic restart is replaced.
+func (s *Supervisor) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("supervisor is shut down")
+	}
+	if s.cmd != nil {
+		return ErrRunning
+	}
+	s.cancelTimer()
+	s.restarts = nil
+	return s.spawn()
+}
+
+// Stop asks the server to stop by writing the stop command to its stdin,
+// and kills it if it has not exited within the stop timeout. It returns
+// without waiting.
+func (s *Supervisor) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cmd == nil {
+		if s.cancelTimer() {
+			s.status.State = StateStopped
+			s.changed()
+			return nil
+		}
+		return ErrNotRunning
+	}
+	s.restart = false
+	if s.stopping != "" {
+		return nil
+	}
+	return s.stop()
+}
+
+// Kill ends the server immediately.
+func (s *Supervisor) Kill() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cmd == nil {
+		return ErrNotRunning
+	}
+	s.restart = false
+	s.stopping = "killed"
+	s.status.State = StateStopping
+	s.changed()
+	return kill(s.cmd)
+}
+
+// Restart stops the server gracefully and starts it again, or just starts
+// it when it is not running.
+func (s *Supervisor) Restart() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("supervisor is shut down")
+	}
+	if s.cmd == nil {
+		s.cancelTimer()
+		s.restarts = nil
+		return s.spawn()
+	}
+	s.restart = true
+	if s.stopping != "" {
+		return nil
+	}
+	return s.stop()
+}
+
+// Shutdown stops the server gracefully, killing it if ctx ends first, and
+// prevents further starts. It waits for the process to exit.
+func (s *Supervisor) Shutdown(ctx context.Context) {
+	s.mu.Lock()
+	s.closed = true
+	s.restart = false
+	s.cancelTimer()
+	exited := s.exited
+	if s.cmd != nil && s.stopping == "" {
+		_ = s.stop()
+	}
+	s.mu.Unlock()
+	if exited == nil {
+		return
+	}
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		s.mu.Lock()
+		if s.cmd != nil {
+			s.stopping = "killed"
+			_ = kill(s.cmd)
+		}
+		s.mu.Unlock()
+		<-exited
+	}
+}
+
+// spawn starts the process. The caller holds mu.
+func (s *Supervisor) spawn() error {
+	if len(s.cfg.Command) == 0 {
+		return errors.New("no server command is configured")
+	}
+	cmd := exec.Command(s.cfg.Command[0], s.cfg.Command[1:]...)
+	cmd.Dir = s.cfg.Dir
+	cmd.Env = append(os.Environ(), s.cfg.Env...)
+	cmd.WaitDelay = waitDelay
+	isolate(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	// Output is copied through in-process pipes rather than read from the
+	// child's pipes directly, so Wait owns the copying and gives up after
+	// WaitDelay instead of blocking on a descendant that kept them open.
+	stdout, stdoutWriter := io.Pipe()
+	stderr, stderrWriter := io.Pipe()
+	cmd.Stdout, cmd.Stderr = stdoutWriter, stderrWriter
+	if err := cmd.Start(); err != nil {
+		stdoutWriter.Close()
+		stderrWriter.Close()
+		s.status.State = StateCrashed
+		s.status.Reason = "could not start: " + err.Error()
+		s.changed()
+		return fmt.Errorf("could not start server: %w", err)
+	}
+
+	exited := make(chan struct{})
+	s.cmd, s.stdin, s.exited = cmd, stdin, exited
+	s.stopping, s.restart = "", false
+	s.status = Status{
+		State:     StateRunning,
+		PID:       cmd.Process.Pid,
+		StartedAt: time.Now(),
+		Restarts:  len(s.restarts),
+	}
+	s.changed()
+
+	var pumps sync.WaitGroup
+	for _, pipe := range []io.Reader{stdout, stderr} {
+		pumps.Add(1)
+		go func() {
+			defer pumps.Done()
+			s.pump(pipe)
+		}()
+	}
+	go func() {
+		err := cmd.Wait()
+		stdoutWriter.Close()
+		stderrWriter.Close()
+		pumps.Wait()
+		s.exit(cmd, err)
+		close(exited)
+	}()
+	return nil
+}
+
+func (s *Supervisor) pump(r io.Reader) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var line []byte
+	for {
+		chunk, more, err := reader.ReadLine()
+		if len(line) < maxLine {
+			line = append(line, chunk[:min(len(chunk), maxLine-len(line))]...)
+		}
+		if err != nil {
+			if len(line) > 0 && s.OnOutput != nil {
+				s.OnOutput(string(line))
+			}
+			return
+		}
+		if more {
+			continue
+		}
+		if s.OnOutput != nil {
+			s.OnOutput(string(line))
+		}
+		line = line[:0]
+	}
+}
+
+// stop writes the stop command and arms the kill timer. The caller holds mu.
+func (s *Supervisor) stop() error {
+	s.stopping = "stopped"
+	s.status.State = StateStopping
+	s.changed()
+
+	cmd, exited := s.cmd, s.exited
+	if _, err := io.WriteString(s.stdin, s.cfg.StopCommand+"\n"); err != nil {
+		s.stopping = "killed"
+		return kill(cmd)
+	}
+	if timeout := s.cfg.StopTimeout; timeout > 0 {
+		go func() {
+			select {
+			case <-exited:
+			case <-time.After(timeout):
+				s.mu.Lock()
+				if s.cmd == cmd {
+					s.stopping = "killed"
+					_ = kill(cmd)
+				}
+				s.mu.Unlock()
+			}
+		}()
+	}
+	return nil
+}
+
+// exit records how the process ended and decides whether to start it again.
+func (s *Supervisor) exit(cmd *exec.Cmd, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cmd != cmd {
+		return
+	}
+	s.cmd, s.stdin = nil, nil
+
+	code := cmd.ProcessState.ExitCode()
+	now := time.Now()
+	s.status.PID = 0
+	s.status.ExitedAt = now
+	s.status.ExitCode = &code
+	s.status.NextRestart = time.Time{}
+
+	requested := s.stopping
+	s.stopping = ""
+	crashed := requested == "" && code != 0
+	switch {
+	case requested != "":
+		s.status.State = StateStopped
+		s.status.Reason = requested + " on request"
+	case crashed:
+		s.status.State = StateCrashed
+		s.status.Crashed = true
+		s.status.Reason = "crashed: " + describeExit(code, err)
+	default:
+		s.status.State = StateStopped
+		s.status.Reason = "server exited"
+	}
+
+	if s.restart && !s.closed {
+		s.restart = false
+		s.restarts = nil
+		// spawn reports the new state, or the failure to start.
+		_ = s.spawn()
+		return
+	}
+
+	auto := !s.closed && requested == "" &&
+		(s.cfg.Restart == RestartAlways || (s.cfg.Restart == RestartOnCrash && crashed))
+	if auto {
+		window := now.Add(-s.cfg.RestartWindow)
+		s.restarts = slices.DeleteFunc(s.restarts, func(at time.Time) bool { return at.Before(window) })
+		if s.cfg.MaxRestarts > 0 && len(s.restarts) >= s.cfg.MaxRestarts {
+			s.status.State = StateCrashed
+			s.status.Reason += fmt.Sprintf("; not restarting after %d restarts in %s", len(s.restarts), s.cfg.RestartWindow)
+			auto = false
+		}
+	}
+	if auto {
+		s.status.State = StateRestarting
+		s.status.NextRestart = now.Add(s.cfg.RestartDelay)
+		s.timer = time.AfterFunc(s.cfg.RestartDelay, s.autoRestart)
+	}
+	s.status.Restarts = len(s.restarts)
+	s.changed()
+}
+
+func (s *Supervisor) autoRestart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer == nil || s.cmd != nil || s.closed {
+		return
+	}
+	s.timer = nil
+	s.restarts = append(s.restarts, time.Now())
+	_ = s.spawn()
+}
+
+// cancelTimer drops a pending automatic restart. The caller holds mu.
+func (s *Supervisor) cancelTimer() bool {
+	if s.timer == nil {
+		return false
+	}
+	s.timer.Stop()
+	s.timer = nil
+	s.status.NextRestart = time.Time{}
+	return true
+}
+
+// changed reports the status. The caller holds mu, so OnState must not call
+// back into the supervisor.
+func (s *Supervisor) changed() {
+	if s.OnState != nil {
+		s.OnState(s.status)
+	}
+}
+
+func describeExit(code int, err error) string {
+	var exitErr *exec.ExitError
+	if code < 0 && errors.As(err, &exitErr) {
+		return exitErr.Error()
+	}
+	return fmt.Sprintf("exit code %d", code)
+}
@@ -0,0 +1,237 @@
+package supervisor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess is the server the tests supervise. It does nothing unless
+// started by one of them through helperConfig.
+func TestHelperProcess(t *testing.T) {
+	mode := os.Getenv("BEACON_SUPERVISOR_HELPER")
+	if mode == "" {
+		return
+	}
+	fmt.Println("booting")
+	switch mode {
+	case "crash":
+		os.Exit(3)
+	case "serve":
+		// Run until the stop command arrives, like a Minecraft console.
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if scanner.Text() == "stop" {
+				os.Exit(0)
+			}
+		}
+		os.Exit(1)
+	}
+	os.Exit(2)
+}
+
+func helperConfig(mode, restart string) Config {
+	return Config{
+		Command:       []string{os.Args[0], "-test.run=^TestHelperProcess$"},
+		Env:           []string{"BEACON_SUPERVISOR_HELPER=" + mode},
+		StopTimeout:   5 * time.Second,
+		Restart:       restart,
+		RestartDelay:  10 * time.Millisecond,
+		MaxRestarts:   2,
+		RestartWindow: time.Minute,
+	}
+}
+
+// watch records every status the supervisor reports.
+func watch(s *Supervisor) <-chan Status {
+	states := make(chan Status, 64)
+	s.OnState = func(status Status) { states <- status }
+	return states
+}
+
+// expect waits for the next reported states and fails unless they match.
+func expect(t *testing.T, states <-chan Status, want ...State) Status {
+	t.Helper()
+	var last Status
+	for i, state := range want {
+		select {
+		case last = <-states:
+			if last.State != state {
+				t.Fatalf("state %d: got %s (%s), want %s", i, last.State, last.Reason, state)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("state %d: timed out waiting for %s", i, state)
+		}
+	}
+	return last
+}
+
+// quiet fails if another state is reported within a while.
+func quiet(t *testing.T, states <-chan Status) {
+	t.Helper()
+	select {
+	case status := <-states:
+		t.Fatalf("unexpected state %s (%s)", status.State, status.Reason)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func shutdown(t *testing.T, s *Supervisor) {
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.Shutdown(ctx)
+	})
+}
+
+func TestRestartOnCrashGivesUp(t *testing.T) {
+	s := New(helperConfig("crash", RestartOnCrash))
+	states := watch(s)
+	output := make(chan string, 16)
+	s.OnOutput = func(line string) { output <- line }
+	shutdown(t, s)
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	// Two restarts are allowed within the window; the third crash is final.
+	expect(t, states, StateRunning, StateRestarting, StateRunning, StateRestarting, StateRunning)
+	final := expect(t, states, StateCrashed)
+	if !final.Crashed || final.ExitCode == nil || *final.ExitCode != 3 {
+		t.Errorf("final status = %+v, want a crash with exit code 3", final)
+	}
+	if final.Restarts != 2 || !strings.Contains(final.Reason, "not restarting after 2 restarts") {
+		t.Errorf("final status = %+v, want it to give up after 2 restarts", final)
+	}
+	quiet(t, states)
+
+	select {
+	case line := <-output:
+		if line != "booting" {
+			t.Errorf("output = %q, want booting", line)
+		}
+	default:
+		t.Error("server output was not passed on")
+	}
+
+	// Starting by hand resets the count.
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start after giving up: %v", err)
+	}
+	if status := expect(t, states, StateRunning); status.Restarts != 0 {
+		t.Errorf("restarts after a manual start = %d, want 0", status.Restarts)
+	}
+}
+
+func TestRestartWindowExpires(t *testing.T) {
+	cfg := helperConfig("crash", RestartOnCrash)
+	cfg.MaxRestarts = 1
+	cfg.RestartWindow = time.Nanosecond
+	s := New(cfg)
+	states := watch(s)
+	shutdown(t, s)
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	// Each restart has left the window by the next crash, so the limit of
+	// one is never reached.
+	for range 3 {
+		expect(t, states, StateRunning, StateRestarting)
+	}
+}
+
+func TestStopCancelsPendingRestart(t *testing.T) {
+	cfg := helperConfig("crash", RestartOnCrash)
+	cfg.RestartDelay = time.Hour
+	s := New(cfg)
+	states := watch(s)
+	shutdown(t, s)
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if status := expect(t, states, StateRunning, StateRestarting); status.NextRestart.IsZero() {
+		t.Error("pending restart has no time")
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if status := expect(t, states, StateStopped); !status.NextRestart.IsZero() {
+		t.Error("cancelled restart is still scheduled")
+	}
+	if err := s.Stop(); err != ErrNotRunning {
+		t.Errorf("second Stop = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestNoRestartAfterStop(t *testing.T) {
+	for _, restart := range []string{RestartOnCrash, RestartAlways} {
+		t.Run(restart, func(t *testing.T) {
+			s := New(helperConfig("serve", restart))
+			states := watch(s)
+			shutdown(t, s)
+
+			if err := s.Start(); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			expect(t, states, StateRunning)
+			if err := s.Stop(); err != nil {
+				t.Fatalf("Stop: %v", err)
+			}
+			expect(t, states, StateStopping)
+			final := expect(t, states, StateStopped)
+			if final.Crashed || final.Reason != "stopped on request" {
+				t.Errorf("final status = %+v, want a requested stop", final)
+			}
+			quiet(t, states)
+			if err := s.Stop(); err != ErrNotRunning {
+				t.Errorf("second Stop = %v, want ErrNotRunning", err)
+			}
+		})
+	}
+}
+
+func TestNoRestartAfterKill(t *testing.T) {
+	s := New(helperConfig("serve", RestartOnCrash))
+	states := watch(s)
+	shutdown(t, s)
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	expect(t, states, StateRunning)
+	if err := s.Kill(); err != nil {
+		t.Fatalf("Kill: %v", err)
+	}
+	expect(t, states, StateStopping)
+	if final := expect(t, states, StateStopped); final.Reason != "killed on request" {
+		t.Errorf("reason = %q, want killed on request", final.Reason)
+	}
+	quiet(t, states)
+}
+
+func TestRestartByHand(t *testing.T) {
+	s := New(helperConfig("serve", RestartNever))
+	states := watch(s)
+	shutdown(t, s)
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	first := expect(t, states, StateRunning)
+	if err := s.Start(); err != ErrRunning {
+		t.Errorf("Start while running = %v, want ErrRunning", err)
+	}
+	if err := s.Restart(); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	expect(t, states, StateStopping)
+	if second := expect(t, states, StateRunning); second.PID == first.PID {
+		t.Error("Restart kept the same process")
+	}
+}
@@ -0,0 +1,18 @@
+// Attach the double-submit CSRF token to every state-changing same-origin request.
+(() => {
+    const nativeFetch = window.fetch.bind(window);
+    window.beaconCsrfToken = () => {
+        const entry = document.cookie.split('; ').find(c => c.startsWith('beacon_csrf='));
+        return entry ? decodeURIComponent(entry.slice('beacon_csrf='.length)) : '';
+    };
+    window.fetch = (input, init = {}) => {
+        const method = (init.method || (input instanceof Request ? input.method : 'GET')).toUpperCase();
+        const url = new URL(input instanceof Request ? input.url : String(input), window.location.href);
+        if (url.origin === window.location.origin && !['GET', 'HEAD', 'OPTIONS'].includes(method)) {
+            const headers = new Headers(init.headers || (input instanceof Request ? input.headers : undefined));
+            headers.set('X-CSRF-Token', window.beaconCsrfToken());
+            init = { ...init, headers };
+        }
+        return nativeFetch(input, init);
+    };
+})();
@@ -17,6 +17,7 @@ dependencies {
     compileOnly(libs.paper)
     compileOnly(libs.vault)
     api(libs.websocket)
+    api(libs.msgpack)
     compileOnly(libs.log4j)
 }
 
@@ -1,6 +1,17 @@
 package net.trybeacon.plugin;
 
 import net.trybeacon.plugin.commands.BeaconCommand;
+import net.trybeacon.plugin.listeners.ChatMuteListener;
+import net.trybeacon.plugin.listeners.PlayerConnectionListener;
+import net.trybeacon.plugin.listeners.TickTimeListener;
+import net.trybeacon.plugin.diagnostics.DiagnosticsService;
+import net.trybeacon.plugin.diagnostics.ProfilerService;
+import net.trybeacon.plugin.moderation.ModerationService;
+import net.trybeacon.plugin.plugins.PluginInventoryService;
+import net.trybeacon.plugin.roster.RosterService;
+import net.trybeacon.plugin.worlds.GameruleService;
+import net.trybeacon.plugin.worlds.HotspotService;
+import net.trybeacon.plugin.worlds.WorldManagementService;
 import net.trybeacon.plugin.permissions.VaultPermissionService;
 import org.bukkit.Bukkit;
 import org.bukkit.configuration.file.FileConfiguration;
@@ -8,32 +19,61 @@ import org.bukkit.configuration.file.YamlConfiguration;
 import org.bukkit.plugin.java.JavaPlugin;
 import org.bukkit.scheduler.BukkitTask;
 import net.trybeacon.plugin.logging.WebSocketLogAppender;
+import net.trybeacon.plugin.tasks.DiskUsageTask;
 import net.trybeacon.plugin.tasks.ServerStatsTask;
 import net.trybeacon.plugin.websocket.BackendClient;
+import net.trybeacon.plugin.websocket.BackendDialer;
+import net.trybeacon.plugin.websocket.BackendListener;
+import net.trybeacon.plugin.websocket.CertificatePinning;
 
 import java.io.File;
 import java.io.InputStream;
 import java.io.InputStreamReader;
+import java.net.InetSocketAddress;
 import java.net.URI;
 import java.net.URISyntaxException;
 import java.nio.charset.StandardCharsets;
+import java.security.GeneralSecurityException;
 import java.util.HashSet;
+import java.util.List;
 import java.util.Set;
 
 public class BeaconPlugin extends JavaPlugin {
 
     private static final long RECONNECT_INTERVAL_TICKS = 100L; // 5 seconds
+    private static final long DISK_USAGE_INTERVAL_TICKS = 6000L; // 5 minutes
 
-    private BackendClient webSocketClient;
+    private BackendDialer webSocketClient;
+    private BackendListener backendListener;
+    // The live session with the backend, whichever side opened it.
+    private volatile BackendClient backendSession;
     private WebSocketLogAppender logAppender;
     private BukkitTask statsTask;
+    private BukkitTask diskUsageTask;
     private BukkitTask reconnectTask;
     private volatile boolean shuttingDown;
     private volatile boolean connectionAttemptInFlight;
     private String backendWebSocketUrl;
     private String backendPublicUrl;
+    private String backendCertificateFingerprint;
+    private boolean backendCompression;
+    private boolean backendBinaryFrames;
+    private String backendSecret;
+    private String backendMode;
+    private String listenerAddress;
     private int panelTokenExpirySeconds;
+    private List<String> statsBackupFolders;
+    private TickTimeListener tickTimeListener;
+    private DiskUsageTask diskUsage;
     private VaultPermissionService vaultPermissionService;
+    private ModerationService moderationService;
+    private RosterService rosterService;
+    private WorldManagementService worldManagementService;
+    private GameruleService gameruleService;
+    private HotspotService hotspotService;
+    private DiagnosticsService diagnosticsService;
+    private ProfilerService profilerService;
+    private PluginInventoryService pluginInventoryService;
 
     @Override
     public void onEnable() {
@@ -44,7 +84,26 @@ public class BeaconPlugin extends JavaPlugin {
         loadConfig();
         vaultPermissionService = new VaultPermissionService(this);
         vaultPermissionService.initialize();
+        moderationService = new ModerationService(this);
+        rosterService = new RosterService();
+        worldManagementService = new WorldManagementService(this);
+        gameruleService = new GameruleService();
+        hotspotService = new HotspotService();
+        diagnosticsService = new DiagnosticsService(this);
+        profilerService = new ProfilerService(this);
+        pluginInventoryService = new PluginInventoryService(this);
+        tickTimeListener = new TickTimeListener();
+        diskUsage = new DiskUsageTask(Bukkit.getWorldContainer(), statsBackupFolders);
         registerCommands();
+        getServer().getPluginManager().registerEvents(new PlayerConnectionListener(this), this);
+        getServer().getPluginManager().registerEvents(new ChatMuteListener(moderationService), this);
+        getServer().getPluginManager().registerEvents(profilerService, this);
+        getServer().getPluginManager().registerEvents(tickTimeListener, this);
+        if (isListenMode()) {
+            getLogger().info("Beacon Plugin is starting! Waiting for the Go backend to connect...");
+            startListener();
+            return;
+        }
         getLogger().info("Beacon Plugin is starting! Attempting to connect to Go backend...");
         connectToWebSocket();
         startReconnectLoop();
@@ -56,10 +115,21 @@ public class BeaconPlugin extends JavaPlugin {
         connectionAttemptInFlight = false;
         stopReconnectLoop();
         stopStreamingTasks();
+        if (profilerService != null) {
+            profilerService.shutdown();
+        }
 
         if (webSocketClient != null && !webSocketClient.isClosed() && !webSocketClient.isClosing()) {
             webSocketClient.close();
         }
+        if (backendListener != null) {
+            try {
+                backendListener.stop(1000);
+            } catch (InterruptedException e) {
+                Thread.currentThread().interrupt();
+            }
+            backendListener = null;
+        }
 
         getLogger().info("Beacon Plugin disabled. Connection closed.");
     }
@@ -74,19 +144,61 @@ public class BeaconPlugin extends JavaPlugin {
 
         try {
             URI serverUri = new URI(backendWebSocketUrl);
-            webSocketClient = new BackendClient(serverUri, this);
+            webSocketClient = new BackendDialer(serverUri, this);
+            if ("wss".equalsIgnoreCase(serverUri.getScheme()) && !backendCertificateFingerprint.isBlank()) {
+                webSocketClient.setSocketFactory(CertificatePinning.socketFactory(backendCertificateFingerprint));
+            }
             connectionAttemptInFlight = true;
             webSocketClient.connect();
         } catch (URISyntaxException e) {
             connectionAttemptInFlight = false;
             getLogger().severe("Invalid WebSocket URI: " + e.getMessage());
+        } catch (GeneralSecurityException e) {
+            connectionAttemptInFlight = false;
+            getLogger().severe("Could not configure certificate pinning: " + e.getMessage());
+        }
+    }
+
+    private synchronized void startListener() {
+        // Whoever connects to the listener becomes the backend and can run
+        // commands, so listen mode is never started without a secret.
+        if (backendSecret.isBlank()) {
+            getLogger().severe("backend.mode is \"listen\" but backend.secret is empty; not listening. Set the same secret as plugin.secret in beacon.yml.");
+            return;
         }
+        try {
+            backendListener = new BackendListener(parseListenAddress(listenerAddress), this);
+            backendListener.start();
+        } catch (IllegalArgumentException e) {
+            getLogger().severe("Invalid backend.listen-address: " + e.getMessage());
+        }
+    }
+
+    private static InetSocketAddress parseListenAddress(String address) {
+        int colon = address.lastIndexOf(':');
+        if (colon < 0) {
+            throw new IllegalArgumentException("expected host:port, got \"" + address + "\"");
+        }
+        String host = address.substring(0, colon);
+        int port = Integer.parseInt(address.substring(colon + 1));
+        return host.isBlank() ? new InetSocketAddress(port) : new InetSocketAddress(host, port);
+    }
+
+    private boolean isListenMode() {
+        return "listen".equalsIgnoreCase(backendMode);
     }
 
     private void loadConfig() {
         backendWebSocketUrl = getConfig().getString("backend.websocket-url", "ws://localhost:8080/ws");
         backendPublicUrl = getConfig().getString("backend.public-url", "http://localhost:8080");
+        backendCertificateFingerprint = getConfig().getString("backend.certificate-fingerprint", "");
+        backendCompression = getConfig().getBoolean("backend.compression", true);
+        backendBinaryFrames = getConfig().getBoolean("backend.binary-frames", true);
+        backendSecret = getConfig().getString("backend.secret", "");
+        backendMode = getConfig().getString("backend.mode", "connect");
+        listenerAddress = getConfig().getString("backend.listen-address", "0.0.0.0:8765");
         panelTokenExpirySeconds = Math.max(30, getConfig().getInt("auth.token-expiration-seconds", 300));
+        statsBackupFolders = getConfig().getStringList("stats.backup-folders");
     }
 
     private void syncConfig() {
@@ -141,13 +253,25 @@ public class BeaconPlugin extends JavaPlugin {
     }
 
     public BackendClient getBackendClient() {
-        return webSocketClient;
+        return backendSession;
+    }
+
+    public String getBackendSecret() {
+        return backendSecret;
     }
 
     public String getBackendPublicUrl() {
         return backendPublicUrl;
     }
 
+    public boolean isBackendCompressionEnabled() {
+        return backendCompression;
+    }
+
+    public boolean isBackendBinaryFramesEnabled() {
+        return backendBinaryFrames;
+    }
+
     public int getPanelTokenExpirySeconds() {
         return panelTokenExpirySeconds;
     }
@@ -156,13 +280,46 @@ public class BeaconPlugin extends JavaPlugin {
         return vaultPermissionService;
     }
 
+    public ModerationService getModerationService() {
+        return moderationService;
+    }
+
+    public RosterService getRosterService() {
+        return rosterService;
+    }
+
+    public WorldManagementService getWorldManagementService() {
+        return worldManagementService;
+    }
+
+    public GameruleService getGameruleService() {
+        return gameruleService;
+    }
+
+    public HotspotService getHotspotService() {
+        return hotspotService;
+    }
+
+    public DiagnosticsService getDiagnosticsService() {
+        return diagnosticsService;
+    }
+
+    public ProfilerService getProfilerService() {
+        return profilerService;
+    }
+
+    public PluginInventoryService getPluginInventoryService() {
+        return pluginInventoryService;
+    }
+
     /**
      * Called by BackendClient when a connection is successfully opened.
      */
     public synchronized void onBackendConnected(BackendClient client) {
         if (shuttingDown) return;
-        if (client != webSocketClient) return;
+        if (!isCurrentTransport(client)) return;
 
+        backendSession = client;
         connectionAttemptInFlight = false;
         stopReconnectLoop();
         stopStreamingTasks();
@@ -170,28 +327,35 @@ public class BeaconPlugin extends JavaPlugin {
         logAppender = new WebSocketLogAppender(client);
         logAppender.attach();
 
-        statsTask = Bukkit.getScheduler().runTaskTimer(this, new ServerStatsTask(client), 0L, 40L);
+        statsTask = Bukkit.getScheduler().runTaskTimer(this, new ServerStatsTask(client, tickTimeListener, diskUsage), 0L, 40L);
+        diskUsageTask = Bukkit.getScheduler().runTaskTimerAsynchronously(this, diskUsage, 0L, DISK_USAGE_INTERVAL_TICKS);
     }
 
     /**
      * Called by BackendClient when the socket closes.
      */
     public synchronized void onBackendDisconnected(BackendClient client) {
-        if (client != webSocketClient) return;
+        if (client != backendSession) return;
+        backendSession = null;
         connectionAttemptInFlight = false;
         stopStreamingTasks();
 
-        if (!shuttingDown) {
+        if (!shuttingDown && !isListenMode()) {
             getLogger().warning("Backend disconnected. Reconnect loop active.");
             startReconnectLoop();
         }
     }
 
     public synchronized void onBackendError(BackendClient client, Exception ex) {
-        if (client != webSocketClient) return;
+        if (!isCurrentTransport(client)) return;
         connectionAttemptInFlight = false;
     }
 
+    private boolean isCurrentTransport(BackendClient client) {
+        if (webSocketClient != null && client == webSocketClient.getSession()) return true;
+        return backendListener != null && backendListener.isCurrent(client);
+    }
+
     private synchronized void startReconnectLoop() {
         if (shuttingDown) return;
         if (reconnectTask != null && !reconnectTask.isCancelled()) return;
@@ -215,6 +379,10 @@ public class BeaconPlugin extends JavaPlugin {
             statsTask.cancel();
             statsTask = null;
         }
+        if (diskUsageTask != null) {
+            diskUsageTask.cancel();
+            diskUsageTask = null;
+        }
 
         if (logAppender != null) {
             logAppender.detach();
@@ -0,0 +1,156 @@
+package net.trybeacon.plugin.diagnostics;
+
+import com.google.gson.JsonArray;
+import com.google.gson.JsonObject;
+import net.trybeacon.plugin.BeaconPlugin;
+import org.bukkit.Bukkit;
+import org.bukkit.plugin.Plugin;
+
+import javax.management.MBeanServer;
+import javax.management.ObjectName;
+import java.io.File;
+import java.io.IOException;
+import java.io.InputStream;
+import java.lang.management.ManagementFactory;
+import java.nio.charset.StandardCharsets;
+import java.nio.file.Files;
+import java.nio.file.Path;
+
+/**
+ * Finds crash reports and JVM fatal error logs on the server, and takes
+ * thread dumps and heap histograms of the running JVM. Safe to call off the
+ * main thread.
+ */
+public class DiagnosticsService {
+
+    private static final int MAX_READ_BYTES = 512 * 1024;
+    private static final String CRASH_REPORTS_DIR = "crash-reports";
+
+    private final BeaconPlugin plugin;
+
+    public DiagnosticsService(BeaconPlugin plugin) {
+        this.plugin = plugin;
+    }
+
+    public JsonObject perform(JsonObject payload) throws Exception {
+        String action = payload.has("action") ? payload.get("action").getAsString() : "";
+        return switch (action) {
+            case "list" -> list();
+            case "read" -> read(payload.has("path") ? payload.get("path").getAsString() : "");
+            case "thread_dump" -> diagnosticCommand("threadPrint");
+            case "heap_histogram" -> diagnosticCommand("gcClassHistogram");
+            default -> throw new IllegalArgumentException("unsupported action");
+        };
+    }
+
+    private JsonObject list() throws IOException {
+        Path root = serverRoot();
+        JsonArray files = new JsonArray();
+
+        Path crashDir = root.resolve(CRASH_REPORTS_DIR);
+        if (Files.isDirectory(crashDir)) {
+            try (var stream = Files.list(crashDir)) {
+                for (Path path : (Iterable<Path>) stream::iterator) {
+                    if (isCrashReport(path)) {
+                        files.add(fileEntry(root, path, "crash_report"));
+                    }
+                }
+            }
+        }
+        try (var stream = Files.list(root)) {
+            for (Path path : (Iterable<Path>) stream::iterator) {
+                if (isFatalErrorLog(path)) {
+                    files.add(fileEntry(root, path, "hs_err"));
+                }
+            }
+        }
+
+        JsonArray plugins = new JsonArray();
+        for (Plugin installed : Bukkit.getPluginManager().getPlugins()) {
+            String main = installed.getDescription().getMain();
+            int dot = main.lastIndexOf('.');
+            if (dot <= 0) {
+                continue;
+            }
+            JsonObject entry = new JsonObject();
+            entry.addProperty("name", installed.getName());
+            entry.addProperty("package", main.substring(0, dot));
+            plugins.add(entry);
+        }
+
+        JsonObject data = new JsonObject();
+        data.add("files", files);
+        data.add("plugins", plugins);
+        return data;
+    }
+
+    private JsonObject read(String rawPath) throws IOException {
+        Path root = serverRoot();
+        Path path = root.resolve(rawPath).normalize();
+        boolean allowed = path.startsWith(root)
+                && ((path.getParent() != null && path.getParent().equals(root.resolve(CRASH_REPORTS_DIR)) && isCrashReport(path))
+                || (root.equals(path.getParent()) && isFatalErrorLog(path)));
+        if (!allowed) {
+            throw new IllegalArgumentException("not a crash report");
+        }
+
+        byte[] bytes;
+        boolean truncated;
+        try (InputStream in = Files.newInputStream(path)) {
+            bytes = in.readNBytes(MAX_READ_BYTES);
+            truncated = in.read() != -1;
+        }
+
+        JsonObject data = new JsonObject();
+        data.addProperty("path", relativePath(root, path));
+        data.addProperty("content", new String(bytes, StandardCharsets.UTF_8));
+        data.addProperty("truncated", truncated);
+        return data;
+    }
+
+    /**
+     * Runs a jcmd-style diagnostic command in this JVM, e.g. threadPrint for
+     * Thread.print or gcClassHistogram for GC.class_histogram.
+     */
+    private JsonObject diagnosticCommand(String operation) throws Exception {
+        MBeanServer server = ManagementFactory.getPlatformMBeanServer();
+        ObjectName name = new ObjectName("com.sun.management:type=DiagnosticCommand");
+        Object result = server.invoke(name, operation, new Object[]{new String[0]}, new String[]{String[].class.getName()});
+
+        JsonObject data = new JsonObject();
+        data.addProperty("content", result == null ? "" : result.toString());
+        return data;
+    }
+
+    private static boolean isCrashReport(Path path) {
+        String name = path.getFileName().toString();
+        return Files.isRegularFile(path) && name.startsWith("crash-") && name.endsWith(".txt");
+    }
+
+    private static boolean isFatalErrorLog(Path path) {
+        String name = path.getFileName().toString();
+        return Files.isRegularFile(path) && name.startsWith("hs_err_pid") && name.endsWith(".log");
+    }
+
+    private static JsonObject fileEntry(Path root, Path path, String kind) throws IOException {
+        JsonObject entry = new JsonObject();
+        entry.addProperty("path", relativePath(root, path));
+        entry.addProperty("kind", kind);
+        entry.addProperty("size", Files.size(path));
+        entry.addProperty("mod_time", Files.getLastModifiedTime(path).toInstant().toString());
+        return entry;
+    }
+
+    private static String relativePath(Path root, Path path) {
+        return root.relativize(path).toString().replace('\\', '/');
+    }
+
+    private Path serverRoot() throws IOException {
+        File pluginsDir = plugin.getDataFolder().getParentFile();
+        File root = pluginsDir != null ? pluginsDir.getParentFile() : null;
+        if (root == null) {
+            root = new File(".");
+        }
+        return root.toPath().toRealPath();
+    }
+}
@@ -0,0 +1,332 @@
+package net.trybeacon.plugin.diagnostics;
+
+import com.destroystokyo.paper.event.server.ServerTickEndEvent;
+import com.google.gson.JsonArray;
+import com.google.gson.JsonObject;
+import net.trybeacon.plugin.BeaconPlugin;
+import org.bukkit.Bukkit;
+import org.bukkit.World;
+import org.bukkit.event.EventHandler;
+import org.bukkit.event.EventPriority;
+import org.bukkit.event.HandlerList;
+import org.bukkit.event.Listener;
+import org.bukkit.plugin.Plugin;
+import org.bukkit.plugin.RegisteredListener;
+
+import java.lang.reflect.Method;
+import java.time.Instant;
+import java.util.ArrayList;
+import java.util.HashMap;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.concurrent.ExecutionException;
+
+/**
+ * Samples the server's main thread to show where ticks go: which plugin's
+ * code was running, which event was being handled and whether a world was
+ * ticking. Sampling runs on its own thread; tick times come from Paper's
+ * tick end event.
+ */
+public class ProfilerService implements Listener {
+
+    private static final int MAX_DEPTH = 200;
+    private static final int MAX_NODES = 100_000;
+    private static final long DEADLINE_GRACE_MS = 30_000;
+    private static final String FALLBACK_PLUGIN = "Minecraft";
+
+    private final BeaconPlugin plugin;
+    private final Thread mainThread;
+    private Session session;
+
+    public ProfilerService(BeaconPlugin plugin) {
+        this.plugin = plugin;
+        this.mainThread = Thread.currentThread();
+    }
+
+    public JsonObject perform(JsonObject payload) throws Exception {
+        String action = payload.has("action") ? payload.get("action").getAsString() : "";
+        return switch (action) {
+            case "start" -> start(
+                    payload.has("duration_ms") ? payload.get("duration_ms").getAsLong() : 60_000,
+                    payload.has("interval_ms") ? payload.get("interval_ms").getAsInt() : 10);
+            case "stop" -> stop();
+            default -> throw new IllegalArgumentException("unsupported action");
+        };
+    }
+
+    /**
+     * Stops sampling without reporting, e.g. when the plugin is disabled.
+     */
+    public synchronized void shutdown() {
+        if (session != null) {
+            session.sampler.interrupt();
+            session = null;
+        }
+    }
+
+    @EventHandler(priority = EventPriority.MONITOR)
+    public void onTickEnd(ServerTickEndEvent event) {
+        Session current;
+        synchronized (this) {
+            current = session;
+        }
+        if (current != null && current.sampler.isAlive()) {
+            synchronized (current) {
+                current.tickTimes.add(event.getTickDuration());
+            }
+        }
+    }
+
+    private synchronized JsonObject start(long durationMs, int intervalMs) {
+        if (durationMs <= 0 || intervalMs <= 0) {
+            throw new IllegalArgumentException("duration and interval must be positive");
+        }
+        if (session != null && session.sampler.isAlive()) {
+            throw new IllegalStateException("a profile is already running");
+        }
+        Session started = new Session(intervalMs, packages(), eventHandlers());
+        long deadline = System.currentTimeMillis() + durationMs + DEADLINE_GRACE_MS;
+        started.sampler = new Thread(() -> sample(started, deadline), "Beacon Profiler");
+        started.sampler.setDaemon(true);
+        session = started;
+        started.sampler.start();
+
+        JsonObject data = new JsonObject();
+        data.addProperty("started_at", started.startedAt.toString());
+        return data;
+    }
+
+    private JsonObject stop() throws Exception {
+        Session stopped;
+        synchronized (this) {
+            stopped = session;
+            session = null;
+        }
+        if (stopped == null) {
+            throw new IllegalStateException("no profile is running");
+        }
+        stopped.sampler.interrupt();
+        stopped.sampler.join(5000);
+
+        Map<String, Integer> weights;
+        try {
+            weights = Bukkit.getScheduler().callSyncMethod(plugin, ProfilerService::worldWeights).get();
+        } catch (ExecutionException ex) {
+            throw ex.getCause() instanceof Exception cause ? cause : ex;
+        }
+        synchronized (stopped) {
+            return stopped.report(weights);
+        }
+    }
+
+    private void sample(Session session, long deadline) {
+        while (!Thread.currentThread().isInterrupted() && System.currentTimeMillis() < deadline) {
+            StackTraceElement[] stack = mainThread.getStackTrace();
+            synchronized (session) {
+                session.record(stack);
+            }
+            try {
+                Thread.sleep(session.intervalMs);
+            } catch (InterruptedException ex) {
+                return;
+            }
+        }
+    }
+
+    private static Map<String, Integer> worldWeights() {
+        Map<String, Integer> weights = new LinkedHashMap<>();
+        for (World world : Bukkit.getWorlds()) {
+            weights.put(world.getName(), world.getEntityCount() + world.getTileEntityCount());
+        }
+        return weights;
+    }
+
+    /**
+     * Maps each plugin's main package to its name, so a frame can be
+     * attributed to the plugin whose code it is.
+     */
+    private static Map<String, String> packages() {
+        Map<String, String> packages = new HashMap<>();
+        for (Plugin installed : Bukkit.getPluginManager().getPlugins()) {
+            String main = installed.getDescription().getMain();
+            int dot = main.lastIndexOf('.');
+            if (dot > 0) {
+                packages.put(main.substring(0, dot + 1), installed.getName());
+            }
+        }
+        return packages;
+    }
+
+    /**
+     * Maps "class#method" of every registered event handler to the name of
+     * the event it handles.
+     */
+    private static Map<String, String> eventHandlers() {
+        Map<String, String> handlers = new HashMap<>();
+        for (HandlerList list : HandlerList.getHandlerLists()) {
+            for (RegisteredListener registered : list.getRegisteredListeners()) {
+                Class<?> type = registered.getListener().getClass();
+                for (Method method : type.getDeclaredMethods()) {
+                    if (method.isAnnotationPresent(EventHandler.class) && method.getParameterCount() == 1) {
+                        handlers.putIfAbsent(type.getName() + "#" + method.getName(), method.getParameterTypes()[0].getSimpleName());
+                    }
+                }
+            }
+        }
+        return handlers;
+    }
+
+    private static final class Node {
+        final String name;
+        int value;
+        final Map<String, Node> children = new LinkedHashMap<>();
+
+        Node(String name) {
+            this.name = name;
+        }
+
+        JsonObject toJson() {
+            JsonObject json = new JsonObject();
+            json.addProperty("name", name);
+            json.addProperty("value", value);
+            if (!children.isEmpty()) {
+                JsonArray array = new JsonArray();
+                for (Node child : children.values()) {
+                    array.add(child.toJson());
+                }
+                json.add("children", array);
+            }
+            return json;
+        }
+    }
+
+    private static final class Session {
+        final Instant startedAt = Instant.now();
+        final int intervalMs;
+        final Map<String, String> packages;
+        final Map<String, String> eventHandlers;
+        final List<Double> tickTimes = new ArrayList<>();
+        final Map<String, Integer> plugins = new HashMap<>();
+        final Map<String, Integer> events = new HashMap<>();
+        final Node root = new Node("root");
+        int samples;
+        int idleSamples;
+        int worldSamples;
+        int nodes;
+        Thread sampler;
+
+        Session(int intervalMs, Map<String, String> packages, Map<String, String> eventHandlers) {
+            this.intervalMs = intervalMs;
+            this.packages = packages;
+            this.eventHandlers = eventHandlers;
+        }
+
+        void record(StackTraceElement[] stack) {
+            if (stack.length == 0) {
+                return;
+            }
+            for (StackTraceElement frame : stack) {
+                if (isIdle(frame)) {
+                    idleSamples++;
+                    return;
+                }
+            }
+            samples++;
+
+            // Stack traces run innermost first; the flame graph runs from the
+            // outermost frame down.
+            Node node = root;
+            boolean inWorld = false;
+            for (int i = stack.length - 1; i >= Math.max(0, stack.length - MAX_DEPTH); i--) {
+                StackTraceElement frame = stack[i];
+                String name = frame.getClassName() + "." + frame.getMethodName();
+                Node child = node.children.get(name);
+                if (child == null) {
+                    if (nodes >= MAX_NODES) {
+                        break;
+                    }
+                    child = new Node(name);
+                    node.children.put(name, child);
+                    nodes++;
+                }
+                child.value++;
+                node = child;
+                inWorld |= isWorldTick(frame);
+            }
+            if (inWorld) {
+                worldSamples++;
+            }
+
+            String owner = null;
+            String event = null;
+            for (StackTraceElement frame : stack) {
+                if (owner == null) {
+                    owner = owner(frame.getClassName());
+                }
+                if (event == null) {
+                    event = eventHandlers.get(frame.getClassName() + "#" + frame.getMethodName());
+                }
+                if (owner != null && event != null) {
+                    break;
+                }
+            }
+            plugins.merge(owner == null ? FALLBACK_PLUGIN : owner, 1, Integer::sum);
+            if (event != null) {
+                events.merge(event, 1, Integer::sum);
+            }
+        }
+
+        private String owner(String className) {
+            String best = null;
+            int bestLength = 0;
+            for (Map.Entry<String, String> entry : packages.entrySet()) {
+                if (entry.getKey().length() > bestLength && className.startsWith(entry.getKey())) {
+                    best = entry.getValue();
+                    bestLength = entry.getKey().length();
+                }
+            }
+            return best;
+        }
+
+        private static boolean isIdle(StackTraceElement frame) {
+            if (!frame.getClassName().endsWith("MinecraftServer")) {
+                return false;
+            }
+            String method = frame.getMethodName();
+            return method.equals("waitUntilNextTick") || method.equals("waitForTasks") || method.equals("managedBlock");
+        }
+
+        private static boolean isWorldTick(StackTraceElement frame) {
+            String className = frame.getClassName();
+            return (className.endsWith("ServerLevel") || className.endsWith("WorldServer"))
+                    && frame.getMethodName().equals("tick");
+        }
+
+        JsonObject report(Map<String, Integer> weights) {
+            JsonObject data = new JsonObject();
+            data.addProperty("started_at", startedAt.toString());
+            data.addProperty("interval_ms", intervalMs);
+            data.addProperty("samples", samples);
+            data.addProperty("idle_samples", idleSamples);
+            JsonArray ticks = new JsonArray();
+            for (double tick : tickTimes) {
+                ticks.add(tick);
+            }
+            data.add("tick_times", ticks);
+            data.add("plugins", counts(plugins));
+            data.add("events", counts(events));
+            data.addProperty("world_samples", worldSamples);
+            data.add("world_weights", counts(weights));
+            root.value = samples;
+            data.add("tree", root.toJson());
+            return data;
+        }
+
+        private static JsonObject counts(Map<String, Integer> counts) {
+            JsonObject json = new JsonObject();
+            counts.forEach(json::addProperty);
+            return json;
+        }
+    }
+}
@@ -0,0 +1,29 @@
+package net.trybeacon.plugin.listeners;
+
+import net.trybeacon.plugin.moderation.ModerationService;
+import org.bukkit.ChatColor;
+import org.bukkit.event.EventHandler;
+import org.bukkit.event.EventPriority;
+import org.bukkit.event.Listener;
+import org.bukkit.event.player.AsyncPlayerChatEvent;
+
+/**
+ * Drops chat from players muted through the panel.
+ */
+public class ChatMuteListener implements Listener {
+
+    private final ModerationService moderationService;
+
+    public ChatMuteListener(ModerationService moderationService) {
+        this.moderationService = moderationService;
+    }
+
+    @SuppressWarnings("deprecation")
+    @EventHandler(priority = EventPriority.LOWEST, ignoreCancelled = true)
+    public void onChat(AsyncPlayerChatEvent event) {
+        if (moderationService.isMuted(event.getPlayer().getUniqueId())) {
+            event.setCancelled(true);
+            event.getPlayer().sendMessage(ChatColor.RED + "You are muted.");
+        }
+    }
+}
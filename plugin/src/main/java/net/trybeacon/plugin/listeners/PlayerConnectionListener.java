@@ -0,0 +1,43 @@
+package net.trybeacon.plugin.listeners;
+
+import com.google.gson.JsonObject;
+import net.trybeacon.plugin.BeaconPlugin;
+import net.trybeacon.plugin.websocket.BackendClient;
+import org.bukkit.entity.Player;
+import org.bukkit.event.EventHandler;
+import org.bukkit.event.EventPriority;
+import org.bukkit.event.Listener;
+import org.bukkit.event.player.PlayerJoinEvent;
+
+import java.net.InetSocketAddress;
+
+/**
+ * Tells the backend who joined and from where, so it can keep a directory of
+ * players who are not online.
+ */
+public class PlayerConnectionListener implements Listener {
+
+    private final BeaconPlugin plugin;
+
+    public PlayerConnectionListener(BeaconPlugin plugin) {
+        this.plugin = plugin;
+    }
+
+    @EventHandler(priority = EventPriority.MONITOR)
+    public void onJoin(PlayerJoinEvent event) {
+        BackendClient client = plugin.getBackendClient();
+        if (client == null || !client.isOpen()) {
+            return;
+        }
+
+        Player player = event.getPlayer();
+        JsonObject payload = new JsonObject();
+        payload.addProperty("uuid", player.getUniqueId().toString());
+        payload.addProperty("name", player.getName());
+        InetSocketAddress address = player.getAddress();
+        if (address != null && address.getAddress() != null) {
+            payload.addProperty("address", address.getAddress().getHostAddress());
+        }
+        client.sendEvent("player_join", payload);
+    }
+}
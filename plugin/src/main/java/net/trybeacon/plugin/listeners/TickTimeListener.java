@@ -0,0 +1,56 @@
+package net.trybeacon.plugin.listeners;
+
+import com.destroystokyo.paper.event.server.ServerTickEndEvent;
+import com.google.gson.JsonObject;
+import org.bukkit.event.EventHandler;
+import org.bukkit.event.EventPriority;
+import org.bukkit.event.Listener;
+
+import java.util.Arrays;
+
+/**
+ * Keeps the durations of the last minute of ticks. Both the tick end event
+ * and the stats task run on the main thread, so no locking is needed.
+ */
+public class TickTimeListener implements Listener {
+
+    private static final int WINDOW = 1200;
+
+    private final double[] durations = new double[WINDOW];
+    private int next;
+    private int count;
+
+    @EventHandler(priority = EventPriority.MONITOR)
+    public void onTickEnd(ServerTickEndEvent event) {
+        durations[next] = event.getTickDuration();
+        next = (next + 1) % WINDOW;
+        count = Math.min(count + 1, WINDOW);
+    }
+
+    /**
+     * Returns the average, 95th percentile and longest tick in milliseconds,
+     * or null before the first tick.
+     */
+    public JsonObject summary() {
+        if (count == 0) {
+            return null;
+        }
+        double[] sorted = Arrays.copyOf(durations, count);
+        Arrays.sort(sorted);
+        double total = 0;
+        for (double duration : sorted) {
+            total += duration;
+        }
+        int rank = (int) Math.ceil(0.95 * count);
+
+        JsonObject summary = new JsonObject();
+        summary.addProperty("avg", round(total / count));
+        summary.addProperty("p95", round(sorted[Math.max(rank, 1) - 1]));
+        summary.addProperty("max", round(sorted[count - 1]));
+        return summary;
+    }
+
+    private static double round(double value) {
+        return Math.round(value * 100) / 100.0;
+    }
+}
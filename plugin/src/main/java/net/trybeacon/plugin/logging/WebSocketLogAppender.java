@@ -2,23 +2,22 @@ package net.trybeacon.plugin.logging;
 
 import com.google.gson.JsonObject;
 
-import net.trybeacon.plugin.util.ProtocolBuilder;
+import net.trybeacon.plugin.websocket.BackendClient;
 
 import org.apache.logging.log4j.LogManager;
 import org.apache.logging.log4j.core.LogEvent;
 import org.apache.logging.log4j.core.Logger;
 import org.apache.logging.log4j.core.appender.AbstractAppender;
-import org.java_websocket.client.WebSocketClient;
 
 import java.text.SimpleDateFormat;
 import java.util.Date;
 
 public class WebSocketLogAppender extends AbstractAppender {
 
-    private final WebSocketClient client;
+    private final BackendClient client;
     private final SimpleDateFormat timeFormat = new SimpleDateFormat("HH:mm:ss");
 
-    public WebSocketLogAppender(WebSocketClient client) {
+    public WebSocketLogAppender(BackendClient client) {
         super("BeaconLogAppender", null, null, false, null);
         this.client = client;
     }
@@ -52,7 +51,7 @@ public class WebSocketLogAppender extends AbstractAppender {
         payload.addProperty("level", logLevel);
         payload.addProperty("message", logLine);
         
-        client.send(ProtocolBuilder.buildEvent("console_log", payload));
+        client.sendEvent("console_log", payload);
     }
 
     /**
@@ -0,0 +1,195 @@
+package net.trybeacon.plugin.moderation;
+
+import com.google.gson.JsonObject;
+import net.trybeacon.plugin.BeaconPlugin;
+import org.bukkit.BanList;
+import org.bukkit.Bukkit;
+import org.bukkit.ChatColor;
+import org.bukkit.OfflinePlayer;
+import org.bukkit.configuration.file.YamlConfiguration;
+import org.bukkit.entity.Player;
+
+import java.io.File;
+import java.io.IOException;
+import java.util.Date;
+import java.util.Map;
+import java.util.UUID;
+import java.util.concurrent.ConcurrentHashMap;
+
+/**
+ * Carries out moderation actions requested from the panel. Bans go through the
+ * server's own ban lists so they show up in banned-players.json and
+ * banned-ips.json; mutes are kept by the plugin in mutes.yml.
+ */
+public class ModerationService {
+
+    private final BeaconPlugin plugin;
+    private final File mutesFile;
+    // Muted player UUIDs to the epoch millis their mute ends, or 0 for permanent.
+    private final Map<UUID, Long> mutes = new ConcurrentHashMap<>();
+
+    public ModerationService(BeaconPlugin plugin) {
+        this.plugin = plugin;
+        this.mutesFile = new File(plugin.getDataFolder(), "mutes.yml");
+        loadMutes();
+    }
+
+    /**
+     * Must be called on the main thread.
+     */
+    public JsonObject performAction(JsonObject payload) {
+        String action = string(payload, "action");
+        String playerName = string(payload, "player");
+        String reason = string(payload, "reason");
+        String source = string(payload, "source");
+        long expiresAtUnix = payload.has("expires_at_unix") ? payload.get("expires_at_unix").getAsLong() : 0L;
+        Date expires = expiresAtUnix > 0 ? new Date(expiresAtUnix * 1000L) : null;
+
+        JsonObject data = new JsonObject();
+        switch (action) {
+            case "kick" -> {
+                Player player = requireOnline(playerName);
+                player.kickPlayer(reason.isEmpty() ? "Kicked by an operator." : reason);
+            }
+            case "ban" -> {
+                requireName(playerName);
+                banList(BanList.Type.NAME).addBan(playerName, emptyToNull(reason), expires, source);
+                Player online = Bukkit.getPlayerExact(playerName);
+                if (online != null) {
+                    online.kickPlayer(reason.isEmpty() ? "You are banned from this server." : reason);
+                }
+            }
+            case "ban_ip" -> {
+                String address = string(payload, "ip");
+                Player online = playerName.isEmpty() ? null : Bukkit.getPlayerExact(playerName);
+                if (address.isEmpty() && online != null && online.getAddress() != null) {
+                    address = online.getAddress().getAddress().getHostAddress();
+                }
+                if (address.isEmpty()) {
+                    throw new IllegalArgumentException("player is offline; give an IP address to ban");
+                }
+                banList(BanList.Type.IP).addBan(address, emptyToNull(reason), expires, source);
+                for (Player player : Bukkit.getOnlinePlayers()) {
+                    if (player.getAddress() != null && address.equals(player.getAddress().getAddress().getHostAddress())) {
+                        player.kickPlayer(reason.isEmpty() ? "Your IP address is banned from this server." : reason);
+                    }
+                }
+                data.addProperty("ip", address);
+            }
+            case "unban" -> {
+                requireName(playerName);
+                banList(BanList.Type.NAME).pardon(playerName);
+            }
+            case "unban_ip" -> {
+                String address = string(payload, "ip");
+                if (address.isEmpty()) {
+                    throw new IllegalArgumentException("ip is required");
+                }
+                banList(BanList.Type.IP).pardon(address);
+            }
+            case "mute" -> {
+                OfflinePlayer target = offlinePlayer(playerName);
+                mutes.put(target.getUniqueId(), expires == null ? 0L : expires.getTime());
+                saveMutes();
+                Player online = target.getPlayer();
+                if (online != null) {
+                    online.sendMessage(ChatColor.RED + "You have been muted" + (reason.isEmpty() ? "." : ": " + reason));
+                }
+            }
+            case "unmute" -> {
+                OfflinePlayer target = offlinePlayer(playerName);
+                mutes.remove(target.getUniqueId());
+                saveMutes();
+                Player online = target.getPlayer();
+                if (online != null) {
+                    online.sendMessage(ChatColor.GREEN + "You are no longer muted.");
+                }
+            }
+            case "warn" -> {
+                Player online = Bukkit.getPlayerExact(playerName);
+                if (online != null) {
+                    online.sendMessage(ChatColor.RED + "Warning" + (reason.isEmpty() ? "" : ": " + reason));
+                }
+                data.addProperty("delivered", online != null);
+            }
+            default -> throw new IllegalArgumentException("unsupported moderation action");
+        }
+        return data;
+    }
+
+    /**
+     * Reports whether a player may not chat right now. Safe to call from any thread.
+     */
+    public boolean isMuted(UUID uuid) {
+        Long until = mutes.get(uuid);
+        if (until == null) {
+            return false;
+        }
+        if (until != 0L && until <= System.currentTimeMillis()) {
+            mutes.remove(uuid);
+            return false;
+        }
+        return true;
+    }
+
+    @SuppressWarnings({"deprecation", "unchecked", "rawtypes"})
+    private BanList<String> banList(BanList.Type type) {
+        return (BanList) Bukkit.getBanList(type);
+    }
+
+    private Player requireOnline(String playerName) {
+        requireName(playerName);
+        Player player = Bukkit.getPlayerExact(playerName);
+        if (player == null) {
+            throw new IllegalArgumentException("player is not online");
+        }
+        return player;
+    }
+
+    @SuppressWarnings("deprecation")
+    private OfflinePlayer offlinePlayer(String playerName) {
+        requireName(playerName);
+        Player online = Bukkit.getPlayerExact(playerName);
+        return online != null ? online : Bukkit.getOfflinePlayer(playerName);
+    }
+
+    private void requireName(String playerName) {
+        if (playerName.isEmpty()) {
+            throw new IllegalArgumentException("player is required");
+        }
+    }
+
+    private void loadMutes() {
+        if (!mutesFile.exists()) {
+            return;
+        }
+        YamlConfiguration yaml = YamlConfiguration.loadConfiguration(mutesFile);
+        for (String key : yaml.getKeys(false)) {
+            try {
+                mutes.put(UUID.fromString(key), yaml.getLong(key));
+            } catch (IllegalArgumentException ignored) {
+                // not a UUID
+            }
+        }
+    }
+
+    private void saveMutes() {
+        YamlConfiguration yaml = new YamlConfiguration();
+        for (Map.Entry<UUID, Long> entry : mutes.entrySet()) {
+            yaml.set(entry.getKey().toString(), entry.getValue());
+        }
+        try {
+            yaml.save(mutesFile);
+        } catch (IOException ex) {
+            plugin.getLogger().warning("Failed to save mutes.yml: " + ex.getMessage());
+        }
+    }
+
+    private static String string(JsonObject payload, String key) {
+        return payload.has(key) && !payload.get(key).isJsonNull() ? payload.get(key).getAsString().trim() : "";
+    }
+
+    private static String emptyToNull(String value) {
+        return value.isEmpty() ? null : value;
+    }
+}
@@ -0,0 +1,361 @@
+package net.trybeacon.plugin.plugins;
+
+import com.google.gson.JsonArray;
+import com.google.gson.JsonObject;
+import net.trybeacon.plugin.BeaconPlugin;
+import org.bukkit.Bukkit;
+import org.bukkit.configuration.file.YamlConfiguration;
+import org.bukkit.plugin.Plugin;
+import org.bukkit.plugin.PluginDescriptionFile;
+import org.bukkit.plugin.PluginManager;
+
+import java.io.File;
+import java.io.FileOutputStream;
+import java.io.IOException;
+import java.io.InputStream;
+import java.io.InputStreamReader;
+import java.io.OutputStream;
+import java.io.Reader;
+import java.nio.charset.StandardCharsets;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.nio.file.StandardCopyOption;
+import java.nio.file.attribute.FileTime;
+import java.security.MessageDigest;
+import java.security.NoSuchAlgorithmException;
+import java.util.Base64;
+import java.util.HexFormat;
+import java.util.List;
+import java.util.Map;
+import java.util.concurrent.ConcurrentHashMap;
+import java.util.concurrent.ExecutionException;
+import java.util.jar.JarFile;
+import java.util.regex.Pattern;
+import java.util.stream.Stream;
+import java.util.zip.ZipEntry;
+
+/**
+ * Reports the installed plugins and the jars in plugins/ and the update
+ * folder, enables, disables and reloads plugins, and installs uploaded jars.
+ * Safe to call off the main thread; plugin state changes are run on it.
+ */
+public class PluginInventoryService {
+
+    private static final Pattern UPLOAD_ID = Pattern.compile("[a-f0-9]{1,64}");
+
+    private final BeaconPlugin plugin;
+    private final File uploadsFolder;
+    private final Map<Path, JarInfo> jarCache = new ConcurrentHashMap<>();
+
+    /** What is known about a jar, valid while its size and modified time hold. */
+    private record JarInfo(long size, FileTime modified, String sha256, String name, String version) {
+    }
+
+    public PluginInventoryService(BeaconPlugin plugin) {
+        this.plugin = plugin;
+        this.uploadsFolder = new File(plugin.getDataFolder(), "plugin-uploads");
+        // Uploads cut short by a restart are never finished.
+        File[] leftovers = uploadsFolder.listFiles();
+        if (leftovers != null) {
+            for (File leftover : leftovers) {
+                leftover.delete();
+            }
+        }
+    }
+
+    public JsonObject perform(JsonObject payload) throws Exception {
+        String action = string(payload, "action");
+        return switch (action) {
+            case "list" -> list();
+            case "enable", "disable", "reload" -> {
+                String name = string(payload, "name");
+                try {
+                    yield Bukkit.getScheduler().callSyncMethod(plugin, () -> setState(name, action)).get();
+                } catch (ExecutionException ex) {
+                    throw ex.getCause() instanceof Exception cause ? cause : ex;
+                }
+            }
+            case "upload_chunk" -> appendChunk(payload);
+            case "install" -> install(payload);
+            case "discard" -> {
+                Files.deleteIfExists(uploadFile(string(payload, "upload_id")).toPath());
+                yield new JsonObject();
+            }
+            default -> throw new IllegalArgumentException("unsupported action");
+        };
+    }
+
+    private JsonObject list() throws IOException {
+        Path root = serverRoot();
+        Path pluginsFolder = pluginsFolder();
+        Path updateFolder = updateFolder();
+
+        JsonArray plugins = new JsonArray();
+        for (Plugin installed : Bukkit.getPluginManager().getPlugins()) {
+            plugins.add(pluginEntry(root, installed));
+        }
+
+        JsonArray jars = new JsonArray();
+        addJars(jars, root, pluginsFolder, false);
+        if (!updateFolder.equals(pluginsFolder)) {
+            addJars(jars, root, updateFolder, true);
+        }
+
+        JsonObject data = new JsonObject();
+        data.add("plugins", plugins);
+        data.add("jars", jars);
+        data.addProperty("plugins_folder", relativePath(root, pluginsFolder));
+        data.addProperty("update_folder", relativePath(root, updateFolder));
+        return data;
+    }
+
+    /**
+     * Must be called on the main thread.
+     */
+    private JsonObject setState(String name, String action) throws IOException {
+        Plugin target = findPlugin(name);
+        if (target == null) {
+            throw new IllegalArgumentException("no plugin named " + name);
+        }
+        if (target == plugin) {
+            throw new IllegalArgumentException("Beacon cannot " + action + " itself");
+        }
+        PluginManager manager = Bukkit.getPluginManager();
+        if (!action.equals("enable") && target.isEnabled()) {
+            manager.disablePlugin(target);
+        }
+        if (!action.equals("disable") && !target.isEnabled()) {
+            manager.enablePlugin(target);
+            if (!target.isEnabled()) {
+                throw new IllegalStateException(target.getName() + " failed to enable; see the server log");
+            }
+        }
+        String done = switch (action) {
+            case "enable" -> "enabled";
+            case "disable" -> "disabled";
+            default -> "reloaded";
+        };
+        plugin.getLogger().info("🔌 Plugin " + target.getName() + " " + done + " from the panel.");
+
+        JsonObject data = new JsonObject();
+        data.add("plugin", pluginEntry(serverRoot(), target));
+        return data;
+    }
+
+    private JsonObject appendChunk(JsonObject payload) throws IOException {
+        long offset = payload.has("offset") ? payload.get("offset").getAsLong() : 0L;
+        byte[] data = Base64.getDecoder().decode(string(payload, "data"));
+        File upload = uploadFile(string(payload, "upload_id"));
+        if (offset == 0) {
+            Files.createDirectories(uploadsFolder.toPath());
+            Files.deleteIfExists(upload.toPath());
+        }
+        long length = upload.length();
+        if (offset + data.length == length) {
+            return new JsonObject();
+        }
+        if (offset != length) {
+            throw new IllegalArgumentException("expected chunk at offset " + length);
+        }
+        try (OutputStream out = new FileOutputStream(upload, true)) {
+            out.write(data);
+        }
+        return new JsonObject();
+    }
+
+    private JsonObject install(JsonObject payload) throws IOException {
+        File upload = uploadFile(string(payload, "upload_id"));
+        if (!upload.isFile()) {
+            throw new IllegalArgumentException("no jar was uploaded");
+        }
+        Path root = serverRoot();
+        Path target = root.resolve(string(payload, "file")).normalize();
+        Path parent = target.getParent();
+        boolean staged = updateFolder().equals(parent);
+        if (!target.getFileName().toString().endsWith(".jar") || !(staged || pluginsFolder().equals(parent))) {
+            throw new IllegalArgumentException("jars can only be installed in the plugins or update folder");
+        }
+        if (!staged && Files.exists(target)) {
+            throw new IllegalArgumentException(relativePath(root, target) + " already exists");
+        }
+        String expected = string(payload, "sha256");
+        if (!sha256(upload.toPath()).equalsIgnoreCase(expected)) {
+            throw new IllegalArgumentException("uploaded jar does not match its checksum");
+        }
+
+        Files.createDirectories(parent);
+        Files.move(upload.toPath(), target, StandardCopyOption.REPLACE_EXISTING);
+        plugin.getLogger().info("🔌 " + (staged ? "Staged update " : "Installed ") + relativePath(root, target) + " from the panel; it takes effect on the next restart.");
+
+        JsonObject data = new JsonObject();
+        data.addProperty("file", relativePath(root, target));
+        return data;
+    }
+
+    private JsonObject pluginEntry(Path root, Plugin installed) {
+        PluginDescriptionFile description = installed.getDescription();
+        JsonObject entry = new JsonObject();
+        entry.addProperty("name", installed.getName());
+        entry.addProperty("version", description.getVersion());
+        entry.add("authors", array(description.getAuthors()));
+        entry.addProperty("description", description.getDescription() == null ? "" : description.getDescription());
+        entry.addProperty("enabled", installed.isEnabled());
+        entry.add("depend", array(description.getDepend()));
+        entry.add("softdepend", array(description.getSoftDepend()));
+        entry.addProperty("main", description.getMain());
+
+        Path jar = jarOf(installed);
+        if (jar != null && jar.startsWith(root)) {
+            entry.addProperty("file", relativePath(root, jar));
+            try {
+                entry.addProperty("sha256", jarInfo(jar).sha256());
+            } catch (IOException ignored) {
+                entry.addProperty("sha256", "");
+            }
+        } else {
+            entry.addProperty("file", "");
+            entry.addProperty("sha256", "");
+        }
+        return entry;
+    }
+
+    private void addJars(JsonArray jars, Path root, Path folder, boolean staged) throws IOException {
+        if (!Files.isDirectory(folder)) {
+            return;
+        }
+        try (Stream<Path> stream = Files.list(folder)) {
+            for (Path path : (Iterable<Path>) stream::iterator) {
+                if (!Files.isRegularFile(path) || !path.getFileName().toString().endsWith(".jar")) {
+                    continue;
+                }
+                JarInfo info;
+                try {
+                    info = jarInfo(path);
+                } catch (IOException ex) {
+                    plugin.getLogger().warning("Could not read " + path.getFileName() + ": " + ex.getMessage());
+                    continue;
+                }
+                JsonObject entry = new JsonObject();
+                entry.addProperty("file", relativePath(root, path));
+                entry.addProperty("size", info.size());
+                entry.addProperty("sha256", info.sha256());
+                entry.addProperty("mod_time", info.modified().toInstant().toString());
+                entry.addProperty("staged", staged);
+                entry.addProperty("name", info.name());
+                entry.addProperty("version", info.version());
+                jars.add(entry);
+            }
+        }
+    }
+
+    /** Hashes and reads the descriptor of a jar, reusing the last result while the file is unchanged. */
+    private JarInfo jarInfo(Path jar) throws IOException {
+        long size = Files.size(jar);
+        FileTime modified = Files.getLastModifiedTime(jar);
+        JarInfo cached = jarCache.get(jar);
+        if (cached != null && cached.size() == size && cached.modified().equals(modified)) {
+            return cached;
+        }
+
+        String name = "";
+        String version = "";
+        try (JarFile file = new JarFile(jar.toFile())) {
+            ZipEntry descriptor = file.getEntry("paper-plugin.yml");
+            if (descriptor == null) {
+                descriptor = file.getEntry("plugin.yml");
+            }
+            if (descriptor != null) {
+                try (Reader reader = new InputStreamReader(file.getInputStream(descriptor), StandardCharsets.UTF_8)) {
+                    YamlConfiguration yaml = YamlConfiguration.loadConfiguration(reader);
+                    name = yaml.getString("name", "");
+                    version = yaml.getString("version", "");
+                }
+            }
+        } catch (IOException ignored) {
+            // Not a readable zip; still worth listing with its hash.
+        }
+
+        JarInfo info = new JarInfo(size, modified, sha256(jar), name, version);
+        jarCache.put(jar, info);
+        return info;
+    }
+
+    private static Plugin findPlugin(String name) {
+        for (Plugin installed : Bukkit.getPluginManager().getPlugins()) {
+            if (installed.getName().equalsIgnoreCase(name)) {
+                return installed;
+            }
+        }
+        return null;
+    }
+
+    private static Path jarOf(Plugin installed) {
+        try {
+            var source = installed.getClass().getProtectionDomain().getCodeSource();
+            if (source == null) {
+                return null;
+            }
+            return Path.of(source.getLocation().toURI()).toRealPath();
+        } catch (Exception ex) {
+            return null;
+        }
+    }
+
+    private static String sha256(Path path) throws IOException {
+        MessageDigest digest;
+        try {
+            digest = MessageDigest.getInstance("SHA-256");
+        } catch (NoSuchAlgorithmException ex) {
+            throw new IOException(ex);
+        }
+        byte[] buffer = new byte[64 * 1024];
+        try (InputStream in = Files.newInputStream(path)) {
+            int read;
+            while ((read = in.read(buffer)) != -1) {
+                digest.update(buffer, 0, read);
+            }
+        }
+        return HexFormat.of().formatHex(digest.digest());
+    }
+
+    private File uploadFile(String uploadId) {
+        if (!UPLOAD_ID.matcher(uploadId).matches()) {
+            throw new IllegalArgumentException("invalid upload id");
+        }
+        return new File(uploadsFolder, uploadId + ".jar");
+    }
+
+    private Path pluginsFolder() throws IOException {
+        return plugin.getDataFolder().getParentFile().toPath().toRealPath();
+    }
+
+    private Path updateFolder() throws IOException {
+        Path folder = Bukkit.getUpdateFolderFile().toPath().toAbsolutePath().normalize();
+        return Files.exists(folder) ? folder.toRealPath() : folder;
+    }
+
+    private Path serverRoot() throws IOException {
+        File pluginsDir = plugin.getDataFolder().getParentFile();
+        File root = pluginsDir != null ? pluginsDir.getParentFile() : null;
+        if (root == null) {
+            root = new File(".");
+        }
+        return root.toPath().toRealPath();
+    }
+
+    private static String relativePath(Path root, Path path) {
+        return root.relativize(path).toString().replace('\\', '/');
+    }
+
+    private static JsonArray array(List<String> values) {
+        JsonArray array = new JsonArray();
+        if (values != null) {
+            values.forEach(array::add);
+        }
+        return array;
+    }
+
+    private static String string(JsonObject payload, String key) {
+        return payload.has(key) && !payload.get(key).isJsonNull() ? payload.get(key).getAsString().trim() : "";
+    }
+}
@@ -0,0 +1,150 @@
+package net.trybeacon.plugin.roster;
+
+import com.google.gson.JsonArray;
+import com.google.gson.JsonElement;
+import com.google.gson.JsonObject;
+import org.bukkit.Bukkit;
+import org.bukkit.OfflinePlayer;
+import org.bukkit.entity.Player;
+
+import java.nio.charset.StandardCharsets;
+import java.util.Collection;
+import java.util.UUID;
+
+/**
+ * Manages the whitelist and operator list for the panel. Names are resolved
+ * from the server's own caches only, never through an online lookup, so a
+ * player on an online-mode server must have joined before (or be passed with
+ * a UUID the backend already knows).
+ */
+public class RosterService {
+
+    /**
+     * Must be called on the main thread.
+     */
+    public JsonObject performAction(JsonObject payload) {
+        String action = payload.has("action") ? payload.get("action").getAsString() : "";
+        JsonObject data = new JsonObject();
+        switch (action) {
+            case "list" -> {
+                data.addProperty("whitelist_enabled", Bukkit.hasWhitelist());
+                data.add("whitelist", entries(Bukkit.getWhitelistedPlayers()));
+                data.add("ops", entries(Bukkit.getOperators()));
+            }
+            case "whitelist_toggle" -> {
+                boolean enabled = payload.has("enabled") && payload.get("enabled").getAsBoolean();
+                Bukkit.setWhitelist(enabled);
+                if (enabled && Bukkit.isWhitelistEnforced()) {
+                    for (Player player : Bukkit.getOnlinePlayers()) {
+                        if (!player.isWhitelisted() && !player.isOp()) {
+                            player.kickPlayer("You are not whitelisted on this server.");
+                        }
+                    }
+                }
+                data.addProperty("whitelist_enabled", enabled);
+            }
+            case "whitelist_add", "whitelist_remove", "op_add", "op_remove" -> data.add("results", apply(action, payload));
+            default -> throw new IllegalArgumentException("unsupported roster action");
+        }
+        return data;
+    }
+
+    private JsonArray apply(String action, JsonObject payload) {
+        JsonArray results = new JsonArray();
+        JsonArray players = payload.has("players") ? payload.getAsJsonArray("players") : new JsonArray();
+        for (JsonElement element : players) {
+            JsonObject entry = element.getAsJsonObject();
+            String name = entry.has("name") ? entry.get("name").getAsString() : "";
+            String uuid = entry.has("uuid") ? entry.get("uuid").getAsString() : "";
+
+            JsonObject result = new JsonObject();
+            result.addProperty("name", name);
+            OfflinePlayer target = resolve(name, uuid);
+            if (target == null) {
+                result.addProperty("ok", false);
+                result.addProperty("error", "unknown player; they must join the server once first");
+                results.add(result);
+                continue;
+            }
+            result.addProperty("uuid", target.getUniqueId().toString());
+            if (target.getName() != null) {
+                result.addProperty("name", target.getName());
+            }
+
+            String error = switch (action) {
+                case "whitelist_add" -> {
+                    if (target.isWhitelisted()) {
+                        yield "already whitelisted";
+                    }
+                    target.setWhitelisted(true);
+                    yield null;
+                }
+                case "whitelist_remove" -> {
+                    if (!target.isWhitelisted()) {
+                        yield "not whitelisted";
+                    }
+                    target.setWhitelisted(false);
+                    yield null;
+                }
+                case "op_add" -> {
+                    if (target.isOp()) {
+                        yield "already an operator";
+                    }
+                    target.setOp(true);
+                    yield null;
+                }
+                default -> {
+                    if (!target.isOp()) {
+                        yield "not an operator";
+                    }
+                    target.setOp(false);
+                    yield null;
+                }
+            };
+            result.addProperty("ok", error == null);
+            if (error != null) {
+                result.addProperty("error", error);
+            }
+            results.add(result);
+        }
+        return results;
+    }
+
+    private OfflinePlayer resolve(String name, String uuid) {
+        if (!uuid.isEmpty()) {
+            try {
+                return Bukkit.getOfflinePlayer(UUID.fromString(uuid));
+            } catch (IllegalArgumentException ignored) {
+                // fall back to the name
+            }
+        }
+        if (name.isEmpty()) {
+            return null;
+        }
+        Player online = Bukkit.getPlayerExact(name);
+        if (online != null) {
+            return online;
+        }
+        OfflinePlayer cached = Bukkit.getOfflinePlayerIfCached(name);
+        if (cached != null) {
+            return cached;
+        }
+        if (!Bukkit.getOnlineMode()) {
+            // Offline-mode servers derive UUIDs from the name.
+            UUID offline = UUID.nameUUIDFromBytes(("OfflinePlayer:" + name).getBytes(StandardCharsets.UTF_8));
+            return Bukkit.getOfflinePlayer(offline);
+        }
+        return null;
+    }
+
+    private static JsonArray entries(Collection<OfflinePlayer> players) {
+        JsonArray out = new JsonArray();
+        for (OfflinePlayer player : players) {
+            JsonObject entry = new JsonObject();
+            entry.addProperty("uuid", player.getUniqueId().toString());
+            entry.addProperty("name", player.getName() == null ? "" : player.getName());
+            out.add(entry);
+        }
+        return out;
+    }
+}
@@ -0,0 +1,99 @@
+package net.trybeacon.plugin.tasks;
+
+import com.google.gson.JsonArray;
+import com.google.gson.JsonObject;
+
+import java.io.File;
+import java.io.IOException;
+import java.nio.file.FileStore;
+import java.nio.file.FileVisitResult;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.nio.file.SimpleFileVisitor;
+import java.nio.file.attribute.BasicFileAttributes;
+import java.util.List;
+
+/**
+ * Measures the world and backup folders and the space left on the disk that
+ * holds them. Walking the folders is slow on big worlds, so this runs off the
+ * main thread every few minutes and the stats task sends the last result.
+ */
+public class DiskUsageTask implements Runnable {
+
+    private final File worldContainer;
+    private final List<String> backupFolders;
+    private volatile JsonObject latest;
+
+    public DiskUsageTask(File worldContainer, List<String> backupFolders) {
+        this.worldContainer = worldContainer;
+        this.backupFolders = backupFolders;
+    }
+
+    /**
+     * Returns the last measurement, or null before the first one finishes.
+     */
+    public JsonObject latest() {
+        return latest;
+    }
+
+    @Override
+    public void run() {
+        JsonArray folders = new JsonArray();
+        File[] files = worldContainer.listFiles();
+        if (files != null) {
+            for (File file : files) {
+                if (file.isDirectory() && new File(file, "level.dat").exists()) {
+                    folders.add(folder(file.getName(), "world", file.toPath()));
+                }
+            }
+        }
+        for (String name : backupFolders) {
+            Path path = worldContainer.toPath().resolve(name);
+            if (Files.isDirectory(path)) {
+                folders.add(folder(name, "backup", path));
+            }
+        }
+
+        JsonObject disk = new JsonObject();
+        try {
+            FileStore store = Files.getFileStore(worldContainer.toPath());
+            disk.addProperty("total", store.getTotalSpace());
+            disk.addProperty("free", store.getUsableSpace());
+        } catch (IOException ex) {
+            disk.addProperty("total", 0);
+            disk.addProperty("free", 0);
+        }
+        disk.add("folders", folders);
+        latest = disk;
+    }
+
+    private static JsonObject folder(String name, String kind, Path path) {
+        JsonObject folder = new JsonObject();
+        folder.addProperty("name", name);
+        folder.addProperty("kind", kind);
+        folder.addProperty("bytes", size(path));
+        return folder;
+    }
+
+    private static long size(Path root) {
+        long[] total = {0};
+        try {
+            Files.walkFileTree(root, new SimpleFileVisitor<>() {
+                @Override
+                public FileVisitResult visitFile(Path file, BasicFileAttributes attrs) {
+                    total[0] += attrs.size();
+                    return FileVisitResult.CONTINUE;
+                }
+
+                @Override
+                public FileVisitResult visitFileFailed(Path file, IOException exc) {
+                    // Region files can vanish or be locked mid-walk; skip them.
+                    return FileVisitResult.CONTINUE;
+                }
+            });
+        } catch (IOException ex) {
+            // Report what was counted before the walk failed.
+        }
+        return total[0];
+    }
+}
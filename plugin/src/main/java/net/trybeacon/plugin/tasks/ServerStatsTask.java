@@ -4,19 +4,30 @@ import com.google.gson.JsonArray;
 import com.google.gson.JsonObject;
 import org.bukkit.Bukkit;
 import org.bukkit.GameRule;
+import org.bukkit.Location;
 import org.bukkit.Registry;
 import org.bukkit.Statistic;
+import org.bukkit.WorldBorder;
 import org.bukkit.entity.Player;
-import org.java_websocket.client.WebSocketClient;
+import net.trybeacon.plugin.listeners.TickTimeListener;
+import net.trybeacon.plugin.websocket.BackendClient;
 
 import java.io.File;
+import java.lang.management.GarbageCollectorMXBean;
+import java.lang.management.ManagementFactory;
+import java.lang.management.OperatingSystemMXBean;
+import java.util.Locale;
 
 public class ServerStatsTask implements Runnable {
 
-    private final WebSocketClient webSocketClient;
+    private final BackendClient webSocketClient;
+    private final TickTimeListener tickTimes;
+    private final DiskUsageTask diskUsage;
 
-    public ServerStatsTask(WebSocketClient webSocketClient) {
+    public ServerStatsTask(BackendClient webSocketClient, TickTimeListener tickTimes, DiskUsageTask diskUsage) {
         this.webSocketClient = webSocketClient;
+        this.tickTimes = tickTimes;
+        this.diskUsage = diskUsage;
     }
 
     @Override
@@ -29,12 +40,44 @@ public class ServerStatsTask implements Runnable {
         JsonObject payload = new JsonObject();
         payload.addProperty("players", Bukkit.getOnlinePlayers().size());
         payload.addProperty("max_players", Bukkit.getMaxPlayers());
-        payload.addProperty("tps", String.format("%.2f", Math.min(20.0, Bukkit.getServer().getTPS()[0])));
+        double[] tps = Bukkit.getServer().getTPS();
+        payload.addProperty("tps", String.format(Locale.ROOT, "%.2f", Math.min(20.0, tps[0])));
+        payload.addProperty("tps_1m", roundTps(tps[0]));
+        payload.addProperty("tps_5m", roundTps(tps[1]));
+        payload.addProperty("tps_15m", roundTps(tps[2]));
+        JsonObject mspt = tickTimes.summary();
+        if (mspt != null) {
+            payload.add("mspt", mspt);
+        }
         
         Runtime runtime = Runtime.getRuntime();
         payload.addProperty("ram_used", (runtime.totalMemory() - runtime.freeMemory()) / 1048576L);
         payload.addProperty("ram_max", runtime.maxMemory() / 1048576L);
 
+        JsonObject cpu = new JsonObject();
+        OperatingSystemMXBean os = ManagementFactory.getOperatingSystemMXBean();
+        if (os instanceof com.sun.management.OperatingSystemMXBean load) {
+            addPercent(cpu, "process", load.getProcessCpuLoad());
+            addPercent(cpu, "system", load.getCpuLoad());
+        }
+        payload.add("cpu", cpu);
+
+        JsonArray gcArray = new JsonArray();
+        for (GarbageCollectorMXBean gc : ManagementFactory.getGarbageCollectorMXBeans()) {
+            JsonObject gcObj = new JsonObject();
+            gcObj.addProperty("name", gc.getName());
+            gcObj.addProperty("count", Math.max(0, gc.getCollectionCount()));
+            gcObj.addProperty("time_ms", Math.max(0, gc.getCollectionTime()));
+            gcArray.add(gcObj);
+        }
+        payload.add("gc", gcArray);
+        payload.addProperty("threads", ManagementFactory.getThreadMXBean().getThreadCount());
+
+        JsonObject disk = diskUsage.latest();
+        if (disk != null) {
+            payload.add("disk", disk);
+        }
+
         JsonArray playerArray = new JsonArray();
         for (Player p : Bukkit.getOnlinePlayers()) {
             JsonObject playerObj = new JsonObject();
@@ -62,6 +105,21 @@ public class ServerStatsTask implements Runnable {
             worldObj.addProperty("storming", w.hasStorm());
             worldObj.addProperty("difficulty", w.getDifficulty().name());
             worldObj.addProperty("seed", String.valueOf(w.getSeed()));
+            worldObj.addProperty("pvp", w.getPVP());
+
+            WorldBorder border = w.getWorldBorder();
+            JsonObject borderObj = new JsonObject();
+            borderObj.addProperty("center_x", border.getCenter().getX());
+            borderObj.addProperty("center_z", border.getCenter().getZ());
+            borderObj.addProperty("size", border.getSize());
+            worldObj.add("border", borderObj);
+
+            Location spawn = w.getSpawnLocation();
+            JsonObject spawnObj = new JsonObject();
+            spawnObj.addProperty("x", spawn.getX());
+            spawnObj.addProperty("y", spawn.getY());
+            spawnObj.addProperty("z", spawn.getZ());
+            worldObj.add("spawn", spawnObj);
             
             // Current Gamerules
             JsonObject gamerulesObj = new JsonObject();
@@ -119,18 +177,23 @@ public class ServerStatsTask implements Runnable {
         payload.add("default_gamerules", defaultGamerulesObj);
         
         // Send world stats packet
-        JsonObject worldJson = new JsonObject();
-        worldJson.addProperty("event", "world_stats");
-        worldJson.add("payload", worldArray);
-        webSocketClient.send(worldJson.toString());
+        webSocketClient.sendEvent("world_stats", worldArray);
 
         payload.add("player_list", playerArray);
 
         // Send server stats packet
-        JsonObject rootJson = new JsonObject();
-        rootJson.addProperty("event", "server_stats");
-        rootJson.add("payload", payload);
+        webSocketClient.sendEvent("server_stats", payload);
+    }
 
-        webSocketClient.send(rootJson.toString());
+    private static double roundTps(double tps) {
+        return Math.round(Math.min(20.0, tps) * 100) / 100.0;
+    }
+
+    // The JVM reports load as a fraction, or a negative number when it
+    // cannot measure it.
+    private static void addPercent(JsonObject target, String key, double load) {
+        if (load >= 0) {
+            target.addProperty(key, Math.round(load * 1000) / 10.0);
+        }
     }
 }
\ No newline at end of file
@@ -1,5 +1,6 @@
 package net.trybeacon.plugin.websocket;
 
+import com.google.gson.JsonElement;
 import com.google.gson.JsonObject;
 import com.google.gson.JsonParser;
 import com.google.gson.JsonArray;
@@ -7,37 +8,74 @@ import net.trybeacon.plugin.BeaconPlugin;
 import net.trybeacon.plugin.files.FileManagerService;
 import net.trybeacon.plugin.permissions.VaultPermissionService;
 import org.bukkit.Bukkit;
+import org.bukkit.Difficulty;
+import org.bukkit.Location;
+import org.bukkit.WorldBorder;
 import org.bukkit.command.CommandMap;
 import org.bukkit.WorldCreator;
 import org.bukkit.entity.Player;
 import org.bukkit.permissions.PermissionAttachmentInfo;
-import org.java_websocket.client.WebSocketClient;
-import org.java_websocket.handshake.ServerHandshake;
+import org.java_websocket.WebSocket;
 
 import java.io.File;
-import java.net.URI;
+import java.io.IOException;
 import java.util.ArrayList;
 import java.util.List;
 import java.util.Locale;
 import java.util.Map;
+import java.util.Set;
 import java.util.UUID;
+import java.util.concurrent.ConcurrentHashMap;
+
+/**
+ * One protocol session with the backend. The socket underneath comes either
+ * from {@link BackendDialer} (the plugin connects out) or from
+ * {@link BackendListener} (the backend connects in); everything above the
+ * socket behaves the same.
+ */
+public class BackendClient {
 
-public class BackendClient extends WebSocketClient {
-    
     private final BeaconPlugin plugin;
+    private final WebSocket connection;
     private final FileManagerService fileManagerService;
+    // Request IDs the backend is still waiting on; removed on completion or rpc_cancel.
+    private final Set<String> inFlightRequests = ConcurrentHashMap.newKeySet();
+    // Set once the backend agrees to MessagePack for high-frequency events.
+    private volatile boolean binaryFrames;
 
-    public BackendClient(URI serverUri, BeaconPlugin plugin) {
-        super(serverUri);
+    public BackendClient(BeaconPlugin plugin, WebSocket connection) {
         this.plugin = plugin;
+        this.connection = connection;
         this.fileManagerService = new FileManagerService(plugin);
     }
 
-    @Override
-    public void onOpen(ServerHandshake handshakeData) {
+    public WebSocket getConnection() {
+        return connection;
+    }
+
+    public boolean isOpen() {
+        return connection.isOpen();
+    }
+
+    public void close() {
+        connection.close();
+    }
+
+    public void handleOpen() {
         plugin.getLogger().info("✅ Connected successfully to Go Backend!");
         plugin.onBackendConnected(this);
 
+        JsonObject helloPayload = new JsonObject();
+        helloPayload.addProperty("protocol_version", Protocol.VERSION);
+        helloPayload.addProperty("plugin_version", plugin.getDescription().getVersion());
+        helloPayload.add("capabilities", Protocol.capabilitiesJson());
+        JsonArray encodings = new JsonArray();
+        if (plugin.isBackendBinaryFramesEnabled()) {
+            encodings.add(Protocol.ENCODING_MSGPACK);
+        }
+        helloPayload.add("encodings", encodings);
+        sendEvent("hello", helloPayload);
+
         JsonObject envPayload = new JsonObject();
         envPayload.addProperty("software", Bukkit.getName() + " " + Bukkit.getVersion());
         envPayload.addProperty("java", "Java " + System.getProperty("java.version"));
@@ -47,7 +85,7 @@ public class BackendClient extends WebSocketClient {
         envJson.addProperty("event", "server_env");
         envJson.add("payload", envPayload);
 
-        this.send(envJson.toString());
+        connection.send(envJson.toString());
 
         JsonObject pathPayload = new JsonObject();
         pathPayload.addProperty("plugin_data_dir", plugin.getDataFolder().getAbsolutePath());
@@ -55,15 +93,18 @@ public class BackendClient extends WebSocketClient {
         JsonObject pathJson = new JsonObject();
         pathJson.addProperty("event", "plugin_paths");
         pathJson.add("payload", pathPayload);
-        this.send(pathJson.toString());
+        connection.send(pathJson.toString());
     }
 
-    @Override
-    public void onMessage(String message) { 
+    public void handleMessage(String message) {
         try {
             JsonObject json = JsonParser.parseString(message).getAsJsonObject();
             String event = json.has("event") ? json.get("event").getAsString() : "";
             
+            if (event.equals("hello_ack")) {
+                handleHelloAck(json.getAsJsonObject("payload"));
+            }
+
             if (event.equals("console_command")) {
                 String command = json.get("command").getAsString();
                 Bukkit.getScheduler().runTask(plugin, () -> {
@@ -91,7 +132,7 @@ public class BackendClient extends WebSocketClient {
                     JsonObject envelope = new JsonObject();
                     envelope.addProperty("event", "console_tab_complete_result");
                     envelope.add("payload", payload);
-                    send(envelope.toString());
+                    connection.send(envelope.toString());
                 });
             }
 
@@ -139,6 +180,30 @@ public class BackendClient extends WebSocketClient {
                                 world.setGameRuleValue(payload.get("rule").getAsString(), payload.get("value").getAsString());
                             }
                             break;
+                        case "set_difficulty":
+                            world.setDifficulty(Difficulty.valueOf(payload.get("difficulty").getAsString().toUpperCase(Locale.ROOT)));
+                            break;
+                        case "set_spawn": {
+                            double x = payload.get("x").getAsDouble();
+                            double z = payload.get("z").getAsDouble();
+                            double y = payload.has("y")
+                                    ? payload.get("y").getAsDouble()
+                                    : world.getHighestBlockYAt((int) Math.floor(x), (int) Math.floor(z)) + 1;
+                            world.setSpawnLocation(new Location(world, x, y, z));
+                            break;
+                        }
+                        case "set_border": {
+                            WorldBorder border = world.getWorldBorder();
+                            if (payload.has("center_x") && payload.has("center_z")) {
+                                border.setCenter(payload.get("center_x").getAsDouble(), payload.get("center_z").getAsDouble());
+                            }
+                            long seconds = payload.has("transition_seconds") ? payload.get("transition_seconds").getAsLong() : 0L;
+                            border.setSize(payload.get("size").getAsDouble(), seconds);
+                            break;
+                        }
+                        case "set_pvp":
+                            world.setPVP(payload.get("pvp").getAsBoolean());
+                            break;
                         case "unload":
                             evacuateWorld(world);
                             Bukkit.unloadWorld(world, true);
@@ -185,32 +250,89 @@ public class BackendClient extends WebSocketClient {
 
             if (event.equals("file_manager_request")) {
                 JsonObject payload = json.getAsJsonObject("payload");
+                trackRequest(payload);
                 Bukkit.getScheduler().runTaskAsynchronously(plugin, () -> handleFileManagerRequest(payload));
             }
 
             if (event.equals("player_permissions_request")) {
                 JsonObject payload = json.getAsJsonObject("payload");
+                trackRequest(payload);
                 Bukkit.getScheduler().runTask(plugin, () -> handlePlayerPermissionsRequest(payload));
             }
 
             if (event.equals("permission_admin_request")) {
                 JsonObject payload = json.getAsJsonObject("payload");
+                trackRequest(payload);
                 Bukkit.getScheduler().runTask(plugin, () -> handlePermissionAdminRequest(payload));
             }
 
+            if (event.equals("moderation_request")) {
+                JsonObject payload = json.getAsJsonObject("payload");
+                trackRequest(payload);
+                Bukkit.getScheduler().runTask(plugin, () -> handleModerationRequest(payload));
+            }
+
+            if (event.equals("roster_request")) {
+                JsonObject payload = json.getAsJsonObject("payload");
+                trackRequest(payload);
+                Bukkit.getScheduler().runTask(plugin, () -> handleRosterRequest(payload));
+            }
+
+            if (event.equals("world_manage_request")) {
+                JsonObject payload = json.getAsJsonObject("payload");
+                trackRequest(payload);
+                Bukkit.getScheduler().runTask(plugin, () -> handleWorldManageRequest(payload));
+            }
+
+            if (event.equals("gamerules_request")) {
+                JsonObject payload = json.getAsJsonObject("payload");
+                trackRequest(payload);
+                Bukkit.getScheduler().runTask(plugin, () -> handleGamerulesRequest(payload));
+            }
+
+            if (event.equals("hotspots_request")) {
+                JsonObject payload = json.getAsJsonObject("payload");
+                trackRequest(payload);
+                Bukkit.getScheduler().runTask(plugin, () -> handleHotspotsRequest(payload));
+            }
+
+            if (event.equals("diagnostics_request")) {
+                JsonObject payload = json.getAsJsonObject("payload");
+                trackRequest(payload);
+                Bukkit.getScheduler().runTaskAsynchronously(plugin, () -> handleDiagnosticsRequest(payload));
+            }
+
+            if (event.equals("plugins_request")) {
+                JsonObject payload = json.getAsJsonObject("payload");
+                trackRequest(payload);
+                Bukkit.getScheduler().runTaskAsynchronously(plugin, () -> handlePluginsRequest(payload));
+            }
+
+            if (event.equals("profiler_request")) {
+                JsonObject payload = json.getAsJsonObject("payload");
+                trackRequest(payload);
+                Bukkit.getScheduler().runTaskAsynchronously(plugin, () -> handleProfilerRequest(payload));
+            }
+
+            if (event.equals("rpc_cancel")) {
+                JsonObject payload = json.getAsJsonObject("payload");
+                if (payload != null && payload.has("request_id")) {
+                    inFlightRequests.remove(payload.get("request_id").getAsString());
+                }
+            }
+
         } catch (Exception e) {
             // Ignore messages that aren't valid JSON
         }
     }
 
-    @Override
-    public void onClose(int code, String reason, boolean remote) {
+    public void handleClose(String reason) {
         plugin.getLogger().warning("❌ Disconnected from backend. Reason: " + reason);
+        inFlightRequests.clear();
         plugin.onBackendDisconnected(this);
     }
 
-    @Override
-    public void onError(Exception ex) {
+    public void handleError(Exception ex) {
         plugin.getLogger().severe("⚠️ WebSocket error: " + ex.getMessage());
         plugin.onBackendError(this, ex);
     }
@@ -245,6 +367,9 @@ public class BackendClient extends WebSocketClient {
     private void handleFileManagerRequest(JsonObject payload) {
         JsonObject responsePayload = new JsonObject();
         String requestId = payload.has("request_id") ? payload.get("request_id").getAsString() : "";
+        if (!inFlightRequests.contains(requestId)) {
+            return;
+        }
         responsePayload.addProperty("request_id", requestId);
 
         try {
@@ -260,15 +385,167 @@ public class BackendClient extends WebSocketClient {
             responsePayload.addProperty("error", ex.getMessage() == null ? "file operation failed" : ex.getMessage());
         }
 
-        JsonObject envelope = new JsonObject();
-        envelope.addProperty("event", "file_manager_response");
-        envelope.add("payload", responsePayload);
-        this.send(envelope.toString());
+        sendResponse("file_manager_response", responsePayload);
+    }
+
+    private void handleModerationRequest(JsonObject payload) {
+        String requestId = payload.has("request_id") ? payload.get("request_id").getAsString() : "";
+        if (!inFlightRequests.contains(requestId)) {
+            return;
+        }
+
+        JsonObject responsePayload = new JsonObject();
+        responsePayload.addProperty("request_id", requestId);
+        try {
+            JsonObject data = plugin.getModerationService().performAction(payload);
+            responsePayload.addProperty("ok", true);
+            responsePayload.add("data", data);
+        } catch (Exception ex) {
+            responsePayload.addProperty("ok", false);
+            responsePayload.addProperty("error", ex.getMessage() == null ? "moderation action failed" : ex.getMessage());
+        }
+        sendResponse("moderation_response", responsePayload);
+    }
+
+    private void handleRosterRequest(JsonObject payload) {
+        String requestId = payload.has("request_id") ? payload.get("request_id").getAsString() : "";
+        if (!inFlightRequests.contains(requestId)) {
+            return;
+        }
+
+        JsonObject responsePayload = new JsonObject();
+        responsePayload.addProperty("request_id", requestId);
+        try {
+            JsonObject data = plugin.getRosterService().performAction(payload);
+            responsePayload.addProperty("ok", true);
+            responsePayload.add("data", data);
+        } catch (Exception ex) {
+            responsePayload.addProperty("ok", false);
+            responsePayload.addProperty("error", ex.getMessage() == null ? "roster action failed" : ex.getMessage());
+        }
+        sendResponse("roster_response", responsePayload);
+    }
+
+    private void handleWorldManageRequest(JsonObject payload) {
+        String requestId = payload.has("request_id") ? payload.get("request_id").getAsString() : "";
+        if (!inFlightRequests.contains(requestId)) {
+            return;
+        }
+
+        JsonObject responsePayload = new JsonObject();
+        responsePayload.addProperty("request_id", requestId);
+        try {
+            JsonObject data = plugin.getWorldManagementService().performAction(payload);
+            responsePayload.addProperty("ok", true);
+            responsePayload.add("data", data);
+        } catch (Exception ex) {
+            responsePayload.addProperty("ok", false);
+            responsePayload.addProperty("error", ex.getMessage() == null ? "world action failed" : ex.getMessage());
+        }
+        sendResponse("world_manage_response", responsePayload);
+    }
+
+    private void handleGamerulesRequest(JsonObject payload) {
+        String requestId = payload.has("request_id") ? payload.get("request_id").getAsString() : "";
+        if (!inFlightRequests.contains(requestId)) {
+            return;
+        }
+
+        JsonObject responsePayload = new JsonObject();
+        responsePayload.addProperty("request_id", requestId);
+        try {
+            JsonObject data = plugin.getGameruleService().performAction(payload);
+            responsePayload.addProperty("ok", true);
+            responsePayload.add("data", data);
+        } catch (Exception ex) {
+            responsePayload.addProperty("ok", false);
+            responsePayload.addProperty("error", ex.getMessage() == null ? "gamerules action failed" : ex.getMessage());
+        }
+        sendResponse("gamerules_response", responsePayload);
+    }
+
+    private void handleHotspotsRequest(JsonObject payload) {
+        String requestId = payload.has("request_id") ? payload.get("request_id").getAsString() : "";
+        if (!inFlightRequests.contains(requestId)) {
+            return;
+        }
+
+        JsonObject responsePayload = new JsonObject();
+        responsePayload.addProperty("request_id", requestId);
+        try {
+            JsonObject data = plugin.getHotspotService().scan(payload);
+            responsePayload.addProperty("ok", true);
+            responsePayload.add("data", data);
+        } catch (Exception ex) {
+            responsePayload.addProperty("ok", false);
+            responsePayload.addProperty("error", ex.getMessage() == null ? "hotspot scan failed" : ex.getMessage());
+        }
+        sendResponse("hotspots_response", responsePayload);
+    }
+
+    private void handleDiagnosticsRequest(JsonObject payload) {
+        String requestId = payload.has("request_id") ? payload.get("request_id").getAsString() : "";
+        if (!inFlightRequests.contains(requestId)) {
+            return;
+        }
+
+        JsonObject responsePayload = new JsonObject();
+        responsePayload.addProperty("request_id", requestId);
+        try {
+            JsonObject data = plugin.getDiagnosticsService().perform(payload);
+            responsePayload.addProperty("ok", true);
+            responsePayload.add("data", data);
+        } catch (Exception ex) {
+            responsePayload.addProperty("ok", false);
+            responsePayload.addProperty("error", ex.getMessage() == null ? "diagnostics request failed" : ex.getMessage());
+        }
+        sendResponse("diagnostics_response", responsePayload);
+    }
+
+    private void handlePluginsRequest(JsonObject payload) {
+        String requestId = payload.has("request_id") ? payload.get("request_id").getAsString() : "";
+        if (!inFlightRequests.contains(requestId)) {
+            return;
+        }
+
+        JsonObject responsePayload = new JsonObject();
+        responsePayload.addProperty("request_id", requestId);
+        try {
+            JsonObject data = plugin.getPluginInventoryService().perform(payload);
+            responsePayload.addProperty("ok", true);
+            responsePayload.add("data", data);
+        } catch (Exception ex) {
+            responsePayload.addProperty("ok", false);
+            responsePayload.addProperty("error", ex.getMessage() == null ? "plugins request failed" : ex.getMessage());
+        }
+        sendResponse("plugins_response", responsePayload);
+    }
+
+    private void handleProfilerRequest(JsonObject payload) {
+        String requestId = payload.has("request_id") ? payload.get("request_id").getAsString() : "";
+        if (!inFlightRequests.contains(requestId)) {
+            return;
+        }
+
+        JsonObject responsePayload = new JsonObject();
+        responsePayload.addProperty("request_id", requestId);
+        try {
+            JsonObject data = plugin.getProfilerService().perform(payload);
+            responsePayload.addProperty("ok", true);
+            responsePayload.add("data", data);
+        } catch (Exception ex) {
+            responsePayload.addProperty("ok", false);
+            responsePayload.addProperty("error", ex.getMessage() == null ? "profiler request failed" : ex.getMessage());
+        }
+        sendResponse("profiler_response", responsePayload);
     }
 
     private void handlePlayerPermissionsRequest(JsonObject payload) {
         String requestId = payload.has("request_id") ? payload.get("request_id").getAsString() : "";
         String playerUUID = payload.has("player_uuid") ? payload.get("player_uuid").getAsString() : "";
+        if (!inFlightRequests.contains(requestId)) {
+            return;
+        }
 
         JsonObject responsePayload = new JsonObject();
         responsePayload.addProperty("request_id", requestId);
@@ -298,25 +575,64 @@ public class BackendClient extends WebSocketClient {
             responsePayload.add("permissions", permissions);
         }
 
-        sendEvent("player_permissions_response", responsePayload);
+        sendResponse("player_permissions_response", responsePayload);
     }
 
-    public boolean sendEvent(String eventName, JsonObject payload) {
-        if (!this.isOpen()) {
+    public boolean sendEvent(String eventName, JsonElement payload) {
+        if (!connection.isOpen()) {
             return false;
         }
         JsonObject envelope = new JsonObject();
         envelope.addProperty("event", eventName);
         envelope.add("payload", payload);
-        this.send(envelope.toString());
+        if (binaryFrames && Protocol.BINARY_EVENTS.contains(eventName)) {
+            try {
+                connection.send(MessagePackEncoder.encode(envelope));
+                return true;
+            } catch (IOException ex) {
+                plugin.getLogger().fine("MessagePack encoding failed, sending JSON: " + ex.getMessage());
+            }
+        }
+        connection.send(envelope.toString());
         return true;
     }
 
+    private void handleHelloAck(JsonObject payload) {
+        if (payload == null) {
+            return;
+        }
+        int version = payload.has("protocol_version") ? payload.get("protocol_version").getAsInt() : 0;
+        binaryFrames = payload.has("encoding") && Protocol.ENCODING_MSGPACK.equals(payload.get("encoding").getAsString());
+        if (version < Protocol.VERSION) {
+            plugin.getLogger().warning("⚠️ Backend speaks protocol v" + version + " (plugin v" + Protocol.VERSION + "); some features may be unavailable until the backend is updated.");
+        }
+    }
+
+    private void trackRequest(JsonObject payload) {
+        if (payload != null && payload.has("request_id")) {
+            inFlightRequests.add(payload.get("request_id").getAsString());
+        }
+    }
+
+    /**
+     * Answers a backend request unless the backend cancelled it in the meantime.
+     */
+    private void sendResponse(String eventName, JsonObject payload) {
+        String requestId = payload.has("request_id") ? payload.get("request_id").getAsString() : "";
+        if (!inFlightRequests.remove(requestId)) {
+            return;
+        }
+        sendEvent(eventName, payload);
+    }
+
     private void handlePermissionAdminRequest(JsonObject payload) {
         String requestId = payload.has("request_id") ? payload.get("request_id").getAsString() : "";
         String action = payload.has("action") ? payload.get("action").getAsString() : "";
         String playerUUIDRaw = payload.has("player_uuid") ? payload.get("player_uuid").getAsString() : "";
         String playerName = payload.has("player_name") ? payload.get("player_name").getAsString() : "";
+        if (!inFlightRequests.contains(requestId)) {
+            return;
+        }
 
         JsonObject responsePayload = new JsonObject();
         responsePayload.addProperty("request_id", requestId);
@@ -327,7 +643,7 @@ public class BackendClient extends WebSocketClient {
         if (permissionService == null || !permissionService.isReady()) {
             responsePayload.addProperty("ok", false);
             responsePayload.addProperty("error", "vault permission provider unavailable");
-            sendEvent("permission_admin_response", responsePayload);
+            sendResponse("permission_admin_response", responsePayload);
             return;
         }
 
@@ -356,7 +672,7 @@ public class BackendClient extends WebSocketClient {
 
                 responsePayload.addProperty("ok", true);
                 responsePayload.add("permissions", permissionsJson);
-                sendEvent("permission_admin_response", responsePayload);
+                sendResponse("permission_admin_response", responsePayload);
                 return;
             }
 
@@ -369,17 +685,17 @@ public class BackendClient extends WebSocketClient {
                 if (!changed) {
                     responsePayload.addProperty("error", "permission backend rejected update");
                 }
-                sendEvent("permission_admin_response", responsePayload);
+                sendResponse("permission_admin_response", responsePayload);
                 return;
             }
 
             responsePayload.addProperty("ok", false);
             responsePayload.addProperty("error", "unsupported permission admin action");
-            sendEvent("permission_admin_response", responsePayload);
+            sendResponse("permission_admin_response", responsePayload);
         } catch (Exception ex) {
             responsePayload.addProperty("ok", false);
             responsePayload.addProperty("error", ex.getMessage() == null ? "permission action failed" : ex.getMessage());
-            sendEvent("permission_admin_response", responsePayload);
+            sendResponse("permission_admin_response", responsePayload);
         }
     }
 
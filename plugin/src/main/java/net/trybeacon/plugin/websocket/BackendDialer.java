@@ -0,0 +1,48 @@
+package net.trybeacon.plugin.websocket;
+
+import net.trybeacon.plugin.BeaconPlugin;
+import org.java_websocket.client.WebSocketClient;
+import org.java_websocket.handshake.ServerHandshake;
+
+import java.net.URI;
+
+/**
+ * Transport for the default mode: the plugin connects out to the backend.
+ */
+public class BackendDialer extends WebSocketClient {
+
+    private final BackendClient session;
+
+    public BackendDialer(URI serverUri, BeaconPlugin plugin) {
+        super(serverUri, Protocol.draft(plugin.isBackendCompressionEnabled()));
+        this.session = new BackendClient(plugin, this);
+        String secret = plugin.getBackendSecret();
+        if (!secret.isBlank()) {
+            addHeader(Protocol.AUTHORIZATION_HEADER, Protocol.bearer(secret));
+        }
+    }
+
+    public BackendClient getSession() {
+        return session;
+    }
+
+    @Override
+    public void onOpen(ServerHandshake handshakeData) {
+        session.handleOpen();
+    }
+
+    @Override
+    public void onMessage(String message) {
+        session.handleMessage(message);
+    }
+
+    @Override
+    public void onClose(int code, String reason, boolean remote) {
+        session.handleClose(reason);
+    }
+
+    @Override
+    public void onError(Exception ex) {
+        session.handleError(ex);
+    }
+}
@@ -0,0 +1,89 @@
+package net.trybeacon.plugin.websocket;
+
+import net.trybeacon.plugin.BeaconPlugin;
+import org.java_websocket.WebSocket;
+import org.java_websocket.drafts.Draft;
+import org.java_websocket.exceptions.InvalidDataException;
+import org.java_websocket.framing.CloseFrame;
+import org.java_websocket.handshake.ClientHandshake;
+import org.java_websocket.handshake.ServerHandshakeBuilder;
+import org.java_websocket.server.WebSocketServer;
+
+import java.net.InetSocketAddress;
+import java.util.List;
+
+/**
+ * Transport for reverse-connect mode: the plugin listens and the backend
+ * connects in, for servers the backend can reach but not the other way round.
+ * Only one backend session is kept; a new connection replaces the old one.
+ */
+public class BackendListener extends WebSocketServer {
+
+    private final BeaconPlugin plugin;
+    private volatile BackendClient current;
+
+    public BackendListener(InetSocketAddress address, BeaconPlugin plugin) {
+        super(address, List.of(Protocol.draft(plugin.isBackendCompressionEnabled())));
+        this.plugin = plugin;
+        setReuseAddr(true);
+    }
+
+    public boolean isCurrent(BackendClient session) {
+        return session != null && session == current;
+    }
+
+    @Override
+    public ServerHandshakeBuilder onWebsocketHandshakeReceivedAsServer(WebSocket conn, Draft draft, ClientHandshake request) throws InvalidDataException {
+        ServerHandshakeBuilder response = super.onWebsocketHandshakeReceivedAsServer(conn, draft, request);
+        String secret = plugin.getBackendSecret();
+        if (secret.isBlank() || !Protocol.matchesBearer(request.getFieldValue(Protocol.AUTHORIZATION_HEADER), secret)) {
+            throw new InvalidDataException(CloseFrame.POLICY_VALIDATION, "invalid backend secret");
+        }
+        return response;
+    }
+
+    @Override
+    public void onOpen(WebSocket conn, ClientHandshake handshake) {
+        BackendClient previous = current;
+        BackendClient session = new BackendClient(plugin, conn);
+        conn.setAttachment(session);
+        current = session;
+        if (previous != null && previous.isOpen()) {
+            previous.close();
+        }
+        session.handleOpen();
+    }
+
+    @Override
+    public void onMessage(WebSocket conn, String message) {
+        BackendClient session = conn.getAttachment();
+        if (session != null) {
+            session.handleMessage(message);
+        }
+    }
+
+    @Override
+    public void onClose(WebSocket conn, int code, String reason, boolean remote) {
+        BackendClient session = conn.getAttachment();
+        if (session != null) {
+            session.handleClose(reason);
+        }
+    }
+
+    @Override
+    public void onError(WebSocket conn, Exception ex) {
+        if (conn == null) {
+            plugin.getLogger().severe("⚠️ Backend listener error: " + ex.getMessage());
+            return;
+        }
+        BackendClient session = conn.getAttachment();
+        if (session != null) {
+            session.handleError(ex);
+        }
+    }
+
+    @Override
+    public void onStart() {
+        plugin.getLogger().info("👂 Waiting for the backend to connect on " + getAddress());
+    }
+}
@@ -0,0 +1,62 @@
+package net.trybeacon.plugin.websocket;
+
+import javax.net.ssl.SSLContext;
+import javax.net.ssl.SSLSocketFactory;
+import javax.net.ssl.TrustManager;
+import javax.net.ssl.X509TrustManager;
+import java.security.GeneralSecurityException;
+import java.security.MessageDigest;
+import java.security.cert.CertificateException;
+import java.security.cert.X509Certificate;
+import java.util.HexFormat;
+import java.util.Locale;
+
+/**
+ * Builds a socket factory that trusts exactly one certificate, identified by its
+ * SHA-256 fingerprint. Used for backends serving a self-signed certificate.
+ */
+public final class CertificatePinning {
+
+    private CertificatePinning() {
+    }
+
+    public static SSLSocketFactory socketFactory(String fingerprint) throws GeneralSecurityException {
+        String expected = normalize(fingerprint);
+        X509TrustManager trustManager = new X509TrustManager() {
+            @Override
+            public void checkClientTrusted(X509Certificate[] chain, String authType) throws CertificateException {
+                throw new CertificateException("client certificates are not supported");
+            }
+
+            @Override
+            public void checkServerTrusted(X509Certificate[] chain, String authType) throws CertificateException {
+                if (chain == null || chain.length == 0) {
+                    throw new CertificateException("backend presented no certificate");
+                }
+                String actual;
+                try {
+                    byte[] digest = MessageDigest.getInstance("SHA-256").digest(chain[0].getEncoded());
+                    actual = HexFormat.of().formatHex(digest);
+                } catch (Exception ex) {
+                    throw new CertificateException("could not fingerprint backend certificate", ex);
+                }
+                if (!actual.equals(expected)) {
+                    throw new CertificateException("backend certificate fingerprint mismatch");
+                }
+            }
+
+            @Override
+            public X509Certificate[] getAcceptedIssuers() {
+                return new X509Certificate[0];
+            }
+        };
+
+        SSLContext context = SSLContext.getInstance("TLS");
+        context.init(null, new TrustManager[]{trustManager}, null);
+        return context.getSocketFactory();
+    }
+
+    private static String normalize(String fingerprint) {
+        return fingerprint.replace(":", "").replace(" ", "").toLowerCase(Locale.ROOT);
+    }
+}
@@ -0,0 +1,67 @@
+package net.trybeacon.plugin.websocket;
+
+import com.google.gson.JsonArray;
+import com.google.gson.JsonElement;
+import com.google.gson.JsonObject;
+import com.google.gson.JsonPrimitive;
+import org.msgpack.core.MessageBufferPacker;
+import org.msgpack.core.MessagePack;
+
+import java.io.IOException;
+import java.math.BigDecimal;
+import java.util.Map;
+
+/**
+ * Encodes Gson trees as MessagePack for binary frames, preserving the same
+ * structure the backend would otherwise receive as JSON text.
+ */
+public final class MessagePackEncoder {
+
+    private MessagePackEncoder() {
+    }
+
+    public static byte[] encode(JsonElement element) throws IOException {
+        try (MessageBufferPacker packer = MessagePack.newDefaultBufferPacker()) {
+            pack(packer, element);
+            return packer.toByteArray();
+        }
+    }
+
+    private static void pack(MessageBufferPacker packer, JsonElement element) throws IOException {
+        if (element == null || element.isJsonNull()) {
+            packer.packNil();
+            return;
+        }
+        if (element.isJsonObject()) {
+            JsonObject object = element.getAsJsonObject();
+            packer.packMapHeader(object.size());
+            for (Map.Entry<String, JsonElement> entry : object.entrySet()) {
+                packer.packString(entry.getKey());
+                pack(packer, entry.getValue());
+            }
+            return;
+        }
+        if (element.isJsonArray()) {
+            JsonArray array = element.getAsJsonArray();
+            packer.packArrayHeader(array.size());
+            for (JsonElement item : array) {
+                pack(packer, item);
+            }
+            return;
+        }
+
+        JsonPrimitive primitive = element.getAsJsonPrimitive();
+        if (primitive.isBoolean()) {
+            packer.packBoolean(primitive.getAsBoolean());
+        } else if (primitive.isNumber()) {
+            BigDecimal number = primitive.getAsBigDecimal();
+            if (number.stripTrailingZeros().scale() <= 0) {
+                packer.packLong(number.longValueExact());
+            } else {
+                packer.packDouble(number.doubleValue());
+            }
+        } else {
+            packer.packString(primitive.getAsString());
+        }
+    }
+}
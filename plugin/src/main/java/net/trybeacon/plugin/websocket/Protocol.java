@@ -0,0 +1,77 @@
+package net.trybeacon.plugin.websocket;
+
+import com.google.gson.JsonArray;
+import org.java_websocket.drafts.Draft_6455;
+import org.java_websocket.extensions.permessage_deflate.PerMessageDeflateExtension;
+
+import java.nio.charset.StandardCharsets;
+import java.security.MessageDigest;
+import java.util.List;
+import java.util.Set;
+
+/**
+ * Wire protocol constants shared with the backend. Bump {@link #VERSION} when
+ * an event changes shape, and add a capability when the plugin learns a new request.
+ */
+public final class Protocol {
+
+    public static final int VERSION = 1;
+
+    public static final String ENCODING_MSGPACK = "msgpack";
+
+    public static final String AUTHORIZATION_HEADER = "Authorization";
+
+    /** Events sent often enough to be worth MessagePack once the backend agrees. */
+    public static final Set<String> BINARY_EVENTS = Set.of("console_log", "server_stats", "world_stats");
+
+    public static final List<String> CAPABILITIES = List.of(
+            "file_manager",
+            "permissions",
+            "permission_admin",
+            "console",
+            "tab_complete",
+            "world_actions",
+            "rpc_cancel",
+            "moderation",
+            "roster",
+            "world_management",
+            "world_settings",
+            "gamerules",
+            "hotspots",
+            "diagnostics",
+            "plugins",
+            "profiler"
+    );
+
+    private Protocol() {
+    }
+
+    public static Draft_6455 draft(boolean compression) {
+        return compression ? new Draft_6455(new PerMessageDeflateExtension()) : new Draft_6455();
+    }
+
+    public static String bearer(String secret) {
+        return "Bearer " + secret;
+    }
+
+    /**
+     * Checks an Authorization header against the shared secret in constant time.
+     */
+    public static boolean matchesBearer(String header, String secret) {
+        if (header == null) {
+            return false;
+        }
+        return MessageDigest.isEqual(
+                header.trim().getBytes(StandardCharsets.UTF_8),
+                bearer(secret).getBytes(StandardCharsets.UTF_8)
+        );
+    }
+
+    public static JsonArray capabilitiesJson() {
+        JsonArray array = new JsonArray();
+        for (String capability : CAPABILITIES) {
+            array.add(capability);
+        }
+        return array;
+    }
+}
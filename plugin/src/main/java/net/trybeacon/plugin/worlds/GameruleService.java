@@ -0,0 +1,92 @@
+package net.trybeacon.plugin.worlds;
+
+import com.google.gson.JsonArray;
+import com.google.gson.JsonElement;
+import com.google.gson.JsonObject;
+import org.bukkit.Bukkit;
+import org.bukkit.World;
+
+import java.util.ArrayList;
+import java.util.List;
+import java.util.Map;
+
+/**
+ * Applies many gamerules across many worlds at once, reporting each rule's
+ * outcome. With rollback, one failure restores every rule already changed so
+ * worlds are never left half-configured.
+ */
+public class GameruleService {
+
+    private record Change(World world, String rule, String previous, JsonObject result) {
+    }
+
+    /**
+     * Must be called on the main thread.
+     */
+    public JsonObject performAction(JsonObject payload) {
+        String action = payload.has("action") ? payload.get("action").getAsString() : "";
+        if (!action.equals("apply")) {
+            throw new IllegalArgumentException("unsupported gamerules action");
+        }
+        boolean rollback = payload.has("rollback") && payload.get("rollback").getAsBoolean();
+        JsonObject rules = payload.has("rules") ? payload.getAsJsonObject("rules") : new JsonObject();
+        JsonArray worldNames = payload.has("worlds") ? payload.getAsJsonArray("worlds") : new JsonArray();
+
+        JsonArray results = new JsonArray();
+        List<Change> applied = new ArrayList<>();
+        boolean allOk = true;
+        for (JsonElement element : worldNames) {
+            String worldName = element.getAsString();
+            World world = Bukkit.getWorld(worldName);
+            for (Map.Entry<String, JsonElement> entry : rules.entrySet()) {
+                String rule = entry.getKey();
+                String value = entry.getValue().getAsString();
+
+                JsonObject result = new JsonObject();
+                result.addProperty("world", worldName);
+                result.addProperty("rule", rule);
+                result.addProperty("value", value);
+                results.add(result);
+
+                String error = null;
+                String previous = null;
+                if (world == null) {
+                    error = "world is not loaded";
+                } else if (!world.isGameRule(rule)) {
+                    error = "unknown gamerule";
+                } else {
+                    previous = world.getGameRuleValue(rule);
+                    if (!world.setGameRuleValue(rule, value)) {
+                        error = "invalid value";
+                    }
+                }
+                result.addProperty("previous", previous == null ? "" : previous);
+                result.addProperty("ok", error == null);
+                if (error != null) {
+                    result.addProperty("error", error);
+                    allOk = false;
+                } else {
+                    applied.add(new Change(world, rule, previous, result));
+                }
+            }
+        }
+
+        boolean rolledBack = false;
+        if (!allOk && rollback) {
+            for (int i = applied.size() - 1; i >= 0; i--) {
+                Change change = applied.get(i);
+                if (change.previous() != null) {
+                    change.world().setGameRuleValue(change.rule(), change.previous());
+                }
+                change.result().addProperty("rolled_back", true);
+            }
+            rolledBack = !applied.isEmpty();
+        }
+
+        JsonObject data = new JsonObject();
+        data.addProperty("ok", allOk);
+        data.addProperty("rolled_back", rolledBack);
+        data.add("results", results);
+        return data;
+    }
+}
@@ -0,0 +1,95 @@
+package net.trybeacon.plugin.worlds;
+
+import com.google.gson.JsonArray;
+import com.google.gson.JsonObject;
+import org.bukkit.Bukkit;
+import org.bukkit.Chunk;
+import org.bukkit.World;
+import org.bukkit.block.BlockState;
+import org.bukkit.entity.Entity;
+
+import java.util.ArrayList;
+import java.util.Comparator;
+import java.util.List;
+import java.util.Locale;
+import java.util.Map;
+import java.util.TreeMap;
+
+/**
+ * Counts entities and tile entities in every loaded chunk of a world and
+ * reports the busiest ones with a breakdown by type.
+ */
+public class HotspotService {
+
+    private static final int MAX_TOP = 100;
+
+    private record ChunkLoad(int x, int z, Map<String, Integer> entities, Map<String, Integer> tiles,
+                             int entityCount, int tileCount) {
+        int load() {
+            return entityCount + tileCount;
+        }
+    }
+
+    /**
+     * Must be called on the main thread.
+     */
+    public JsonObject scan(JsonObject payload) {
+        String worldName = payload.has("world") ? payload.get("world").getAsString() : "";
+        int top = payload.has("top") ? payload.get("top").getAsInt() : 20;
+        top = Math.max(1, Math.min(MAX_TOP, top));
+
+        World world = Bukkit.getWorld(worldName);
+        if (world == null) {
+            throw new IllegalArgumentException("world is not loaded");
+        }
+
+        Chunk[] chunks = world.getLoadedChunks();
+        List<ChunkLoad> loads = new ArrayList<>(chunks.length);
+        int totalEntities = 0;
+        int totalTiles = 0;
+        for (Chunk chunk : chunks) {
+            Map<String, Integer> entities = new TreeMap<>();
+            for (Entity entity : chunk.getEntities()) {
+                entities.merge(entity.getType().name().toLowerCase(Locale.ROOT), 1, Integer::sum);
+            }
+            Map<String, Integer> tiles = new TreeMap<>();
+            for (BlockState state : chunk.getTileEntities(false)) {
+                tiles.merge(state.getType().name().toLowerCase(Locale.ROOT), 1, Integer::sum);
+            }
+            int entityCount = entities.values().stream().mapToInt(Integer::intValue).sum();
+            int tileCount = tiles.values().stream().mapToInt(Integer::intValue).sum();
+            totalEntities += entityCount;
+            totalTiles += tileCount;
+            if (entityCount + tileCount > 0) {
+                loads.add(new ChunkLoad(chunk.getX(), chunk.getZ(), entities, tiles, entityCount, tileCount));
+            }
+        }
+        loads.sort(Comparator.comparingInt(ChunkLoad::load).reversed());
+
+        JsonArray hotspots = new JsonArray();
+        for (ChunkLoad load : loads.subList(0, Math.min(top, loads.size()))) {
+            JsonObject chunk = new JsonObject();
+            chunk.addProperty("x", load.x());
+            chunk.addProperty("z", load.z());
+            chunk.addProperty("entities", load.entityCount());
+            chunk.addProperty("tile_entities", load.tileCount());
+            chunk.add("entity_types", counts(load.entities()));
+            chunk.add("tile_entity_types", counts(load.tiles()));
+            hotspots.add(chunk);
+        }
+
+        JsonObject data = new JsonObject();
+        data.addProperty("world", world.getName());
+        data.addProperty("loaded_chunks", chunks.length);
+        data.addProperty("entities", totalEntities);
+        data.addProperty("tile_entities", totalTiles);
+        data.add("hotspots", hotspots);
+        return data;
+    }
+
+    private static JsonObject counts(Map<String, Integer> counts) {
+        JsonObject out = new JsonObject();
+        counts.forEach(out::addProperty);
+        return out;
+    }
+}
@@ -0,0 +1,451 @@
+package net.trybeacon.plugin.worlds;
+
+import com.google.gson.JsonObject;
+import net.trybeacon.plugin.BeaconPlugin;
+import net.trybeacon.plugin.websocket.BackendClient;
+import org.bukkit.Bukkit;
+import org.bukkit.World;
+import org.bukkit.WorldCreator;
+import org.bukkit.WorldType;
+import org.bukkit.entity.Player;
+
+import java.io.File;
+import java.io.FileOutputStream;
+import java.io.IOException;
+import java.io.InputStream;
+import java.io.OutputStream;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.nio.file.StandardCopyOption;
+import java.util.ArrayList;
+import java.util.Base64;
+import java.util.Comparator;
+import java.util.Enumeration;
+import java.util.List;
+import java.util.Locale;
+import java.util.Set;
+import java.util.concurrent.ConcurrentHashMap;
+import java.util.regex.Pattern;
+import java.util.stream.Stream;
+import java.util.zip.ZipEntry;
+import java.util.zip.ZipFile;
+
+/**
+ * Creates, imports, clones and deletes worlds for the panel. Requests are
+ * answered as soon as they are accepted; the slow part runs in the background
+ * and reports through world_progress events until one arrives with done set.
+ */
+public class WorldManagementService {
+
+    private static final Pattern WORLD_NAME = Pattern.compile("[A-Za-z0-9_-]{1,64}");
+    private static final Pattern OPERATION_ID = Pattern.compile("[a-f0-9]{1,64}");
+    // Per-world files that must not travel with a copy.
+    private static final Set<String> SKIPPED_FILES = Set.of("session.lock", "uid.dat");
+
+    private final BeaconPlugin plugin;
+    private final File importsFolder;
+    private final Set<String> busyWorlds = ConcurrentHashMap.newKeySet();
+
+    public WorldManagementService(BeaconPlugin plugin) {
+        this.plugin = plugin;
+        this.importsFolder = new File(plugin.getDataFolder(), "world-imports");
+    }
+
+    /**
+     * Must be called on the main thread.
+     */
+    public JsonObject performAction(JsonObject payload) throws IOException {
+        String action = string(payload, "action");
+        String operationId = string(payload, "operation_id");
+        String worldName = string(payload, "world");
+        if (!OPERATION_ID.matcher(operationId).matches()) {
+            throw new IllegalArgumentException("invalid operation id");
+        }
+        if (!WORLD_NAME.matcher(worldName).matches()) {
+            throw new IllegalArgumentException("invalid world name");
+        }
+
+        switch (action) {
+            case "create" -> create(operationId, worldName, payload);
+            case "clone" -> cloneWorld(operationId, worldName, string(payload, "source"));
+            case "delete" -> delete(operationId, worldName);
+            case "import_chunk" -> appendChunk(operationId, payload);
+            case "import" -> importArchive(operationId, worldName, payload);
+            default -> throw new IllegalArgumentException("unsupported world action");
+        }
+
+        JsonObject data = new JsonObject();
+        data.addProperty("operation_id", operationId);
+        return data;
+    }
+
+    private void create(String operationId, String worldName, JsonObject payload) {
+        requireNewWorld(worldName);
+        WorldCreator creator = new WorldCreator(worldName);
+        creator.environment(environment(string(payload, "environment")));
+        String type = string(payload, "type");
+        if (!type.isEmpty()) {
+            creator.type(WorldType.valueOf(type.toUpperCase(Locale.ROOT)));
+        }
+        String seed = string(payload, "seed");
+        if (!seed.isEmpty()) {
+            creator.seed(parseSeed(seed));
+        }
+        String generator = string(payload, "generator");
+        if (!generator.isEmpty()) {
+            creator.generator(generator);
+        }
+        if (payload.has("structures")) {
+            creator.generateStructures(payload.get("structures").getAsBoolean());
+        }
+
+        claim(worldName);
+        progress(operationId, "create", worldName, "generating", 0.1);
+        // Generate on the next tick so the acceptance reaches the backend first.
+        Bukkit.getScheduler().runTask(plugin, () -> load(operationId, "create", worldName, creator));
+    }
+
+    private void cloneWorld(String operationId, String worldName, String sourceName) {
+        if (!WORLD_NAME.matcher(sourceName).matches()) {
+            throw new IllegalArgumentException("invalid source world name");
+        }
+        requireNewWorld(worldName);
+        World source = Bukkit.getWorld(sourceName);
+        File sourceFolder = source != null ? source.getWorldFolder() : new File(Bukkit.getWorldContainer(), sourceName);
+        if (!new File(sourceFolder, "level.dat").isFile()) {
+            throw new IllegalArgumentException("world " + sourceName + " does not exist");
+        }
+        if (busyWorlds.contains(sourceName.toLowerCase(Locale.ROOT))) {
+            throw new IllegalArgumentException(sourceName + " is busy with another operation");
+        }
+        World.Environment environment = source != null ? source.getEnvironment() : guessEnvironment(sourceName);
+        if (source != null) {
+            source.save();
+        }
+
+        claim(worldName);
+        File target = new File(Bukkit.getWorldContainer(), worldName);
+        Bukkit.getScheduler().runTaskAsynchronously(plugin, () -> {
+            try {
+                copyFolder(operationId, worldName, sourceFolder.toPath(), target.toPath());
+            } catch (IOException ex) {
+                deleteQuietly(target.toPath());
+                fail(operationId, "clone", worldName, "copy failed: " + ex.getMessage());
+                return;
+            }
+            Bukkit.getScheduler().runTask(plugin, () ->
+                    load(operationId, "clone", worldName, new WorldCreator(worldName).environment(environment)));
+        });
+    }
+
+    private void delete(String operationId, String worldName) {
+        World world = Bukkit.getWorld(worldName);
+        File folder;
+        if (world != null) {
+            if (world.equals(Bukkit.getWorlds().get(0))) {
+                throw new IllegalArgumentException("the primary world cannot be deleted while the server is running");
+            }
+            folder = world.getWorldFolder();
+        } else {
+            folder = new File(Bukkit.getWorldContainer(), worldName);
+        }
+        if (!new File(folder, "level.dat").isFile()) {
+            throw new IllegalArgumentException("world " + worldName + " does not exist");
+        }
+        claim(worldName);
+        if (world != null) {
+            evacuate(world);
+            if (!Bukkit.unloadWorld(world, false)) {
+                release(worldName);
+                throw new IllegalStateException("could not unload " + worldName);
+            }
+        }
+
+        progress(operationId, "delete", worldName, "deleting", 0);
+        Bukkit.getScheduler().runTaskAsynchronously(plugin, () -> {
+            try {
+                deleteFolder(operationId, worldName, folder.toPath());
+                finish(operationId, "delete", worldName, null);
+            } catch (IOException ex) {
+                fail(operationId, "delete", worldName, "delete failed: " + ex.getMessage());
+            }
+        });
+    }
+
+    /**
+     * Appends one base64 chunk of an uploaded archive. Chunks must arrive in
+     * order; a repeated chunk is ignored so a retried request is harmless.
+     */
+    private void appendChunk(String operationId, JsonObject payload) throws IOException {
+        long offset = payload.has("offset") ? payload.get("offset").getAsLong() : 0L;
+        byte[] data = Base64.getDecoder().decode(string(payload, "data"));
+        File archive = importArchiveFile(operationId);
+        if (offset == 0) {
+            Files.createDirectories(importsFolder.toPath());
+            Files.deleteIfExists(archive.toPath());
+        }
+        long length = archive.length();
+        if (offset + data.length == length) {
+            return;
+        }
+        if (offset != length) {
+            throw new IllegalArgumentException("expected chunk at offset " + length);
+        }
+        try (OutputStream out = new FileOutputStream(archive, true)) {
+            out.write(data);
+        }
+    }
+
+    private void importArchive(String operationId, String worldName, JsonObject payload) {
+        File archive = importArchiveFile(operationId);
+        if (!archive.isFile()) {
+            throw new IllegalArgumentException("no archive was uploaded for this operation");
+        }
+        requireNewWorld(worldName);
+        String root = string(payload, "root");
+        World.Environment environment = environment(string(payload, "environment"));
+
+        claim(worldName);
+        File target = new File(Bukkit.getWorldContainer(), worldName);
+        progress(operationId, "import", worldName, "extracting", 0.5);
+        Bukkit.getScheduler().runTaskAsynchronously(plugin, () -> {
+            try {
+                extract(operationId, worldName, archive, root, target.toPath());
+            } catch (IOException | IllegalArgumentException ex) {
+                deleteQuietly(target.toPath());
+                fail(operationId, "import", worldName, "extract failed: " + ex.getMessage());
+                return;
+            } finally {
+                archive.delete();
+            }
+            Bukkit.getScheduler().runTask(plugin, () ->
+                    load(operationId, "import", worldName, new WorldCreator(worldName).environment(environment)));
+        });
+    }
+
+    private void load(String operationId, String action, String worldName, WorldCreator creator) {
+        progress(operationId, action, worldName, "loading", 0.95);
+        try {
+            if (Bukkit.createWorld(creator) == null) {
+                fail(operationId, action, worldName, "the server could not load the world");
+                return;
+            }
+        } catch (RuntimeException ex) {
+            fail(operationId, action, worldName, ex.getMessage() == null ? "world load failed" : ex.getMessage());
+            return;
+        }
+        plugin.getLogger().info("✅ World '" + worldName + "' is ready (" + action + ").");
+        finish(operationId, action, worldName, null);
+    }
+
+    private void copyFolder(String operationId, String worldName, Path source, Path target) throws IOException {
+        List<Path> files = listFiles(source);
+        Progress progress = new Progress(operationId, "clone", worldName, "copying", files.size(), 0, 0.9);
+        for (Path file : files) {
+            Path relative = source.relativize(file);
+            if (!SKIPPED_FILES.contains(relative.toString())) {
+                Path destination = target.resolve(relative);
+                Files.createDirectories(destination.getParent());
+                Files.copy(file, destination, StandardCopyOption.COPY_ATTRIBUTES);
+            }
+            progress.step();
+        }
+    }
+
+    private void deleteFolder(String operationId, String worldName, Path folder) throws IOException {
+        List<Path> files = listFiles(folder);
+        Progress progress = new Progress(operationId, "delete", worldName, "deleting", files.size(), 0, 0.95);
+        for (Path file : files) {
+            Files.deleteIfExists(file);
+            progress.step();
+        }
+        deleteQuietly(folder);
+        if (Files.exists(folder)) {
+            throw new IOException("some files could not be removed");
+        }
+    }
+
+    private void extract(String operationId, String worldName, File archive, String root, Path target) throws IOException {
+        String prefix = root.isEmpty() ? "" : root + "/";
+        Path normalizedTarget = target.toAbsolutePath().normalize();
+        try (ZipFile zip = new ZipFile(archive)) {
+            Progress progress = new Progress(operationId, "import", worldName, "extracting", zip.size(), 0.5, 0.9);
+            Enumeration<? extends ZipEntry> entries = zip.entries();
+            while (entries.hasMoreElements()) {
+                ZipEntry entry = entries.nextElement();
+                progress.step();
+                String name = entry.getName().replace('\\', '/');
+                if (!name.startsWith(prefix)) {
+                    continue;
+                }
+                name = name.substring(prefix.length());
+                if (name.isEmpty() || SKIPPED_FILES.contains(name)) {
+                    continue;
+                }
+                Path destination = normalizedTarget.resolve(name).normalize();
+                if (!destination.startsWith(normalizedTarget)) {
+                    throw new IllegalArgumentException("entry " + entry.getName() + " escapes the world folder");
+                }
+                if (entry.isDirectory()) {
+                    Files.createDirectories(destination);
+                    continue;
+                }
+                Files.createDirectories(destination.getParent());
+                try (InputStream in = zip.getInputStream(entry)) {
+                    Files.copy(in, destination);
+                }
+            }
+        }
+        if (!Files.isRegularFile(target.resolve("level.dat"))) {
+            throw new IllegalArgumentException("archive has no level.dat");
+        }
+    }
+
+    private void requireNewWorld(String worldName) {
+        if (Bukkit.getWorld(worldName) != null || new File(Bukkit.getWorldContainer(), worldName).exists()) {
+            throw new IllegalArgumentException("a world named " + worldName + " already exists");
+        }
+        if (busyWorlds.contains(worldName.toLowerCase(Locale.ROOT))) {
+            throw new IllegalArgumentException(worldName + " is busy with another operation");
+        }
+    }
+
+    private void claim(String worldName) {
+        if (!busyWorlds.add(worldName.toLowerCase(Locale.ROOT))) {
+            throw new IllegalArgumentException(worldName + " is busy with another operation");
+        }
+    }
+
+    private void release(String worldName) {
+        busyWorlds.remove(worldName.toLowerCase(Locale.ROOT));
+    }
+
+    private File importArchiveFile(String operationId) {
+        return new File(importsFolder, operationId + ".zip");
+    }
+
+    private void evacuate(World world) {
+        World mainWorld = Bukkit.getWorlds().get(0);
+        for (Player player : world.getPlayers()) {
+            player.teleport(mainWorld.getSpawnLocation());
+        }
+    }
+
+    private void fail(String operationId, String action, String worldName, String error) {
+        plugin.getLogger().warning("❌ World " + action + " of '" + worldName + "' failed: " + error);
+        finish(operationId, action, worldName, error);
+    }
+
+    private void finish(String operationId, String action, String worldName, String error) {
+        release(worldName);
+        JsonObject payload = progressPayload(operationId, action, worldName, error == null ? "done" : "failed", error == null ? 1 : 0);
+        payload.addProperty("done", true);
+        if (error != null) {
+            payload.addProperty("error", error);
+        }
+        send(payload);
+    }
+
+    private void progress(String operationId, String action, String worldName, String stage, double progress) {
+        send(progressPayload(operationId, action, worldName, stage, progress));
+    }
+
+    private JsonObject progressPayload(String operationId, String action, String worldName, String stage, double progress) {
+        JsonObject payload = new JsonObject();
+        payload.addProperty("operation_id", operationId);
+        payload.addProperty("world", worldName);
+        payload.addProperty("action", action);
+        payload.addProperty("stage", stage);
+        payload.addProperty("progress", Math.max(0, Math.min(1, progress)));
+        return payload;
+    }
+
+    private void send(JsonObject payload) {
+        BackendClient client = plugin.getBackendClient();
+        if (client != null) {
+            client.sendEvent("world_progress", payload);
+        }
+    }
+
+    private static List<Path> listFiles(Path folder) throws IOException {
+        List<Path> files = new ArrayList<>();
+        try (Stream<Path> walk = Files.walk(folder)) {
+            walk.filter(Files::isRegularFile).forEach(files::add);
+        }
+        return files;
+    }
+
+    private static void deleteQuietly(Path folder) {
+        if (!Files.exists(folder)) {
+            return;
+        }
+        try (Stream<Path> walk = Files.walk(folder)) {
+            walk.sorted(Comparator.reverseOrder()).forEach(path -> path.toFile().delete());
+        } catch (IOException ignored) {
+        }
+    }
+
+    private static World.Environment environment(String raw) {
+        return switch (raw) {
+            case "", "normal" -> World.Environment.NORMAL;
+            case "nether" -> World.Environment.NETHER;
+            case "the_end" -> World.Environment.THE_END;
+            default -> throw new IllegalArgumentException("unknown environment " + raw);
+        };
+    }
+
+    private static World.Environment guessEnvironment(String worldName) {
+        if (worldName.endsWith("_nether")) {
+            return World.Environment.NETHER;
+        }
+        if (worldName.endsWith("_the_end")) {
+            return World.Environment.THE_END;
+        }
+        return World.Environment.NORMAL;
+    }
+
+    /** Numeric seeds are used as-is; anything else is hashed like the vanilla create screen does. */
+    private static long parseSeed(String seed) {
+        try {
+            return Long.parseLong(seed);
+        } catch (NumberFormatException ex) {
+            return seed.hashCode();
+        }
+    }
+
+    private static String string(JsonObject payload, String key) {
+        return payload.has(key) && !payload.get(key).isJsonNull() ? payload.get(key).getAsString().trim() : "";
+    }
+
+    /** Reports progress through a stage, at most once per percent. */
+    private class Progress {
+        private final String operationId;
+        private final String action;
+        private final String worldName;
+        private final String stage;
+        private final int total;
+        private final double from;
+        private final double to;
+        private int done;
+        private int lastPercent = -1;
+
+        Progress(String operationId, String action, String worldName, String stage, int total, double from, double to) {
+            this.operationId = operationId;
+            this.action = action;
+            this.worldName = worldName;
+            this.stage = stage;
+            this.total = Math.max(1, total);
+            this.from = from;
+            this.to = to;
+        }
+
+        void step() {
+            done++;
+            int percent = done * 100 / total;
+            if (percent != lastPercent) {
+                lastPercent = percent;
+                progress(operationId, action, worldName, stage, from + (to - from) * done / total);
+            }
+        }
+    }
+}